@@ -0,0 +1,208 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+
+// Conditions and condition Reasons for the MachinePool object
+
+const (
+	// ProviderIDsValidCondition reports whether all the entries in Spec.ProviderIDList match the
+	// Spec.ProviderIDPrefix expected by the MachinePool.
+	ProviderIDsValidCondition clusterv1.ConditionType = "ProviderIDsValid"
+
+	// UnexpectedProviderIDReason (Severity=Warning) documents a MachinePool reporting provider IDs that don't
+	// match the expected prefix, e.g. because the infrastructure was created in the wrong region or account.
+	UnexpectedProviderIDReason = "UnexpectedProviderID"
+)
+
+const (
+	// ProviderIDsAllowedCondition reports whether all the entries in Spec.ProviderIDList match one of the provider
+	// ID prefixes the MachinePool controller is configured to allow. It is only set when such prefixes are configured.
+	ProviderIDsAllowedCondition clusterv1.ConditionType = "ProviderIDsAllowed"
+
+	// ProviderIDNotAllowedReason (Severity=Error) documents a MachinePool reporting provider IDs outside the allowed
+	// prefixes, e.g. instances of another cloud account injected by its infrastructure object.
+	ProviderIDNotAllowedReason = "ProviderIDNotAllowed"
+)
+
+const (
+	// MachinePoolRemoteConnectedCondition reports whether the MachinePool controller can connect to the workload
+	// cluster the MachinePool belongs to, which is required to reconcile the MachinePool's Nodes.
+	MachinePoolRemoteConnectedCondition clusterv1.ConditionType = "RemoteConnected"
+
+	// KubeconfigMissingReason (Severity=Warning) documents a MachinePool controller which can't find the kubeconfig
+	// secret of the workload cluster.
+	KubeconfigMissingReason = "KubeconfigMissing"
+
+	// ConnectionRefusedReason (Severity=Warning) documents a MachinePool controller whose connections to the
+	// workload cluster API server are refused.
+	ConnectionRefusedReason = "ConnectionRefused"
+
+	// UnauthorizedReason (Severity=Warning) documents a MachinePool controller whose credentials are rejected by
+	// the workload cluster API server.
+	UnauthorizedReason = "Unauthorized"
+
+	// RemoteConnectionFailedReason (Severity=Warning) documents a MachinePool controller failing to connect to the
+	// workload cluster for any other reason.
+	RemoteConnectionFailedReason = "RemoteConnectionFailed"
+)
+
+const (
+	// NodesHealthyCondition reports whether all the Nodes of a MachinePool became Ready within Spec.NodeStartupTimeout.
+	// Nodes which didn't are reported with the UnhealthyNode reason and counted in Status.UnhealthyReplicas.
+	NodesHealthyCondition clusterv1.ConditionType = "NodesHealthy"
+)
+
+const (
+	// ControlPlaneInitializedCondition reports whether the control plane of the cluster the MachinePool belongs
+	// to is initialized. The MachinePool isn't bootstrapped before, unless it has the
+	// SkipWaitForControlPlaneInitializedAnnotation.
+	ControlPlaneInitializedCondition clusterv1.ConditionType = "ControlPlaneInitialized"
+
+	// WaitingForControlPlaneInitializedReason (Severity=Info) documents a MachinePool waiting for the control plane
+	// of its cluster to be initialized before being bootstrapped.
+	WaitingForControlPlaneInitializedReason = "WaitingForControlPlaneInitialized"
+)
+
+const (
+	// BootstrapDataSecretValidCondition reports whether the bootstrap data secret of a MachinePool holds a non-empty
+	// value. It is only set when the MachinePool controller validates bootstrap data secrets.
+	BootstrapDataSecretValidCondition clusterv1.ConditionType = "BootstrapDataSecretValid"
+
+	// BootstrapDataSecretNotFoundReason (Severity=Warning) documents a MachinePool whose bootstrap data secret
+	// doesn't exist.
+	BootstrapDataSecretNotFoundReason = "BootstrapDataSecretNotFound"
+
+	// InvalidBootstrapDataSecretReason (Severity=Warning) documents a MachinePool whose bootstrap data secret
+	// has no value or an empty one.
+	InvalidBootstrapDataSecretReason = "InvalidBootstrapDataSecret"
+)
+
+const (
+	// ReplicasDefaultedCondition (informational) reports that Spec.Replicas wasn't set by the user and was defaulted,
+	// as recorded by the ReplicasDefaultedAnnotation. It is removed once the replicas are set explicitly.
+	ReplicasDefaultedCondition clusterv1.ConditionType = "ReplicasDefaulted"
+)
+
+const (
+	// ProvisioningApprovedCondition reports whether the pre-provision webhook approved provisioning the infrastructure
+	// of a MachinePool. It is only set when the MachinePool controller is configured with a pre-provision webhook.
+	ProvisioningApprovedCondition clusterv1.ConditionType = "ProvisioningApproved"
+
+	// WaitingForApprovalReason (Severity=Info) documents a MachinePool waiting for the pre-provision webhook to
+	// approve provisioning its infrastructure.
+	WaitingForApprovalReason = "WaitingForApproval"
+)
+
+const (
+	// SpecDriftCondition (informational) reports that spec.replicas of the infrastructure object of a MachinePool
+	// diverged from Spec.Replicas, e.g. because the infrastructure object was edited directly. It is removed once
+	// both agree again.
+	SpecDriftCondition clusterv1.ConditionType = "SpecDrift"
+
+	// ReplicasDriftedReason (Severity=Warning) documents a MachinePool whose infrastructure object has a different
+	// number of replicas than the MachinePool.
+	ReplicasDriftedReason = "ReplicasDrifted"
+)
+
+const (
+	// ReconcileSkippedCondition (informational) reports that reconciliation of a MachinePool is skipped because of
+	// its SkipReconcileAnnotation, whose value is reported as the message. It is removed once the annotation is.
+	ReconcileSkippedCondition clusterv1.ConditionType = "ReconcileSkipped"
+
+	// SkipReconcileRequestedReason (Severity=Info) documents a MachinePool annotated to skip its reconciliation.
+	SkipReconcileRequestedReason = "SkipReconcileRequested"
+)
+
+const (
+	// PendingCondition (informational) reports why a MachinePool is in the Pending phase, before its bootstrap data
+	// is generated. It is removed once the MachinePool leaves the Pending phase.
+	PendingCondition clusterv1.ConditionType = "Pending"
+
+	// ReconciliationPausedReason (Severity=Info) documents a Pending MachinePool whose reconciliation, or the one of
+	// its Cluster, is paused.
+	ReconciliationPausedReason = "ReconciliationPaused"
+
+	// WaitingForClusterReason (Severity=Info) documents a Pending MachinePool waiting for the infrastructure of its
+	// Cluster to be ready, or for its control plane to be initialized.
+	WaitingForClusterReason = "WaitingForCluster"
+
+	// BootstrapNotStartedReason (Severity=Info) documents a Pending MachinePool whose bootstrap data isn't generated
+	// yet, or which has neither a bootstrap configuration nor a bootstrap data secret.
+	BootstrapNotStartedReason = "BootstrapNotStarted"
+)
+
+const (
+	// OverProvisionedCondition (informational) reports that the infrastructure provider of a MachinePool reports
+	// more instances than Spec.Replicas, beyond the margin the controller is configured with, e.g. because of runaway
+	// provisioning. The instances aren't scaled down by the controller. It is removed once the excess is gone.
+	OverProvisionedCondition clusterv1.ConditionType = "OverProvisioned"
+
+	// TooManyInstancesReason (Severity=Warning) documents a MachinePool whose infrastructure provider reports more
+	// instances than desired.
+	TooManyInstancesReason = "TooManyInstances"
+)
+
+const (
+	// DeletionBlockedCondition (informational) reports that an external object of a MachinePool being deleted
+	// couldn't be deleted, e.g. because of a transient API error. It is removed once the deletion succeeds.
+	DeletionBlockedCondition clusterv1.ConditionType = "DeletionBlocked"
+
+	// DeleteFailedReason (Severity=Warning) documents a MachinePool whose deletion is blocked by an external object
+	// failing to be deleted.
+	DeleteFailedReason = "DeleteFailed"
+
+	// DeleteRetriesExhaustedReason (Severity=Error) documents a MachinePool whose deletion is blocked by an external
+	// object which failed to be deleted too many times, the deletion isn't retried anymore.
+	DeleteRetriesExhaustedReason = "DeleteRetriesExhausted"
+)
+
+const (
+	// InfrastructureCredentialsAvailableCondition reports whether the credentials secret referenced by the
+	// infrastructure object of a MachinePool exists. It is only set when the MachinePool controller is configured
+	// with the path of the field referencing it.
+	InfrastructureCredentialsAvailableCondition clusterv1.ConditionType = "InfrastructureCredentialsAvailable"
+
+	// MissingCredentialsReason (Severity=Error) documents a MachinePool whose infrastructure object references a
+	// credentials secret which doesn't exist.
+	MissingCredentialsReason = "MissingCredentials"
+)
+
+const (
+	// DependenciesReadyCondition reports whether the MachinePools a MachinePool depends on are Running, so that its
+	// infrastructure can be provisioned. It is only set on MachinePools with dependencies.
+	DependenciesReadyCondition clusterv1.ConditionType = "DependenciesReady"
+
+	// WaitingForDependencyReason (Severity=Info) documents a MachinePool waiting for a MachinePool it depends on to
+	// be Running before provisioning its infrastructure.
+	WaitingForDependencyReason = "WaitingForDependency"
+
+	// DependencyCycleReason (Severity=Error) documents a MachinePool which depends, directly or not, on itself, and
+	// whose infrastructure is never provisioned.
+	DependencyCycleReason = "DependencyCycle"
+)
+
+const (
+	// NodeVersionsUpToDateCondition reports whether all the Nodes of a MachinePool run the Kubernetes version of
+	// Spec.Template.Spec.Version. It is only set on MachinePools with a version.
+	NodeVersionsUpToDateCondition clusterv1.ConditionType = "NodeVersionsUpToDate"
+
+	// NodeVersionsLaggingReason (Severity=Info) documents a MachinePool with Nodes running another Kubernetes version
+	// than the desired one, e.g. while an upgrade is rolled out.
+	NodeVersionsLaggingReason = "NodeVersionsLagging"
+)
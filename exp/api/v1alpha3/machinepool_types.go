@@ -26,6 +26,190 @@ import (
 const (
 	// MachinePoolFinalizer is used to ensure deletion of dependencies (nodes, infra).
 	MachinePoolFinalizer = "machinepool.exp.cluster.x-k8s.io"
+
+	// MachinePoolNameLabel is the label set on objects to identify the MachinePool they belong to.
+	MachinePoolNameLabel = "exp.cluster.x-k8s.io/machine-pool-name"
+
+	// TopologyOwnedLabel is set on MachinePools managed by the topology controller of a ClusterClass.
+	TopologyOwnedLabel = "topology.cluster.x-k8s.io/owned"
+
+	// InfrastructureMissingSinceAnnotation is set on a MachinePool by the controller to record, in RFC3339 format,
+	// when its infrastructure object was first found missing after having been ready.
+	InfrastructureMissingSinceAnnotation = "exp.cluster.x-k8s.io/infrastructure-missing-since"
+
+	// TraceParentAnnotation is set by the MachinePool controller on the bootstrap and infrastructure objects
+	// when tracing is enabled. It carries, in W3C traceparent format, the trace of the reconciliation that
+	// last changed the MachinePool spec, so that provider controllers can continue the trace.
+	TraceParentAnnotation = "exp.cluster.x-k8s.io/traceparent"
+
+	// ProviderIDListSyncedHashAnnotation is set by the MachinePool controller on the infrastructure object of
+	// a MachinePool using the MachinePool provider ID list source. It records a hash of the provider ID list
+	// last written to the infrastructure object.
+	ProviderIDListSyncedHashAnnotation = "exp.cluster.x-k8s.io/provider-id-list-synced-hash"
+
+	// ManagedTaintsAnnotation is set by the MachinePool controller on the Nodes of a MachinePool to record the
+	// taints, in key:effect format, added from Spec.Taints. Only these taints are removed from the Nodes once
+	// dropped from Spec.Taints.
+	ManagedTaintsAnnotation = "exp.cluster.x-k8s.io/managed-taints"
+
+	// AppliedTaintsHashAnnotation is set by the MachinePool controller on a MachinePool to record a hash of the
+	// Spec.Taints last applied to all its Nodes, so that the Nodes are only reconciled again once the taints change.
+	AppliedTaintsHashAnnotation = "exp.cluster.x-k8s.io/applied-taints-hash"
+
+	// ManagedAnnotationsAnnotation is set by the MachinePool controller on the Nodes of a MachinePool to record the
+	// comma separated keys of the annotations propagated from the MachinePool.
+	ManagedAnnotationsAnnotation = "exp.cluster.x-k8s.io/managed-annotations"
+
+	// AppliedAnnotationsHashAnnotation is set by the MachinePool controller on a MachinePool to record a hash of the
+	// annotations last propagated to all its Nodes, so that the Nodes are only reconciled again once they change.
+	AppliedAnnotationsHashAnnotation = "exp.cluster.x-k8s.io/applied-annotations-hash"
+
+	// CordonedAnnotation is set by the MachinePool controller on the Nodes it cordons. Nodes which are still
+	// part of the MachinePool are uncordoned once Ready, Nodes cordoned by someone else are left untouched.
+	CordonedAnnotation = "exp.cluster.x-k8s.io/cordoned"
+
+	// InfrastructureRecreatedAtAnnotation is set on a MachinePool by the controller to record, in RFC3339 format,
+	// when it last deleted the failed infrastructure object of the MachinePool to have it recreated.
+	InfrastructureRecreatedAtAnnotation = "exp.cluster.x-k8s.io/infrastructure-recreated-at"
+
+	// InfrastructureRecreateAttemptsAnnotation is set on a MachinePool by the controller to count the deletions
+	// of its failed infrastructure object since it was last ready.
+	InfrastructureRecreateAttemptsAnnotation = "exp.cluster.x-k8s.io/infrastructure-recreate-attempts"
+
+	// DeleteExternalAttemptsAnnotation is set on a MachinePool being deleted by the controller to count the failed
+	// deletions of its external objects. The deletions are retried with backoff up to a maximum number of attempts,
+	// removing the annotation resumes the retries once they stopped.
+	DeleteExternalAttemptsAnnotation = "exp.cluster.x-k8s.io/delete-external-attempts"
+
+	// ManagedZoneLabelAnnotation is set by the MachinePool controller on the Nodes it set the zone label of from
+	// Status.InstanceFailureDomains. Zone labels set by someone else, e.g. a cloud provider, are left untouched.
+	ManagedZoneLabelAnnotation = "exp.cluster.x-k8s.io/managed-zone-label"
+
+	// NodeImageLabel is set by the MachinePool controller on the Nodes of a MachinePool to the image of their
+	// instance from Status.InstanceImages, e.g. for upgrade tooling to select the Nodes running an image.
+	NodeImageLabel = "node.cluster.x-k8s.io/image"
+
+	// TemplateHashAnnotation is set on the Nodes of a MachinePool, e.g. by its bootstrap or infrastructure provider,
+	// to the hash of the template of the MachinePool they were created from, as reported by
+	// Status.RolloutStatus.TemplateHash.
+	TemplateHashAnnotation = "exp.cluster.x-k8s.io/template-hash"
+
+	// NodeMachinePoolAnnotation is set by the MachinePool controller on the Nodes of a MachinePool to its name, along
+	// with NodeMachinePoolNamespaceAnnotation, so that a Node can be mapped back to its MachinePool.
+	NodeMachinePoolAnnotation = "cluster.x-k8s.io/machine-pool"
+
+	// NodeMachinePoolNamespaceAnnotation is set by the MachinePool controller on the Nodes of a MachinePool to its
+	// namespace.
+	NodeMachinePoolNamespaceAnnotation = "cluster.x-k8s.io/machine-pool-namespace"
+
+	// ReplicasDefaultedAnnotation is set on a MachinePool by the defaulting webhook when it defaults Spec.Replicas,
+	// to record the defaulted number of replicas. It is removed by the controller once the replicas are changed.
+	ReplicasDefaultedAnnotation = "exp.cluster.x-k8s.io/replicas-defaulted"
+
+	// ReplicasManagedByAnnotation is set on a MachinePool whose replicas are managed by an external system, e.g. an
+	// autoscaler, to its name. Spec.Replicas is then advisory: the controller never scales the infrastructure, and
+	// only reports the replicas observed by the infrastructure provider.
+	ReplicasManagedByAnnotation = "exp.cluster.x-k8s.io/replicas-managed-by"
+
+	// DrainOnTerminationAnnotation is set by the MachinePool controller on the Nodes of a MachinePool when it is
+	// configured to drain Nodes on termination signals, to signal termination handlers that the Nodes are drained
+	// before their termination is acknowledged.
+	DrainOnTerminationAnnotation = "exp.cluster.x-k8s.io/drain-on-termination"
+
+	// TerminationSignalAnnotation is set on a Node, e.g. by the termination handler of an infrastructure provider,
+	// to signal that its instance is about to be terminated.
+	TerminationSignalAnnotation = "exp.cluster.x-k8s.io/termination-signal"
+
+	// TerminationAcknowledgedAnnotation is set by the MachinePool controller on a Node with the
+	// TerminationSignalAnnotation once it is drained, to acknowledge that its instance can be terminated.
+	TerminationAcknowledgedAnnotation = "exp.cluster.x-k8s.io/termination-acknowledged"
+
+	// ReplacementRequestedAnnotation is set by the MachinePool controller, when configured to replace unhealthy Nodes,
+	// on the Nodes whose host failed, for infrastructure providers to replace their instance, e.g. by marking it
+	// unhealthy in its scaling group.
+	ReplacementRequestedAnnotation = "exp.cluster.x-k8s.io/replacement-requested"
+
+	// FailureDomainDistributionAnnotation is set by the MachinePool controller on the infrastructure object of a
+	// MachinePool being scaled up, when it is configured to rebalance failure domains, to the desired number of
+	// instances in each failure domain as comma separated failureDomain=count pairs, e.g. "us-east-1a=2,us-east-1b=2".
+	// Providers should create the new instances in the failure domains below their count. It is removed once the
+	// MachinePool isn't scaling up anymore.
+	FailureDomainDistributionAnnotation = "exp.cluster.x-k8s.io/failure-domain-distribution"
+
+	// ProvisionAnnotation is set to ProvisionRequested by the MachinePool controller on the infrastructure object
+	// of a MachinePool when it is configured to request provisioning, for providers which only provision objects
+	// explicitly opted in. Providers set it to ProvisionProvisioned to acknowledge the request.
+	ProvisionAnnotation = "provider.cluster.x-k8s.io/provision"
+
+	// ProvisionRequested is the value of the ProvisionAnnotation requesting provisioning.
+	ProvisionRequested = "requested"
+
+	// ProvisionProvisioned is the value of the ProvisionAnnotation acknowledging provisioning.
+	ProvisionProvisioned = "provisioned"
+
+	// NodeReadyConditionTypeAnnotation can be set on a MachinePool to the type of the Node condition which signals
+	// that its Nodes are ready, for Nodes which don't use the standard Ready condition, e.g. with custom kubelets.
+	// Defaults to Ready.
+	NodeReadyConditionTypeAnnotation = "exp.cluster.x-k8s.io/node-ready-condition-type"
+
+	// SubnetIDsAnnotation can be set on the template of a MachinePool to the comma separated IDs of the subnets
+	// of its instances. It is propagated to spec.subnetIDs of the infrastructure object.
+	SubnetIDsAnnotation = "exp.cluster.x-k8s.io/subnet-ids"
+
+	// PodCIDRAnnotation can be set on the template of a MachinePool to the CIDR of the pods of its Nodes.
+	// It is propagated to spec.podCIDR of the infrastructure object.
+	PodCIDRAnnotation = "exp.cluster.x-k8s.io/pod-cidr"
+
+	// BootstrapFormatAnnotation is set by the MachinePool controller on the infrastructure object of a MachinePool
+	// to the format of its bootstrap data, for providers which need to know it. Bootstrap providers can set it as
+	// a label on the bootstrap data secret, otherwise the format is detected from the data.
+	BootstrapFormatAnnotation = "bootstrap.cluster.x-k8s.io/format"
+
+	// BootstrapFormatCloudConfig is the value of the BootstrapFormatAnnotation for cloud-init bootstrap data.
+	BootstrapFormatCloudConfig = "cloud-config"
+
+	// BootstrapFormatIgnition is the value of the BootstrapFormatAnnotation for Ignition bootstrap data.
+	BootstrapFormatIgnition = "ignition"
+
+	// PreProvisionApprovedAnnotation is set on a MachinePool by the controller once the pre-provision webhook
+	// approved provisioning its infrastructure, so that the webhook isn't called again.
+	PreProvisionApprovedAnnotation = "exp.cluster.x-k8s.io/pre-provision-approved"
+
+	// ImmutableTemplateFieldsAnnotation is set on a MachinePool by the controller once its infrastructure is ready,
+	// to record the JSON encoded values of the immutable template paths it is configured with.
+	ImmutableTemplateFieldsAnnotation = "exp.cluster.x-k8s.io/immutable-template-fields"
+
+	// GeneratedInfrastructureNameAnnotation is set on a MachinePool by the controller to the name of the
+	// infrastructure object it cloned from Spec.InfrastructureTemplateRef, so that the template is cloned only once.
+	GeneratedInfrastructureNameAnnotation = "exp.cluster.x-k8s.io/generated-infrastructure-name"
+
+	// SkipReconcileAnnotation can be set on a MachinePool, to the reason why, to temporarily stop the MachinePool
+	// controller from reconciling it, e.g. while debugging it. Neither the MachinePool, nor its deletion, nor its
+	// external objects are reconciled while it is set, the controller only reports the reason in the
+	// ReconcileSkippedCondition.
+	SkipReconcileAnnotation = "machinepool.cluster.x-k8s.io/skip-reconcile"
+
+	// SkipWaitForControlPlaneInitializedAnnotation can be set on a MachinePool to bootstrap it without waiting
+	// for the control plane of its cluster to be initialized.
+	SkipWaitForControlPlaneInitializedAnnotation = "exp.cluster.x-k8s.io/skip-wait-for-control-plane-initialized"
+
+	// WorkloadClusterAnnotation can be set on a MachinePool to the name of the Cluster, in the namespace of the
+	// MachinePool, whose workload cluster hosts the Nodes of the MachinePool, e.g. with hosted control planes. The
+	// Nodes are looked up in the workload cluster of the Cluster the MachinePool belongs to otherwise.
+	WorkloadClusterAnnotation = "exp.cluster.x-k8s.io/workload-cluster"
+)
+
+// ProviderIDListSource defines which object is the source of truth for the provider ID list of a MachinePool.
+type ProviderIDListSource string
+
+const (
+	// ProviderIDListSourceInfrastructure means the provider ID list is read from the infrastructure
+	// object into the MachinePool. This is the default.
+	ProviderIDListSourceInfrastructure = ProviderIDListSource("Infrastructure")
+
+	// ProviderIDListSourceMachinePool means the provider ID list of the MachinePool is written to
+	// the infrastructure object.
+	ProviderIDListSourceMachinePool = ProviderIDListSource("MachinePool")
 )
 
 // ANCHOR: MachinePoolSpec
@@ -40,13 +224,19 @@ type MachinePoolSpec struct {
 	// This is a pointer to distinguish between explicit zero and not specified.
 	Replicas *int32 `json:"replicas,omitempty"`
 
+	// MinReplicas is the minimum number of desired machines. Replicas is raised to MinReplicas by the
+	// controller if set below it, e.g. to protect against accidentally scaling critical pools down to zero.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+
 	// Template describes the machines that will be created.
 	Template clusterv1.MachineTemplateSpec `json:"template"`
 
 	// The deployment strategy to use to replace existing machine instances with
 	// new ones.
 	// +optional
-	Strategy *clusterv1.MachineDeploymentStrategy `json:"strategy,omitempty"`
+	Strategy *MachinePoolStrategy `json:"strategy,omitempty"`
 
 	// Minimum number of seconds for which a newly created machine instances should
 	// be ready.
@@ -60,12 +250,84 @@ type MachinePoolSpec struct {
 	// +optional
 	ProviderIDList []string `json:"providerIDList,omitempty"`
 
+	// ProviderIDListSource defines whether ProviderIDList is read from the infrastructure object (Infrastructure),
+	// or written to the infrastructure object (MachinePool). Defaults to Infrastructure.
+	// +kubebuilder:validation:Enum=Infrastructure;MachinePool
+	// +optional
+	ProviderIDListSource ProviderIDListSource `json:"providerIDListSource,omitempty"`
+
+	// ProviderIDPrefix, if set, is the prefix every entry in ProviderIDList is expected to start with,
+	// e.g. "aws://us-east-1". Provider IDs that don't match are not used to match nodes.
+	// +optional
+	ProviderIDPrefix string `json:"providerIDPrefix,omitempty"`
+
+	// InfrastructureGeneration, if set, pins the MachinePool to the given metadata.generation of the
+	// infrastructure object referenced by Spec.Template.Spec.InfrastructureRef. The state of the infrastructure
+	// object (readiness, provider IDs and replicas) is only adopted once it is observed at that generation.
+	// +optional
+	InfrastructureGeneration *int64 `json:"infrastructureGeneration,omitempty"`
+
 	// FailureDomains is the list of failure domains this MachinePool should be attached to.
 	FailureDomains []string `json:"failureDomains,omitempty"`
+
+	// NodeStartupTimeout is the amount of time a Node of the MachinePool can stay not Ready after joining the
+	// cluster before it is considered unhealthy. Nodes are not checked for a startup timeout if unset.
+	// +optional
+	NodeStartupTimeout *metav1.Duration `json:"nodeStartupTimeout,omitempty"`
+
+	// NodeDrainTimeout is the total amount of time the controller spends gracefully evicting the pods of a Node
+	// of the MachinePool it drains. The pods left once it elapsed are forcefully deleted, without waiting for
+	// their grace period nor honoring their PodDisruptionBudgets. Undefined or zero means pods are only ever
+	// evicted gracefully.
+	// +optional
+	NodeDrainTimeout *metav1.Duration `json:"nodeDrainTimeout,omitempty"`
+
+	// Taints are applied to the Nodes of the MachinePool. The controller only manages the taints it added,
+	// taints added to the Nodes by other means are left untouched.
+	// +optional
+	Taints []corev1.Taint `json:"taints,omitempty"`
+
+	// StatusFieldMappings are the fields of the infrastructure object's status to copy into Status.ProviderStatus.
+	// +optional
+	StatusFieldMappings []StatusFieldMapping `json:"statusFieldMappings,omitempty"`
+
+	// DependsOn are the MachinePools in the same namespace which must be Running before the infrastructure of this
+	// MachinePool is provisioned, e.g. a pool of system Nodes before a pool of workload Nodes.
+	// +optional
+	DependsOn []corev1.LocalObjectReference `json:"dependsOn,omitempty"`
+
+	// InfrastructureTemplateRef is a reference to an infrastructure template, shared by several MachinePools,
+	// which is cloned into an infrastructure object for this MachinePool on its first reconciliation.
+	// Template.Spec.InfrastructureRef is then set to the cloned object.
+	// +optional
+	InfrastructureTemplateRef *corev1.ObjectReference `json:"infrastructureTemplateRef,omitempty"`
 }
 
 // ANCHOR_END: MachinePoolSpec
 
+// MachinePoolStrategy describes how to replace the machine instances of a MachinePool.
+type MachinePoolStrategy struct {
+	clusterv1.MachineDeploymentStrategy `json:",inline"`
+
+	// RecreateOnFailure makes the controller delete the infrastructure object of the MachinePool when it reports
+	// a terminal failure reason, so that it gets recreated, instead of leaving the MachinePool failed. Successive
+	// deletions are backed off until the infrastructure object becomes ready.
+	// +optional
+	RecreateOnFailure bool `json:"recreateOnFailure,omitempty"`
+}
+
+// StatusFieldMapping copies a field of the status of the infrastructure object into Status.ProviderStatus.
+type StatusFieldMapping struct {
+	// Name is the key the field is copied to in Status.ProviderStatus.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Path is the dot separated path of the field in the status of the infrastructure object,
+	// e.g. "autoScalingGroup.arn" for status.autoScalingGroup.arn.
+	// +kubebuilder:validation:MinLength=1
+	Path string `json:"path"`
+}
+
 // ANCHOR: MachinePoolStatus
 
 // MachinePoolStatus defines the observed state of MachinePool
@@ -74,10 +336,28 @@ type MachinePoolStatus struct {
 	// +optional
 	NodeRefs []corev1.ObjectReference `json:"nodeRefs,omitempty"`
 
+	// NodeRefsCount is the number of Nodes referenced by the MachinePool. It is set even if
+	// the controller is configured to not store NodeRefs, e.g. for very large MachinePools.
+	// +optional
+	NodeRefsCount int32 `json:"nodeRefsCount,omitempty"`
+
+	// ReconciledNodes is the number of Nodes of the MachinePool reconciled so far, when their reconciliation
+	// is spread over several reconciliations by the node read budget of the controller. It is reset to zero
+	// once all the Nodes have been reconciled.
+	// +optional
+	ReconciledNodes int32 `json:"reconciledNodes,omitempty"`
+
 	// Replicas is the most recently observed number of replicas.
 	// +optional
 	Replicas int32 `json:"replicas"`
 
+	// Selector is the label selector, in string format, matching the objects of the MachinePool.
+	// It is used by the scale subresource, e.g. for autoscalers. The string will be in the same
+	// format as the query-param syntax.
+	// More info about label selectors: http://kubernetes.io/docs/user-guide/labels#label-selectors
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
 	// The number of ready replicas for this MachinePool. A machine is considered ready when the node has been created and is "Ready".
 	// +optional
 	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
@@ -94,6 +374,25 @@ type MachinePoolStatus struct {
 	// +optional
 	UnavailableReplicas int32 `json:"unavailableReplicas,omitempty"`
 
+	// PendingReplicas is the number of provider IDs of the MachinePool without a matching Node yet, i.e. of
+	// instances still joining the cluster. Unlike UnavailableReplicas, it doesn't count Nodes which aren't ready.
+	// +optional
+	PendingReplicas int32 `json:"pendingReplicas,omitempty"`
+
+	// UnhealthyReplicas is the number of Nodes which didn't become Ready within Spec.NodeStartupTimeout, or
+	// whose host failed according to the unhealthy Node condition the controller is configured with.
+	// +optional
+	UnhealthyReplicas int32 `json:"unhealthyReplicas,omitempty"`
+
+	// ReplicaBreakdown counts the replicas of the MachinePool by readiness. The counts add up to Replicas.
+	// +optional
+	ReplicaBreakdown *MachinePoolReplicaBreakdown `json:"replicaBreakdown,omitempty"`
+
+	// RolloutStatus describes the rollout of Spec.Template to the Nodes of the MachinePool, e.g. to gate CI/CD
+	// pipelines on its completion.
+	// +optional
+	RolloutStatus *MachinePoolRolloutStatus `json:"rolloutStatus,omitempty"`
+
 	// FailureReason indicates that there is a problem reconciling the state, and
 	// will be set to a token value suitable for programmatic interpretation.
 	// +optional
@@ -109,6 +408,27 @@ type MachinePoolStatus struct {
 	// +optional
 	Phase string `json:"phase,omitempty"`
 
+	// DisplayPhase is the phase of the MachinePool as it should be displayed to users.
+	// It is the same as Phase, unless the controller is configured with a different name for it.
+	// +optional
+	DisplayPhase string `json:"displayPhase,omitempty"`
+
+	// Summary is a one-line human readable summary of the status of the MachinePool, with its ready and
+	// desired replicas and its displayed phase, e.g. "3/3 ready, Running".
+	// +optional
+	Summary string `json:"summary,omitempty"`
+
+	// ProgressPercent is the percentage of the desired replicas of the MachinePool which are ready, between 0 and
+	// 100, e.g. to display the progress of its provisioning or scaling. It is 100 for MachinePools scaled to zero
+	// once their infrastructure is ready.
+	// +optional
+	ProgressPercent int32 `json:"progressPercent,omitempty"`
+
+	// NotRunningReason names what keeps the MachinePool from being Running, e.g. its infrastructure not being ready
+	// or some of its replicas not being ready yet. It is empty while the MachinePool is Running.
+	// +optional
+	NotRunningReason string `json:"notRunningReason,omitempty"`
+
 	// BootstrapReady is the state of the bootstrap provider.
 	// +optional
 	BootstrapReady bool `json:"bootstrapReady"`
@@ -117,13 +437,206 @@ type MachinePoolStatus struct {
 	// +optional
 	InfrastructureReady bool `json:"infrastructureReady"`
 
+	// TopologyManaged is true if the MachinePool is managed by the topology controller of a ClusterClass,
+	// as reported by the TopologyOwnedLabel. The controller doesn't mutate the spec of such MachinePools.
+	// +optional
+	TopologyManaged bool `json:"topologyManaged,omitempty"`
+
 	// ObservedGeneration is the latest generation observed by the controller.
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastReconcileTime is the last time the MachinePool was successfully reconciled, with a resolution of
+	// about a minute. It can be used to detect MachinePools which aren't being reconciled anymore.
+	// +optional
+	LastReconcileTime *metav1.Time `json:"lastReconcileTime,omitempty"`
+
+	// LastError is the message of the error the last reconciliation of the MachinePool failed with, and is cleared
+	// once a reconciliation succeeds. Unlike FailureReason and FailureMessage, which report terminal failures of the
+	// infrastructure provider, it surfaces transient errors of the controller, e.g. an unreachable workload cluster.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// Version is the Kubernetes version the bootstrap and infrastructure providers of the MachinePool provision
+	// its instances with, i.e. Spec.Template.Spec.Version as of the last reconciliation which completed with both
+	// of them ready.
+	// +optional
+	Version *string `json:"version,omitempty"`
+
+	// NodeVersions counts the Nodes referenced by the MachinePool by their kubelet version.
+	// +optional
+	NodeVersions map[string]int32 `json:"nodeVersions,omitempty"`
+
+	// OldestNodeCreationTime is the creation time of the oldest Node referenced by the MachinePool. It can be
+	// used to rotate the instances of the MachinePool before they reach a maximum age.
+	// +optional
+	OldestNodeCreationTime *metav1.Time `json:"oldestNodeCreationTime,omitempty"`
+
+	// Conditions define the current service state of the MachinePool.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// DeletionProgress describes the objects the MachinePool is still waiting on while it is being deleted.
+	// +optional
+	DeletionProgress *MachinePoolDeletionProgress `json:"deletionProgress,omitempty"`
+
+	// ProviderStatus are the fields of the infrastructure object's status selected by Spec.StatusFieldMappings.
+	// +optional
+	ProviderStatus map[string]string `json:"providerStatus,omitempty"`
+
+	// InstanceStates are the states of the machine instances of the MachinePool by provider ID,
+	// e.g. "running", "stopping" or "terminated", if reported by the infrastructure provider.
+	// +optional
+	InstanceStates map[string]string `json:"instanceStates,omitempty"`
+
+	// InstanceFailureDomains are the failure domains of the machine instances of the MachinePool by provider ID,
+	// if reported by the infrastructure provider. They are set as the zone label of the matching Nodes.
+	// +optional
+	InstanceFailureDomains map[string]string `json:"instanceFailureDomains,omitempty"`
+
+	// FailureDomainCoverage lists the failure domains of the Cluster the MachinePool is attached to, with the number
+	// of its machine instances in each, as reported in InstanceFailureDomains. A failure domain without instances
+	// lost all the capacity of the MachinePool.
+	// +optional
+	FailureDomainCoverage []MachinePoolFailureDomainCoverage `json:"failureDomainCoverage,omitempty"`
+
+	// InstanceImages are the images, e.g. AMIs, of the machine instances of the MachinePool by provider ID, if
+	// reported by the infrastructure provider. They are set as the NodeImageLabel of the matching Nodes.
+	// +optional
+	InstanceImages map[string]string `json:"instanceImages,omitempty"`
+
+	// Capacity is the aggregate capacity, e.g. CPU and memory, of the machine instances of the MachinePool, if
+	// the infrastructure provider reports the capacity of each instance. It can be used by autoscalers to estimate
+	// the impact of scaling the MachinePool up.
+	// +optional
+	Capacity corev1.ResourceList `json:"capacity,omitempty"`
+
+	// InfrastructureConditions are the conditions of the infrastructure object whose types the controller is
+	// configured to copy, so that they can be seen without fetching the infrastructure object.
+	// +optional
+	InfrastructureConditions clusterv1.Conditions `json:"infrastructureConditions,omitempty"`
+
+	// ScaleDownCandidates are the provider IDs of the instances of the MachinePool whose utilization is reported
+	// by the infrastructure provider, from the least to the most utilized. It can be used by external tooling,
+	// e.g. autoscalers, to pick the instances to remove when scaling the MachinePool down.
+	// +optional
+	ScaleDownCandidates []string `json:"scaleDownCandidates,omitempty"`
+
+	// NodeStatuses describe the readiness of the Nodes referenced by the MachinePool, up to a limit, if the
+	// controller is configured to report them.
+	// +optional
+	NodeStatuses []MachinePoolNodeStatus `json:"nodeStatuses,omitempty"`
+
+	// NodeDrains describe the progress of the drains of the Nodes of the MachinePool which are in progress.
+	// +optional
+	NodeDrains []MachinePoolNodeDrain `json:"nodeDrains,omitempty"`
 }
 
 // ANCHOR_END: MachinePoolStatus
 
+// MachinePoolDeletionProgress describes the dependencies that remain to be deleted for a MachinePool.
+type MachinePoolDeletionProgress struct {
+	// BootstrapRemaining is true while the bootstrap config referenced by the MachinePool still exists.
+	// +optional
+	BootstrapRemaining bool `json:"bootstrapRemaining"`
+
+	// InfrastructureRemaining is true while the infrastructure object referenced by the MachinePool still exists.
+	// +optional
+	InfrastructureRemaining bool `json:"infrastructureRemaining"`
+
+	// NodesRemaining is the number of Nodes referenced by the MachinePool which are still to be deleted.
+	// +optional
+	NodesRemaining int32 `json:"nodesRemaining"`
+}
+
+// MachinePoolReplicaBreakdown counts the replicas of a MachinePool by readiness.
+type MachinePoolReplicaBreakdown struct {
+	// Provisioning is the number of instances which don't have a Node yet.
+	// +optional
+	Provisioning int32 `json:"provisioning"`
+
+	// Ready is the number of instances whose Node is Ready.
+	// +optional
+	Ready int32 `json:"ready"`
+
+	// NotReady is the number of instances whose Node is not Ready.
+	// +optional
+	NotReady int32 `json:"notReady"`
+
+	// Deleting is the number of instances being deleted, according to the states reported by the
+	// infrastructure provider.
+	// +optional
+	Deleting int32 `json:"deleting"`
+}
+
+// MachinePoolRolloutStatus describes the rollout of the template of a MachinePool to its Nodes. Nodes are matched to
+// the template they were created from by their TemplateHashAnnotation.
+type MachinePoolRolloutStatus struct {
+	// TemplateHash is the hash of Spec.Template, which the Nodes created from it are expected to be annotated with.
+	// +optional
+	TemplateHash string `json:"templateHash"`
+
+	// InProgress is true while not all the desired replicas have a Node created from Spec.Template.
+	// +optional
+	InProgress bool `json:"inProgress"`
+
+	// UpdatedReplicas is the number of Nodes created from Spec.Template.
+	// +optional
+	UpdatedReplicas int32 `json:"updatedReplicas"`
+
+	// Replicas is the number of Nodes of the MachinePool.
+	// +optional
+	Replicas int32 `json:"replicas"`
+
+	// Strategy is the type of the deployment strategy of the MachinePool, if any.
+	// +optional
+	Strategy clusterv1.MachineDeploymentStrategyType `json:"strategy,omitempty"`
+}
+
+// MachinePoolFailureDomainCoverage describes the machine instances of a MachinePool in a failure domain.
+type MachinePoolFailureDomainCoverage struct {
+	// FailureDomain is the name of the failure domain.
+	FailureDomain string `json:"failureDomain"`
+
+	// Instances is the number of machine instances of the MachinePool in the failure domain.
+	// +optional
+	Instances int32 `json:"instances"`
+}
+
+// MachinePoolNodeStatus describes the readiness of a Node referenced by a MachinePool.
+type MachinePoolNodeStatus struct {
+	// Name is the name of the Node.
+	Name string `json:"name"`
+
+	// ProviderID is the provider ID of the Node.
+	ProviderID string `json:"providerID"`
+
+	// Ready is the status of the ready condition of the Node, Unknown if it has none.
+	// +optional
+	Ready corev1.ConditionStatus `json:"ready,omitempty"`
+
+	// ReadyTransitionTime is the last time the ready condition of the Node transitioned.
+	// +optional
+	ReadyTransitionTime *metav1.Time `json:"readyTransitionTime,omitempty"`
+}
+
+// MachinePoolNodeDrain describes the progress of the drain of a Node of a MachinePool.
+type MachinePoolNodeDrain struct {
+	// NodeName is the name of the Node.
+	NodeName string `json:"nodeName"`
+
+	// StartTime is the time the controller started draining the Node.
+	StartTime metav1.Time `json:"startTime"`
+
+	// PodsRemaining is the number of pods left to evict from the Node as of the last drain attempt.
+	// +optional
+	PodsRemaining int32 `json:"podsRemaining"`
+
+	// Forced is true once the NodeDrainTimeout elapsed and the remaining pods are forcefully deleted.
+	// +optional
+	Forced bool `json:"forced,omitempty"`
+}
+
 // MachinePoolPhase is a string representation of a MachinePool Phase.
 //
 // This type is a high-level indicator of the status of the MachinePool as it is provisioned,
@@ -201,10 +714,11 @@ func (m *MachinePoolStatus) GetTypedPhase() MachinePoolPhase {
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:path=machinepools,shortName=mp,scope=Namespaced,categories=cluster-api
 // +kubebuilder:subresource:status
-// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
 // +kubebuilder:storageversion
 // +kubebuilder:printcolumn:name="Replicas",type="string",JSONPath=".status.replicas",description="MachinePool replicas count"
 // +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="MachinePool status such as Terminating/Pending/Provisioning/Running/Failed etc"
+// +kubebuilder:printcolumn:name="Summary",type="string",JSONPath=".status.summary",description="MachinePool ready and desired replicas, and phase"
 // +k8s:conversion-gen=false
 
 // MachinePool is the Schema for the machinepools API
@@ -216,6 +730,14 @@ type MachinePool struct {
 	Status MachinePoolStatus `json:"status,omitempty"`
 }
 
+func (m *MachinePool) GetConditions() clusterv1.Conditions {
+	return m.Status.Conditions
+}
+
+func (m *MachinePool) SetConditions(conditions clusterv1.Conditions) {
+	m.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // MachinePoolList contains a list of MachinePool
@@ -17,15 +17,24 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
 	"k8s.io/utils/pointer"
 
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 func (m *MachinePool) SetupWebhookWithManager(mgr ctrl.Manager) error {
@@ -40,6 +49,66 @@ func (m *MachinePool) SetupWebhookWithManager(mgr ctrl.Manager) error {
 var _ webhook.Defaulter = &MachinePool{}
 var _ webhook.Validator = &MachinePool{}
 
+const machinePoolValidatePath = "/validate-exp-cluster-x-k8s-io-v1alpha3-machinepool"
+
+// MachinePoolValidator is the validating webhook of MachinePools. On top of the validation of MachinePool, it rejects
+// changes to the ImmutableTemplatePaths of the template of MachinePools once their infrastructure has been ready.
+type MachinePoolValidator struct {
+	// ImmutableTemplatePaths are the dotted paths, relative to Spec.Template, of the fields of the template of
+	// MachinePools which can't change once their infrastructure has been ready, e.g. "spec.infrastructureRef.name"
+	// for infrastructure providers which can't update their instances in place.
+	ImmutableTemplatePaths []string
+
+	decoder *admission.Decoder
+}
+
+var _ admission.Handler = &MachinePoolValidator{}
+var _ admission.DecoderInjector = &MachinePoolValidator{}
+
+// SetupWebhookWithManager sets up the MachinePool webhooks, validating MachinePools with the MachinePoolValidator.
+func (v *MachinePoolValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	// Registered first, so that the builder skips the path of the validating webhook of MachinePool.
+	mgr.GetWebhookServer().Register(machinePoolValidatePath, &webhook.Admission{Handler: v})
+	return (&MachinePool{}).SetupWebhookWithManager(mgr)
+}
+
+// InjectDecoder implements admission.DecoderInjector.
+func (v *MachinePoolValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle implements admission.Handler.
+func (v *MachinePoolValidator) Handle(_ context.Context, req admission.Request) admission.Response {
+	m := &MachinePool{}
+	var err error
+	switch req.Operation {
+	case admissionv1beta1.Create:
+		if err := v.decoder.DecodeRaw(req.Object, m); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		err = m.ValidateCreate()
+	case admissionv1beta1.Update:
+		old := &MachinePool{}
+		if err := v.decoder.DecodeRaw(req.Object, m); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		err = m.validate(old, v.ImmutableTemplatePaths)
+	case admissionv1beta1.Delete:
+		if err := v.decoder.DecodeRaw(req.OldObject, m); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		err = m.ValidateDelete()
+	}
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
+
 // Default implements webhook.Defaulter so a webhook will be registered for the type
 func (m *MachinePool) Default() {
 	if m.Labels == nil {
@@ -49,6 +118,10 @@ func (m *MachinePool) Default() {
 
 	if m.Spec.Replicas == nil {
 		m.Spec.Replicas = pointer.Int32Ptr(1)
+		if m.Annotations == nil {
+			m.Annotations = make(map[string]string)
+		}
+		m.Annotations[ReplicasDefaultedAnnotation] = strconv.Itoa(int(*m.Spec.Replicas))
 	}
 
 	if m.Spec.MinReadySeconds == nil {
@@ -62,11 +135,15 @@ func (m *MachinePool) Default() {
 	if len(m.Spec.Template.Spec.InfrastructureRef.Namespace) == 0 {
 		m.Spec.Template.Spec.InfrastructureRef.Namespace = m.Namespace
 	}
+
+	if m.Spec.InfrastructureTemplateRef != nil && len(m.Spec.InfrastructureTemplateRef.Namespace) == 0 {
+		m.Spec.InfrastructureTemplateRef.Namespace = m.Namespace
+	}
 }
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (m *MachinePool) ValidateCreate() error {
-	return m.validate(nil)
+	return m.validate(nil, nil)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
@@ -75,15 +152,15 @@ func (m *MachinePool) ValidateUpdate(old runtime.Object) error {
 	if !ok {
 		return apierrors.NewBadRequest(fmt.Sprintf("expected a MachinePool but got a %T", old))
 	}
-	return m.validate(oldMP)
+	return m.validate(oldMP, nil)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
 func (m *MachinePool) ValidateDelete() error {
-	return m.validate(nil)
+	return m.validate(nil, nil)
 }
 
-func (m *MachinePool) validate(old *MachinePool) error {
+func (m *MachinePool) validate(old *MachinePool, immutableTemplatePaths []string) error {
 	var allErrs field.ErrorList
 	if m.Spec.Template.Spec.Bootstrap.ConfigRef == nil && m.Spec.Template.Spec.Bootstrap.DataSecretName == nil {
 		allErrs = append(
@@ -117,6 +194,26 @@ func (m *MachinePool) validate(old *MachinePool) error {
 		)
 	}
 
+	if m.Spec.InfrastructureTemplateRef != nil && m.Spec.InfrastructureTemplateRef.Namespace != m.Namespace {
+		allErrs = append(
+			allErrs,
+			field.Invalid(
+				field.NewPath("spec", "infrastructureTemplateRef", "namespace"),
+				m.Spec.InfrastructureTemplateRef.Namespace,
+				"must match metadata.namespace",
+			),
+		)
+	}
+
+	for i, dependency := range m.Spec.DependsOn {
+		if dependency.Name == m.Name {
+			allErrs = append(
+				allErrs,
+				field.Invalid(field.NewPath("spec", "dependsOn").Index(i).Child("name"), dependency.Name, "a MachinePool can't depend on itself"),
+			)
+		}
+	}
+
 	if old != nil && old.Spec.ClusterName != m.Spec.ClusterName {
 		allErrs = append(
 			allErrs,
@@ -124,8 +221,75 @@ func (m *MachinePool) validate(old *MachinePool) error {
 		)
 	}
 
+	if old != nil && len(immutableTemplatePaths) > 0 {
+		if _, recorded := old.Annotations[ImmutableTemplateFieldsAnnotation]; old.Status.InfrastructureReady || recorded {
+			allErrs = append(allErrs, m.validateImmutableTemplateFields(old, immutableTemplatePaths)...)
+		}
+	}
+
 	if len(allErrs) == 0 {
 		return nil
 	}
 	return apierrors.NewInvalid(GroupVersion.WithKind("MachinePool").GroupKind(), m.Name, allErrs)
 }
+
+// validateImmutableTemplateFields rejects changes to the given paths of the template of a MachinePool.
+func (m *MachinePool) validateImmutableTemplateFields(old *MachinePool, paths []string) field.ErrorList {
+	templatePath := field.NewPath("spec", "template")
+	oldFields, err := ImmutableTemplateFields(&old.Spec.Template, paths)
+	if err != nil {
+		return field.ErrorList{field.InternalError(templatePath, err)}
+	}
+	fields, err := ImmutableTemplateFields(&m.Spec.Template, paths)
+	if err != nil {
+		return field.ErrorList{field.InternalError(templatePath, err)}
+	}
+
+	var allErrs field.ErrorList
+	for _, path := range ChangedImmutableTemplatePaths(paths, oldFields, fields) {
+		parts := strings.Split(path, ".")
+		allErrs = append(
+			allErrs,
+			field.Forbidden(
+				templatePath.Child(parts[0], parts[1:]...),
+				"cannot be changed once the infrastructure of the MachinePool has been ready, create a new MachinePool instead",
+			),
+		)
+	}
+	return allErrs
+}
+
+// ImmutableTemplateFields returns the JSON encoded values of the given dotted paths of a MachinePool template, by
+// path. Fields which aren't set are encoded as null.
+func ImmutableTemplateFields(template *clusterv1.MachineTemplateSpec, paths []string) (map[string]string, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(template)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string, len(paths))
+	for _, path := range paths {
+		value, _, err := unstructured.NestedFieldNoCopy(content, strings.Split(path, ".")...)
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		fields[path] = string(encoded)
+	}
+	return fields, nil
+}
+
+// ChangedImmutableTemplatePaths returns the given paths whose values differ between two results of
+// ImmutableTemplateFields. Paths missing from the old fields, e.g. configured since, are ignored.
+func ChangedImmutableTemplatePaths(paths []string, oldFields, fields map[string]string) []string {
+	var changed []string
+	for _, path := range paths {
+		if oldValue, ok := oldFields[path]; ok && oldValue != fields[path] {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
@@ -17,14 +17,19 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"context"
+	"encoding/json"
 	"testing"
 
 	. "github.com/onsi/gomega"
 
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 func TestMachinePoolDefault(t *testing.T) {
@@ -40,6 +45,7 @@ func TestMachinePoolDefault(t *testing.T) {
 					Bootstrap: clusterv1.Bootstrap{ConfigRef: &corev1.ObjectReference{}},
 				},
 			},
+			InfrastructureTemplateRef: &corev1.ObjectReference{},
 		},
 	}
 
@@ -47,9 +53,29 @@ func TestMachinePoolDefault(t *testing.T) {
 
 	g.Expect(m.Labels[clusterv1.ClusterLabelName]).To(Equal(m.Spec.ClusterName))
 	g.Expect(m.Spec.Replicas).To(Equal(pointer.Int32Ptr(1)))
+	g.Expect(m.Annotations).To(HaveKeyWithValue(ReplicasDefaultedAnnotation, "1"))
 	g.Expect(m.Spec.MinReadySeconds).To(Equal(pointer.Int32Ptr(0)))
 	g.Expect(m.Spec.Template.Spec.Bootstrap.ConfigRef.Namespace).To(Equal(m.Namespace))
 	g.Expect(m.Spec.Template.Spec.InfrastructureRef.Namespace).To(Equal(m.Namespace))
+	g.Expect(m.Spec.InfrastructureTemplateRef.Namespace).To(Equal(m.Namespace))
+}
+
+func TestMachinePoolDefaultExplicitReplicas(t *testing.T) {
+	g := NewWithT(t)
+
+	m := &MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "foobar",
+		},
+		Spec: MachinePoolSpec{
+			Replicas: pointer.Int32Ptr(3),
+		},
+	}
+
+	m.Default()
+
+	g.Expect(m.Spec.Replicas).To(Equal(pointer.Int32Ptr(3)))
+	g.Expect(m.Annotations).NotTo(HaveKey(ReplicasDefaultedAnnotation))
 }
 
 func TestMachinePoolBootstrapValidation(t *testing.T) {
@@ -218,3 +244,147 @@ func TestMachinePoolClusterNameImmutable(t *testing.T) {
 		})
 	}
 }
+
+func TestMachinePoolValidatorImmutableTemplatePaths(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &MachinePoolValidator{ImmutableTemplatePaths: []string{"spec.infrastructureRef.kind", "spec.failureDomain"}}
+	if err := v.InjectDecoder(decoder); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name                string
+		infrastructureReady bool
+		recorded            bool
+		mutate              func(mp *MachinePool)
+		expectErr           bool
+	}{
+		{
+			name:                "when an immutable field changes before the infrastructure is ready",
+			infrastructureReady: false,
+			mutate:              func(mp *MachinePool) { mp.Spec.Template.Spec.InfrastructureRef.Kind = "OtherInfrastructureConfig" },
+			expectErr:           false,
+		},
+		{
+			name:                "when a mutable field changes once the infrastructure is ready",
+			infrastructureReady: true,
+			mutate:              func(mp *MachinePool) { mp.Spec.Template.Spec.InfrastructureRef.Name = "infra-config-2" },
+			expectErr:           false,
+		},
+		{
+			name:                "when an immutable field changes once the infrastructure is ready",
+			infrastructureReady: true,
+			mutate:              func(mp *MachinePool) { mp.Spec.Template.Spec.InfrastructureRef.Kind = "OtherInfrastructureConfig" },
+			expectErr:           true,
+		},
+		{
+			name:                "when an unset immutable field is set once the infrastructure is ready",
+			infrastructureReady: true,
+			mutate:              func(mp *MachinePool) { mp.Spec.Template.Spec.FailureDomain = pointer.StringPtr("us-east-1a") },
+			expectErr:           true,
+		},
+		{
+			name:      "when an immutable field changes after the infrastructure has been ready",
+			recorded:  true,
+			mutate:    func(mp *MachinePool) { mp.Spec.Template.Spec.InfrastructureRef.Kind = "OtherInfrastructureConfig" },
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			oldMP := &MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}},
+				Spec: MachinePoolSpec{
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							Bootstrap: clusterv1.Bootstrap{ConfigRef: &corev1.ObjectReference{}},
+							InfrastructureRef: corev1.ObjectReference{
+								Kind: "InfrastructureConfig",
+								Name: "infra-config-1",
+							},
+						},
+					},
+				},
+				Status: MachinePoolStatus{InfrastructureReady: tt.infrastructureReady},
+			}
+			if tt.recorded {
+				oldMP.Annotations[ImmutableTemplateFieldsAnnotation] = "{}"
+			}
+
+			newMP := oldMP.DeepCopy()
+			tt.mutate(newMP)
+
+			// Changes to the paths are only rejected by the MachinePoolValidator.
+			g.Expect(newMP.ValidateUpdate(oldMP)).To(Succeed())
+
+			oldRaw, err := json.Marshal(oldMP)
+			g.Expect(err).NotTo(HaveOccurred())
+			newRaw, err := json.Marshal(newMP)
+			g.Expect(err).NotTo(HaveOccurred())
+			resp := v.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+				Operation: admissionv1beta1.Update,
+				Object:    runtime.RawExtension{Raw: newRaw},
+				OldObject: runtime.RawExtension{Raw: oldRaw},
+			}})
+			if tt.expectErr {
+				g.Expect(resp.Allowed).To(BeFalse())
+				g.Expect(string(resp.Result.Reason)).To(ContainSubstring("create a new MachinePool instead"))
+			} else {
+				g.Expect(resp.Allowed).To(BeTrue())
+			}
+		})
+	}
+}
+
+func TestMachinePoolDependsOnValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		dependsOn []corev1.LocalObjectReference
+		expectErr bool
+	}{
+		{
+			name:      "should succeed if the MachinePool depends on other MachinePools",
+			dependsOn: []corev1.LocalObjectReference{{Name: "system"}},
+			expectErr: false,
+		},
+		{
+			name:      "should return error if the MachinePool depends on itself",
+			dependsOn: []corev1.LocalObjectReference{{Name: "system"}, {Name: "workload"}},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			m := &MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Name: "workload"},
+				Spec: MachinePoolSpec{
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							Bootstrap: clusterv1.Bootstrap{ConfigRef: &corev1.ObjectReference{}},
+						},
+					},
+					DependsOn: tt.dependsOn,
+				},
+			}
+
+			if tt.expectErr {
+				g.Expect(m.ValidateCreate()).NotTo(Succeed())
+			} else {
+				g.Expect(m.ValidateCreate()).To(Succeed())
+			}
+		})
+	}
+}
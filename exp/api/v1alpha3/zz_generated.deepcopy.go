@@ -22,6 +22,7 @@ package v1alpha3
 
 import (
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	apiv1alpha3 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/errors"
@@ -54,6 +55,36 @@ func (in *MachinePool) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachinePoolDeletionProgress) DeepCopyInto(out *MachinePoolDeletionProgress) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachinePoolDeletionProgress.
+func (in *MachinePoolDeletionProgress) DeepCopy() *MachinePoolDeletionProgress {
+	if in == nil {
+		return nil
+	}
+	out := new(MachinePoolDeletionProgress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachinePoolFailureDomainCoverage) DeepCopyInto(out *MachinePoolFailureDomainCoverage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachinePoolFailureDomainCoverage.
+func (in *MachinePoolFailureDomainCoverage) DeepCopy() *MachinePoolFailureDomainCoverage {
+	if in == nil {
+		return nil
+	}
+	out := new(MachinePoolFailureDomainCoverage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MachinePoolList) DeepCopyInto(out *MachinePoolList) {
 	*out = *in
@@ -86,6 +117,71 @@ func (in *MachinePoolList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachinePoolNodeDrain) DeepCopyInto(out *MachinePoolNodeDrain) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachinePoolNodeDrain.
+func (in *MachinePoolNodeDrain) DeepCopy() *MachinePoolNodeDrain {
+	if in == nil {
+		return nil
+	}
+	out := new(MachinePoolNodeDrain)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachinePoolNodeStatus) DeepCopyInto(out *MachinePoolNodeStatus) {
+	*out = *in
+	if in.ReadyTransitionTime != nil {
+		in, out := &in.ReadyTransitionTime, &out.ReadyTransitionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachinePoolNodeStatus.
+func (in *MachinePoolNodeStatus) DeepCopy() *MachinePoolNodeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MachinePoolNodeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachinePoolReplicaBreakdown) DeepCopyInto(out *MachinePoolReplicaBreakdown) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachinePoolReplicaBreakdown.
+func (in *MachinePoolReplicaBreakdown) DeepCopy() *MachinePoolReplicaBreakdown {
+	if in == nil {
+		return nil
+	}
+	out := new(MachinePoolReplicaBreakdown)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachinePoolRolloutStatus) DeepCopyInto(out *MachinePoolRolloutStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachinePoolRolloutStatus.
+func (in *MachinePoolRolloutStatus) DeepCopy() *MachinePoolRolloutStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MachinePoolRolloutStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MachinePoolSpec) DeepCopyInto(out *MachinePoolSpec) {
 	*out = *in
@@ -94,10 +190,15 @@ func (in *MachinePoolSpec) DeepCopyInto(out *MachinePoolSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
 	in.Template.DeepCopyInto(&out.Template)
 	if in.Strategy != nil {
 		in, out := &in.Strategy, &out.Strategy
-		*out = new(apiv1alpha3.MachineDeploymentStrategy)
+		*out = new(MachinePoolStrategy)
 		(*in).DeepCopyInto(*out)
 	}
 	if in.MinReadySeconds != nil {
@@ -110,11 +211,48 @@ func (in *MachinePoolSpec) DeepCopyInto(out *MachinePoolSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.InfrastructureGeneration != nil {
+		in, out := &in.InfrastructureGeneration, &out.InfrastructureGeneration
+		*out = new(int64)
+		**out = **in
+	}
 	if in.FailureDomains != nil {
 		in, out := &in.FailureDomains, &out.FailureDomains
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.NodeStartupTimeout != nil {
+		in, out := &in.NodeStartupTimeout, &out.NodeStartupTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.NodeDrainTimeout != nil {
+		in, out := &in.NodeDrainTimeout, &out.NodeDrainTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Taints != nil {
+		in, out := &in.Taints, &out.Taints
+		*out = make([]v1.Taint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StatusFieldMappings != nil {
+		in, out := &in.StatusFieldMappings, &out.StatusFieldMappings
+		*out = make([]StatusFieldMapping, len(*in))
+		copy(*out, *in)
+	}
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.InfrastructureTemplateRef != nil {
+		in, out := &in.InfrastructureTemplateRef, &out.InfrastructureTemplateRef
+		*out = new(v1.ObjectReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachinePoolSpec.
@@ -135,6 +273,16 @@ func (in *MachinePoolStatus) DeepCopyInto(out *MachinePoolStatus) {
 		*out = make([]v1.ObjectReference, len(*in))
 		copy(*out, *in)
 	}
+	if in.ReplicaBreakdown != nil {
+		in, out := &in.ReplicaBreakdown, &out.ReplicaBreakdown
+		*out = new(MachinePoolReplicaBreakdown)
+		**out = **in
+	}
+	if in.RolloutStatus != nil {
+		in, out := &in.RolloutStatus, &out.RolloutStatus
+		*out = new(MachinePoolRolloutStatus)
+		**out = **in
+	}
 	if in.FailureReason != nil {
 		in, out := &in.FailureReason, &out.FailureReason
 		*out = new(errors.MachinePoolStatusFailure)
@@ -145,6 +293,104 @@ func (in *MachinePoolStatus) DeepCopyInto(out *MachinePoolStatus) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.LastReconcileTime != nil {
+		in, out := &in.LastReconcileTime, &out.LastReconcileTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Version != nil {
+		in, out := &in.Version, &out.Version
+		*out = new(string)
+		**out = **in
+	}
+	if in.NodeVersions != nil {
+		in, out := &in.NodeVersions, &out.NodeVersions
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.OldestNodeCreationTime != nil {
+		in, out := &in.OldestNodeCreationTime, &out.OldestNodeCreationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1alpha3.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DeletionProgress != nil {
+		in, out := &in.DeletionProgress, &out.DeletionProgress
+		*out = new(MachinePoolDeletionProgress)
+		**out = **in
+	}
+	if in.ProviderStatus != nil {
+		in, out := &in.ProviderStatus, &out.ProviderStatus
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.InstanceStates != nil {
+		in, out := &in.InstanceStates, &out.InstanceStates
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.InstanceFailureDomains != nil {
+		in, out := &in.InstanceFailureDomains, &out.InstanceFailureDomains
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.FailureDomainCoverage != nil {
+		in, out := &in.FailureDomainCoverage, &out.FailureDomainCoverage
+		*out = make([]MachinePoolFailureDomainCoverage, len(*in))
+		copy(*out, *in)
+	}
+	if in.InstanceImages != nil {
+		in, out := &in.InstanceImages, &out.InstanceImages
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.InfrastructureConditions != nil {
+		in, out := &in.InfrastructureConditions, &out.InfrastructureConditions
+		*out = make(apiv1alpha3.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ScaleDownCandidates != nil {
+		in, out := &in.ScaleDownCandidates, &out.ScaleDownCandidates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodeStatuses != nil {
+		in, out := &in.NodeStatuses, &out.NodeStatuses
+		*out = make([]MachinePoolNodeStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodeDrains != nil {
+		in, out := &in.NodeDrains, &out.NodeDrains
+		*out = make([]MachinePoolNodeDrain, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachinePoolStatus.
@@ -156,3 +402,34 @@ func (in *MachinePoolStatus) DeepCopy() *MachinePoolStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachinePoolStrategy) DeepCopyInto(out *MachinePoolStrategy) {
+	*out = *in
+	in.MachineDeploymentStrategy.DeepCopyInto(&out.MachineDeploymentStrategy)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachinePoolStrategy.
+func (in *MachinePoolStrategy) DeepCopy() *MachinePoolStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(MachinePoolStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StatusFieldMapping) DeepCopyInto(out *StatusFieldMapping) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StatusFieldMapping.
+func (in *StatusFieldMapping) DeepCopy() *StatusFieldMapping {
+	if in == nil {
+		return nil
+	}
+	out := new(StatusFieldMapping)
+	in.DeepCopyInto(out)
+	return out
+}
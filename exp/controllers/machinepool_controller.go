@@ -18,18 +18,30 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/external"
 	"sigs.k8s.io/cluster-api/controllers/remote"
@@ -37,6 +49,7 @@ import (
 	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -44,11 +57,14 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;patch
-// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;patch;delete
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=exp.infrastructure.cluster.x-k8s.io;infrastructure.cluster.x-k8s.io;bootstrap.cluster.x-k8s.io,resources=*,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=exp.cluster.x-k8s.io,resources=machinepools;machinepools/status,verbs=get;list;watch;create;update;patch;delete
@@ -58,11 +74,222 @@ type MachinePoolReconciler struct {
 	Client client.Client
 	Log    logr.Logger
 
+	// InfrastructureMissingGracePeriod is how long the infrastructure object of a ready MachinePool
+	// can be missing before the MachinePool is marked as failed. Defaults to one minute.
+	InfrastructureMissingGracePeriod time.Duration
+
+	// AdoptControlledExternalObjects, if true, makes MachinePools take over the bootstrap and infrastructure objects
+	// they reference when these are controlled by another object. Reconciling such MachinePools fails otherwise.
+	AdoptControlledExternalObjects bool
+
+	// ClusterOwnerReferences, if true, adds a non-controller owner reference to their Cluster on the bootstrap and
+	// infrastructure objects of MachinePools, in addition to the controller reference to the MachinePool, for
+	// tooling expecting these objects to reference their Cluster directly.
+	ClusterOwnerReferences bool
+
+	// ImmutableTemplatePaths are the dotted paths, relative to Spec.Template, of the fields of the template of
+	// MachinePools which can't change once their infrastructure has been ready. Changes are also rejected by the
+	// MachinePoolValidator, configured with the same paths.
+	ImmutableTemplatePaths []string
+
+	// ValidateBootstrapDataSecrets, if true, only marks the bootstrap of a MachinePool ready once its
+	// bootstrap data secret holds a non-empty value.
+	ValidateBootstrapDataSecrets bool
+
+	// StatusProviderIDListKinds are the kinds of infrastructure objects which publish the provider IDs of their
+	// instances under status.providerIDList, which is read if spec.providerIDList isn't set.
+	StatusProviderIDListKinds []schema.GroupVersionKind
+
+	// InfrastructureConditionTypes are the types of the conditions of infrastructure objects copied to
+	// Status.InfrastructureConditions. Defaults to Ready.
+	InfrastructureConditionTypes []clusterv1.ConditionType
+
+	// InfrastructureReplicasKinds are the kinds of infrastructure objects which read the desired number of
+	// instances from their spec.replicas, to which Spec.Replicas is written.
+	InfrastructureReplicasKinds []schema.GroupVersionKind
+
+	// CorrectReplicasDrift, if true, writes Spec.Replicas back to spec.replicas of infrastructure objects of other
+	// kinds than the InfrastructureReplicasKinds when it diverges. The drift is only reported otherwise.
+	CorrectReplicasDrift bool
+
+	// OverProvisionedMargin, if positive, is the number of instances the infrastructure provider of a MachinePool can
+	// report beyond Spec.Replicas before the MachinePool is flagged with the OverProvisioned condition and a warning
+	// event. The instances aren't scaled down by the controller.
+	OverProvisionedMargin int
+
+	// BootstrapReadyWait is how long to wait before checking again on the bootstrap of a MachinePool which
+	// isn't ready. Defaults to 30 seconds.
+	BootstrapReadyWait time.Duration
+
+	// InfrastructureReadyWait is how long to wait before checking again on the infrastructure of a MachinePool
+	// which isn't ready. Defaults to 30 seconds.
+	InfrastructureReadyWait time.Duration
+
+	// PreProvisionWebhookURL, if set, is called with the MachinePool before its infrastructure is provisioned for
+	// the first time. The infrastructure is only reconciled once the webhook approved it with a 200 response.
+	PreProvisionWebhookURL string
+
+	// AllowedProviderIDPrefixes, if set, are the prefixes, e.g. of the cloud accounts or regions the infrastructure
+	// is expected in, one of which the provider IDs of every MachinePool must start with. Other provider IDs are
+	// ignored and the MachinePool is flagged, to catch infrastructure reporting instances it doesn't own.
+	AllowedProviderIDPrefixes []string
+
+	// CredentialsSecretPath, if set, is the dotted path of the field of infrastructure objects referencing their
+	// credentials secret, either by name or with a name and a namespace, e.g. "spec.identityRef". The secret is
+	// checked to exist, so that missing credentials are reported rather than the infrastructure never being ready.
+	CredentialsSecretPath string
+
+	// PropagatedAnnotationPrefixes, if set, are the prefixes of the annotations of MachinePools, e.g. cost allocation
+	// annotations, which are propagated to their Nodes. Propagated annotations dropped from a MachinePool are
+	// removed from its Nodes.
+	PropagatedAnnotationPrefixes []string
+
+	// UnhealthyNodeConditionType, if set, is the type of the provider specific Node condition, e.g. reported by
+	// a hardware monitor, which signals that the host of a Node failed. Nodes with the condition True are cordoned
+	// and counted in Status.UnhealthyReplicas.
+	UnhealthyNodeConditionType string
+
+	// ReplaceUnhealthyNodes, if true, requests the replacement of the instances of unhealthy Nodes from the
+	// infrastructure provider with the ReplacementRequestedAnnotation.
+	ReplaceUnhealthyNodes bool
+
+	// ExternalPatchFieldManager, if set, is the field manager under which the Cluster label and the controller
+	// reference of the bootstrap and infrastructure objects of MachinePools are set with server-side apply, instead
+	// of a merge patch, so that the MachinePool only owns these fields and doesn't conflict with other controllers.
+	ExternalPatchFieldManager string
+
+	// RequestProvisioning, if true, requests provisioning of the infrastructure objects of
+	// MachinePools with the ProvisionAnnotation, and only marks them ready once the provider acknowledged it.
+	RequestProvisioning bool
+
+	// TreatMissingReplicasAsZero, if true, sets Status.Replicas to zero instead of waiting for the infrastructure
+	// provider to report replicas and provider IDs when a MachinePool is scaled to zero, for providers which
+	// never set status.replicas in that case.
+	TreatMissingReplicasAsZero bool
+
+	// ReconcileTimeout bounds the time a single reconciliation of a MachinePool can spend on calls to the
+	// API servers and providers, so that a hung call doesn't block a worker. Defaults to five minutes.
+	ReconcileTimeout time.Duration
+
+	// DrainNodesOnTerminationSignal, if true, marks the Nodes of MachinePools with the DrainOnTerminationAnnotation,
+	// and drains the Nodes signaled for termination with the TerminationSignalAnnotation before acknowledging the
+	// signal with the TerminationAcknowledgedAnnotation.
+	DrainNodesOnTerminationSignal bool
+
+	// NodeRefsCountOnly, if true, only stores the number of Nodes referenced by a MachinePool in
+	// Status.NodeRefsCount and leaves Status.NodeRefs empty, to keep the status of large MachinePools small.
+	NodeRefsCountOnly bool
+
+	// DrainFailureDomainsSequentially, if true, makes MachinePools drain and delete the Nodes they are
+	// removing one failure domain at a time, as read from the zone labels of the Nodes, so that deleting or
+	// scaling down a MachinePool spread across zones doesn't remove capacity from all of them at once.
+	// The Nodes of a MachinePool being deleted are still all cordoned up front.
+	DrainFailureDomainsSequentially bool
+
+	// RebalanceFailureDomains, if true, communicates to the infrastructure provider of a MachinePool being scaled up
+	// the failure domains to create the new instances in, favoring the least populated failure domains of its
+	// Cluster, with the FailureDomainDistributionAnnotation.
+	RebalanceFailureDomains bool
+
+	// DrainRetiredNodes, if true, drains the Nodes whose instances were removed from a MachinePool, or of a deleted
+	// MachinePool, before deleting them, within the Spec.NodeDrainTimeout of the MachinePool.
+	DrainRetiredNodes bool
+
+	// NodeDrainGracePeriod, if positive, overrides the termination grace period of the pods evicted when draining
+	// Nodes. The grace period of each pod is honored otherwise.
+	NodeDrainGracePeriod time.Duration
+
+	// NodeDrainPodTimeout bounds the time a single drain attempt waits for the evicted pods to terminate before
+	// requeueing the MachinePool, so that other MachinePools get reconciled meanwhile. Defaults to 20 seconds.
+	NodeDrainPodTimeout time.Duration
+
+	// NodeReadinessGate, if set, is the key of a label or annotation Nodes must carry, in addition to being Ready,
+	// to be counted in the ready replicas of a MachinePool, e.g. set by a post-join verification on the Nodes.
+	NodeReadinessGate string
+
+	// NodeStatusesLimit, if positive, reports the readiness of up to that many Nodes of each MachinePool in
+	// Status.NodeStatuses.
+	NodeStatusesLimit int
+
+	// NodeReadBudget, if positive, caps the number of Nodes whose taints and cordon state are reconciled by a single
+	// reconciliation of a MachinePool. The Nodes are listed from the workload cluster once per reconciliation, and
+	// the Nodes of larger MachinePools are reconciled in chunks over successive reconciliations, the progress being
+	// tracked in Status.ReconciledNodes.
+	NodeReadBudget int
+
+	// PhaseDisplayNames maps phases to the names written to Status.DisplayPhase, e.g. to keep
+	// spellings expected by existing tooling. Phases which aren't mapped are displayed as is.
+	PhaseDisplayNames map[expv1.MachinePoolPhase]string
+
+	// PhaseEvaluator, if set, decides the phase of MachinePools from the phase computed by the controller,
+	// e.g. to align the notion of Running with an infrastructure provider. The computed phase is used otherwise.
+	PhaseEvaluator PhaseEvaluator
+
+	// PhaseHooks are called, in order, when the phase of a MachinePool changes.
+	PhaseHooks []PhaseHook
+
+	// PhaseHookErrorsBlockReconcile, if true, fails the reconciliation of a MachinePool when one of the
+	// PhaseHooks returns an error. Errors returned by the hooks are only logged otherwise.
+	PhaseHookErrorsBlockReconcile bool
+
+	// Tracer, if set, is used to create spans around the reconciliation phases.
+	// Tracing is disabled when nil.
+	Tracer Tracer
+
 	config           *rest.Config
 	controller       controller.Controller
 	recorder         record.EventRecorder
 	externalWatchers sync.Map
 	scheme           *runtime.Scheme
+
+	// cachedReader reads external objects from the manager's informer cache
+	// once a watch for their kind has been established.
+	cachedReader client.Reader
+
+	// apiReader reads objects which aren't cached from the API server, e.g. the pause ConfigMap and the MachinePool
+	// CustomResourceDefinition. Defaults to Client.
+	apiReader client.Reader
+
+	// remoteClientGetter returns a client for the workload cluster, defaults to remote.NewClusterClient.
+	remoteClientGetter remote.ClusterClientGetter
+
+	// kubeClientGetter returns a clientset for the workload cluster, used to drain Nodes. Defaults to newKubeClient.
+	kubeClientGetter kubeClientGetter
+
+	// clock is used to read the current time, defaults to the real clock.
+	clock clock.Clock
+
+	// reconcileErrorEvents holds the last reconcile error event recorded for each MachinePool,
+	// keyed by namespaced name, to throttle identical events.
+	reconcileErrorEvents sync.Map
+
+	// staleNodeRefsChecks holds the time the NodeRefs of each MachinePool were last checked for staleness,
+	// keyed by namespaced name, to rate-limit the checks.
+	staleNodeRefsChecks sync.Map
+
+	// reconcileStates holds the reconcileState of each MachinePool, keyed by namespaced name, for the metrics.
+	reconcileStates sync.Map
+
+	// paused is set to 1 while reconciliation is paused by the pause ConfigMap, so that the pause and
+	// resume are only logged once.
+	paused int32
+
+	// crdTerminating is set to 1 while the MachinePool CustomResourceDefinition is being deleted, so that the
+	// halt and resume are only logged once.
+	crdTerminating int32
+
+	// crdCheckLock guards crdCheckedAt, the time the MachinePool CustomResourceDefinition was last read, to
+	// rate-limit the reads.
+	crdCheckLock sync.Mutex
+	crdCheckedAt time.Time
+
+	// throttleLock guards throttleBackoff and throttledUntil.
+	throttleLock sync.Mutex
+
+	// throttleBackoff is the global backoff applied while the management cluster API server rejects requests
+	// with 429 responses, and throttledUntil the time until which reconciliations are deferred.
+	throttleBackoff time.Duration
+	throttledUntil  time.Time
 }
 
 func (r *MachinePoolReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
@@ -90,40 +317,126 @@ func (r *MachinePoolReconciler) SetupWithManager(mgr ctrl.Manager, options contr
 	if err != nil {
 		return errors.Wrap(err, "failed adding Watch for Cluster to controller manager")
 	}
+	pauseInformer, err := newPauseConfigMapInformer(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	err = mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		pauseInformer.Run(stop)
+		return nil
+	}))
+	if err != nil {
+		return errors.Wrap(err, "failed adding pause ConfigMap informer to controller manager")
+	}
+	err = c.Watch(
+		&source.Informer{Informer: pauseInformer},
+		&handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.pauseConfigMapToMachinePools),
+		},
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed adding Watch for ConfigMap to controller manager")
+	}
 
 	r.controller = c
 	r.recorder = mgr.GetEventRecorderFor("machinepool-controller")
 	r.config = mgr.GetConfig()
 	r.scheme = mgr.GetScheme()
+	r.cachedReader = mgr.GetCache()
+	r.apiReader = mgr.GetAPIReader()
+
+	// Warm up the external watchers once elected leader. Failing to do so only delays them to the first
+	// reconciliation of each MachinePool, so errors are logged rather than stopping the manager.
+	err = mgr.Add(manager.RunnableFunc(func(<-chan struct{}) error {
+		if err := r.warmUpExternalWatches(context.Background()); err != nil {
+			r.Log.Error(err, "Failed to warm up external watchers")
+		}
+		return nil
+	}))
+	if err != nil {
+		return errors.Wrap(err, "failed adding external watchers warm-up to controller manager")
+	}
+
+	// Keep the time since the last successful reconciliation growing for MachinePools which aren't reconciled.
+	err = mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		wait.Until(r.updateReconcileMetrics, reconcileMetricsInterval, stop)
+		return nil
+	}))
+	if err != nil {
+		return errors.Wrap(err, "failed adding reconcile metrics ticker to controller manager")
+	}
 	return nil
 }
 
-func (r *MachinePoolReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr error) {
-	ctx := context.Background()
+func (r *MachinePoolReconciler) Reconcile(req ctrl.Request) (res ctrl.Result, reterr error) {
+	ctx, span := r.startSpan(context.Background(), "Reconcile")
+	defer span.End()
 	logger := r.Log.WithValues("machinepool", req.NamespacedName)
 
+	// Back off globally while the management cluster API server is overloaded.
+	if wait := r.apiThrottleWait(); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+	defer func() {
+		res, reterr = r.throttleRequeue(res, reterr)
+	}()
+
+	paused, err := r.isReconcilePaused(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if paused {
+		return ctrl.Result{RequeueAfter: pausedRequeueWait}, nil
+	}
+
+	// Stop cleanly while the MachinePool CRD is being deleted, the controller is going away with it.
+	terminating, err := r.isCRDTerminating(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if terminating {
+		return ctrl.Result{}, nil
+	}
+
+	reconcileTimeout := r.ReconcileTimeout
+	if reconcileTimeout == 0 {
+		reconcileTimeout = defaultReconcileTimeout
+	}
+	// The MachinePool is patched with the parent context below, so that it is still patched after a timeout.
+	reconcileCtx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
 	mp := &expv1.MachinePool{}
-	if err := r.Client.Get(ctx, req.NamespacedName, mp); err != nil {
+	if err := r.Client.Get(reconcileCtx, req.NamespacedName, mp); err != nil {
 		if apierrors.IsNotFound(err) {
 			// Object not found, return. Created objects are automatically garbage collected.
 			// For additional cleanup logic use finalizers.
+			r.forgetReconcileMetrics(req.NamespacedName)
+			r.reconcileErrorEvents.Delete(req.NamespacedName.String())
+			r.staleNodeRefsChecks.Delete(req.NamespacedName.String())
 			return ctrl.Result{}, nil
 		}
 		logger.Error(err, "Error reading the object - requeue the request.")
 		return ctrl.Result{}, err
 	}
 
-	cluster, err := util.GetClusterByName(ctx, r.Client, mp.ObjectMeta.Namespace, mp.Spec.ClusterName)
+	// Return early if reconciliation of the object is skipped, only reporting why.
+	if reason, ok := mp.Annotations[expv1.SkipReconcileAnnotation]; ok {
+		logger.Info("Reconciliation is skipped for this object", "reason", reason)
+		return ctrl.Result{}, r.reconcileSkipped(ctx, mp, reason)
+	}
+
+	cluster, err := util.GetClusterByName(reconcileCtx, r.Client, mp.ObjectMeta.Namespace, mp.Spec.ClusterName)
 	if err != nil {
 		logger.Error(err, "Failed to get Cluster %s for MachinePool.", mp.Spec.ClusterName)
 		return ctrl.Result{}, errors.Wrapf(err, "failed to get cluster %q for machinepool %q in namespace %q",
 			mp.Spec.ClusterName, mp.Name, mp.Namespace)
 	}
 
-	// Return early if the object or Cluster is paused.
+	// Return early if the object or Cluster is paused, only reporting why freshly created MachinePools are Pending.
 	if annotations.IsPaused(cluster, mp) {
 		logger.Info("Reconciliation is paused for this object")
-		return ctrl.Result{}, nil
+		return ctrl.Result{}, r.reconcilePausedPending(ctx, cluster, mp)
 	}
 
 	// Initialize the patch helper.
@@ -131,11 +444,29 @@ func (r *MachinePoolReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, rete
 	if err != nil {
 		return ctrl.Result{}, err
 	}
+	// Keep the conditions as they were before the reconciliation, to record their transitions.
+	initial := mp.DeepCopy()
+	conditions.Delete(mp, expv1.ReconcileSkippedCondition)
 
 	defer func() {
-		r.reconcilePhase(mp)
+		reconcileReadyConditions(mp)
+
+		phaseCtx, phaseSpan := r.startSpan(ctx, "reconcilePhase")
+		if err := r.reconcilePhase(phaseCtx, mp); err != nil {
+			reterr = kerrors.NewAggregate([]error{reterr, err})
+		}
+		reconcilePendingCondition(cluster, mp)
+		phaseSpan.End()
+		r.recordConditionTransitions(initial, mp)
 		// TODO(jpang): add support for metrics.
 
+		// Record reconciliations which succeeded, including the ones requeued e.g. to wait for dependencies.
+		if reterr == nil {
+			r.reconcileLastReconcileTime(mp)
+		}
+
+		reconcileLastError(mp, reterr)
+
 		// Always attempt to patch the object and status after each reconciliation.
 		// Patch ObservedGeneration only if the reconciliation completed successfully
 		patchOpts := []patch.Option{}
@@ -145,6 +476,9 @@ func (r *MachinePoolReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, rete
 		if err := patchHelper.Patch(ctx, mp, patchOpts...); err != nil {
 			reterr = kerrors.NewAggregate([]error{reterr, err})
 		}
+
+		r.recordReconcileError(mp, reterr)
+		r.recordReconcileMetrics(req.NamespacedName, reterr == nil && !res.Requeue && res.RequeueAfter == 0)
 	}()
 
 	// Reconcile labels.
@@ -161,17 +495,33 @@ func (r *MachinePoolReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, rete
 
 	// Handle deletion reconciliation loop.
 	if !mp.ObjectMeta.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(ctx, cluster, mp)
+		return r.reconcileDelete(reconcileCtx, cluster, mp)
 	}
 
 	// Handle normal reconciliation loop.
-	return r.reconcile(ctx, cluster, mp)
+	return r.reconcile(reconcileCtx, cluster, mp)
 }
 
 func (r *MachinePoolReconciler) reconcile(ctx context.Context, cluster *clusterv1.Cluster, mp *expv1.MachinePool) (ctrl.Result, error) {
 	logger := r.Log.WithValues("machinepool", mp.Name, "namespace", mp.Namespace)
 	logger = logger.WithValues("cluster", cluster.Name)
 
+	// Let an owner being deleted, e.g. a MachineDeployment-like parent, drive the deletion of the MachinePool rather
+	// than racing with it by mutating the spec of the MachinePool and of its external objects.
+	owner, err := r.deletingOwner(ctx, cluster, mp)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if owner != nil {
+		logger.Info("Owner of MachinePool is being deleted, skipping reconciliation", "owner", owner.Kind+"/"+owner.Name)
+		return ctrl.Result{}, nil
+	}
+
+	// Refuse to roll the MachinePool to a template its infrastructure provider can't apply.
+	if err := r.reconcileImmutableTemplateFields(mp); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// Ensure the MachinePool is owned by the Cluster it belongs to.
 	mp.OwnerReferences = util.EnsureOwnerRef(mp.OwnerReferences, metav1.OwnerReference{
 		APIVersion: cluster.APIVersion,
@@ -180,13 +530,32 @@ func (r *MachinePoolReconciler) reconcile(ctx context.Context, cluster *clusterv
 		UID:        cluster.UID,
 	})
 
+	r.reconcileTopologyManaged(mp)
+	r.reconcileReplicasDefaulted(mp)
+	r.reconcileMinReplicas(mp)
+	r.reconcileSelector(mp)
+
 	// Call the inner reconciliation methods.
+	var bootstrapErr error
+	if r.isSteadyState(mp) {
+		// The bootstrap data only changes when rotated by the bootstrap provider, otherwise only refresh the
+		// replica status and the Node references.
+		bootstrapErr = r.reconcileBootstrapRotation(ctx, cluster, mp)
+	} else {
+		bootstrapErr = r.reconcileBootstrap(ctx, cluster, mp)
+	}
+	infrastructureErr := r.reconcileInfrastructure(ctx, cluster, mp)
 	reconciliationErrors := []error{
-		r.reconcileBootstrap(ctx, cluster, mp),
-		r.reconcileInfrastructure(ctx, cluster, mp),
+		bootstrapErr,
+		infrastructureErr,
 		r.reconcileNodeRefs(ctx, cluster, mp),
 	}
 
+	// The providers provision new instances with the version of the spec once it was reconciled with both ready.
+	if bootstrapErr == nil && infrastructureErr == nil && mp.Status.BootstrapReady && mp.Status.InfrastructureReady {
+		mp.Status.Version = mp.Spec.Template.Spec.Version
+	}
+
 	// Parse the errors, making sure we record if there is a RequeueAfterError.
 	res := ctrl.Result{}
 	errs := []error{}
@@ -206,39 +575,436 @@ func (r *MachinePoolReconciler) reconcile(ctx context.Context, cluster *clusterv
 	return res, kerrors.NewAggregate(errs)
 }
 
+// deletingOwner returns the owner reference of a MachinePool to an owner being deleted, if any. The Cluster of the
+// MachinePool is not considered, its deletion is handled by deleting the MachinePool. Owners which are already gone
+// are left to the garbage collector, and owners of kinds the controller can't read, e.g. without RBAC for them, are
+// considered not being deleted rather than blocking the reconciliation of the MachinePool.
+func (r *MachinePoolReconciler) deletingOwner(ctx context.Context, cluster *clusterv1.Cluster, mp *expv1.MachinePool) (*metav1.OwnerReference, error) {
+	for i := range mp.OwnerReferences {
+		ref := &mp.OwnerReferences[i]
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse owner reference %s %q of MachinePool %q in namespace %q", ref.Kind, ref.Name, mp.Name, mp.Namespace)
+		}
+		if gv.Group == clusterv1.GroupVersion.Group && ref.Kind == "Cluster" && ref.Name == cluster.Name {
+			continue
+		}
+
+		owner := &unstructured.Unstructured{}
+		owner.SetGroupVersionKind(gv.WithKind(ref.Kind))
+		if err := r.Client.Get(ctx, client.ObjectKey{Namespace: mp.Namespace, Name: ref.Name}, owner); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if apierrors.IsForbidden(err) || meta.IsNoMatchError(err) {
+				r.Log.V(2).Info("Can't read owner of MachinePool, assuming it isn't being deleted", "machinepool", mp.Name,
+					"namespace", mp.Namespace, "owner", ref.Kind+"/"+ref.Name, "err", err)
+				continue
+			}
+			return nil, errors.Wrapf(err, "failed to get owner %s %q of MachinePool %q in namespace %q", ref.Kind, ref.Name, mp.Name, mp.Namespace)
+		}
+		if owner.GetDeletionTimestamp() != nil {
+			return ref, nil
+		}
+	}
+	return nil, nil
+}
+
+// reconcileSkipped sets the ReconcileSkippedCondition of a MachinePool annotated with the SkipReconcileAnnotation
+// to the given reason, and patches it if it changed. Nothing else is mutated.
+func (r *MachinePoolReconciler) reconcileSkipped(ctx context.Context, mp *expv1.MachinePool, reason string) error {
+	if reason == "" {
+		reason = "No reason given"
+	}
+	if conditions.IsTrue(mp, expv1.ReconcileSkippedCondition) && conditions.GetMessage(mp, expv1.ReconcileSkippedCondition) == reason {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(mp, r.Client)
+	if err != nil {
+		return err
+	}
+	conditions.Set(mp, &clusterv1.Condition{
+		Type:    expv1.ReconcileSkippedCondition,
+		Status:  corev1.ConditionTrue,
+		Reason:  expv1.SkipReconcileRequestedReason,
+		Message: reason,
+	})
+	return patchHelper.Patch(ctx, mp)
+}
+
+// reconcileLastReconcileTime sets Status.LastReconcileTime to the current time. It is only updated once it is older
+// than lastReconcileTimeResolution, so that patching it doesn't cause the MachinePool to be reconciled again and again.
+func (r *MachinePoolReconciler) reconcileLastReconcileTime(mp *expv1.MachinePool) {
+	now := metav1.NewTime(r.now())
+	if mp.Status.LastReconcileTime != nil && now.Sub(mp.Status.LastReconcileTime.Time) < lastReconcileTimeResolution {
+		return
+	}
+	mp.Status.LastReconcileTime = &now
+}
+
+// reconcileLastError sets Status.LastError to the message of the error the reconciliation failed with,
+// truncated to maxLastErrorLength, and clears it when the reconciliation succeeded.
+func reconcileLastError(mp *expv1.MachinePool, err error) {
+	if err == nil {
+		mp.Status.LastError = ""
+		return
+	}
+	message := err.Error()
+	if len(message) > maxLastErrorLength {
+		message = message[:maxLastErrorLength-3] + "..."
+	}
+	mp.Status.LastError = message
+}
+
+// eventedConditionTypes are the conditions of a MachinePool whose transitions are recorded as events.
+var eventedConditionTypes = []clusterv1.ConditionType{
+	clusterv1.ReadyCondition,
+	clusterv1.InfrastructureReadyCondition,
+	clusterv1.BootstrapReadyCondition,
+}
+
+// reconcileReadyConditions sets the BootstrapReady and InfrastructureReady conditions from the status of the
+// MachinePool, and the Ready condition to their summary.
+func reconcileReadyConditions(mp *expv1.MachinePool) {
+	if mp.Status.BootstrapReady {
+		conditions.MarkTrue(mp, clusterv1.BootstrapReadyCondition)
+	} else if !conditions.IsFalse(mp, clusterv1.BootstrapReadyCondition) {
+		// Keep the reason reported by the bootstrap provider, if any.
+		conditions.MarkFalse(mp, clusterv1.BootstrapReadyCondition, clusterv1.WaitingForDataSecretFallbackReason, clusterv1.ConditionSeverityInfo, "")
+	}
+	if mp.Status.InfrastructureReady {
+		conditions.MarkTrue(mp, clusterv1.InfrastructureReadyCondition)
+	} else {
+		conditions.MarkFalse(mp, clusterv1.InfrastructureReadyCondition, clusterv1.WaitingForInfrastructureFallbackReason, clusterv1.ConditionSeverityInfo, "")
+	}
+
+	conditions.SetSummary(mp,
+		conditions.WithConditions(
+			clusterv1.BootstrapReadyCondition,
+			clusterv1.InfrastructureReadyCondition,
+		),
+		conditions.WithStepCounterIfOnly(
+			clusterv1.BootstrapReadyCondition,
+			clusterv1.InfrastructureReadyCondition,
+		),
+	)
+}
+
+// recordConditionTransitions records an event for each of the eventedConditionTypes whose status flipped between
+// True and False since the given copy of the MachinePool: a Normal event when it became True, a Warning event,
+// including the reason, when it became False.
+func (r *MachinePoolReconciler) recordConditionTransitions(initial, mp *expv1.MachinePool) {
+	for _, t := range eventedConditionTypes {
+		before, after := conditions.Get(initial, t), conditions.Get(mp, t)
+		if before == nil || after == nil || before.Status == after.Status {
+			continue
+		}
+
+		switch {
+		case before.Status == corev1.ConditionFalse && after.Status == corev1.ConditionTrue:
+			r.recorder.Eventf(mp, corev1.EventTypeNormal, string(t), "Condition %s changed from False to True", t)
+		case before.Status == corev1.ConditionTrue && after.Status == corev1.ConditionFalse:
+			r.recorder.Eventf(mp, corev1.EventTypeWarning, string(t), "Condition %s changed from True to False: %s", t, after.Reason)
+		}
+	}
+}
+
+// reconcileErrorEvent is the last reconcile error event recorded for a MachinePool.
+type reconcileErrorEvent struct {
+	reason    string
+	message   string
+	timestamp time.Time
+}
+
+// recordReconcileError records a warning event for an error returned by the reconciliation of a MachinePool.
+// An event with the same reason and message as the previous one is only recorded again after
+// reconcileErrorEventInterval, so that a persistent error doesn't flood the events of the MachinePool.
+func (r *MachinePoolReconciler) recordReconcileError(mp *expv1.MachinePool, err error) {
+	key := util.ObjectKey(mp).String()
+	if err == nil {
+		r.reconcileErrorEvents.Delete(key)
+		return
+	}
+
+	event := reconcileErrorEvent{
+		reason:    "ReconcileError",
+		message:   err.Error(),
+		timestamp: r.now(),
+	}
+	if last, ok := r.reconcileErrorEvents.Load(key); ok {
+		last := last.(reconcileErrorEvent)
+		if last.reason == event.reason && last.message == event.message && event.timestamp.Sub(last.timestamp) < reconcileErrorEventInterval {
+			return
+		}
+	}
+
+	r.recorder.Event(mp, corev1.EventTypeWarning, event.reason, event.message)
+	r.reconcileErrorEvents.Store(key, event)
+}
+
+// now returns the current time according to the clock of the reconciler.
+func (r *MachinePoolReconciler) now() time.Time {
+	if r.clock == nil {
+		return clock.RealClock{}.Now()
+	}
+	return r.clock.Now()
+}
+
+// reconcileTopologyManaged sets Status.TopologyManaged from the TopologyOwnedLabel of the MachinePool.
+func (r *MachinePoolReconciler) reconcileTopologyManaged(mp *expv1.MachinePool) {
+	_, mp.Status.TopologyManaged = mp.Labels[expv1.TopologyOwnedLabel]
+}
+
+// reconcileReplicasDefaulted sets the ReplicasDefaultedCondition while Spec.Replicas still has the value defaulted
+// by the webhook, as recorded by the ReplicasDefaultedAnnotation. Once the replicas are changed, the annotation and
+// the condition are removed.
+func (r *MachinePoolReconciler) reconcileReplicasDefaulted(mp *expv1.MachinePool) {
+	defaulted, ok := mp.Annotations[expv1.ReplicasDefaultedAnnotation]
+	if ok && mp.Spec.Replicas != nil && defaulted == strconv.Itoa(int(*mp.Spec.Replicas)) {
+		conditions.MarkTrue(mp, expv1.ReplicasDefaultedCondition)
+		return
+	}
+
+	delete(mp.Annotations, expv1.ReplicasDefaultedAnnotation)
+	conditions.Delete(mp, expv1.ReplicasDefaultedCondition)
+}
+
+// reconcileMinReplicas raises Spec.Replicas to Spec.MinReplicas if it was set below it. The replicas of
+// topology managed MachinePools are left to the topology controller, and externally managed replicas to their
+// external system.
+func (r *MachinePoolReconciler) reconcileMinReplicas(mp *expv1.MachinePool) {
+	if mp.Status.TopologyManaged || replicasExternallyManaged(mp) {
+		return
+	}
+	if mp.Spec.MinReplicas == nil || mp.Spec.Replicas == nil || *mp.Spec.Replicas >= *mp.Spec.MinReplicas {
+		return
+	}
+
+	r.recorder.Eventf(mp, corev1.EventTypeWarning, "ReplicasBelowMinimum",
+		"Requested %d replicas is below the minimum of %d, scaling to %d replicas instead", *mp.Spec.Replicas, *mp.Spec.MinReplicas, *mp.Spec.MinReplicas)
+	mp.Spec.Replicas = pointer.Int32Ptr(*mp.Spec.MinReplicas)
+}
+
+// reconcileImmutableTemplateFields records the ImmutableTemplatePaths of the template of a MachinePool in the
+// ImmutableTemplateFieldsAnnotation once its infrastructure is ready, and fails if they changed since, e.g. while the
+// webhook wasn't running.
+func (r *MachinePoolReconciler) reconcileImmutableTemplateFields(mp *expv1.MachinePool) error {
+	fields, err := expv1.ImmutableTemplateFields(&mp.Spec.Template, r.ImmutableTemplatePaths)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the immutable template fields of MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+
+	recorded, ok := mp.Annotations[expv1.ImmutableTemplateFieldsAnnotation]
+	if !ok && (!mp.Status.InfrastructureReady || len(fields) == 0) {
+		return nil
+	}
+	if ok {
+		recordedFields := map[string]string{}
+		if err := json.Unmarshal([]byte(recorded), &recordedFields); err != nil {
+			return errors.Wrapf(err, "failed to parse annotation %q of MachinePool %q in namespace %q",
+				expv1.ImmutableTemplateFieldsAnnotation, mp.Name, mp.Namespace)
+		}
+		if changed := expv1.ChangedImmutableTemplatePaths(r.ImmutableTemplatePaths, recordedFields, fields); len(changed) > 0 {
+			return errors.Errorf("template fields %s of MachinePool %q in namespace %q can't change once its infrastructure has been ready, revert them or create a new MachinePool instead",
+				strings.Join(changed, ", "), mp.Name, mp.Namespace)
+		}
+	}
+
+	// Record the fields again, so that paths configured since are recorded too.
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode the immutable template fields of MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+	if mp.Annotations == nil {
+		mp.Annotations = map[string]string{}
+	}
+	mp.Annotations[expv1.ImmutableTemplateFieldsAnnotation] = string(encoded)
+	return nil
+}
+
+// reconcileSelector sets Status.Selector, used by the scale subresource, to the label selector
+// matching the objects of the MachinePool.
+func (r *MachinePoolReconciler) reconcileSelector(mp *expv1.MachinePool) {
+	mp.Status.Selector = labels.SelectorFromSet(labels.Set{
+		clusterv1.ClusterLabelName: mp.Spec.ClusterName,
+		expv1.MachinePoolNameLabel: mp.Name,
+	}).String()
+}
+
+// isSteadyState returns true if both the bootstrap and the infrastructure of a MachinePool are ready, its spec
+// didn't change since it was last reconciled, and no previous reconciliation is waiting for the infrastructure
+// to reappear. At steady state, the external objects have already been adopted and labeled and don't need to be
+// patched again.
+func (r *MachinePoolReconciler) isSteadyState(mp *expv1.MachinePool) bool {
+	if !mp.Status.BootstrapReady || !mp.Status.InfrastructureReady {
+		return false
+	}
+	if mp.Generation != mp.Status.ObservedGeneration {
+		return false
+	}
+	if _, ok := mp.Annotations[expv1.InfrastructureMissingSinceAnnotation]; ok {
+		return false
+	}
+
+	// Changes to the infrastructure object are only noticed once it is being watched, e.g. not after a restart.
+	_, watched := r.externalWatchers.Load(mp.Spec.Template.Spec.InfrastructureRef.GroupVersionKind().String())
+	return watched
+}
+
 func (r *MachinePoolReconciler) reconcileDelete(ctx context.Context, cluster *clusterv1.Cluster, mp *expv1.MachinePool) (ctrl.Result, error) {
+	// Track what is left to delete, so that users can understand why a deletion is not progressing.
+	if mp.Status.DeletionProgress == nil {
+		mp.Status.DeletionProgress = &expv1.MachinePoolDeletionProgress{}
+	}
+	mp.Status.DeletionProgress.NodesRemaining = int32(nodeRefsCount(mp))
+
+	// Stop scheduling new Pods on the Nodes of the MachinePool as soon as it is being deleted.
+	if err := r.cordonNodes(ctx, cluster, mp); err != nil {
+		// Cordoning is best effort, the Nodes are deleted along with the MachinePool regardless.
+		r.Log.Error(err, "Failed to cordon the Nodes of the MachinePool", "machinepool", mp.Name, "namespace", mp.Namespace)
+	}
+
 	if ok, err := r.reconcileDeleteExternal(ctx, mp); !ok || err != nil {
+		// Requeue to retry the deletion of external objects which failed to be deleted.
+		if requeueErr, ok := errors.Cause(err).(capierrors.HasRequeueAfterError); ok {
+			r.Log.Info("Deletion of MachinePool external objects asked to requeue", "machinepool", mp.Name, "namespace", mp.Namespace, "reason", err.Error())
+			return ctrl.Result{RequeueAfter: requeueErr.GetRequeueAfter()}, nil
+		}
 		// Return early and don't remove the finalizer if we got an error or
 		// the external reconciliation deletion isn't ready.
 		return ctrl.Result{}, err
 	}
 
 	if err := r.reconcileDeleteNodes(ctx, cluster, mp); err != nil {
+		// Requeue while the Nodes of the remaining failure domains are waiting to be drained.
+		if requeueErr, ok := errors.Cause(err).(capierrors.HasRequeueAfterError); ok {
+			r.Log.Info("Deletion of MachinePool Nodes asked to requeue", "machinepool", mp.Name, "namespace", mp.Namespace, "reason", err.Error())
+			return ctrl.Result{RequeueAfter: requeueErr.GetRequeueAfter()}, nil
+		}
 		// Return early and don't remove the finalizer if we got an error.
 		return ctrl.Result{}, err
 	}
+	mp.Status.DeletionProgress.NodesRemaining = 0
 
 	controllerutil.RemoveFinalizer(mp, expv1.MachinePoolFinalizer)
 	return ctrl.Result{}, nil
 }
 
 func (r *MachinePoolReconciler) reconcileDeleteNodes(ctx context.Context, cluster *clusterv1.Cluster, machinepool *expv1.MachinePool) error {
-	if len(machinepool.Status.NodeRefs) == 0 {
+	if nodeRefsCount(machinepool) == 0 {
 		return nil
 	}
 
-	clusterClient, err := remote.NewClusterClient(ctx, r.Client, util.ObjectKey(cluster), r.scheme)
+	clusterClient, err := r.getRemoteClient(ctx, cluster, machinepool)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := listNodes(ctx, clusterClient)
 	if err != nil {
 		return err
 	}
 
-	if err := r.deleteRetiredNodes(ctx, clusterClient, machinepool.Status.NodeRefs, machinepool.Spec.ProviderIDList); err != nil {
+	nodeRefs := r.getNodeRefs(machinepool, nodes)
+	if err := r.deleteRetiredNodes(ctx, clusterClient, cluster, machinepool, nodes, nodeRefs, machinepool.Spec.ProviderIDList); err != nil {
 		return err
 	}
 	return nil
 }
 
+// cordonNodes marks the Nodes referenced by a MachinePool as unschedulable.
+// Nodes which are already cordoned or which don't exist anymore are skipped. All the Nodes are cordoned,
+// even when failure domains are drained sequentially, so that pods aren't rescheduled onto Nodes about to be drained.
+func (r *MachinePoolReconciler) cordonNodes(ctx context.Context, cluster *clusterv1.Cluster, mp *expv1.MachinePool) error {
+	if nodeRefsCount(mp) == 0 {
+		return nil
+	}
+
+	clusterClient, err := r.getRemoteClient(ctx, cluster, mp)
+	if err != nil {
+		return err
+	}
+
+	allNodes, err := listNodes(ctx, clusterClient)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range referencedNodes(allNodes, r.getNodeRefs(mp, allNodes)) {
+		if node.Spec.Unschedulable {
+			continue
+		}
+
+		patchBase := client.MergeFrom(node.DeepCopy())
+		node.Spec.Unschedulable = true
+		if node.Annotations == nil {
+			node.Annotations = make(map[string]string)
+		}
+		node.Annotations[expv1.CordonedAnnotation] = ""
+		if err := clusterClient.Patch(ctx, node, patchBase); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to cordon Node %q", node.Name)
+		}
+	}
+	return nil
+}
+
+// getRemoteClient returns a client for the workload cluster of the MachinePool,
+// and reports whether it could connect in the RemoteConnected condition.
+func (r *MachinePoolReconciler) getRemoteClient(ctx context.Context, cluster *clusterv1.Cluster, mp *expv1.MachinePool) (client.Client, error) {
+	remoteClientGetter := r.remoteClientGetter
+	if remoteClientGetter == nil {
+		remoteClientGetter = remote.NewClusterClient
+	}
+
+	clusterKey, err := workloadClusterKey(cluster, mp)
+	if err != nil {
+		conditions.MarkFalse(mp, expv1.MachinePoolRemoteConnectedCondition, expv1.RemoteConnectionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return nil, err
+	}
+
+	clusterClient, err := remoteClientGetter(ctx, r.Client, clusterKey, r.scheme)
+	if err != nil {
+		conditions.MarkFalse(mp, expv1.MachinePoolRemoteConnectedCondition, remoteConnectionFailureReason(err), clusterv1.ConditionSeverityWarning, err.Error())
+		return nil, err
+	}
+	conditions.MarkTrue(mp, expv1.MachinePoolRemoteConnectedCondition)
+	return clusterClient, nil
+}
+
+// workloadClusterKey returns the key of the Cluster whose workload cluster hosts the Nodes of the MachinePool,
+// as set in the WorkloadClusterAnnotation, defaulting to the Cluster the MachinePool belongs to. Only Clusters in
+// the namespace of the MachinePool can be set, so that it can't act on the workload clusters of other namespaces.
+func workloadClusterKey(cluster *clusterv1.Cluster, mp *expv1.MachinePool) (client.ObjectKey, error) {
+	name, ok := mp.Annotations[expv1.WorkloadClusterAnnotation]
+	if !ok {
+		return util.ObjectKey(cluster), nil
+	}
+
+	if name == "" || strings.Contains(name, "/") {
+		return client.ObjectKey{}, errors.Errorf("invalid %s annotation %q on MachinePool %q in namespace %q, expected the name of a Cluster in the same namespace",
+			expv1.WorkloadClusterAnnotation, name, mp.Name, mp.Namespace)
+	}
+	return client.ObjectKey{Namespace: mp.Namespace, Name: name}, nil
+}
+
+// remoteConnectionFailureReason returns the reason explaining why connecting to a workload cluster failed.
+func remoteConnectionFailureReason(err error) string {
+	switch {
+	case apierrors.IsNotFound(errors.Cause(err)):
+		return expv1.KubeconfigMissingReason
+	case apierrors.IsUnauthorized(errors.Cause(err)):
+		return expv1.UnauthorizedReason
+	case errors.Is(err, syscall.ECONNREFUSED), strings.Contains(err.Error(), "connection refused"):
+		return expv1.ConnectionRefusedReason
+	default:
+		return expv1.RemoteConnectionFailedReason
+	}
+}
+
 // reconcileDeleteExternal tries to delete external references, returning true if it cannot find any.
+// Failed deletions are retried with an exponential backoff, up to deleteExternalMaxAttempts, after which
+// the DeletionBlocked condition reports that they aren't retried anymore.
 func (r *MachinePoolReconciler) reconcileDeleteExternal(ctx context.Context, m *expv1.MachinePool) (bool, error) {
 	objects := []*unstructured.Unstructured{}
 	references := []*corev1.ObjectReference{
@@ -260,17 +1026,74 @@ func (r *MachinePoolReconciler) reconcileDeleteExternal(ctx context.Context, m *
 		if obj != nil {
 			objects = append(objects, obj)
 		}
+
+		// Record whether the reference still has to be deleted.
+		if m.Status.DeletionProgress != nil {
+			if ref == m.Spec.Template.Spec.Bootstrap.ConfigRef {
+				m.Status.DeletionProgress.BootstrapRemaining = obj != nil
+			} else {
+				m.Status.DeletionProgress.InfrastructureRemaining = obj != nil
+			}
+		}
+	}
+
+	// Stop retrying once the deletions failed too many times, until the attempts are reset.
+	attempts, _ := strconv.Atoi(m.Annotations[expv1.DeleteExternalAttemptsAnnotation])
+	if len(objects) != 0 && attempts >= deleteExternalMaxAttempts {
+		return false, nil
 	}
 
-	// Issue a delete request for any object that has been found.
+	// Issue a delete request for any object that has been found, requeueing with backoff to retry failures.
 	for _, obj := range objects {
 		if err := r.Client.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
-			return false, errors.Wrapf(err,
-				"failed to delete %v %q for MachinePool %q in namespace %q",
-				obj.GroupVersionKind(), obj.GetName(), m.Name, m.Namespace)
+			attempts++
+			if m.Annotations == nil {
+				m.Annotations = make(map[string]string)
+			}
+			m.Annotations[expv1.DeleteExternalAttemptsAnnotation] = strconv.Itoa(attempts)
+
+			if attempts >= deleteExternalMaxAttempts {
+				conditions.Set(m, &clusterv1.Condition{
+					Type:     expv1.DeletionBlockedCondition,
+					Status:   corev1.ConditionTrue,
+					Severity: clusterv1.ConditionSeverityError,
+					Reason:   expv1.DeleteRetriesExhaustedReason,
+					Message: fmt.Sprintf("Failed to delete %v %q %d times, not retrying: %v",
+						obj.GroupVersionKind(), obj.GetName(), attempts, err),
+				})
+				r.recorder.Eventf(m, corev1.EventTypeWarning, "DeleteRetriesExhausted", "Stopped retrying to delete %v %q after %d attempts: %v",
+					obj.GroupVersionKind(), obj.GetName(), attempts, err)
+				return false, nil
+			}
+
+			conditions.Set(m, &clusterv1.Condition{
+				Type:     expv1.DeletionBlockedCondition,
+				Status:   corev1.ConditionTrue,
+				Severity: clusterv1.ConditionSeverityWarning,
+				Reason:   expv1.DeleteFailedReason,
+				Message:  fmt.Sprintf("Failed to delete %v %q: %v", obj.GroupVersionKind(), obj.GetName(), err),
+			})
+			return false, errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: deleteExternalBackoff(attempts)},
+				"failed to delete %v %q for MachinePool %q in namespace %q: %v",
+				obj.GroupVersionKind(), obj.GetName(), m.Name, m.Namespace, err)
 		}
 	}
+	delete(m.Annotations, expv1.DeleteExternalAttemptsAnnotation)
+	conditions.Delete(m, expv1.DeletionBlockedCondition)
 
 	// Return true if there are no more external objects.
 	return len(objects) == 0, nil
 }
+
+// deleteExternalBackoff returns the time to wait before retrying to delete the external objects of a MachinePool,
+// doubling with each failed attempt up to deleteExternalMaxBackoff.
+func deleteExternalBackoff(attempts int) time.Duration {
+	backoff := deleteExternalInitialBackoff
+	for i := 1; i < attempts && backoff < deleteExternalMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > deleteExternalMaxBackoff {
+		backoff = deleteExternalMaxBackoff
+	}
+	return backoff
+}
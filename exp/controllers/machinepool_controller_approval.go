@@ -0,0 +1,90 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+)
+
+// reconcilePreProvisionApproval asks the PreProvisionWebhookURL, if configured, for the approval to provision the
+// infrastructure of a MachinePool whose infrastructure was never ready. The approval is recorded with the
+// PreProvisionApprovedAnnotation, so that the webhook is only called until it approves the MachinePool.
+func (r *MachinePoolReconciler) reconcilePreProvisionApproval(ctx context.Context, mp *expv1.MachinePool) error {
+	if r.PreProvisionWebhookURL == "" || mp.Status.InfrastructureReady {
+		return nil
+	}
+	if _, ok := mp.Annotations[expv1.PreProvisionApprovedAnnotation]; ok {
+		conditions.MarkTrue(mp, expv1.ProvisioningApprovedCondition)
+		return nil
+	}
+
+	approved, err := r.requestPreProvisionApproval(ctx, mp)
+	if err != nil {
+		conditions.MarkFalse(mp, expv1.ProvisioningApprovedCondition, expv1.WaitingForApprovalReason, clusterv1.ConditionSeverityInfo,
+			"Failed to call the pre-provision webhook: %v", err)
+		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: preProvisionApprovalWait},
+			"failed to call the pre-provision webhook for MachinePool %q in namespace %q: %v, requeuing", mp.Name, mp.Namespace, err)
+	}
+	if !approved {
+		conditions.MarkFalse(mp, expv1.ProvisioningApprovedCondition, expv1.WaitingForApprovalReason, clusterv1.ConditionSeverityInfo,
+			"Provisioning wasn't approved by the pre-provision webhook")
+		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: preProvisionApprovalWait},
+			"Provisioning of MachinePool %q in namespace %q wasn't approved by the pre-provision webhook, requeuing", mp.Name, mp.Namespace)
+	}
+
+	if mp.Annotations == nil {
+		mp.Annotations = make(map[string]string)
+	}
+	mp.Annotations[expv1.PreProvisionApprovedAnnotation] = ""
+	conditions.MarkTrue(mp, expv1.ProvisioningApprovedCondition)
+	r.recorder.Event(mp, corev1.EventTypeNormal, "PreProvisionApproved", "Provisioning approved by the pre-provision webhook")
+	return nil
+}
+
+// requestPreProvisionApproval posts the MachinePool to the PreProvisionWebhookURL, and returns whether the webhook
+// approved provisioning it with a 200 response.
+func (r *MachinePoolReconciler) requestPreProvisionApproval(ctx context.Context, mp *expv1.MachinePool) (bool, error) {
+	body, err := json.Marshal(mp)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to encode MachinePool")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.PreProvisionWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	httpClient := &http.Client{Timeout: preProvisionWebhookTimeout}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK, nil
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+)
+
+func TestReconcileMachinePoolPreProvisionApproval(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	testCases := []struct {
+		name           string
+		statusCode     int
+		expectApproved bool
+	}{
+		{
+			name:           "provisioning proceeds once approved by the webhook",
+			statusCode:     http.StatusOK,
+			expectApproved: true,
+		},
+		{
+			name:       "provisioning waits while the webhook denies it",
+			statusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var requests []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				mp := &expv1.MachinePool{}
+				if err := json.NewDecoder(req.Body).Decode(mp); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				requests = append(requests, mp.Name)
+				w.WriteHeader(tc.statusCode)
+			}))
+			defer server.Close()
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Replicas:    pointer.Int32Ptr(1),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+								Kind:       "InfrastructureConfig",
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client:                 fake.NewFakeClientWithScheme(scheme.Scheme, defaultCluster, machinepool),
+				Log:                    log.Log,
+				scheme:                 scheme.Scheme,
+				recorder:               record.NewFakeRecorder(32),
+				PreProvisionWebhookURL: server.URL,
+			}
+
+			// The infrastructure object doesn't exist yet, so reconciling it requeues either way.
+			for i := 0; i < 2; i++ {
+				err := r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)
+				var requeueErr *capierrors.RequeueAfterError
+				g.Expect(errors.As(err, &requeueErr)).To(BeTrue())
+			}
+
+			if tc.expectApproved {
+				// The approval is cached, the webhook is only called once.
+				g.Expect(requests).To(Equal([]string{"machinepool-test"}))
+				g.Expect(machinepool.Annotations).To(HaveKey(expv1.PreProvisionApprovedAnnotation))
+				g.Expect(conditions.IsTrue(machinepool, expv1.ProvisioningApprovedCondition)).To(BeTrue())
+				return
+			}
+			g.Expect(requests).To(Equal([]string{"machinepool-test", "machinepool-test"}))
+			g.Expect(machinepool.Annotations).NotTo(HaveKey(expv1.PreProvisionApprovedAnnotation))
+			g.Expect(conditions.IsFalse(machinepool, expv1.ProvisioningApprovedCondition)).To(BeTrue())
+			g.Expect(conditions.GetReason(machinepool, expv1.ProvisioningApprovedCondition)).To(Equal(expv1.WaitingForApprovalReason))
+		})
+	}
+}
+
+func TestReconcileMachinePoolPreProvisionApprovalSkipped(t *testing.T) {
+	g := NewWithT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected call to the pre-provision webhook")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	r := &MachinePoolReconciler{
+		Log:                    log.Log,
+		recorder:               record.NewFakeRecorder(32),
+		PreProvisionWebhookURL: server.URL,
+	}
+
+	// MachinePools whose infrastructure was already ready aren't gated.
+	mp := &expv1.MachinePool{Status: expv1.MachinePoolStatus{InfrastructureReady: true}}
+	g.Expect(r.reconcilePreProvisionApproval(context.Background(), mp)).To(Succeed())
+	g.Expect(conditions.Has(mp, expv1.ProvisioningApprovedCondition)).To(BeFalse())
+
+	// MachinePools aren't gated without a webhook.
+	r.PreProvisionWebhookURL = ""
+	mp = &expv1.MachinePool{}
+	g.Expect(r.reconcilePreProvisionApproval(context.Background(), mp)).To(Succeed())
+	g.Expect(conditions.Has(mp, expv1.ProvisioningApprovedCondition)).To(BeFalse())
+}
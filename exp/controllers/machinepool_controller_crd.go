@@ -0,0 +1,81 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+)
+
+// machinePoolCRDName is the name of the MachinePool CustomResourceDefinition.
+var machinePoolCRDName = "machinepools." + expv1.GroupVersion.Group
+
+// isCRDTerminating returns true if the MachinePool CustomResourceDefinition is being deleted,
+// in which case reconciliation is halted rather than failing on every MachinePool.
+// The CustomResourceDefinition is read from the API server, rather than cached, at most once per
+// crdTerminatingCheckInterval. The halt and the resume of the reconciliation are logged once.
+func (r *MachinePoolReconciler) isCRDTerminating(ctx context.Context) (bool, error) {
+	r.crdCheckLock.Lock()
+	defer r.crdCheckLock.Unlock()
+
+	now := r.now()
+	if !r.crdCheckedAt.IsZero() && now.Sub(r.crdCheckedAt) < crdTerminatingCheckInterval {
+		return atomic.LoadInt32(&r.crdTerminating) == 1, nil
+	}
+
+	reader := r.apiReader
+	if reader == nil {
+		reader = r.Client
+	}
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	key := types.NamespacedName{Name: machinePoolCRDName}
+	terminating := false
+	if err := reader.Get(ctx, key, crd); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, errors.Wrapf(err, "failed to retrieve CustomResourceDefinition %s", key.Name)
+		}
+	} else {
+		terminating = !crd.DeletionTimestamp.IsZero() || crdConditionIsTrue(crd, apiextensionsv1.Terminating)
+	}
+	r.crdCheckedAt = now
+
+	if terminating {
+		if atomic.CompareAndSwapInt32(&r.crdTerminating, 0, 1) {
+			r.Log.Info("Reconciliation of MachinePools is halted, the CustomResourceDefinition is being deleted", "crd", key.Name)
+		}
+	} else if atomic.CompareAndSwapInt32(&r.crdTerminating, 1, 0) {
+		r.Log.Info("Reconciliation of MachinePools is resumed", "crd", key.Name)
+	}
+	return terminating, nil
+}
+
+// crdConditionIsTrue returns true if the given condition of the CustomResourceDefinition is True.
+func crdConditionIsTrue(crd *apiextensionsv1.CustomResourceDefinition, conditionType apiextensionsv1.CustomResourceDefinitionConditionType) bool {
+	for _, c := range crd.Status.Conditions {
+		if c.Type == conditionType {
+			return c.Status == apiextensionsv1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestMachinePoolReconcileCRDTerminating(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+	g.Expect(apiextensionsv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "crd-terminating"},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName: testCluster.Name,
+			Replicas:    pointer.Int32Ptr(1),
+		},
+	}
+	deletionTimestamp := metav1.Now()
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              machinePoolCRDName,
+			DeletionTimestamp: &deletionTimestamp,
+		},
+		Status: apiextensionsv1.CustomResourceDefinitionStatus{
+			Conditions: []apiextensionsv1.CustomResourceDefinitionCondition{
+				{Type: apiextensionsv1.Terminating, Status: apiextensionsv1.ConditionTrue},
+			},
+		},
+	}
+
+	fakeClock := clock.NewFakeClock(time.Now())
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, mp, crd),
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(32),
+		clock:    fakeClock,
+	}
+	request := reconcile.Request{NamespacedName: util.ObjectKey(mp)}
+
+	// The MachinePool is left untouched, without error nor requeue, while the CRD is being deleted.
+	for i := 0; i < 2; i++ {
+		res, err := r.Reconcile(request)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(res).To(Equal(reconcile.Result{}))
+		g.Expect(r.crdTerminating).To(BeEquivalentTo(1))
+	}
+
+	actual := &expv1.MachinePool{}
+	g.Expect(r.Client.Get(ctx, util.ObjectKey(mp), actual)).To(Succeed())
+	g.Expect(actual.Finalizers).To(BeEmpty())
+
+	// Reconciliation resumes once the CRD is no longer terminating, e.g. if the deletion is aborted.
+	crd.DeletionTimestamp = nil
+	crd.Status.Conditions = nil
+	g.Expect(r.Client.Update(ctx, crd)).To(Succeed())
+
+	// The CRD isn't read again until the check interval elapses.
+	_, _ = r.Reconcile(request)
+	g.Expect(r.crdTerminating).To(BeEquivalentTo(1))
+
+	fakeClock.Step(crdTerminatingCheckInterval)
+	_, _ = r.Reconcile(request)
+	g.Expect(r.crdTerminating).To(BeEquivalentTo(0))
+}
+
+func TestIsCRDTerminating(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(apiextensionsv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	tests := []struct {
+		name        string
+		crd         *apiextensionsv1.CustomResourceDefinition
+		terminating bool
+	}{
+		{
+			name:        "CRD not found",
+			terminating: false,
+		},
+		{
+			name: "CRD established",
+			crd: &apiextensionsv1.CustomResourceDefinition{
+				ObjectMeta: metav1.ObjectMeta{Name: machinePoolCRDName},
+				Status: apiextensionsv1.CustomResourceDefinitionStatus{
+					Conditions: []apiextensionsv1.CustomResourceDefinitionCondition{
+						{Type: apiextensionsv1.Established, Status: apiextensionsv1.ConditionTrue},
+						{Type: apiextensionsv1.Terminating, Status: apiextensionsv1.ConditionFalse},
+					},
+				},
+			},
+			terminating: false,
+		},
+		{
+			name: "CRD with Terminating condition",
+			crd: &apiextensionsv1.CustomResourceDefinition{
+				ObjectMeta: metav1.ObjectMeta{Name: machinePoolCRDName},
+				Status: apiextensionsv1.CustomResourceDefinitionStatus{
+					Conditions: []apiextensionsv1.CustomResourceDefinitionCondition{
+						{Type: apiextensionsv1.Terminating, Status: apiextensionsv1.ConditionTrue},
+					},
+				},
+			},
+			terminating: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := fake.NewFakeClientWithScheme(scheme.Scheme)
+			if tt.crd != nil {
+				c = fake.NewFakeClientWithScheme(scheme.Scheme, tt.crd)
+			}
+			// The CRD is read from the API server, not from the cache of the client.
+			r := &MachinePoolReconciler{Client: fake.NewFakeClientWithScheme(scheme.Scheme), apiReader: c, Log: log.Log}
+
+			terminating, err := r.isCRDTerminating(ctx)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(terminating).To(Equal(tt.terminating))
+		})
+	}
+}
+
+func TestIsCRDTerminatingRateLimited(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(apiextensionsv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	apiReader := &getCountingClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme)}
+	fakeClock := clock.NewFakeClock(time.Now())
+	r := &MachinePoolReconciler{
+		Client:    fake.NewFakeClientWithScheme(scheme.Scheme),
+		apiReader: apiReader,
+		Log:       log.Log,
+		clock:     fakeClock,
+	}
+
+	// The CRD is read once per check interval.
+	for i := 0; i < 3; i++ {
+		terminating, err := r.isCRDTerminating(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(terminating).To(BeFalse())
+	}
+	g.Expect(apiReader.gets).To(Equal(1))
+
+	fakeClock.Step(crdTerminatingCheckInterval)
+	_, err := r.isCRDTerminating(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(apiReader.gets).To(Equal(2))
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileDependencies waits for the MachinePools listed in Spec.DependsOn to be Running before the infrastructure
+// of a MachinePool is provisioned. MachinePools which are part of a dependency cycle are never provisioned. Once the
+// infrastructure has been ready, the dependencies aren't checked anymore.
+func (r *MachinePoolReconciler) reconcileDependencies(ctx context.Context, mp *expv1.MachinePool) error {
+	if len(mp.Spec.DependsOn) == 0 {
+		conditions.Delete(mp, expv1.DependenciesReadyCondition)
+		return nil
+	}
+	if mp.Status.InfrastructureReady {
+		return nil
+	}
+
+	cycle, err := r.dependencyCycle(ctx, mp)
+	if err != nil {
+		return err
+	}
+	if cycle != nil {
+		conditions.MarkFalse(mp, expv1.DependenciesReadyCondition, expv1.DependencyCycleReason, clusterv1.ConditionSeverityError,
+			"MachinePools depend on each other: %s", strings.Join(cycle, " -> "))
+		return errors.Errorf("MachinePool %q in namespace %q is part of the dependency cycle %s",
+			mp.Name, mp.Namespace, strings.Join(cycle, " -> "))
+	}
+
+	for _, dependencyRef := range mp.Spec.DependsOn {
+		dependency := &expv1.MachinePool{}
+		key := client.ObjectKey{Namespace: mp.Namespace, Name: dependencyRef.Name}
+		if err := r.Client.Get(ctx, key, dependency); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to get MachinePool %q which MachinePool %q depends on", dependencyRef.Name, mp.Name)
+		}
+		if dependency.Status.GetTypedPhase() != expv1.MachinePoolPhaseRunning {
+			conditions.MarkFalse(mp, expv1.DependenciesReadyCondition, expv1.WaitingForDependencyReason, clusterv1.ConditionSeverityInfo,
+				"Waiting for MachinePool %q to be Running", dependencyRef.Name)
+			return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: dependencyWait},
+				"MachinePool %q in namespace %q is waiting for MachinePool %q to be Running, requeuing", mp.Name, mp.Namespace, dependencyRef.Name)
+		}
+	}
+
+	conditions.MarkTrue(mp, expv1.DependenciesReadyCondition)
+	return nil
+}
+
+// dependencyCycle returns the names of the MachinePools of a dependency cycle through a MachinePool, starting and
+// ending with it, if any. MachinePools which don't exist yet are ignored.
+func (r *MachinePoolReconciler) dependencyCycle(ctx context.Context, mp *expv1.MachinePool) ([]string, error) {
+	visited := map[string]bool{}
+
+	var visit func(path []string, dependsOn []corev1.LocalObjectReference) ([]string, error)
+	visit = func(path []string, dependsOn []corev1.LocalObjectReference) ([]string, error) {
+		for _, dependencyRef := range dependsOn {
+			if dependencyRef.Name == mp.Name {
+				return append(path, mp.Name), nil
+			}
+			if visited[dependencyRef.Name] {
+				continue
+			}
+			visited[dependencyRef.Name] = true
+
+			dependency := &expv1.MachinePool{}
+			key := client.ObjectKey{Namespace: mp.Namespace, Name: dependencyRef.Name}
+			if err := r.Client.Get(ctx, key, dependency); err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return nil, errors.Wrapf(err, "failed to get MachinePool %q to detect dependency cycles", dependencyRef.Name)
+			}
+			cycle, err := visit(append(path, dependencyRef.Name), dependency.Spec.DependsOn)
+			if err != nil || cycle != nil {
+				return cycle, err
+			}
+		}
+		return nil, nil
+	}
+
+	return visit([]string{mp.Name}, mp.Spec.DependsOn)
+}
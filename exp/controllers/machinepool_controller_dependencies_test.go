@@ -0,0 +1,119 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+)
+
+func newDependentMachinePool(name string, phase expv1.MachinePoolPhase, dependsOn ...string) *expv1.MachinePool {
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Status:     expv1.MachinePoolStatus{Phase: string(phase)},
+	}
+	for _, dependency := range dependsOn {
+		mp.Spec.DependsOn = append(mp.Spec.DependsOn, corev1.LocalObjectReference{Name: dependency})
+	}
+	return mp
+}
+
+func TestMachinePoolReconcileDependencyChain(t *testing.T) {
+	testCases := []struct {
+		name               string
+		systemPhase        expv1.MachinePoolPhase
+		middlewarePhase    expv1.MachinePoolPhase
+		expectWaiting      bool
+		expectedDependency string
+	}{
+		{
+			name:               "pool waits for its direct dependency",
+			systemPhase:        expv1.MachinePoolPhaseRunning,
+			middlewarePhase:    expv1.MachinePoolPhaseProvisioning,
+			expectWaiting:      true,
+			expectedDependency: "middleware",
+		},
+		{
+			name:            "pool is provisioned once its direct dependency is running",
+			systemPhase:     expv1.MachinePoolPhaseProvisioning,
+			middlewarePhase: expv1.MachinePoolPhaseRunning,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			system := newDependentMachinePool("system", tc.systemPhase)
+			middleware := newDependentMachinePool("middleware", tc.middlewarePhase, "system")
+			workload := newDependentMachinePool("workload", expv1.MachinePoolPhasePending, "middleware")
+
+			r := &MachinePoolReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, system, middleware, workload),
+				Log:    log.Log,
+			}
+
+			err := r.reconcileDependencies(context.Background(), workload)
+			if tc.expectWaiting {
+				var requeueErr *capierrors.RequeueAfterError
+				g.Expect(errors.As(err, &requeueErr)).To(BeTrue())
+				g.Expect(conditions.IsFalse(workload, expv1.DependenciesReadyCondition)).To(BeTrue())
+				g.Expect(conditions.GetReason(workload, expv1.DependenciesReadyCondition)).To(Equal(expv1.WaitingForDependencyReason))
+				g.Expect(conditions.GetMessage(workload, expv1.DependenciesReadyCondition)).To(ContainSubstring(tc.expectedDependency))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(conditions.IsTrue(workload, expv1.DependenciesReadyCondition)).To(BeTrue())
+			}
+		})
+	}
+}
+
+func TestMachinePoolReconcileDependencyCycle(t *testing.T) {
+	g := NewWithT(t)
+
+	system := newDependentMachinePool("system", expv1.MachinePoolPhaseRunning, "workload")
+	middleware := newDependentMachinePool("middleware", expv1.MachinePoolPhaseRunning, "system")
+	workload := newDependentMachinePool("workload", expv1.MachinePoolPhasePending, "middleware")
+
+	r := &MachinePoolReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, system, middleware, workload),
+		Log:    log.Log,
+	}
+
+	err := r.reconcileDependencies(context.Background(), workload)
+	g.Expect(err).To(HaveOccurred())
+	var requeueErr *capierrors.RequeueAfterError
+	g.Expect(errors.As(err, &requeueErr)).To(BeFalse())
+	g.Expect(conditions.IsFalse(workload, expv1.DependenciesReadyCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(workload, expv1.DependenciesReadyCondition)).To(Equal(expv1.DependencyCycleReason))
+	g.Expect(*conditions.GetSeverity(workload, expv1.DependenciesReadyCondition)).To(Equal(clusterv1.ConditionSeverityError))
+	g.Expect(conditions.GetMessage(workload, expv1.DependenciesReadyCondition)).To(Equal("MachinePools depend on each other: workload -> middleware -> system -> workload"))
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/noderefutil"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	kubedrain "sigs.k8s.io/cluster-api/third_party/kubernetes-drain"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// kubeClientGetter returns a clientset for the workload cluster of the given Cluster.
+type kubeClientGetter func(ctx context.Context, c client.Client, cluster client.ObjectKey) (kubernetes.Interface, error)
+
+// newKubeClient returns a clientset for the workload cluster of the given Cluster, from its kubeconfig secret.
+func newKubeClient(ctx context.Context, c client.Client, cluster client.ObjectKey) (kubernetes.Interface, error) {
+	restConfig, err := remote.RESTConfig(ctx, c, cluster)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(restConfig)
+}
+
+// markDrainOnTermination sets the DrainOnTerminationAnnotation on the Node, and returns true if the Node changed.
+func markDrainOnTermination(node *corev1.Node) bool {
+	if _, ok := node.Annotations[expv1.DrainOnTerminationAnnotation]; ok {
+		return false
+	}
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+	node.Annotations[expv1.DrainOnTerminationAnnotation] = ""
+	return true
+}
+
+// terminationSignaled returns true if the Node was signaled for termination and the signal wasn't acknowledged yet.
+func terminationSignaled(node *corev1.Node) bool {
+	if _, ok := node.Annotations[expv1.TerminationSignalAnnotation]; !ok {
+		return false
+	}
+	_, acknowledged := node.Annotations[expv1.TerminationAcknowledgedAnnotation]
+	return !acknowledged
+}
+
+// terminationSignalsPending returns true if one of the Nodes of the given provider IDs wasn't marked to be drained on
+// termination yet, or was signaled for termination without the signal being acknowledged, when draining Nodes on
+// termination signals is enabled.
+func (r *MachinePoolReconciler) terminationSignalsPending(nodesByProviderID map[string]*corev1.Node, providerIDList []string) bool {
+	if !r.DrainNodesOnTerminationSignal {
+		return false
+	}
+
+	for _, providerID := range providerIDList {
+		pid, err := noderefutil.NewProviderID(providerID)
+		if err != nil {
+			continue
+		}
+		node, ok := nodesByProviderID[pid.ID()]
+		if !ok {
+			continue
+		}
+		if _, marked := node.Annotations[expv1.DrainOnTerminationAnnotation]; !marked || terminationSignaled(node) {
+			return true
+		}
+	}
+	return false
+}
+
+// drainNode cordons the Node of a MachinePool and evicts its pods, honoring their grace period. The MachinePool is
+// requeued if the pods couldn't all be evicted yet, or if an eviction is blocked by a PodDisruptionBudget, until
+// the Spec.NodeDrainTimeout of the MachinePool elapses, after which the remaining pods are forcefully deleted.
+// The progress of the drain is reported in Status.NodeDrains.
+func (r *MachinePoolReconciler) drainNode(ctx context.Context, cluster *clusterv1.Cluster, mp *expv1.MachinePool, node *corev1.Node) error {
+	logger := r.Log.WithValues("machinepool", mp.Name, "namespace", mp.Namespace, "node", node.Name)
+
+	clusterKey, err := workloadClusterKey(cluster, mp)
+	if err != nil {
+		return err
+	}
+	getKubeClient := r.kubeClientGetter
+	if getKubeClient == nil {
+		getKubeClient = newKubeClient
+	}
+	kubeClient, err := getKubeClient(ctx, r.Client, clusterKey)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create a clientset to drain Node %q", node.Name)
+	}
+
+	drain := startNodeDrain(mp, node.Name, r.now())
+	if !drain.Forced && nodeDrainTimedOut(mp, drain, r.now()) {
+		drain.Forced = true
+		logger.Info("Node drain timed out, forcefully deleting the remaining pods", "timeout", mp.Spec.NodeDrainTimeout.Duration)
+		r.recorder.Eventf(mp, corev1.EventTypeWarning, "NodeDrainTimedOut",
+			"Forcefully deleting the remaining pods of Node %q, not drained within %s", node.Name, mp.Spec.NodeDrainTimeout.Duration)
+	}
+
+	drainer := &kubedrain.Helper{
+		Client:              kubeClient,
+		Force:               true,
+		IgnoreAllDaemonSets: true,
+		DeleteLocalData:     true,
+		GracePeriodSeconds:  r.nodeDrainGracePeriodSeconds(),
+		// If a pod is not evicted within the pod timeout, retry the eviction next time the
+		// machine pool gets reconciled again (to allow other machine pools to be reconciled).
+		Timeout: r.nodeDrainPodTimeout(),
+		OnPodDeletedOrEvicted: func(pod *corev1.Pod, usingEviction bool) {
+			verbStr := "Deleted"
+			if usingEviction {
+				verbStr = "Evicted"
+			}
+			logger.Info(fmt.Sprintf("%s pod from Node", verbStr),
+				"pod", fmt.Sprintf("%s/%s", pod.Name, pod.Namespace))
+		},
+		Out:    writer{klog.Info},
+		ErrOut: writer{klog.Error},
+		DryRun: false,
+	}
+	if drain.Forced {
+		// Delete the pods without waiting for their grace period nor honoring their PodDisruptionBudgets.
+		drainer.DisableEviction = true
+		drainer.GracePeriodSeconds = 0
+	}
+
+	if noderefutil.IsNodeUnreachable(node) {
+		// When the node is unreachable and some pods are not evicted for as long as this timeout, we ignore them.
+		drainer.SkipWaitForDeleteTimeoutSeconds = 60 * 5 // 5 minutes
+	}
+
+	if err := kubedrain.RunCordonOrUncordon(drainer, node.DeepCopy(), true); err != nil {
+		return errors.Wrapf(err, "unable to cordon Node %q", node.Name)
+	}
+
+	podDeleteList, errs := drainer.GetPodsForDeletion(node.Name)
+	if len(errs) > 0 {
+		return errors.Wrapf(kerrors.NewAggregate(errs), "failed to list the pods to evict from Node %q", node.Name)
+	}
+	pods := podDeleteList.Pods()
+	drain.PodsRemaining = int32(len(pods))
+
+	// Requeue rather than waiting for the evictions blocked by a PodDisruptionBudget to time out.
+	if !drain.Forced {
+		pod, pdb, err := blockingPodDisruptionBudget(drainer, pods)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check the PodDisruptionBudgets of the pods of Node %q", node.Name)
+		}
+		if pdb != nil {
+			logger.Info("Drain blocked by PodDisruptionBudget", "pod", fmt.Sprintf("%s/%s", pod.Namespace, pod.Name), "pdb", pdb.Name)
+			return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: nodeDrainWait},
+				"eviction of pod %s/%s from Node %q is blocked by PodDisruptionBudget %q", pod.Namespace, pod.Name, node.Name, pdb.Name)
+		}
+	}
+
+	if err := kubedrain.RunNodeDrain(drainer, node.Name); err != nil {
+		logger.Error(err, "Drain failed")
+		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: nodeDrainWait},
+			"failed to drain Node %q", node.Name)
+	}
+
+	finishNodeDrain(mp, node.Name)
+	logger.Info("Drained Node")
+	return nil
+}
+
+// nodeDrainGracePeriodSeconds returns the grace period given to the pods evicted when draining a Node,
+// -1 to honor the grace period of each pod.
+func (r *MachinePoolReconciler) nodeDrainGracePeriodSeconds() int {
+	if r.NodeDrainGracePeriod <= 0 {
+		return -1
+	}
+	return int(r.NodeDrainGracePeriod.Seconds())
+}
+
+// nodeDrainPodTimeout returns the time a drain attempt waits for the evicted pods to terminate.
+func (r *MachinePoolReconciler) nodeDrainPodTimeout() time.Duration {
+	if r.NodeDrainPodTimeout <= 0 {
+		return 20 * time.Second
+	}
+	return r.NodeDrainPodTimeout
+}
+
+// startNodeDrain returns the drain of the Node in Status.NodeDrains, adding it if the Node isn't being drained yet.
+func startNodeDrain(mp *expv1.MachinePool, nodeName string, now time.Time) *expv1.MachinePoolNodeDrain {
+	for i := range mp.Status.NodeDrains {
+		if mp.Status.NodeDrains[i].NodeName == nodeName {
+			return &mp.Status.NodeDrains[i]
+		}
+	}
+	mp.Status.NodeDrains = append(mp.Status.NodeDrains, expv1.MachinePoolNodeDrain{
+		NodeName:  nodeName,
+		StartTime: metav1.NewTime(now),
+	})
+	return &mp.Status.NodeDrains[len(mp.Status.NodeDrains)-1]
+}
+
+// finishNodeDrain removes the drain of the Node from Status.NodeDrains.
+func finishNodeDrain(mp *expv1.MachinePool, nodeName string) {
+	var drains []expv1.MachinePoolNodeDrain
+	for _, drain := range mp.Status.NodeDrains {
+		if drain.NodeName != nodeName {
+			drains = append(drains, drain)
+		}
+	}
+	mp.Status.NodeDrains = drains
+}
+
+// pruneNodeDrains removes the drains of the Nodes which aren't referenced by the MachinePool anymore from
+// Status.NodeDrains, e.g. Nodes deleted by other means while being drained.
+func pruneNodeDrains(mp *expv1.MachinePool, nodeRefs []corev1.ObjectReference) {
+	if len(mp.Status.NodeDrains) == 0 {
+		return
+	}
+	referenced := make(map[string]bool, len(nodeRefs))
+	for _, nodeRef := range nodeRefs {
+		referenced[nodeRef.Name] = true
+	}
+	var drains []expv1.MachinePoolNodeDrain
+	for _, drain := range mp.Status.NodeDrains {
+		if referenced[drain.NodeName] {
+			drains = append(drains, drain)
+		}
+	}
+	mp.Status.NodeDrains = drains
+}
+
+// nodeDrainTimedOut returns true if the Spec.NodeDrainTimeout of the MachinePool elapsed since the drain started.
+func nodeDrainTimedOut(mp *expv1.MachinePool, drain *expv1.MachinePoolNodeDrain, now time.Time) bool {
+	if mp.Spec.NodeDrainTimeout == nil || mp.Spec.NodeDrainTimeout.Duration <= 0 {
+		return false
+	}
+	return now.Sub(drain.StartTime.Time) > mp.Spec.NodeDrainTimeout.Duration
+}
+
+// blockingPodDisruptionBudget returns one of the pods to evict along with a PodDisruptionBudget selecting it
+// which allows no disruption, or nils if no eviction is blocked by a PodDisruptionBudget.
+func blockingPodDisruptionBudget(drainer *kubedrain.Helper, pods []corev1.Pod) (*corev1.Pod, *policyv1beta1.PodDisruptionBudget, error) {
+	pdbsByNamespace := make(map[string][]policyv1beta1.PodDisruptionBudget)
+	for _, pod := range pods {
+		pdbs, ok := pdbsByNamespace[pod.Namespace]
+		if !ok {
+			pdbList, err := drainer.Client.PolicyV1beta1().PodDisruptionBudgets(pod.Namespace).List(metav1.ListOptions{})
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to list PodDisruptionBudgets in namespace %q", pod.Namespace)
+			}
+			pdbs = pdbList.Items
+			pdbsByNamespace[pod.Namespace] = pdbs
+		}
+
+		for i := range pdbs {
+			if pdbs[i].Status.PodDisruptionsAllowed > 0 {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(pdbs[i].Spec.Selector)
+			if err != nil || selector.Empty() || !selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			pod := pod
+			return &pod, &pdbs[i], nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// writer implements io.Writer interface as a pass-through for klog.
+type writer struct {
+	logFunc func(args ...interface{})
+}
+
+// Write passes string(p) into writer's logFunc and always returns len(p)
+func (w writer) Write(p []byte) (n int, err error) {
+	w.logFunc(string(p))
+	return len(p), nil
+}
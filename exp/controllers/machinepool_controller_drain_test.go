@@ -0,0 +1,504 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+)
+
+func TestMachinePoolReconcileNodesDrainOnTermination(t *testing.T) {
+	testCases := []struct {
+		name                    string
+		drainOnTermination      bool
+		nodeAnnotations         map[string]string
+		expectedAnnotations     []string
+		unexpectedAnnotations   []string
+		expectDrained           bool
+		expectUnschedulableNode bool
+	}{
+		{
+			name:                  "nodes are left untouched if draining on termination signals is disabled",
+			nodeAnnotations:       map[string]string{expv1.TerminationSignalAnnotation: ""},
+			expectedAnnotations:   []string{expv1.TerminationSignalAnnotation},
+			unexpectedAnnotations: []string{expv1.DrainOnTerminationAnnotation, expv1.TerminationAcknowledgedAnnotation},
+		},
+		{
+			name:                  "nodes without a termination signal are only marked",
+			drainOnTermination:    true,
+			expectedAnnotations:   []string{expv1.DrainOnTerminationAnnotation},
+			unexpectedAnnotations: []string{expv1.TerminationAcknowledgedAnnotation},
+		},
+		{
+			name:                    "nodes with a termination signal are drained and the signal is acknowledged",
+			drainOnTermination:      true,
+			nodeAnnotations:         map[string]string{expv1.TerminationSignalAnnotation: ""},
+			expectedAnnotations:     []string{expv1.DrainOnTerminationAnnotation, expv1.TerminationSignalAnnotation, expv1.TerminationAcknowledgedAnnotation},
+			expectDrained:           true,
+			expectUnschedulableNode: true,
+		},
+		{
+			name:               "nodes with an acknowledged termination signal aren't drained again",
+			drainOnTermination: true,
+			nodeAnnotations: map[string]string{
+				expv1.DrainOnTerminationAnnotation:      "",
+				expv1.TerminationSignalAnnotation:       "",
+				expv1.TerminationAcknowledgedAnnotation: "",
+			},
+			expectedAnnotations: []string{expv1.DrainOnTerminationAnnotation, expv1.TerminationSignalAnnotation, expv1.TerminationAcknowledgedAnnotation},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "node-1",
+					Annotations: tc.nodeAnnotations,
+				},
+				Spec: corev1.NodeSpec{ProviderID: "aws://us-east-1/id-node-1"},
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod-1"},
+				Spec:       corev1.PodSpec{NodeName: node.Name},
+			}
+			workloadClient := fake.NewFakeClientWithScheme(scheme.Scheme, node)
+			kubeClient := kubefake.NewSimpleClientset(node.DeepCopy(), pod)
+
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"}}
+			mp := &expv1.MachinePool{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test"}}
+
+			r := &MachinePoolReconciler{
+				Client:                        fake.NewFakeClientWithScheme(scheme.Scheme),
+				Log:                           log.Log,
+				recorder:                      record.NewFakeRecorder(32),
+				DrainNodesOnTerminationSignal: tc.drainOnTermination,
+				kubeClientGetter: func(_ context.Context, _ client.Client, key client.ObjectKey) (kubernetes.Interface, error) {
+					g.Expect(key).To(Equal(types.NamespacedName{Namespace: "default", Name: "test-cluster"}))
+					return kubeClient, nil
+				},
+			}
+
+			g.Expect(r.reconcileNodes(context.TODO(), cluster, workloadClient, mp, getWorkloadNodes(g, workloadClient, "node-1"))).To(Succeed())
+
+			actual := &corev1.Node{}
+			g.Expect(workloadClient.Get(context.TODO(), types.NamespacedName{Name: "node-1"}, actual)).To(Succeed())
+			for _, annotation := range tc.expectedAnnotations {
+				g.Expect(actual.Annotations).To(HaveKey(annotation))
+			}
+			for _, annotation := range tc.unexpectedAnnotations {
+				g.Expect(actual.Annotations).NotTo(HaveKey(annotation))
+			}
+
+			pods, err := kubeClient.CoreV1().Pods("default").List(metav1.ListOptions{})
+			g.Expect(err).NotTo(HaveOccurred())
+			if tc.expectDrained {
+				g.Expect(pods.Items).To(BeEmpty())
+			} else {
+				g.Expect(pods.Items).To(HaveLen(1))
+			}
+
+			drainedNode, err := kubeClient.CoreV1().Nodes().Get(node.Name, metav1.GetOptions{})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(drainedNode.Spec.Unschedulable).To(Equal(tc.expectUnschedulableNode))
+		})
+	}
+}
+
+func TestMachinePoolReconcileNodeRefsDrainOnTerminationUpToDate(t *testing.T) {
+	g := NewWithT(t)
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test"},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName:    testCluster.Name,
+			Replicas:       pointer.Int32Ptr(1),
+			ProviderIDList: []string{"aws://us-east-1/node-1"},
+		},
+		Status: expv1.MachinePoolStatus{
+			Replicas: 1,
+		},
+	}
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node-1",
+			Annotations: map[string]string{expv1.TemplateHashAnnotation: machinePoolTemplateHash(mp)},
+		},
+		Spec: corev1.NodeSpec{ProviderID: "aws://us-east-1/node-1"},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod-1"},
+		Spec:       corev1.PodSpec{NodeName: node.Name},
+	}
+	workloadClient := &getCountingClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme, node)}
+	kubeClient := kubefake.NewSimpleClientset(node.DeepCopy(), pod)
+
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, testCluster),
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(32),
+		remoteClientGetter: func(_ context.Context, _ client.Client, _ client.ObjectKey, _ *runtime.Scheme) (client.Client, error) {
+			return workloadClient, nil
+		},
+		kubeClientGetter: func(_ context.Context, _ client.Client, _ client.ObjectKey) (kubernetes.Interface, error) {
+			return kubeClient, nil
+		},
+		DrainNodesOnTerminationSignal: true,
+	}
+
+	// The NodeRefs are set, and the Node marked to be drained on termination.
+	g.Expect(r.reconcileNodeRefs(ctx, testCluster, mp)).To(Succeed())
+	g.Expect(mp.Status.NodeRefs).To(HaveLen(1))
+
+	// The MachinePool is up to date, the Node is left alone.
+	workloadClient.patches = 0
+	g.Expect(r.reconcileNodeRefs(ctx, testCluster, mp)).To(Succeed())
+	g.Expect(workloadClient.patches).To(BeZero())
+
+	// A termination signal on a Node of the up to date MachinePool is drained and acknowledged.
+	signaled := &corev1.Node{}
+	g.Expect(workloadClient.Client.Get(ctx, client.ObjectKey{Name: node.Name}, signaled)).To(Succeed())
+	g.Expect(signaled.Annotations).To(HaveKey(expv1.DrainOnTerminationAnnotation))
+	signaled.Annotations[expv1.TerminationSignalAnnotation] = ""
+	g.Expect(workloadClient.Client.Update(ctx, signaled)).To(Succeed())
+
+	g.Expect(r.reconcileNodeRefs(ctx, testCluster, mp)).To(Succeed())
+
+	acknowledged := &corev1.Node{}
+	g.Expect(workloadClient.Client.Get(ctx, client.ObjectKey{Name: node.Name}, acknowledged)).To(Succeed())
+	g.Expect(acknowledged.Annotations).To(HaveKey(expv1.TerminationAcknowledgedAnnotation))
+
+	pods, err := kubeClient.CoreV1().Pods("default").List(metav1.ListOptions{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pods.Items).To(BeEmpty())
+
+	// Once acknowledged, the Node is left alone again.
+	workloadClient.patches = 0
+	g.Expect(r.reconcileNodeRefs(ctx, testCluster, mp)).To(Succeed())
+	g.Expect(workloadClient.patches).To(BeZero())
+}
+
+func TestTerminationSignaled(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    bool
+	}{
+		{
+			name: "node without annotations",
+		},
+		{
+			name:        "node with a termination signal",
+			annotations: map[string]string{expv1.TerminationSignalAnnotation: ""},
+			expected:    true,
+		},
+		{
+			name: "node with an acknowledged termination signal",
+			annotations: map[string]string{
+				expv1.TerminationSignalAnnotation:       "",
+				expv1.TerminationAcknowledgedAnnotation: "",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Annotations: tc.annotations}}
+			g.Expect(terminationSignaled(node)).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestMachinePoolDrainNodePodDisruptionBudget(t *testing.T) {
+	testCases := []struct {
+		name               string
+		disruptionsAllowed int32
+		selector           map[string]string
+		expectBlocked      bool
+	}{
+		{
+			name:               "evictions blocked by a PodDisruptionBudget are requeued",
+			disruptionsAllowed: 0,
+			selector:           map[string]string{"app": "web"},
+			expectBlocked:      true,
+		},
+		{
+			name:               "evictions allowed by a PodDisruptionBudget drain the Node",
+			disruptionsAllowed: 1,
+			selector:           map[string]string{"app": "web"},
+		},
+		{
+			name:               "PodDisruptionBudgets selecting other pods don't block the drain",
+			disruptionsAllowed: 0,
+			selector:           map[string]string{"app": "db"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+				Spec:       corev1.NodeSpec{ProviderID: "aws://us-east-1/id-node-1"},
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-1", Labels: map[string]string{"app": "web"}},
+				Spec:       corev1.PodSpec{NodeName: node.Name},
+			}
+			pdb := &policyv1beta1.PodDisruptionBudget{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pdb"},
+				Spec: policyv1beta1.PodDisruptionBudgetSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: tc.selector},
+				},
+				Status: policyv1beta1.PodDisruptionBudgetStatus{PodDisruptionsAllowed: tc.disruptionsAllowed},
+			}
+			kubeClient := kubefake.NewSimpleClientset(node.DeepCopy(), pod, pdb)
+
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"}}
+			mp := &expv1.MachinePool{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test"}}
+
+			r := &MachinePoolReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme),
+				Log:      log.Log,
+				recorder: record.NewFakeRecorder(32),
+				kubeClientGetter: func(_ context.Context, _ client.Client, _ client.ObjectKey) (kubernetes.Interface, error) {
+					return kubeClient, nil
+				},
+			}
+
+			err := r.drainNode(context.TODO(), cluster, mp, node)
+
+			pods, listErr := kubeClient.CoreV1().Pods("default").List(metav1.ListOptions{})
+			g.Expect(listErr).NotTo(HaveOccurred())
+			if !tc.expectBlocked {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(pods.Items).To(BeEmpty())
+				return
+			}
+
+			// The pod is left in place rather than forcefully deleted, and the drain retried later.
+			g.Expect(err).To(HaveOccurred())
+			var requeueErr *capierrors.RequeueAfterError
+			g.Expect(errors.As(err, &requeueErr)).To(BeTrue())
+			g.Expect(err.Error()).To(ContainSubstring(`blocked by PodDisruptionBudget "pdb"`))
+			g.Expect(pods.Items).To(HaveLen(1))
+
+			// The Node is cordoned nonetheless, so that no new pods are scheduled on it.
+			cordonedNode, err := kubeClient.CoreV1().Nodes().Get(node.Name, metav1.GetOptions{})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(cordonedNode.Spec.Unschedulable).To(BeTrue())
+		})
+	}
+}
+
+func TestMachinePoolDrainNodeTimeout(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name               string
+		drainTimeout       *metav1.Duration
+		drainStartTime     time.Time
+		disruptionsAllowed int32
+		expectDrained      bool
+		expectForced       bool
+	}{
+		{
+			name:               "pods are evicted gracefully",
+			drainTimeout:       &metav1.Duration{Duration: 5 * time.Minute},
+			disruptionsAllowed: 1,
+			expectDrained:      true,
+		},
+		{
+			name:           "pods are not forcefully deleted before the drain timeout",
+			drainTimeout:   &metav1.Duration{Duration: 5 * time.Minute},
+			drainStartTime: now.Add(-time.Minute),
+		},
+		{
+			name:           "pods are not forcefully deleted without a drain timeout",
+			drainStartTime: now.Add(-time.Hour),
+		},
+		{
+			name:           "pods are forcefully deleted once the drain timeout elapsed",
+			drainTimeout:   &metav1.Duration{Duration: 5 * time.Minute},
+			drainStartTime: now.Add(-10 * time.Minute),
+			expectDrained:  true,
+			expectForced:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+				Spec:       corev1.NodeSpec{ProviderID: "aws://us-east-1/id-node-1"},
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-1", Labels: map[string]string{"app": "web"}},
+				Spec:       corev1.PodSpec{NodeName: node.Name},
+			}
+			pdb := &policyv1beta1.PodDisruptionBudget{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pdb"},
+				Spec: policyv1beta1.PodDisruptionBudgetSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				},
+				Status: policyv1beta1.PodDisruptionBudgetStatus{PodDisruptionsAllowed: tc.disruptionsAllowed},
+			}
+			kubeClient := kubefake.NewSimpleClientset(node.DeepCopy(), pod, pdb)
+
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"}}
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test"},
+				Spec:       expv1.MachinePoolSpec{NodeDrainTimeout: tc.drainTimeout},
+			}
+			if !tc.drainStartTime.IsZero() {
+				mp.Status.NodeDrains = []expv1.MachinePoolNodeDrain{
+					{NodeName: node.Name, StartTime: metav1.NewTime(tc.drainStartTime)},
+				}
+			}
+
+			recorder := record.NewFakeRecorder(32)
+			r := &MachinePoolReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme),
+				Log:      log.Log,
+				recorder: recorder,
+				clock:    clock.NewFakeClock(now),
+				kubeClientGetter: func(_ context.Context, _ client.Client, _ client.ObjectKey) (kubernetes.Interface, error) {
+					return kubeClient, nil
+				},
+			}
+
+			err := r.drainNode(context.TODO(), cluster, mp, node)
+
+			pods, listErr := kubeClient.CoreV1().Pods("default").List(metav1.ListOptions{})
+			g.Expect(listErr).NotTo(HaveOccurred())
+			if tc.expectDrained {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(pods.Items).To(BeEmpty())
+				g.Expect(mp.Status.NodeDrains).To(BeEmpty())
+				if tc.expectForced {
+					g.Expect(recorder.Events).To(Receive(ContainSubstring("NodeDrainTimedOut")))
+				}
+				return
+			}
+
+			// The drain is retried later, its progress being reported in the status of the MachinePool.
+			var requeueErr *capierrors.RequeueAfterError
+			g.Expect(errors.As(err, &requeueErr)).To(BeTrue())
+			g.Expect(pods.Items).To(HaveLen(1))
+			g.Expect(mp.Status.NodeDrains).To(HaveLen(1))
+			g.Expect(mp.Status.NodeDrains[0].NodeName).To(Equal(node.Name))
+			g.Expect(mp.Status.NodeDrains[0].PodsRemaining).To(BeEquivalentTo(1))
+			g.Expect(mp.Status.NodeDrains[0].Forced).To(BeFalse())
+			if tc.drainStartTime.IsZero() {
+				g.Expect(mp.Status.NodeDrains[0].StartTime.Time).To(BeTemporally("==", now))
+			}
+		})
+	}
+}
+
+func TestMachinePoolNodeDrainGracePeriodSeconds(t *testing.T) {
+	g := NewWithT(t)
+
+	r := &MachinePoolReconciler{}
+	g.Expect(r.nodeDrainGracePeriodSeconds()).To(Equal(-1))
+	g.Expect(r.nodeDrainPodTimeout()).To(Equal(20 * time.Second))
+
+	r.NodeDrainGracePeriod = 45 * time.Second
+	r.NodeDrainPodTimeout = time.Minute
+	g.Expect(r.nodeDrainGracePeriodSeconds()).To(Equal(45))
+	g.Expect(r.nodeDrainPodTimeout()).To(Equal(time.Minute))
+}
+
+func TestMachinePoolDeleteRetiredNodesDrain(t *testing.T) {
+	g := NewWithT(t)
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       corev1.NodeSpec{ProviderID: "aws://us-east-1/id-node-1"},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-1"},
+		Spec:       corev1.PodSpec{NodeName: node.Name},
+	}
+	kubeClient := kubefake.NewSimpleClientset(node.DeepCopy(), pod)
+	clusterClient := fake.NewFakeClientWithScheme(scheme.Scheme, node.DeepCopy())
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"}}
+	mp := &expv1.MachinePool{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test"}}
+
+	r := &MachinePoolReconciler{
+		Client:            fake.NewFakeClientWithScheme(scheme.Scheme),
+		Log:               log.Log,
+		recorder:          record.NewFakeRecorder(32),
+		DrainRetiredNodes: true,
+		kubeClientGetter: func(_ context.Context, _ client.Client, _ client.ObjectKey) (kubernetes.Interface, error) {
+			return kubeClient, nil
+		},
+	}
+
+	nodeRefs := []corev1.ObjectReference{{Kind: "Node", Name: node.Name}}
+	g.Expect(r.deleteRetiredNodes(context.TODO(), clusterClient, cluster, mp, listWorkloadNodes(g, clusterClient), nodeRefs, nil)).To(Succeed())
+
+	// The pods of the retired Node are evicted before the Node is deleted.
+	pods, err := kubeClient.CoreV1().Pods("default").List(metav1.ListOptions{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pods.Items).To(BeEmpty())
+	err = clusterClient.Get(context.TODO(), client.ObjectKey{Name: node.Name}, &corev1.Node{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	g.Expect(mp.Status.NodeDrains).To(BeEmpty())
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+)
+
+// PhaseEvaluator returns the phase of a MachinePool. It is called with the phase computed by the controller, once
+// the conditions of the MachinePool are computed, and must not modify the MachinePool.
+type PhaseEvaluator func(mp *expv1.MachinePool, computed expv1.MachinePoolPhase) expv1.MachinePoolPhase
+
+// PhaseHook runs custom logic when a MachinePool transitions from one phase to another.
+type PhaseHook interface {
+	// OnPhaseChange is called after the phase of the MachinePool changed from oldPhase to newPhase, before the
+	// MachinePool is patched. It can be called again for the same transition if patching the MachinePool fails.
+	OnPhaseChange(ctx context.Context, mp *expv1.MachinePool, oldPhase, newPhase expv1.MachinePoolPhase) error
+}
+
+// runPhaseHooks calls the PhaseHooks of the reconciler for a phase transition of the MachinePool.
+// Errors returned by the hooks are logged, and only returned if PhaseHookErrorsBlockReconcile is set.
+func (r *MachinePoolReconciler) runPhaseHooks(ctx context.Context, mp *expv1.MachinePool, oldPhase, newPhase expv1.MachinePoolPhase) error {
+	if len(r.PhaseHooks) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, hook := range r.PhaseHooks {
+		if err := hook.OnPhaseChange(ctx, mp, oldPhase, newPhase); err != nil {
+			r.Log.Error(err, "MachinePool phase hook failed", "machinepool", mp.Name, "namespace", mp.Namespace,
+				"oldPhase", oldPhase, "newPhase", newPhase)
+			errs = append(errs, err)
+		}
+	}
+
+	if !r.PhaseHookErrorsBlockReconcile {
+		return nil
+	}
+	return kerrors.NewAggregate(errs)
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type phaseTransition struct {
+	oldPhase expv1.MachinePoolPhase
+	newPhase expv1.MachinePoolPhase
+}
+
+// stubPhaseHook records the phase transitions it is called for, and returns err.
+type stubPhaseHook struct {
+	transitions []phaseTransition
+	err         error
+}
+
+func (h *stubPhaseHook) OnPhaseChange(_ context.Context, _ *expv1.MachinePool, oldPhase, newPhase expv1.MachinePoolPhase) error {
+	h.transitions = append(h.transitions, phaseTransition{oldPhase: oldPhase, newPhase: newPhase})
+	return h.err
+}
+
+func TestMachinePoolPhaseHooks(t *testing.T) {
+	testCases := []struct {
+		name                string
+		phase               expv1.MachinePoolPhase
+		hookErr             error
+		errorsBlock         bool
+		expectedTransitions []phaseTransition
+		expectError         bool
+	}{
+		{
+			name:  "hooks are called on a phase transition",
+			phase: expv1.MachinePoolPhaseProvisioning,
+			expectedTransitions: []phaseTransition{
+				{oldPhase: expv1.MachinePoolPhaseProvisioning, newPhase: expv1.MachinePoolPhaseRunning},
+			},
+		},
+		{
+			name:                "hooks are not called without a phase transition",
+			phase:               expv1.MachinePoolPhaseRunning,
+			expectedTransitions: nil,
+		},
+		{
+			name:    "hook errors don't block the reconciliation by default",
+			phase:   expv1.MachinePoolPhaseProvisioning,
+			hookErr: errors.New("hook failed"),
+			expectedTransitions: []phaseTransition{
+				{oldPhase: expv1.MachinePoolPhaseProvisioning, newPhase: expv1.MachinePoolPhaseRunning},
+			},
+		},
+		{
+			name:        "hook errors block the reconciliation if configured",
+			phase:       expv1.MachinePoolPhaseProvisioning,
+			hookErr:     errors.New("hook failed"),
+			errorsBlock: true,
+			expectedTransitions: []phaseTransition{
+				{oldPhase: expv1.MachinePoolPhaseProvisioning, newPhase: expv1.MachinePoolPhaseRunning},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				Spec: expv1.MachinePoolSpec{
+					Replicas: pointer.Int32Ptr(1),
+				},
+				Status: expv1.MachinePoolStatus{
+					Phase:               string(tc.phase),
+					BootstrapReady:      true,
+					InfrastructureReady: true,
+					ReadyReplicas:       1,
+				},
+			}
+
+			hook := &stubPhaseHook{err: tc.hookErr}
+			r := &MachinePoolReconciler{
+				Log:                           log.Log,
+				PhaseHooks:                    []PhaseHook{hook},
+				PhaseHookErrorsBlockReconcile: tc.errorsBlock,
+			}
+
+			err := r.reconcilePhase(context.Background(), mp)
+			if tc.expectError {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(mp.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhaseRunning))
+			g.Expect(hook.transitions).To(Equal(tc.expectedTransitions))
+		})
+	}
+}
+
+func TestMachinePoolPhaseWithoutHooks(t *testing.T) {
+	g := NewWithT(t)
+
+	mp := &expv1.MachinePool{
+		Spec: expv1.MachinePoolSpec{
+			Replicas: pointer.Int32Ptr(1),
+		},
+		Status: expv1.MachinePoolStatus{
+			Phase:               string(expv1.MachinePoolPhaseProvisioning),
+			BootstrapReady:      true,
+			InfrastructureReady: true,
+			ReadyReplicas:       1,
+		},
+	}
+
+	// Reconcilers without PhaseHooks don't need a logger for phase transitions.
+	r := &MachinePoolReconciler{}
+	g.Expect(r.reconcilePhase(context.Background(), mp)).To(Succeed())
+	g.Expect(mp.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhaseRunning))
+}
+
+func TestMachinePoolPhaseEvaluator(t *testing.T) {
+	g := NewWithT(t)
+
+	// The evaluator only considers MachinePools Running once their infrastructure reports them Ready.
+	evaluator := func(mp *expv1.MachinePool, computed expv1.MachinePoolPhase) expv1.MachinePoolPhase {
+		if computed == expv1.MachinePoolPhaseRunning && !conditions.IsTrue(mp, clusterv1.ReadyCondition) {
+			return expv1.MachinePoolPhaseProvisioned
+		}
+		return computed
+	}
+
+	mp := &expv1.MachinePool{
+		Spec: expv1.MachinePoolSpec{
+			Replicas: pointer.Int32Ptr(1),
+		},
+		Status: expv1.MachinePoolStatus{
+			Phase:               string(expv1.MachinePoolPhaseProvisioning),
+			BootstrapReady:      true,
+			InfrastructureReady: true,
+			ReadyReplicas:       1,
+		},
+	}
+
+	hook := &stubPhaseHook{}
+	r := &MachinePoolReconciler{
+		Log:            log.Log,
+		PhaseEvaluator: evaluator,
+		PhaseHooks:     []PhaseHook{hook},
+	}
+
+	conditions.MarkFalse(mp, clusterv1.ReadyCondition, "WaitingForInfrastructure", clusterv1.ConditionSeverityInfo, "")
+	g.Expect(r.reconcilePhase(context.Background(), mp)).To(Succeed())
+	g.Expect(mp.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhaseProvisioned))
+
+	conditions.MarkTrue(mp, clusterv1.ReadyCondition)
+	g.Expect(r.reconcilePhase(context.Background(), mp)).To(Succeed())
+	g.Expect(mp.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhaseRunning))
+	g.Expect(mp.Status.DisplayPhase).To(Equal(string(expv1.MachinePoolPhaseRunning)))
+
+	// Phase hooks see the phases decided by the evaluator.
+	g.Expect(hook.transitions).To(Equal([]phaseTransition{
+		{oldPhase: expv1.MachinePoolPhaseProvisioning, newPhase: expv1.MachinePoolPhaseProvisioned},
+		{oldPhase: expv1.MachinePoolPhaseProvisioned, newPhase: expv1.MachinePoolPhaseRunning},
+	}))
+
+	// Without an evaluator the computed phase is used.
+	r.PhaseEvaluator = nil
+	conditions.MarkFalse(mp, clusterv1.ReadyCondition, "WaitingForInfrastructure", clusterv1.ConditionSeverityInfo, "")
+	g.Expect(r.reconcilePhase(context.Background(), mp)).To(Succeed())
+	g.Expect(mp.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhaseRunning))
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// machinePoolReconcileAge is the time each MachinePool has waited since its last successful reconciliation.
+	machinePoolReconcileAge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capi_machinepool_seconds_since_last_successful_reconcile",
+			Help: "Seconds since the last successful reconciliation of the MachinePool, or since it was first reconciled if it never was.",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	// machinePoolPendingReconciles is the number of MachinePools whose last reconciliation failed or was requeued.
+	machinePoolPendingReconciles = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capi_machinepool_pending_reconciles",
+			Help: "Number of MachinePools waiting for a successful reconciliation.",
+		},
+		[]string{"namespace"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(machinePoolReconcileAge, machinePoolPendingReconciles)
+}
+
+// reconcileState tracks the reconciliations of a MachinePool for the metrics.
+type reconcileState struct {
+	// lastSuccess is the time of the last successful reconciliation, or of the first one if none succeeded yet.
+	lastSuccess time.Time
+	// pending is true if the last reconciliation failed or was requeued.
+	pending bool
+}
+
+// recordReconcileMetrics records the outcome of a reconciliation of a MachinePool in the metrics.
+func (r *MachinePoolReconciler) recordReconcileMetrics(key types.NamespacedName, succeeded bool) {
+	now := r.now()
+	state := reconcileState{lastSuccess: now}
+	if previous, ok := r.reconcileStates.Load(key); ok {
+		state = previous.(reconcileState)
+	}
+
+	if succeeded {
+		state.lastSuccess = now
+	}
+	if pending := !succeeded; pending != state.pending {
+		if pending {
+			machinePoolPendingReconciles.WithLabelValues(key.Namespace).Inc()
+		} else {
+			machinePoolPendingReconciles.WithLabelValues(key.Namespace).Dec()
+		}
+		state.pending = pending
+	}
+
+	r.reconcileStates.Store(key, state)
+	machinePoolReconcileAge.WithLabelValues(key.Namespace, key.Name).Set(now.Sub(state.lastSuccess).Seconds())
+}
+
+// forgetReconcileMetrics removes a deleted MachinePool from the metrics.
+func (r *MachinePoolReconciler) forgetReconcileMetrics(key types.NamespacedName) {
+	previous, ok := r.reconcileStates.Load(key)
+	if !ok {
+		return
+	}
+	if previous.(reconcileState).pending {
+		machinePoolPendingReconciles.WithLabelValues(key.Namespace).Dec()
+	}
+	r.reconcileStates.Delete(key)
+	machinePoolReconcileAge.DeleteLabelValues(key.Namespace, key.Name)
+}
+
+// updateReconcileMetrics refreshes the time each MachinePool has waited since its last successful reconciliation.
+// It is called periodically, so that the metric keeps growing for MachinePools which aren't reconciled anymore.
+func (r *MachinePoolReconciler) updateReconcileMetrics() {
+	now := r.now()
+	r.reconcileStates.Range(func(key, value interface{}) bool {
+		mpKey := key.(types.NamespacedName)
+		state := value.(reconcileState)
+		machinePoolReconcileAge.WithLabelValues(mpKey.Namespace, mpKey.Name).Set(now.Sub(state.lastSuccess).Seconds())
+		return true
+	})
+}
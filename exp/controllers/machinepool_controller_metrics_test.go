@@ -0,0 +1,93 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestMachinePoolReconcileMetrics(t *testing.T) {
+	g := NewWithT(t)
+
+	machinePoolReconcileAge.Reset()
+	machinePoolPendingReconciles.Reset()
+	defer machinePoolReconcileAge.Reset()
+	defer machinePoolPendingReconciles.Reset()
+
+	fakeClock := clock.NewFakeClock(time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC))
+	r := &MachinePoolReconciler{
+		Log:   log.Log,
+		clock: fakeClock,
+	}
+
+	mp1 := types.NamespacedName{Namespace: "default", Name: "mp1"}
+	mp2 := types.NamespacedName{Namespace: "default", Name: "mp2"}
+	mp3 := types.NamespacedName{Namespace: "other", Name: "mp3"}
+
+	age := func(key types.NamespacedName) float64 {
+		return testutil.ToFloat64(machinePoolReconcileAge.WithLabelValues(key.Namespace, key.Name))
+	}
+	pending := func(namespace string) float64 {
+		return testutil.ToFloat64(machinePoolPendingReconciles.WithLabelValues(namespace))
+	}
+
+	// mp1 is reconciled successfully, mp2 and mp3 are requeued.
+	r.recordReconcileMetrics(mp1, true)
+	r.recordReconcileMetrics(mp2, false)
+	r.recordReconcileMetrics(mp3, false)
+	g.Expect(age(mp1)).To(BeZero())
+	g.Expect(age(mp2)).To(BeZero())
+	g.Expect(pending("default")).To(BeEquivalentTo(1))
+	g.Expect(pending("other")).To(BeEquivalentTo(1))
+
+	// The time since the last successful reconciliation grows with the ticker.
+	fakeClock.Step(90 * time.Second)
+	r.updateReconcileMetrics()
+	g.Expect(age(mp1)).To(BeEquivalentTo(90))
+	g.Expect(age(mp2)).To(BeEquivalentTo(90))
+	g.Expect(age(mp3)).To(BeEquivalentTo(90))
+
+	// mp2 keeps failing and mp1 starts failing, mp3 succeeds.
+	fakeClock.Step(30 * time.Second)
+	r.recordReconcileMetrics(mp1, false)
+	r.recordReconcileMetrics(mp2, false)
+	r.recordReconcileMetrics(mp3, true)
+	g.Expect(age(mp1)).To(BeEquivalentTo(120))
+	g.Expect(age(mp2)).To(BeEquivalentTo(120))
+	g.Expect(age(mp3)).To(BeZero())
+	g.Expect(pending("default")).To(BeEquivalentTo(2))
+	g.Expect(pending("other")).To(BeZero())
+
+	// Deleted MachinePools are removed from the metrics.
+	r.forgetReconcileMetrics(mp2)
+	g.Expect(pending("default")).To(BeEquivalentTo(1))
+	g.Expect(testutil.CollectAndCount(machinePoolReconcileAge)).To(Equal(2))
+
+	fakeClock.Step(time.Minute)
+	r.updateReconcileMetrics()
+	g.Expect(age(mp1)).To(BeEquivalentTo(180))
+	g.Expect(age(mp3)).To(BeEquivalentTo(60))
+	g.Expect(testutil.CollectAndCount(machinePoolReconcileAge)).To(Equal(2))
+}
@@ -19,17 +19,25 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	apicorev1 "k8s.io/api/core/v1"
-	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/mdutil"
 	"sigs.k8s.io/cluster-api/controllers/noderefutil"
-	"sigs.k8s.io/cluster-api/controllers/remote"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -41,6 +49,18 @@ type getNodeReferencesResult struct {
 	references []apicorev1.ObjectReference
 	available  int
 	ready      int
+	pending    int
+	versions   map[string]int32
+	// notReadyCreationTimestamps are the creation timestamps of the Nodes which are not Ready.
+	notReadyCreationTimestamps []metav1.Time
+	// oldestCreationTimestamp is the creation timestamp of the oldest Node.
+	oldestCreationTimestamp *metav1.Time
+	// nodeStatuses describe the readiness of the Nodes.
+	nodeStatuses []expv1.MachinePoolNodeStatus
+	// unhealthy is the number of Nodes with the unhealthy condition.
+	unhealthy int
+	// templateHashes counts the Nodes by their TemplateHashAnnotation.
+	templateHashes map[string]int32
 }
 
 func (r *MachinePoolReconciler) reconcileNodeRefs(ctx context.Context, cluster *clusterv1.Cluster, mp *expv1.MachinePool) error {
@@ -50,8 +70,20 @@ func (r *MachinePoolReconciler) reconcileNodeRefs(ctx context.Context, cluster *
 		return nil
 	}
 
-	// Check that the Machine doesn't already have a NodeRefs.
-	if mp.Status.Replicas == mp.Status.ReadyReplicas && len(mp.Status.NodeRefs) == int(mp.Status.ReadyReplicas) {
+	// Validate the provider IDs reported by the infrastructure provider against the expected and allowed prefixes.
+	providerIDList := r.allowedProviderIDs(mp, validateProviderIDs(mp))
+
+	// Check that the Machine doesn't already have a NodeRefs, that its taints and propagated annotations were applied,
+	// and that all its Nodes were reconciled. Nodes are always reconciled during rollouts, as Nodes are replaced without the number of
+	// Nodes changing.
+	// Up to date Nodes are still checked for the unhealthy condition, if configured, as their hosts can fail while
+	// Ready, and for termination signals, if configured, as they can be signaled at any time. Stored NodeRefs are
+	// checked for staleness from time to time, as providers can reuse the provider ID of a replaced instance.
+	upToDate := mp.Status.Replicas == mp.Status.ReadyReplicas && nodeRefsCount(mp) == int(mp.Status.ReadyReplicas) &&
+		taintsApplied(mp) && r.annotationsApplied(mp) && mp.Status.ReconciledNodes == 0 &&
+		(mp.Status.RolloutStatus == nil || !mp.Status.RolloutStatus.InProgress)
+	checkStale := upToDate && len(mp.Status.NodeRefs) != 0 && r.staleNodeRefsCheckDue(mp)
+	if upToDate && !checkStale && r.UnhealthyNodeConditionType == "" && !r.DrainNodesOnTerminationSignal {
 		return nil
 	}
 
@@ -69,19 +101,38 @@ func (r *MachinePoolReconciler) reconcileNodeRefs(ctx context.Context, cluster *
 		return nil
 	}
 
-	clusterClient, err := remote.NewClusterClient(ctx, r.Client, util.ObjectKey(cluster), r.scheme)
+	clusterClient, err := r.getRemoteClient(ctx, cluster, mp)
+	if err != nil {
+		return err
+	}
+
+	// List the Nodes once, and pass them through, so that the number of reads doesn't grow with the
+	// size of the MachinePool.
+	nodes, err := listNodes(ctx, clusterClient)
 	if err != nil {
 		return err
 	}
 
-	if err = r.deleteRetiredNodes(ctx, clusterClient, mp.Status.NodeRefs, mp.Spec.ProviderIDList); err != nil {
+	if upToDate {
+		nodesByProviderID := newestNodesByProviderID(nodes)
+		stale := checkStale && r.nodeRefsStale(mp, nodesByProviderID, providerIDList)
+		if !stale && !r.unhealthyNodesChanged(mp, nodesByProviderID, providerIDList) &&
+			!r.terminationSignalsPending(nodesByProviderID, providerIDList) {
+			return nil
+		}
+	}
+
+	// Only the count of the previous Node references is known in count only mode, in which case
+	// retired Nodes are left to be removed by the cloud provider.
+	if err = r.deleteRetiredNodes(ctx, clusterClient, cluster, mp, nodes, mp.Status.NodeRefs, mp.Spec.ProviderIDList); err != nil {
 		return err
 	}
 
 	// Get the Node references.
-	nodeRefsResult, err := r.getNodeReferences(ctx, clusterClient, mp.Spec.ProviderIDList)
+	nodeRefsResult, err := r.getNodeReferences(nodes, nodeReadyConditionType(mp), providerIDList)
 	if err != nil {
 		if err == ErrNoAvailableNodes {
+			mp.Status.PendingReplicas = int32(nodeRefsResult.pending)
 			return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: 10 * time.Second},
 				"cannot assign NodeRefs to MachinePool, no matching Nodes")
 		}
@@ -89,13 +140,65 @@ func (r *MachinePoolReconciler) reconcileNodeRefs(ctx context.Context, cluster *
 		return errors.Wrapf(err, "failed to get node references")
 	}
 
+	nodeRefsChanged := !reflect.DeepEqual(mp.Status.NodeRefs, nodeRefsResult.references)
+	if r.NodeRefsCountOnly {
+		nodeRefsChanged = int(mp.Status.NodeRefsCount) != len(nodeRefsResult.references)
+	}
+
 	mp.Status.ReadyReplicas = int32(nodeRefsResult.ready)
 	mp.Status.AvailableReplicas = int32(nodeRefsResult.available)
 	mp.Status.UnavailableReplicas = mp.Status.Replicas - mp.Status.AvailableReplicas
-	mp.Status.NodeRefs = nodeRefsResult.references
+	mp.Status.PendingReplicas = int32(nodeRefsResult.pending)
+	mp.Status.NodeRefsCount = int32(len(nodeRefsResult.references))
+	if r.NodeRefsCountOnly {
+		mp.Status.NodeRefs = nil
+	} else {
+		mp.Status.NodeRefs = nodeRefsResult.references
+	}
+	pruneNodeDrains(mp, nodeRefsResult.references)
+	mp.Status.NodeVersions = nodeRefsResult.versions
+	reconcileNodeVersionsUpToDate(mp)
+	reconcileRolloutStatus(mp, nodeRefsResult.templateHashes, len(nodeRefsResult.references))
+	mp.Status.OldestNodeCreationTime = nodeRefsResult.oldestCreationTimestamp
+	mp.Status.NodeStatuses = nil
+	if r.NodeStatusesLimit > 0 {
+		mp.Status.NodeStatuses = nodeRefsResult.nodeStatuses
+		if len(mp.Status.NodeStatuses) > r.NodeStatusesLimit {
+			mp.Status.NodeStatuses = mp.Status.NodeStatuses[:r.NodeStatusesLimit]
+		}
+	}
+	r.reconcileNodeStartupTimeout(mp, nodeRefsResult.notReadyCreationTimestamps)
+	r.reconcileUnhealthyNodes(mp, nodeRefsResult.unhealthy)
+
+	// Reconcile the Nodes from where the previous reconciliation stopped, within the node read budget.
+	start, end := int(mp.Status.ReconciledNodes), len(nodeRefsResult.references)
+	if start >= end {
+		start = 0
+	}
+	if r.NodeReadBudget > 0 && start+r.NodeReadBudget < end {
+		end = start + r.NodeReadBudget
+	}
+	if err := r.reconcileNodes(ctx, cluster, clusterClient, mp, referencedNodes(nodes, nodeRefsResult.references[start:end])); err != nil {
+		return err
+	}
+	mp.Status.ReconciledNodes = 0
+	if end < len(nodeRefsResult.references) {
+		mp.Status.ReconciledNodes = int32(end)
+	} else {
+		recordAppliedTaints(mp)
+		r.recordAppliedAnnotations(mp)
+	}
 
-	logger.Info("Set MachinePools's NodeRefs", "noderefs", mp.Status.NodeRefs)
-	r.recorder.Event(mp, apicorev1.EventTypeNormal, "SuccessfulSetNodeRefs", fmt.Sprintf("%+v", mp.Status.NodeRefs))
+	if nodeRefsChanged {
+		logger.Info("Set MachinePools's NodeRefs", "noderefs", nodeRefsResult.references)
+		r.recorder.Event(mp, apicorev1.EventTypeNormal, "SuccessfulSetNodeRefs", fmt.Sprintf("%+v", nodeRefsResult.references))
+	}
+
+	if mp.Status.ReconciledNodes != 0 {
+		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: nodeReadBudgetWait},
+			"reconciled %d out of %d Nodes within the node read budget for MachinePool %q in namespace %q",
+			mp.Status.ReconciledNodes, len(nodeRefsResult.references), mp.Name, mp.Namespace)
+	}
 
 	if mp.Status.Replicas != mp.Status.ReadyReplicas || len(nodeRefsResult.references) != int(mp.Status.ReadyReplicas) {
 		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: 30 * time.Second},
@@ -104,16 +207,564 @@ func (r *MachinePoolReconciler) reconcileNodeRefs(ctx context.Context, cluster *
 	return nil
 }
 
+// validateProviderIDs checks the entries of Spec.ProviderIDList against Spec.ProviderIDPrefix, if any,
+// setting the ProviderIDsValid condition accordingly, and returns the provider IDs matching the prefix.
+func validateProviderIDs(mp *expv1.MachinePool) []string {
+	if mp.Spec.ProviderIDPrefix == "" {
+		return mp.Spec.ProviderIDList
+	}
+
+	var valid, unexpected []string
+	for _, providerID := range mp.Spec.ProviderIDList {
+		if strings.HasPrefix(providerID, mp.Spec.ProviderIDPrefix) {
+			valid = append(valid, providerID)
+			continue
+		}
+		unexpected = append(unexpected, providerID)
+	}
+
+	if len(unexpected) > 0 {
+		conditions.MarkFalse(mp, expv1.ProviderIDsValidCondition, expv1.UnexpectedProviderIDReason, clusterv1.ConditionSeverityWarning,
+			"Provider IDs %s don't match the expected prefix %q", strings.Join(unexpected, ", "), mp.Spec.ProviderIDPrefix)
+	} else {
+		conditions.MarkTrue(mp, expv1.ProviderIDsValidCondition)
+	}
+	return valid
+}
+
+// allowedProviderIDs checks the given provider IDs of a MachinePool against the AllowedProviderIDPrefixes, if any,
+// setting the ProviderIDsAllowed condition accordingly, and returns the allowed provider IDs.
+func (r *MachinePoolReconciler) allowedProviderIDs(mp *expv1.MachinePool, providerIDList []string) []string {
+	if len(r.AllowedProviderIDPrefixes) == 0 {
+		return providerIDList
+	}
+
+	var allowed, rejected []string
+	for _, providerID := range providerIDList {
+		if hasAnyPrefix(providerID, r.AllowedProviderIDPrefixes) {
+			allowed = append(allowed, providerID)
+			continue
+		}
+		rejected = append(rejected, providerID)
+	}
+
+	if len(rejected) == 0 {
+		conditions.MarkTrue(mp, expv1.ProviderIDsAllowedCondition)
+		return allowed
+	}
+
+	message := fmt.Sprintf("Provider IDs %s are outside the allowed prefixes %s",
+		strings.Join(rejected, ", "), strings.Join(r.AllowedProviderIDPrefixes, ", "))
+	if conditions.GetMessage(mp, expv1.ProviderIDsAllowedCondition) != message {
+		r.recorder.Event(mp, apicorev1.EventTypeWarning, "ProviderIDNotAllowed", message)
+	}
+	conditions.MarkFalse(mp, expv1.ProviderIDsAllowedCondition, expv1.ProviderIDNotAllowedReason, clusterv1.ConditionSeverityError, "%s", message)
+	return allowed
+}
+
+// hasAnyPrefix returns true if s starts with one of the given prefixes.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileNodeStartupTimeout counts the Nodes which didn't become Ready within Spec.NodeStartupTimeout
+// after joining the cluster, and sets the NodesHealthy condition accordingly.
+func (r *MachinePoolReconciler) reconcileNodeStartupTimeout(mp *expv1.MachinePool, notReadyCreationTimestamps []metav1.Time) {
+	mp.Status.UnhealthyReplicas = 0
+	if mp.Spec.NodeStartupTimeout == nil {
+		return
+	}
+
+	now := r.now()
+	for _, creationTimestamp := range notReadyCreationTimestamps {
+		if now.Sub(creationTimestamp.Time) > mp.Spec.NodeStartupTimeout.Duration {
+			mp.Status.UnhealthyReplicas++
+		}
+	}
+
+	if mp.Status.UnhealthyReplicas > 0 {
+		conditions.MarkFalse(mp, expv1.NodesHealthyCondition, clusterv1.UnhealthyNodeConditionReason, clusterv1.ConditionSeverityWarning,
+			"%d Nodes did not become Ready within the startup timeout of %s", mp.Status.UnhealthyReplicas, mp.Spec.NodeStartupTimeout.Duration)
+		return
+	}
+	conditions.MarkTrue(mp, expv1.NodesHealthyCondition)
+}
+
+// reconcileUnhealthyNodes adds the given number of Nodes with the unhealthy condition to Status.UnhealthyReplicas,
+// and marks the NodesHealthy condition as false if there are any, or as true once no Node is unhealthy anymore.
+func (r *MachinePoolReconciler) reconcileUnhealthyNodes(mp *expv1.MachinePool, unhealthy int) {
+	if unhealthy == 0 {
+		if r.UnhealthyNodeConditionType != "" && mp.Status.UnhealthyReplicas == 0 {
+			conditions.MarkTrue(mp, expv1.NodesHealthyCondition)
+		}
+		return
+	}
+
+	mp.Status.UnhealthyReplicas += int32(unhealthy)
+	conditions.MarkFalse(mp, expv1.NodesHealthyCondition, clusterv1.UnhealthyNodeConditionReason, clusterv1.ConditionSeverityWarning,
+		"%d Nodes are unhealthy, %d of which report the %s condition", mp.Status.UnhealthyReplicas, unhealthy, r.UnhealthyNodeConditionType)
+}
+
+// reconcileNodeVersionsUpToDate sets the NodeVersionsUpToDate condition of a MachinePool with a version according to
+// whether all its Nodes run that version. Only the major, minor and patch versions are compared, as kubelet versions
+// can carry a provider specific suffix.
+func reconcileNodeVersionsUpToDate(mp *expv1.MachinePool) {
+	if mp.Spec.Template.Spec.Version == nil {
+		conditions.Delete(mp, expv1.NodeVersionsUpToDateCondition)
+		return
+	}
+	desired, err := util.ParseMajorMinorPatch(*mp.Spec.Template.Spec.Version)
+	if err != nil {
+		conditions.Delete(mp, expv1.NodeVersionsUpToDateCondition)
+		return
+	}
+
+	var lagging int32
+	var laggingVersions []string
+	for version, count := range mp.Status.NodeVersions {
+		if v, err := util.ParseMajorMinorPatch(version); err == nil && v.Equals(desired) {
+			continue
+		}
+		lagging += count
+		laggingVersions = append(laggingVersions, fmt.Sprintf("%s (%d)", version, count))
+	}
+	if lagging == 0 {
+		conditions.MarkTrue(mp, expv1.NodeVersionsUpToDateCondition)
+		return
+	}
+
+	sort.Strings(laggingVersions)
+	conditions.MarkFalse(mp, expv1.NodeVersionsUpToDateCondition, expv1.NodeVersionsLaggingReason, clusterv1.ConditionSeverityInfo,
+		"%d Nodes don't run version %s: %s", lagging, *mp.Spec.Template.Spec.Version, strings.Join(laggingVersions, ", "))
+}
+
+// reconcileRolloutStatus sets Status.RolloutStatus from the given number of Nodes of the MachinePool and their counts
+// by TemplateHashAnnotation. The rollout is in progress until all the desired replicas, and only them, have a Node
+// created from Spec.Template.
+func reconcileRolloutStatus(mp *expv1.MachinePool, templateHashes map[string]int32, nodes int) {
+	templateHash := machinePoolTemplateHash(mp)
+	status := &expv1.MachinePoolRolloutStatus{
+		TemplateHash:    templateHash,
+		UpdatedReplicas: templateHashes[templateHash],
+		Replicas:        int32(nodes),
+	}
+	if mp.Spec.Strategy != nil {
+		status.Strategy = mp.Spec.Strategy.Type
+	}
+	desired := int32(1)
+	if mp.Spec.Replicas != nil || replicasExternallyManaged(mp) {
+		desired = desiredReplicas(mp)
+	}
+	status.InProgress = status.UpdatedReplicas < desired || status.Replicas > status.UpdatedReplicas
+	mp.Status.RolloutStatus = status
+}
+
+// machinePoolTemplateHash returns the hash of the template of a MachinePool. The bootstrap data, set and rotated by
+// the controller, is left out so that the hash only changes with the template set by users.
+func machinePoolTemplateHash(mp *expv1.MachinePool) string {
+	template := mp.Spec.Template.DeepCopy()
+	template.Spec.Bootstrap.Data = nil
+	template.Spec.Bootstrap.DataSecretName = nil
+	return fmt.Sprintf("%d", mdutil.ComputeHash(template))
+}
+
+// reconcileNodes applies the taints of the MachinePool, and the zone and image labels from the failure domains and
+// images of its instances, to the given Nodes, and uncordons the ones which were cordoned by the controller once they
+// are Ready. Nodes signaled for termination are drained first, if configured. Nodes with the unhealthy condition
+// are cordoned instead, and their replacement requested if configured. Each Node is patched, if needed, once.
+func (r *MachinePoolReconciler) reconcileNodes(ctx context.Context, cluster *clusterv1.Cluster, c client.Client, mp *expv1.MachinePool, nodes []*apicorev1.Node) error {
+	logger := r.Log.WithValues("machinepool", mp.Name, "namespace", mp.Namespace)
+
+	zones := make(map[string]string, len(mp.Status.InstanceFailureDomains))
+	for providerID, failureDomain := range mp.Status.InstanceFailureDomains {
+		pid, err := noderefutil.NewProviderID(providerID)
+		if err != nil {
+			logger.V(2).Info("Failed to parse ProviderID, skipping", "err", err, "providerID", providerID)
+			continue
+		}
+		zones[pid.ID()] = failureDomain
+	}
+
+	images := make(map[string]string, len(mp.Status.InstanceImages))
+	for providerID, image := range mp.Status.InstanceImages {
+		pid, err := noderefutil.NewProviderID(providerID)
+		if err != nil {
+			logger.V(2).Info("Failed to parse ProviderID, skipping", "err", err, "providerID", providerID)
+			continue
+		}
+		if errs := validation.IsValidLabelValue(image); len(errs) > 0 {
+			logger.V(2).Info("Image isn't a valid label value, skipping", "image", image, "providerID", providerID, "errs", errs)
+			continue
+		}
+		images[pid.ID()] = image
+	}
+
+	annotations := r.propagatedAnnotations(mp)
+	readyConditionType := nodeReadyConditionType(mp)
+	for _, node := range nodes {
+		patchBase := client.MergeFrom(node.DeepCopy())
+		changed := applyManagedTaints(node, mp.Spec.Taints)
+		if applyZoneLabel(node, zones) {
+			changed = true
+		}
+		if applyImageLabel(node, images) {
+			changed = true
+		}
+		if applyMachinePoolAnnotations(node, mp) {
+			changed = true
+		}
+		if applyPropagatedAnnotations(node, annotations) {
+			changed = true
+		}
+		unhealthy := r.nodeIsUnhealthy(node)
+		if unhealthy {
+			if r.cordonUnhealthyNode(mp, node) {
+				changed = true
+			}
+		}
+		if r.DrainNodesOnTerminationSignal {
+			if markDrainOnTermination(node) {
+				changed = true
+			}
+			if terminationSignaled(node) {
+				// Acknowledge the termination signal only once the Node is drained, and keep it cordoned.
+				if err := r.drainNode(ctx, cluster, mp, node); err != nil {
+					return err
+				}
+				node.Annotations[expv1.TerminationAcknowledgedAnnotation] = ""
+				changed = true
+			} else if !unhealthy && uncordonNode(node, readyConditionType) {
+				changed = true
+			}
+		} else if !unhealthy && uncordonNode(node, readyConditionType) {
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+		if err := c.Patch(ctx, node, patchBase); err != nil {
+			return errors.Wrapf(err, "failed to patch Node %q", node.Name)
+		}
+	}
+	return nil
+}
+
+// applyImageLabel sets the NodeImageLabel of the Node to the image of its instance, from the given images by
+// provider ID, and returns true if the Node changed. Nodes whose image isn't reported keep their label.
+func applyImageLabel(node *apicorev1.Node, images map[string]string) bool {
+	pid, err := noderefutil.NewProviderID(node.Spec.ProviderID)
+	if err != nil {
+		return false
+	}
+	image, ok := images[pid.ID()]
+	if !ok || image == "" || node.Labels[expv1.NodeImageLabel] == image {
+		return false
+	}
+
+	if node.Labels == nil {
+		node.Labels = make(map[string]string)
+	}
+	node.Labels[expv1.NodeImageLabel] = image
+	return true
+}
+
+// applyMachinePoolAnnotations sets the NodeMachinePoolAnnotation and NodeMachinePoolNamespaceAnnotation of the Node
+// to the MachinePool it belongs to, and returns true if the Node changed. Other annotations are left untouched.
+func applyMachinePoolAnnotations(node *apicorev1.Node, mp *expv1.MachinePool) bool {
+	if node.Annotations[expv1.NodeMachinePoolAnnotation] == mp.Name &&
+		node.Annotations[expv1.NodeMachinePoolNamespaceAnnotation] == mp.Namespace {
+		return false
+	}
+
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+	node.Annotations[expv1.NodeMachinePoolAnnotation] = mp.Name
+	node.Annotations[expv1.NodeMachinePoolNamespaceAnnotation] = mp.Namespace
+	return true
+}
+
+// applyPropagatedAnnotations sets the given annotations on the Node, removing the ones previously propagated by the
+// controller as recorded in the ManagedAnnotationsAnnotation, and returns true if the Node changed.
+func applyPropagatedAnnotations(node *apicorev1.Node, annotations map[string]string) bool {
+	previous := node.Annotations[expv1.ManagedAnnotationsAnnotation]
+	if previous == "" && len(annotations) == 0 {
+		return false
+	}
+
+	changed := false
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+	if previous != "" {
+		for _, key := range strings.Split(previous, ",") {
+			if _, ok := annotations[key]; ok {
+				continue
+			}
+			if _, ok := node.Annotations[key]; ok {
+				delete(node.Annotations, key)
+				changed = true
+			}
+		}
+	}
+	for key, value := range annotations {
+		if current, ok := node.Annotations[key]; !ok || current != value {
+			node.Annotations[key] = value
+			changed = true
+		}
+	}
+
+	newAnnotation := strings.Join(sets.StringKeySet(annotations).List(), ",")
+	if previous != newAnnotation {
+		changed = true
+	}
+	if newAnnotation == "" {
+		delete(node.Annotations, expv1.ManagedAnnotationsAnnotation)
+	} else {
+		node.Annotations[expv1.ManagedAnnotationsAnnotation] = newAnnotation
+	}
+	return changed
+}
+
+// propagatedAnnotations returns the annotations of a MachinePool matching the PropagatedAnnotationPrefixes.
+func (r *MachinePoolReconciler) propagatedAnnotations(mp *expv1.MachinePool) map[string]string {
+	annotations := make(map[string]string)
+	for key, value := range mp.Annotations {
+		if key == expv1.AppliedAnnotationsHashAnnotation {
+			continue
+		}
+		if hasAnyPrefix(key, r.PropagatedAnnotationPrefixes) {
+			annotations[key] = value
+		}
+	}
+	return annotations
+}
+
+// annotationsApplied returns true if the propagated annotations of a MachinePool were applied to all its Nodes, as
+// recorded in the AppliedAnnotationsHashAnnotation.
+func (r *MachinePoolReconciler) annotationsApplied(mp *expv1.MachinePool) bool {
+	return mp.Annotations[expv1.AppliedAnnotationsHashAnnotation] == annotationsHash(r.propagatedAnnotations(mp))
+}
+
+// recordAppliedAnnotations records the propagated annotations of a MachinePool, once applied to all its Nodes, in the
+// AppliedAnnotationsHashAnnotation.
+func (r *MachinePoolReconciler) recordAppliedAnnotations(mp *expv1.MachinePool) {
+	hash := annotationsHash(r.propagatedAnnotations(mp))
+	if hash == "" {
+		delete(mp.Annotations, expv1.AppliedAnnotationsHashAnnotation)
+		return
+	}
+	if mp.Annotations == nil {
+		mp.Annotations = make(map[string]string)
+	}
+	mp.Annotations[expv1.AppliedAnnotationsHashAnnotation] = hash
+}
+
+// annotationsHash returns a hash of the given annotations, or an empty string if there are none.
+func annotationsHash(annotations map[string]string) string {
+	if len(annotations) == 0 {
+		return ""
+	}
+	hasher := fnv.New32a()
+	for _, key := range sets.StringKeySet(annotations).List() {
+		_, _ = hasher.Write([]byte(fmt.Sprintf("%s=%s\n", key, annotations[key])))
+	}
+	return fmt.Sprintf("%x", hasher.Sum32())
+}
+
+// applyZoneLabel sets the zone label of the Node to the failure domain of its instance, from the given failure
+// domains by provider ID, and returns true if the Node changed. Only zone labels set by the controller, as recorded
+// by the ManagedZoneLabelAnnotation, are updated.
+func applyZoneLabel(node *apicorev1.Node, zones map[string]string) bool {
+	pid, err := noderefutil.NewProviderID(node.Spec.ProviderID)
+	if err != nil {
+		return false
+	}
+	zone, ok := zones[pid.ID()]
+	if !ok || zone == "" {
+		return false
+	}
+
+	current, labeled := node.Labels[apicorev1.LabelZoneFailureDomainStable]
+	_, managed := node.Annotations[expv1.ManagedZoneLabelAnnotation]
+	if labeled && (!managed || current == zone) {
+		return false
+	}
+
+	if node.Labels == nil {
+		node.Labels = make(map[string]string)
+	}
+	node.Labels[apicorev1.LabelZoneFailureDomainStable] = zone
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+	node.Annotations[expv1.ManagedZoneLabelAnnotation] = ""
+	return true
+}
+
+// nodeIsUnhealthy returns true if the Node has the UnhealthyNodeConditionType condition True.
+func (r *MachinePoolReconciler) nodeIsUnhealthy(node *apicorev1.Node) bool {
+	if r.UnhealthyNodeConditionType == "" {
+		return false
+	}
+	for _, c := range node.Status.Conditions {
+		if string(c.Type) == r.UnhealthyNodeConditionType {
+			return c.Status == apicorev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// cordonUnhealthyNode cordons an unhealthy Node with the CordonedAnnotation, so that it is uncordoned once healthy
+// and Ready again, requests its replacement if configured, and returns true if the Node changed.
+func (r *MachinePoolReconciler) cordonUnhealthyNode(mp *expv1.MachinePool, node *apicorev1.Node) bool {
+	changed := false
+	if !node.Spec.Unschedulable {
+		node.Spec.Unschedulable = true
+		if node.Annotations == nil {
+			node.Annotations = make(map[string]string)
+		}
+		node.Annotations[expv1.CordonedAnnotation] = ""
+		r.recorder.Eventf(mp, apicorev1.EventTypeWarning, "UnhealthyNodeCordoned", "Cordoned Node %q reporting the %s condition",
+			node.Name, r.UnhealthyNodeConditionType)
+		changed = true
+	}
+	if _, ok := node.Annotations[expv1.ReplacementRequestedAnnotation]; r.ReplaceUnhealthyNodes && !ok {
+		if node.Annotations == nil {
+			node.Annotations = make(map[string]string)
+		}
+		node.Annotations[expv1.ReplacementRequestedAnnotation] = ""
+		changed = true
+	}
+	return changed
+}
+
+// uncordonNode marks a Node which was cordoned by the controller as schedulable again once it is ready
+// according to the given condition type, and returns true if the Node changed.
+func uncordonNode(node *apicorev1.Node, readyConditionType apicorev1.NodeConditionType) bool {
+	if _, ok := node.Annotations[expv1.CordonedAnnotation]; !ok || !nodeIsReady(node, readyConditionType) {
+		return false
+	}
+	node.Spec.Unschedulable = false
+	delete(node.Annotations, expv1.CordonedAnnotation)
+	return true
+}
+
+// applyManagedTaints sets the given taints on the Node, replacing the ones previously added by the controller
+// as recorded in the ManagedTaintsAnnotation, and returns true if the Node changed. Managed taints dropped from
+// the given taints are removed. Taints are identified by key and effect; taints not added by the controller are
+// preserved, and a given taint already set on the Node by other means is left as is and isn't recorded as managed,
+// so that it isn't removed once dropped from the given taints.
+func applyManagedTaints(node *apicorev1.Node, taints []apicorev1.Taint) bool {
+	previous := node.Annotations[expv1.ManagedTaintsAnnotation]
+	if previous == "" && len(taints) == 0 {
+		return false
+	}
+
+	managed := sets.NewString()
+	if previous != "" {
+		managed.Insert(strings.Split(previous, ",")...)
+	}
+	unmanaged := sets.NewString()
+	newTaints := []apicorev1.Taint{}
+	for _, taint := range node.Spec.Taints {
+		if managed.Has(taintKey(taint)) {
+			continue
+		}
+		unmanaged.Insert(taintKey(taint))
+		newTaints = append(newTaints, taint)
+	}
+
+	desired := sets.NewString()
+	for _, taint := range taints {
+		if unmanaged.Has(taintKey(taint)) {
+			continue
+		}
+		desired.Insert(taintKey(taint))
+		newTaints = append(newTaints, taint)
+	}
+
+	newAnnotation := strings.Join(desired.List(), ",")
+	if previous == newAnnotation && reflect.DeepEqual(node.Spec.Taints, newTaints) {
+		return false
+	}
+
+	node.Spec.Taints = newTaints
+	if newAnnotation == "" {
+		delete(node.Annotations, expv1.ManagedTaintsAnnotation)
+		return true
+	}
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+	node.Annotations[expv1.ManagedTaintsAnnotation] = newAnnotation
+	return true
+}
+
+// taintsApplied returns true if the Spec.Taints of a MachinePool were applied to all its Nodes, as recorded in the
+// AppliedTaintsHashAnnotation.
+func taintsApplied(mp *expv1.MachinePool) bool {
+	return mp.Annotations[expv1.AppliedTaintsHashAnnotation] == taintsHash(mp.Spec.Taints)
+}
+
+// recordAppliedTaints records the Spec.Taints of a MachinePool, once applied to all its Nodes, in the
+// AppliedTaintsHashAnnotation.
+func recordAppliedTaints(mp *expv1.MachinePool) {
+	hash := taintsHash(mp.Spec.Taints)
+	if hash == "" {
+		delete(mp.Annotations, expv1.AppliedTaintsHashAnnotation)
+		return
+	}
+	if mp.Annotations == nil {
+		mp.Annotations = make(map[string]string)
+	}
+	mp.Annotations[expv1.AppliedTaintsHashAnnotation] = hash
+}
+
+// taintsHash returns a hash of the given taints, or an empty string if there are none.
+func taintsHash(taints []apicorev1.Taint) string {
+	if len(taints) == 0 {
+		return ""
+	}
+	hasher := fnv.New32a()
+	for _, taint := range taints {
+		_, _ = hasher.Write([]byte(fmt.Sprintf("%s=%s\n", taintKey(taint), taint.Value)))
+	}
+	return fmt.Sprintf("%x", hasher.Sum32())
+}
+
+func taintKey(taint apicorev1.Taint) string {
+	return fmt.Sprintf("%s:%s", taint.Key, taint.Effect)
+}
+
 // deleteRetiredNodes deletes nodes that don't have a corresponding ProviderID in Spec.ProviderIDList.
+// When failure domains are drained sequentially, only the Nodes of the first failure domain are deleted
+// and a RequeueAfterError is returned if Nodes of other failure domains are left to delete. The Nodes are
+// drained before being deleted if the reconciler is configured to drain retired Nodes. The referenced Nodes are
+// looked up in the given Nodes, listed from the workload cluster.
 // A MachinePool infrastucture provider indicates an instance in the set has been deleted by
 // removing its ProviderID from the slice.
-func (r *MachinePoolReconciler) deleteRetiredNodes(ctx context.Context, c client.Client, nodeRefs []apicorev1.ObjectReference, providerIDList []string) error {
+func (r *MachinePoolReconciler) deleteRetiredNodes(ctx context.Context, c client.Client, cluster *clusterv1.Cluster, mp *expv1.MachinePool, nodes []apicorev1.Node, nodeRefs []apicorev1.ObjectReference, providerIDList []string) error {
 	logger := r.Log.WithValues("providerIDList", len(providerIDList))
+	if len(nodeRefs) == 0 {
+		return nil
+	}
+
+	nodesByName := make(map[string]*apicorev1.Node, len(nodes))
+	for i := range nodes {
+		nodesByName[nodes[i].Name] = &nodes[i]
+	}
+
 	nodeRefsMap := make(map[string]*apicorev1.Node, len(nodeRefs))
 	for _, nodeRef := range nodeRefs {
-		node := &corev1.Node{}
-		if err := c.Get(ctx, client.ObjectKey{Name: nodeRef.Name}, node); err != nil {
-			logger.V(2).Info("Failed to get Node, skipping", "err", err, "nodeRef.Name", nodeRef.Name)
+		node, ok := nodesByName[nodeRef.Name]
+		if !ok {
+			logger.V(2).Info("Node not found, skipping", "nodeRef.Name", nodeRef.Name)
 			continue
 		}
 
@@ -133,41 +784,129 @@ func (r *MachinePoolReconciler) deleteRetiredNodes(ctx context.Context, c client
 		}
 		delete(nodeRefsMap, pid.ID())
 	}
+	retiredNodes := make([]*apicorev1.Node, 0, len(nodeRefsMap))
 	for _, node := range nodeRefsMap {
+		retiredNodes = append(retiredNodes, node)
+	}
+	deferred := false
+	if r.DrainFailureDomainsSequentially {
+		retiredNodes, deferred = firstFailureDomainNodes(retiredNodes)
+	}
+	for _, node := range retiredNodes {
+		if r.DrainRetiredNodes {
+			if err := r.drainNode(ctx, cluster, mp, node); err != nil {
+				return err
+			}
+		}
 		if err := c.Delete(ctx, node); err != nil {
 			return errors.Wrapf(err, "failed to delete Node")
 		}
 	}
+	if deferred {
+		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: failureDomainDrainWait},
+			"deleted the retired Nodes of failure domain %q, Nodes of other failure domains are left to delete", nodeFailureDomain(retiredNodes[0]))
+	}
 	return nil
 }
 
-func (r *MachinePoolReconciler) getNodeReferences(ctx context.Context, c client.Client, providerIDList []string) (getNodeReferencesResult, error) {
-	logger := r.Log.WithValues("providerIDList", len(providerIDList))
+// nodeFailureDomain returns the failure domain of a Node, read from its zone labels.
+func nodeFailureDomain(node *apicorev1.Node) string {
+	if zone, ok := node.Labels[apicorev1.LabelZoneFailureDomainStable]; ok {
+		return zone
+	}
+	return node.Labels[apicorev1.LabelZoneFailureDomain]
+}
 
-	var ready, available int
-	nodeRefsMap := make(map[string]apicorev1.Node)
-	nodeList := apicorev1.NodeList{}
-	for {
-		if err := c.List(ctx, &nodeList, client.Continue(nodeList.Continue)); err != nil {
-			return getNodeReferencesResult{}, errors.Wrapf(err, "failed to List nodes")
+// firstFailureDomainNodes returns the Nodes of the first failure domain, in lexical order, and whether
+// Nodes of other failure domains were left out. Nodes without a failure domain come first.
+func firstFailureDomainNodes(nodes []*apicorev1.Node) ([]*apicorev1.Node, bool) {
+	if len(nodes) == 0 {
+		return nodes, false
+	}
+
+	first := nodeFailureDomain(nodes[0])
+	for _, node := range nodes[1:] {
+		if failureDomain := nodeFailureDomain(node); failureDomain < first {
+			first = failureDomain
 		}
+	}
 
-		for _, node := range nodeList.Items {
-			nodeProviderID, err := noderefutil.NewProviderID(node.Spec.ProviderID)
-			if err != nil {
-				logger.V(2).Info("Failed to parse ProviderID, skipping", "err", err, "providerID", node.Spec.ProviderID)
-				continue
-			}
+	selected := make([]*apicorev1.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if nodeFailureDomain(node) == first {
+			selected = append(selected, node)
+		}
+	}
+	return selected, len(selected) < len(nodes)
+}
+
+// nodeRefsCount returns the number of Nodes referenced by a MachinePool, whether or not their references are stored.
+func nodeRefsCount(mp *expv1.MachinePool) int {
+	if len(mp.Status.NodeRefs) != 0 {
+		return len(mp.Status.NodeRefs)
+	}
+	return int(mp.Status.NodeRefsCount)
+}
+
+// getNodeRefs returns the Node references of a MachinePool. If only their count is stored,
+// the given Nodes are looked up by the provider IDs of the MachinePool instead.
+func (r *MachinePoolReconciler) getNodeRefs(mp *expv1.MachinePool, nodes []apicorev1.Node) []apicorev1.ObjectReference {
+	if len(mp.Status.NodeRefs) != 0 || mp.Status.NodeRefsCount == 0 {
+		return mp.Status.NodeRefs
+	}
+
+	// The only error is ErrNoAvailableNodes, in which case there are no Node references.
+	nodeRefsResult, _ := r.getNodeReferences(nodes, nodeReadyConditionType(mp), mp.Spec.ProviderIDList)
+	return nodeRefsResult.references
+}
+
+// referencedNodes returns the given Nodes which are referenced by nodeRefs, in the order of nodeRefs.
+// References to Nodes which don't exist anymore are skipped.
+func referencedNodes(nodes []apicorev1.Node, nodeRefs []apicorev1.ObjectReference) []*apicorev1.Node {
+	nodesByName := make(map[string]*apicorev1.Node, len(nodes))
+	for i := range nodes {
+		nodesByName[nodes[i].Name] = &nodes[i]
+	}
+
+	referenced := make([]*apicorev1.Node, 0, len(nodeRefs))
+	for _, nodeRef := range nodeRefs {
+		if node, ok := nodesByName[nodeRef.Name]; ok {
+			referenced = append(referenced, node)
+		}
+	}
+	return referenced
+}
 
-			nodeRefsMap[nodeProviderID.ID()] = node
+// listNodes lists all the Nodes of a workload cluster, page by page.
+func listNodes(ctx context.Context, c client.Client) ([]apicorev1.Node, error) {
+	var nodes []apicorev1.Node
+	nodeList := apicorev1.NodeList{}
+	for {
+		if err := c.List(ctx, &nodeList, client.Continue(nodeList.Continue)); err != nil {
+			return nil, errors.Wrapf(err, "failed to List nodes")
 		}
+		nodes = append(nodes, nodeList.Items...)
 
 		if nodeList.Continue == "" {
 			break
 		}
 	}
+	return nodes, nil
+}
+
+// getNodeReferences returns the references to the given Nodes matching the provider IDs of a MachinePool.
+func (r *MachinePoolReconciler) getNodeReferences(nodes []apicorev1.Node, readyConditionType apicorev1.NodeConditionType, providerIDList []string) (getNodeReferencesResult, error) {
+	logger := r.Log.WithValues("providerIDList", len(providerIDList))
+
+	var ready, available, pending, unhealthy int
+	nodeRefsMap := newestNodesByProviderID(nodes)
 
 	var nodeRefs []apicorev1.ObjectReference
+	var notReady []metav1.Time
+	var oldest *metav1.Time
+	var nodeStatuses []expv1.MachinePoolNodeStatus
+	versions := make(map[string]int32)
+	templateHashes := make(map[string]int32)
 	for _, providerID := range providerIDList {
 		pid, err := noderefutil.NewProviderID(providerID)
 		if err != nil {
@@ -176,27 +915,166 @@ func (r *MachinePoolReconciler) getNodeReferences(ctx context.Context, c client.
 		}
 		if node, ok := nodeRefsMap[pid.ID()]; ok {
 			available++
-			if nodeIsReady(&node) {
+			if nodeIsReady(node, readyConditionType) && r.nodePassesReadinessGate(node) {
 				ready++
+			} else {
+				notReady = append(notReady, node.CreationTimestamp)
+			}
+			if r.nodeIsUnhealthy(node) {
+				unhealthy++
+			}
+			if version := node.Status.NodeInfo.KubeletVersion; version != "" {
+				versions[version]++
 			}
+			if templateHash := node.Annotations[expv1.TemplateHashAnnotation]; templateHash != "" {
+				templateHashes[templateHash]++
+			}
+			if oldest == nil || node.CreationTimestamp.Before(oldest) {
+				oldest = node.CreationTimestamp.DeepCopy()
+			}
+			nodeStatuses = append(nodeStatuses, nodeStatus(node, readyConditionType))
 			nodeRefs = append(nodeRefs, apicorev1.ObjectReference{
 				Kind:       node.Kind,
 				APIVersion: node.APIVersion,
 				Name:       node.Name,
 				UID:        node.UID,
 			})
+		} else {
+			pending++
 		}
 	}
 
 	if len(nodeRefs) == 0 {
-		return getNodeReferencesResult{}, ErrNoAvailableNodes
+		return getNodeReferencesResult{pending: pending}, ErrNoAvailableNodes
+	}
+	return getNodeReferencesResult{nodeRefs, available, ready, pending, versions, notReady, oldest, nodeStatuses, unhealthy, templateHashes}, nil
+}
+
+// staleNodeRefsCheckDue returns true if the NodeRefs of a MachinePool are due to be checked for staleness, which is
+// done at most once per staleNodeRefsCheckInterval so that MachinePools at steady state don't list the Nodes of their
+// workload cluster on every reconciliation. The first check is due one interval after the MachinePool is first seen.
+func (r *MachinePoolReconciler) staleNodeRefsCheckDue(mp *expv1.MachinePool) bool {
+	key := util.ObjectKey(mp).String()
+	now := r.now()
+	last, ok := r.staleNodeRefsChecks.Load(key)
+	if ok && now.Sub(last.(time.Time)) < staleNodeRefsCheckInterval {
+		return false
+	}
+	r.staleNodeRefsChecks.Store(key, now)
+	return ok
+}
+
+// newestNodesByProviderID returns the given Nodes by the ID of their provider ID, preferring the newest Node if a
+// provider ID was reused for a replaced instance before its Node was removed.
+func newestNodesByProviderID(nodes []apicorev1.Node) map[string]*apicorev1.Node {
+	nodesByProviderID := make(map[string]*apicorev1.Node, len(nodes))
+	for i := range nodes {
+		nodeProviderID, err := noderefutil.NewProviderID(nodes[i].Spec.ProviderID)
+		if err != nil {
+			continue
+		}
+		if existing, ok := nodesByProviderID[nodeProviderID.ID()]; ok && nodes[i].CreationTimestamp.Before(&existing.CreationTimestamp) {
+			continue
+		}
+		nodesByProviderID[nodeProviderID.ID()] = &nodes[i]
+	}
+	return nodesByProviderID
+}
+
+// nodeRefsStale returns true if one of the given provider IDs of a MachinePool maps to a Node which isn't in its
+// NodeRefs, e.g. because the provider reused the provider ID of a replaced instance for a new Node. An event is
+// recorded for each stale reference, which is refreshed by the following reconciliation of the NodeRefs.
+func (r *MachinePoolReconciler) nodeRefsStale(mp *expv1.MachinePool, nodesByProviderID map[string]*apicorev1.Node, providerIDList []string) bool {
+	referenced := make(map[types.UID]bool, len(mp.Status.NodeRefs))
+	for _, nodeRef := range mp.Status.NodeRefs {
+		referenced[nodeRef.UID] = true
+	}
+
+	stale := false
+	for _, providerID := range providerIDList {
+		pid, err := noderefutil.NewProviderID(providerID)
+		if err != nil {
+			continue
+		}
+		node, ok := nodesByProviderID[pid.ID()]
+		if !ok || referenced[node.UID] {
+			continue
+		}
+		r.recorder.Eventf(mp, apicorev1.EventTypeNormal, "StaleNodeRef",
+			"Provider ID %s now belongs to Node %s with UID %s, refreshing NodeRefs", providerID, node.Name, node.UID)
+		stale = true
+	}
+	return stale
+}
+
+// unhealthyNodesChanged returns true if one of the Nodes of the given provider IDs of a MachinePool with the unhealthy
+// condition wasn't cordoned, or had its replacement requested, yet, or if the number of unhealthy Nodes changed since
+// the last reconciliation of the NodeRefs, e.g. because a Node recovered and has to be uncordoned.
+func (r *MachinePoolReconciler) unhealthyNodesChanged(mp *expv1.MachinePool, nodesByProviderID map[string]*apicorev1.Node, providerIDList []string) bool {
+	if r.UnhealthyNodeConditionType == "" {
+		return false
+	}
+
+	var unhealthy int32
+	for _, providerID := range providerIDList {
+		pid, err := noderefutil.NewProviderID(providerID)
+		if err != nil {
+			continue
+		}
+		node, ok := nodesByProviderID[pid.ID()]
+		if !ok || !r.nodeIsUnhealthy(node) {
+			continue
+		}
+		unhealthy++
+		if _, ok := node.Annotations[expv1.ReplacementRequestedAnnotation]; !node.Spec.Unschedulable || (r.ReplaceUnhealthyNodes && !ok) {
+			return true
+		}
+	}
+	return unhealthy != mp.Status.UnhealthyReplicas
+}
+
+// nodePassesReadinessGate returns true if the Node has a label or an annotation with the NodeReadinessGate key,
+// or if no readiness gate is configured.
+func (r *MachinePoolReconciler) nodePassesReadinessGate(node *apicorev1.Node) bool {
+	if r.NodeReadinessGate == "" {
+		return true
+	}
+	if _, ok := node.Labels[r.NodeReadinessGate]; ok {
+		return true
+	}
+	_, ok := node.Annotations[r.NodeReadinessGate]
+	return ok
+}
+
+// nodeReadyConditionType returns the type of the Node condition signaling that the Nodes of a MachinePool are ready,
+// from its NodeReadyConditionTypeAnnotation if set.
+func nodeReadyConditionType(mp *expv1.MachinePool) apicorev1.NodeConditionType {
+	if conditionType := mp.Annotations[expv1.NodeReadyConditionTypeAnnotation]; conditionType != "" {
+		return apicorev1.NodeConditionType(conditionType)
+	}
+	return apicorev1.NodeReady
+}
+
+// nodeStatus returns the readiness of a Node according to the given condition type.
+func nodeStatus(node *apicorev1.Node, readyConditionType apicorev1.NodeConditionType) expv1.MachinePoolNodeStatus {
+	status := expv1.MachinePoolNodeStatus{
+		Name:       node.Name,
+		ProviderID: node.Spec.ProviderID,
+		Ready:      apicorev1.ConditionUnknown,
+	}
+	for _, n := range node.Status.Conditions {
+		if n.Type == readyConditionType {
+			status.Ready = n.Status
+			status.ReadyTransitionTime = n.LastTransitionTime.DeepCopy()
+			break
+		}
 	}
-	return getNodeReferencesResult{nodeRefs, available, ready}, nil
+	return status
 }
 
-func nodeIsReady(node *apicorev1.Node) bool {
+func nodeIsReady(node *apicorev1.Node, readyConditionType apicorev1.NodeConditionType) bool {
 	for _, n := range node.Status.Conditions {
-		if n.Type == apicorev1.NodeReady {
+		if n.Type == readyConditionType {
 			return n.Status == apicorev1.ConditionTrue
 		}
 	}
@@ -18,19 +18,30 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	fakeremote "sigs.k8s.io/cluster-api/controllers/remote/fake"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
 )
 
 func TestMachinePoolGetNodeReference(t *testing.T) {
@@ -145,7 +156,7 @@ func TestMachinePoolGetNodeReference(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			gt := NewWithT(t)
 
-			result, err := r.getNodeReferences(context.TODO(), client, test.providerIDList)
+			result, err := r.getNodeReferences(listWorkloadNodes(g, client), corev1.NodeReady, test.providerIDList)
 			if test.err == nil {
 				g.Expect(err).To(BeNil())
 			} else {
@@ -167,3 +178,1979 @@ func TestMachinePoolGetNodeReference(t *testing.T) {
 
 	}
 }
+
+func TestMachinePoolValidateProviderIDs(t *testing.T) {
+	testCases := []struct {
+		name              string
+		providerIDPrefix  string
+		providerIDList    []string
+		expected          []string
+		expectedCondition *clusterv1.Condition
+	}{
+		{
+			name:           "no prefix, all provider ids are used",
+			providerIDList: []string{"aws://us-east-1/id-node-1", "aws://us-west-2/id-node-2"},
+			expected:       []string{"aws://us-east-1/id-node-1", "aws://us-west-2/id-node-2"},
+		},
+		{
+			name:              "matching prefix",
+			providerIDPrefix:  "aws://us-east-1",
+			providerIDList:    []string{"aws://us-east-1/id-node-1", "aws://us-east-1/id-node-2"},
+			expected:          []string{"aws://us-east-1/id-node-1", "aws://us-east-1/id-node-2"},
+			expectedCondition: conditions.TrueCondition(expv1.ProviderIDsValidCondition),
+		},
+		{
+			name:             "mismatching prefix",
+			providerIDPrefix: "aws://us-east-1",
+			providerIDList:   []string{"aws://us-east-1/id-node-1", "aws://us-west-2/id-node-2", "gce://us-central1/gce-id-node-3"},
+			expected:         []string{"aws://us-east-1/id-node-1"},
+			expectedCondition: conditions.FalseCondition(expv1.ProviderIDsValidCondition, expv1.UnexpectedProviderIDReason, clusterv1.ConditionSeverityWarning,
+				"Provider IDs aws://us-west-2/id-node-2, gce://us-central1/gce-id-node-3 don't match the expected prefix \"aws://us-east-1\""),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				Spec: expv1.MachinePoolSpec{
+					ProviderIDPrefix: tc.providerIDPrefix,
+					ProviderIDList:   tc.providerIDList,
+				},
+			}
+
+			g.Expect(validateProviderIDs(mp)).To(Equal(tc.expected))
+
+			if tc.expectedCondition == nil {
+				g.Expect(conditions.Has(mp, expv1.ProviderIDsValidCondition)).To(BeFalse())
+				return
+			}
+			c := conditions.Get(mp, expv1.ProviderIDsValidCondition)
+			g.Expect(c).NotTo(BeNil())
+			g.Expect(c.Status).To(Equal(tc.expectedCondition.Status))
+			g.Expect(c.Reason).To(Equal(tc.expectedCondition.Reason))
+			g.Expect(c.Severity).To(Equal(tc.expectedCondition.Severity))
+			g.Expect(c.Message).To(Equal(tc.expectedCondition.Message))
+		})
+	}
+}
+
+func TestMachinePoolAllowedProviderIDs(t *testing.T) {
+	testCases := []struct {
+		name              string
+		allowedPrefixes   []string
+		providerIDList    []string
+		expected          []string
+		expectedCondition *clusterv1.Condition
+		expectEvent       bool
+	}{
+		{
+			name:           "no allowed prefixes, all provider ids are used",
+			providerIDList: []string{"aws:///us-east-1a/i-1", "aws:///eu-west-1a/i-2"},
+			expected:       []string{"aws:///us-east-1a/i-1", "aws:///eu-west-1a/i-2"},
+		},
+		{
+			name:              "provider ids in policy",
+			allowedPrefixes:   []string{"aws:///us-east-1", "aws:///us-west-2"},
+			providerIDList:    []string{"aws:///us-east-1a/i-1", "aws:///us-west-2b/i-2"},
+			expected:          []string{"aws:///us-east-1a/i-1", "aws:///us-west-2b/i-2"},
+			expectedCondition: conditions.TrueCondition(expv1.ProviderIDsAllowedCondition),
+		},
+		{
+			name:            "provider ids out of policy are rejected",
+			allowedPrefixes: []string{"azure:///subscriptions/1234/"},
+			providerIDList:  []string{"azure:///subscriptions/1234/vm-1", "azure:///subscriptions/5678/vm-2"},
+			expected:        []string{"azure:///subscriptions/1234/vm-1"},
+			expectedCondition: conditions.FalseCondition(expv1.ProviderIDsAllowedCondition, expv1.ProviderIDNotAllowedReason, clusterv1.ConditionSeverityError,
+				"Provider IDs azure:///subscriptions/5678/vm-2 are outside the allowed prefixes azure:///subscriptions/1234/"),
+			expectEvent: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			recorder := record.NewFakeRecorder(32)
+			r := &MachinePoolReconciler{
+				Log:                       log.Log,
+				recorder:                  recorder,
+				AllowedProviderIDPrefixes: tc.allowedPrefixes,
+			}
+			mp := &expv1.MachinePool{}
+
+			g.Expect(r.allowedProviderIDs(mp, tc.providerIDList)).To(Equal(tc.expected))
+
+			if tc.expectedCondition == nil {
+				g.Expect(conditions.Has(mp, expv1.ProviderIDsAllowedCondition)).To(BeFalse())
+			} else {
+				c := conditions.Get(mp, expv1.ProviderIDsAllowedCondition)
+				g.Expect(c).NotTo(BeNil())
+				g.Expect(c.Status).To(Equal(tc.expectedCondition.Status))
+				g.Expect(c.Reason).To(Equal(tc.expectedCondition.Reason))
+				g.Expect(c.Severity).To(Equal(tc.expectedCondition.Severity))
+				g.Expect(c.Message).To(Equal(tc.expectedCondition.Message))
+			}
+			if tc.expectEvent {
+				g.Expect(recorder.Events).To(Receive(ContainSubstring("ProviderIDNotAllowed")))
+			}
+
+			// Rejected provider IDs are only reported once.
+			g.Expect(r.allowedProviderIDs(mp, tc.providerIDList)).To(Equal(tc.expected))
+			g.Expect(recorder.Events).NotTo(Receive())
+		})
+	}
+}
+
+func TestMachinePoolGetNodeReferenceVersions(t *testing.T) {
+	g := NewWithT(t)
+
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme),
+		Log:      log.Log,
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	newNode := func(name, providerID, kubeletVersion string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: corev1.NodeSpec{
+				ProviderID: providerID,
+			},
+			Status: corev1.NodeStatus{
+				NodeInfo: corev1.NodeSystemInfo{
+					KubeletVersion: kubeletVersion,
+				},
+			},
+		}
+	}
+
+	client := fake.NewFakeClientWithScheme(scheme.Scheme,
+		newNode("node-1", "aws://us-east-1/id-node-1", "v1.17.3"),
+		newNode("node-2", "aws://us-east-1/id-node-2", "v1.18.2"),
+		newNode("node-3", "aws://us-east-1/id-node-3", "v1.18.2"),
+		newNode("node-4", "aws://us-east-1/id-node-4", ""),
+		newNode("node-5", "aws://us-east-1/id-node-5", "v1.16.0"),
+	)
+
+	result, err := r.getNodeReferences(listWorkloadNodes(g, client), corev1.NodeReady, []string{
+		"aws://us-east-1/id-node-1",
+		"aws://us-east-1/id-node-2",
+		"aws://us-east-1/id-node-3",
+		"aws://us-east-1/id-node-4",
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.references).To(HaveLen(4))
+	g.Expect(result.versions).To(Equal(map[string]int32{
+		"v1.17.3": 1,
+		"v1.18.2": 2,
+	}))
+}
+
+func TestMachinePoolGetNodeReferenceReadinessGate(t *testing.T) {
+	const gate = "example.com/post-join-verified"
+
+	newNode := func(name string, ready bool, labels, annotations map[string]string) *corev1.Node {
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Labels:      labels,
+				Annotations: annotations,
+			},
+			Spec: corev1.NodeSpec{
+				ProviderID: "aws://us-east-1/" + name,
+			},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: status},
+				},
+			},
+		}
+	}
+
+	client := fake.NewFakeClientWithScheme(scheme.Scheme,
+		newNode("node-1", true, nil, nil),
+		newNode("node-2", true, nil, map[string]string{gate: ""}),
+		newNode("node-3", true, map[string]string{gate: "true"}, nil),
+		newNode("node-4", false, nil, map[string]string{gate: ""}),
+	)
+	providerIDList := []string{
+		"aws://us-east-1/node-1",
+		"aws://us-east-1/node-2",
+		"aws://us-east-1/node-3",
+		"aws://us-east-1/node-4",
+	}
+
+	testCases := []struct {
+		name              string
+		nodeReadinessGate string
+		expectedReady     int
+	}{
+		{
+			name:          "nodes are ready once Ready without a readiness gate",
+			expectedReady: 3,
+		},
+		{
+			name:              "nodes are only ready once Ready with the readiness gate label or annotation",
+			nodeReadinessGate: gate,
+			expectedReady:     2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r := &MachinePoolReconciler{
+				Client:            fake.NewFakeClientWithScheme(scheme.Scheme),
+				Log:               log.Log,
+				recorder:          record.NewFakeRecorder(32),
+				NodeReadinessGate: tc.nodeReadinessGate,
+			}
+
+			result, err := r.getNodeReferences(listWorkloadNodes(g, client), corev1.NodeReady, providerIDList)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result.references).To(HaveLen(4))
+			g.Expect(result.available).To(Equal(4))
+			g.Expect(result.ready).To(Equal(tc.expectedReady))
+		})
+	}
+}
+
+func TestMachinePoolGetNodeReferenceOldestCreationTime(t *testing.T) {
+	g := NewWithT(t)
+
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme),
+		Log:      log.Log,
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	now := time.Now().Truncate(time.Second)
+	newNode := func(name, providerID string, age time.Duration) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			},
+			Spec: corev1.NodeSpec{
+				ProviderID: providerID,
+			},
+		}
+	}
+
+	client := fake.NewFakeClientWithScheme(scheme.Scheme,
+		newNode("node-1", "aws://us-east-1/id-node-1", 2*time.Hour),
+		newNode("node-2", "aws://us-east-1/id-node-2", 72*time.Hour),
+		newNode("node-3", "aws://us-east-1/id-node-3", 10*time.Minute),
+		// The oldest Node isn't part of the MachinePool.
+		newNode("node-4", "aws://us-east-1/id-node-4", 720*time.Hour),
+	)
+
+	result, err := r.getNodeReferences(listWorkloadNodes(g, client), corev1.NodeReady, []string{
+		"aws://us-east-1/id-node-1",
+		"aws://us-east-1/id-node-2",
+		"aws://us-east-1/id-node-3",
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.references).To(HaveLen(3))
+	g.Expect(result.oldestCreationTimestamp).NotTo(BeNil())
+	g.Expect(result.oldestCreationTimestamp.Time).To(BeTemporally("==", now.Add(-72*time.Hour)))
+
+	// The oldest Node is replaced by a newer one.
+	result, err = r.getNodeReferences(listWorkloadNodes(g, client), corev1.NodeReady, []string{
+		"aws://us-east-1/id-node-1",
+		"aws://us-east-1/id-node-3",
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.oldestCreationTimestamp).NotTo(BeNil())
+	g.Expect(result.oldestCreationTimestamp.Time).To(BeTemporally("==", now.Add(-2*time.Hour)))
+}
+
+func TestMachinePoolReconcileNodeTaints(t *testing.T) {
+	manualTaint := corev1.Taint{Key: "manual", Value: "true", Effect: corev1.TaintEffectNoSchedule}
+	gpuTaint := corev1.Taint{Key: "nvidia.com/gpu", Value: "present", Effect: corev1.TaintEffectNoSchedule}
+	dedicatedTaint := corev1.Taint{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoExecute}
+
+	testCases := []struct {
+		name                string
+		nodeTaints          []corev1.Taint
+		nodeAnnotations     map[string]string
+		taints              []corev1.Taint
+		expectedTaints      []corev1.Taint
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:                "taints are applied, manual taints are preserved",
+			nodeTaints:          []corev1.Taint{manualTaint},
+			taints:              []corev1.Taint{gpuTaint, dedicatedTaint},
+			expectedTaints:      []corev1.Taint{manualTaint, gpuTaint, dedicatedTaint},
+			expectedAnnotations: map[string]string{expv1.ManagedTaintsAnnotation: "dedicated:NoExecute,nvidia.com/gpu:NoSchedule"},
+		},
+		{
+			name:                "managed taints dropped from spec are removed, manual taints are preserved",
+			nodeTaints:          []corev1.Taint{manualTaint, gpuTaint, dedicatedTaint},
+			nodeAnnotations:     map[string]string{expv1.ManagedTaintsAnnotation: "dedicated:NoExecute,nvidia.com/gpu:NoSchedule"},
+			taints:              []corev1.Taint{gpuTaint},
+			expectedTaints:      []corev1.Taint{manualTaint, gpuTaint},
+			expectedAnnotations: map[string]string{expv1.ManagedTaintsAnnotation: "nvidia.com/gpu:NoSchedule"},
+		},
+		{
+			name:            "all managed taints dropped from spec are removed",
+			nodeTaints:      []corev1.Taint{manualTaint, gpuTaint},
+			nodeAnnotations: map[string]string{expv1.ManagedTaintsAnnotation: "nvidia.com/gpu:NoSchedule"},
+			expectedTaints:  []corev1.Taint{manualTaint},
+		},
+		{
+			name:           "no taints in spec, node taints are untouched",
+			nodeTaints:     []corev1.Taint{manualTaint},
+			expectedTaints: []corev1.Taint{manualTaint},
+		},
+		{
+			name:                "managed taints whose value changed in spec are replaced",
+			nodeTaints:          []corev1.Taint{manualTaint, gpuTaint},
+			nodeAnnotations:     map[string]string{expv1.ManagedTaintsAnnotation: "nvidia.com/gpu:NoSchedule"},
+			taints:              []corev1.Taint{{Key: "nvidia.com/gpu", Value: "absent", Effect: corev1.TaintEffectNoSchedule}},
+			expectedTaints:      []corev1.Taint{manualTaint, {Key: "nvidia.com/gpu", Value: "absent", Effect: corev1.TaintEffectNoSchedule}},
+			expectedAnnotations: map[string]string{expv1.ManagedTaintsAnnotation: "nvidia.com/gpu:NoSchedule"},
+		},
+		{
+			name:                "taints in spec already set by users are left as is and not recorded as managed",
+			nodeTaints:          []corev1.Taint{{Key: "nvidia.com/gpu", Value: "user", Effect: corev1.TaintEffectNoSchedule}},
+			taints:              []corev1.Taint{gpuTaint, dedicatedTaint},
+			expectedTaints:      []corev1.Taint{{Key: "nvidia.com/gpu", Value: "user", Effect: corev1.TaintEffectNoSchedule}, dedicatedTaint},
+			expectedAnnotations: map[string]string{expv1.ManagedTaintsAnnotation: "dedicated:NoExecute"},
+		},
+		{
+			name:            "user taints matching taints dropped from spec are preserved",
+			nodeTaints:      []corev1.Taint{gpuTaint, dedicatedTaint},
+			nodeAnnotations: map[string]string{expv1.ManagedTaintsAnnotation: "dedicated:NoExecute"},
+			expectedTaints:  []corev1.Taint{gpuTaint},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "node-1",
+					Annotations: tc.nodeAnnotations,
+				},
+				Spec: corev1.NodeSpec{
+					ProviderID: "aws://us-east-1/id-node-1",
+					Taints:     tc.nodeTaints,
+				},
+			}
+			client := fake.NewFakeClientWithScheme(scheme.Scheme, node)
+
+			r := &MachinePoolReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme),
+				Log:      log.Log,
+				recorder: record.NewFakeRecorder(32),
+			}
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test"},
+				Spec:       expv1.MachinePoolSpec{Taints: tc.taints},
+			}
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"}}
+
+			g.Expect(r.reconcileNodes(context.TODO(), cluster, client, mp, getWorkloadNodes(g, client, "node-1"))).To(Succeed())
+
+			actual := &corev1.Node{}
+			g.Expect(client.Get(context.TODO(), types.NamespacedName{Name: "node-1"}, actual)).To(Succeed())
+			g.Expect(actual.Spec.Taints).To(Equal(tc.expectedTaints))
+			if tc.expectedAnnotations == nil {
+				g.Expect(actual.Annotations).NotTo(HaveKey(expv1.ManagedTaintsAnnotation))
+			}
+			for key, value := range tc.expectedAnnotations {
+				g.Expect(actual.Annotations).To(HaveKeyWithValue(key, value))
+			}
+		})
+	}
+}
+
+func TestMachinePoolTaintsApplied(t *testing.T) {
+	g := NewWithT(t)
+
+	mp := &expv1.MachinePool{}
+	g.Expect(taintsApplied(mp)).To(BeTrue())
+
+	mp.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+	g.Expect(taintsApplied(mp)).To(BeFalse())
+
+	recordAppliedTaints(mp)
+	g.Expect(mp.Annotations).To(HaveKey(expv1.AppliedTaintsHashAnnotation))
+	g.Expect(taintsApplied(mp)).To(BeTrue())
+
+	mp.Spec.Taints[0].Value = "cpu"
+	g.Expect(taintsApplied(mp)).To(BeFalse())
+
+	mp.Spec.Taints = nil
+	g.Expect(taintsApplied(mp)).To(BeFalse())
+	recordAppliedTaints(mp)
+	g.Expect(mp.Annotations).NotTo(HaveKey(expv1.AppliedTaintsHashAnnotation))
+	g.Expect(taintsApplied(mp)).To(BeTrue())
+}
+
+func TestMachinePoolNodeStartupTimeout(t *testing.T) {
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme),
+		Log:      log.Log,
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	newNode := func(name string, age time.Duration, ready corev1.ConditionStatus) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+			},
+			Spec: corev1.NodeSpec{
+				ProviderID: "aws://us-east-1/" + name,
+			},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: ready},
+				},
+			},
+		}
+	}
+
+	client := fake.NewFakeClientWithScheme(scheme.Scheme,
+		newNode("ready-node", time.Hour, corev1.ConditionTrue),
+		newNode("stuck-node", time.Hour, corev1.ConditionFalse),
+		newNode("starting-node", time.Minute, corev1.ConditionFalse),
+	)
+
+	testCases := []struct {
+		name                      string
+		nodeStartupTimeout        *metav1.Duration
+		expectedUnhealthyReplicas int32
+		expectedCondition         *clusterv1.Condition
+	}{
+		{
+			name:                      "no node startup timeout",
+			expectedUnhealthyReplicas: 0,
+		},
+		{
+			name:                      "node exceeding the startup timeout is unhealthy",
+			nodeStartupTimeout:        &metav1.Duration{Duration: 10 * time.Minute},
+			expectedUnhealthyReplicas: 1,
+			expectedCondition: conditions.FalseCondition(expv1.NodesHealthyCondition, clusterv1.UnhealthyNodeConditionReason, clusterv1.ConditionSeverityWarning,
+				"1 Nodes did not become Ready within the startup timeout of 10m0s"),
+		},
+		{
+			name:                      "nodes within the startup timeout are healthy",
+			nodeStartupTimeout:        &metav1.Duration{Duration: 2 * time.Hour},
+			expectedUnhealthyReplicas: 0,
+			expectedCondition:         conditions.TrueCondition(expv1.NodesHealthyCondition),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			result, err := r.getNodeReferences(listWorkloadNodes(g, client), corev1.NodeReady, []string{
+				"aws://us-east-1/ready-node",
+				"aws://us-east-1/stuck-node",
+				"aws://us-east-1/starting-node",
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result.notReadyCreationTimestamps).To(HaveLen(2))
+
+			mp := &expv1.MachinePool{
+				Spec: expv1.MachinePoolSpec{
+					NodeStartupTimeout: tc.nodeStartupTimeout,
+				},
+			}
+			r.reconcileNodeStartupTimeout(mp, result.notReadyCreationTimestamps)
+			g.Expect(mp.Status.UnhealthyReplicas).To(Equal(tc.expectedUnhealthyReplicas))
+
+			if tc.expectedCondition == nil {
+				g.Expect(conditions.Has(mp, expv1.NodesHealthyCondition)).To(BeFalse())
+				return
+			}
+			c := conditions.Get(mp, expv1.NodesHealthyCondition)
+			g.Expect(c).NotTo(BeNil())
+			g.Expect(c.Status).To(Equal(tc.expectedCondition.Status))
+			g.Expect(c.Reason).To(Equal(tc.expectedCondition.Reason))
+			g.Expect(c.Message).To(Equal(tc.expectedCondition.Message))
+		})
+	}
+}
+
+func TestMachinePoolReconcileUnhealthyNodes(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		replaceUnhealthyNodes bool
+	}{
+		{
+			name: "unhealthy node is cordoned",
+		},
+		{
+			name:                  "unhealthy node is cordoned and its replacement requested",
+			replaceUnhealthyNodes: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			newNode := func(name string, hardwareFailure corev1.ConditionStatus) *corev1.Node {
+				return &corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: name},
+					Spec:       corev1.NodeSpec{ProviderID: "aws://us-east-1/" + name},
+					Status: corev1.NodeStatus{
+						Conditions: []corev1.NodeCondition{
+							{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+							{Type: "HardwareFailure", Status: hardwareFailure},
+						},
+					},
+				}
+			}
+			client := fake.NewFakeClientWithScheme(scheme.Scheme,
+				newNode("healthy-node", corev1.ConditionFalse), newNode("failed-node", corev1.ConditionTrue))
+
+			recorder := record.NewFakeRecorder(32)
+			r := &MachinePoolReconciler{
+				Client:                     fake.NewFakeClientWithScheme(scheme.Scheme),
+				Log:                        log.Log,
+				recorder:                   recorder,
+				UnhealthyNodeConditionType: "HardwareFailure",
+				ReplaceUnhealthyNodes:      tc.replaceUnhealthyNodes,
+			}
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test"},
+			}
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"}}
+
+			// The node with the unhealthy condition is counted unhealthy, even though it is Ready.
+			result, err := r.getNodeReferences(listWorkloadNodes(g, client), corev1.NodeReady, []string{
+				"aws://us-east-1/healthy-node",
+				"aws://us-east-1/failed-node",
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result.ready).To(Equal(2))
+			g.Expect(result.unhealthy).To(Equal(1))
+
+			r.reconcileNodeStartupTimeout(mp, result.notReadyCreationTimestamps)
+			r.reconcileUnhealthyNodes(mp, result.unhealthy)
+			g.Expect(mp.Status.UnhealthyReplicas).To(Equal(int32(1)))
+			g.Expect(conditions.IsFalse(mp, expv1.NodesHealthyCondition)).To(BeTrue())
+			g.Expect(conditions.GetMessage(mp, expv1.NodesHealthyCondition)).To(Equal("1 Nodes are unhealthy, 1 of which report the HardwareFailure condition"))
+
+			// Only the unhealthy node is cordoned.
+			g.Expect(r.reconcileNodes(context.TODO(), cluster, client, mp, referencedNodes(listWorkloadNodes(g, client), result.references))).To(Succeed())
+			g.Expect(recorder.Events).To(Receive(ContainSubstring("UnhealthyNodeCordoned")))
+
+			healthy := &corev1.Node{}
+			g.Expect(client.Get(context.TODO(), types.NamespacedName{Name: "healthy-node"}, healthy)).To(Succeed())
+			g.Expect(healthy.Spec.Unschedulable).To(BeFalse())
+			g.Expect(healthy.Annotations).NotTo(HaveKey(expv1.ReplacementRequestedAnnotation))
+
+			failed := &corev1.Node{}
+			g.Expect(client.Get(context.TODO(), types.NamespacedName{Name: "failed-node"}, failed)).To(Succeed())
+			g.Expect(failed.Spec.Unschedulable).To(BeTrue())
+			g.Expect(failed.Annotations).To(HaveKey(expv1.CordonedAnnotation))
+			if tc.replaceUnhealthyNodes {
+				g.Expect(failed.Annotations).To(HaveKey(expv1.ReplacementRequestedAnnotation))
+			} else {
+				g.Expect(failed.Annotations).NotTo(HaveKey(expv1.ReplacementRequestedAnnotation))
+			}
+
+			// Reconciling the node again doesn't record another event.
+			g.Expect(r.reconcileNodes(context.TODO(), cluster, client, mp, referencedNodes(listWorkloadNodes(g, client), result.references))).To(Succeed())
+			g.Expect(recorder.Events).NotTo(Receive())
+
+			// The node is uncordoned once healthy again.
+			failed.Status.Conditions[1].Status = corev1.ConditionFalse
+			g.Expect(client.Update(context.TODO(), failed)).To(Succeed())
+			g.Expect(r.reconcileNodes(context.TODO(), cluster, client, mp, referencedNodes(listWorkloadNodes(g, client), result.references))).To(Succeed())
+
+			// Read the node into a new object, as decoding leaves the fields missing from the response untouched.
+			recovered := &corev1.Node{}
+			g.Expect(client.Get(context.TODO(), types.NamespacedName{Name: "failed-node"}, recovered)).To(Succeed())
+			g.Expect(recovered.Spec.Unschedulable).To(BeFalse())
+			g.Expect(recovered.Annotations).NotTo(HaveKey(expv1.CordonedAnnotation))
+
+			// The NodesHealthy condition clears once no Node is unhealthy anymore, without a startup timeout.
+			result, err = r.getNodeReferences(listWorkloadNodes(g, client), corev1.NodeReady, []string{
+				"aws://us-east-1/healthy-node",
+				"aws://us-east-1/failed-node",
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result.unhealthy).To(BeZero())
+
+			r.reconcileNodeStartupTimeout(mp, result.notReadyCreationTimestamps)
+			r.reconcileUnhealthyNodes(mp, result.unhealthy)
+			g.Expect(mp.Status.UnhealthyReplicas).To(BeZero())
+			g.Expect(conditions.IsTrue(mp, expv1.NodesHealthyCondition)).To(BeTrue())
+		})
+	}
+}
+
+func TestMachinePoolReconcileNodeRefsUnhealthyUpToDate(t *testing.T) {
+	g := NewWithT(t)
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test"},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName:    testCluster.Name,
+			Replicas:       pointer.Int32Ptr(2),
+			ProviderIDList: []string{"aws://us-east-1/healthy-node", "aws://us-east-1/failing-node"},
+		},
+		Status: expv1.MachinePoolStatus{
+			Replicas: 2,
+		},
+	}
+
+	newNode := func(name string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Annotations: map[string]string{expv1.TemplateHashAnnotation: machinePoolTemplateHash(mp)},
+			},
+			Spec: corev1.NodeSpec{ProviderID: "aws://us-east-1/" + name},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+					{Type: "HardwareFailure", Status: corev1.ConditionFalse},
+				},
+			},
+		}
+	}
+	workloadClient := &getCountingClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme, newNode("healthy-node"), newNode("failing-node"))}
+
+	recorder := record.NewFakeRecorder(32)
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, testCluster),
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: recorder,
+		remoteClientGetter: func(_ context.Context, _ client.Client, _ client.ObjectKey, _ *runtime.Scheme) (client.Client, error) {
+			return workloadClient, nil
+		},
+		UnhealthyNodeConditionType: "HardwareFailure",
+	}
+
+	// The NodeRefs are set once.
+	g.Expect(r.reconcileNodeRefs(ctx, testCluster, mp)).To(Succeed())
+	g.Expect(mp.Status.NodeRefs).To(HaveLen(2))
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("SuccessfulSetNodeRefs")))
+
+	// Healthy Nodes which are up to date are only listed, neither read one by one nor reported again.
+	workloadClient.gets = 0
+	g.Expect(r.reconcileNodeRefs(ctx, testCluster, mp)).To(Succeed())
+	g.Expect(workloadClient.gets).To(BeZero())
+	g.Expect(recorder.Events).NotTo(Receive())
+
+	// A Node whose host fails is cordoned, without reporting the unchanged NodeRefs again.
+	failing := &corev1.Node{}
+	g.Expect(workloadClient.Client.Get(ctx, client.ObjectKey{Name: "failing-node"}, failing)).To(Succeed())
+	failing.Status.Conditions[1].Status = corev1.ConditionTrue
+	g.Expect(workloadClient.Client.Update(ctx, failing)).To(Succeed())
+
+	g.Expect(r.reconcileNodeRefs(ctx, testCluster, mp)).To(Succeed())
+	g.Expect(mp.Status.UnhealthyReplicas).To(BeEquivalentTo(1))
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("UnhealthyNodeCordoned")))
+	g.Expect(recorder.Events).NotTo(Receive())
+
+	// Once cordoned, the unhealthy Node is left alone.
+	workloadClient.gets = 0
+	g.Expect(r.reconcileNodeRefs(ctx, testCluster, mp)).To(Succeed())
+	g.Expect(workloadClient.gets).To(BeZero())
+	g.Expect(recorder.Events).NotTo(Receive())
+}
+
+func TestMachinePoolReconcileNodeVersionsUpToDate(t *testing.T) {
+	testCases := []struct {
+		name              string
+		version           *string
+		nodeVersions      map[string]int32
+		expectedCondition *clusterv1.Condition
+	}{
+		{
+			name:         "no condition without a version",
+			nodeVersions: map[string]int32{"v1.17.3": 2},
+		},
+		{
+			name:              "nodes running the version are up to date",
+			version:           pointer.StringPtr("1.18.2"),
+			nodeVersions:      map[string]int32{"v1.18.2": 2, "v1.18.2-eks-1": 1},
+			expectedCondition: conditions.TrueCondition(expv1.NodeVersionsUpToDateCondition),
+		},
+		{
+			name:         "nodes running other versions are lagging",
+			version:      pointer.StringPtr("v1.18.2"),
+			nodeVersions: map[string]int32{"v1.18.2": 1, "v1.17.3": 2, "v1.18.1": 1},
+			expectedCondition: conditions.FalseCondition(expv1.NodeVersionsUpToDateCondition, expv1.NodeVersionsLaggingReason,
+				clusterv1.ConditionSeverityInfo, "3 Nodes don't run version v1.18.2: v1.17.3 (2), v1.18.1 (1)"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				Spec: expv1.MachinePoolSpec{
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{Version: tc.version},
+					},
+				},
+				Status: expv1.MachinePoolStatus{NodeVersions: tc.nodeVersions},
+			}
+			reconcileNodeVersionsUpToDate(mp)
+
+			if tc.expectedCondition == nil {
+				g.Expect(conditions.Has(mp, expv1.NodeVersionsUpToDateCondition)).To(BeFalse())
+				return
+			}
+			c := conditions.Get(mp, expv1.NodeVersionsUpToDateCondition)
+			g.Expect(c).NotTo(BeNil())
+			g.Expect(c.Status).To(Equal(tc.expectedCondition.Status))
+			g.Expect(c.Reason).To(Equal(tc.expectedCondition.Reason))
+			g.Expect(c.Message).To(Equal(tc.expectedCondition.Message))
+		})
+	}
+}
+
+func TestMachinePoolReconcileRolloutStatus(t *testing.T) {
+	g := NewWithT(t)
+
+	mp := &expv1.MachinePool{
+		Spec: expv1.MachinePoolSpec{
+			Replicas: pointer.Int32Ptr(3),
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{Version: pointer.StringPtr("v1.18.2")},
+			},
+			Strategy: &expv1.MachinePoolStrategy{
+				MachineDeploymentStrategy: clusterv1.MachineDeploymentStrategy{
+					Type: clusterv1.RollingUpdateMachineDeploymentStrategyType,
+				},
+			},
+		},
+	}
+	templateHash := machinePoolTemplateHash(mp)
+
+	// The bootstrap data set by the controller doesn't change the hash, the template set by users does.
+	withData := mp.DeepCopy()
+	withData.Spec.Template.Spec.Bootstrap.DataSecretName = pointer.StringPtr("secret-data")
+	g.Expect(machinePoolTemplateHash(withData)).To(Equal(templateHash))
+	oldTemplate := mp.DeepCopy()
+	oldTemplate.Spec.Template.Spec.Version = pointer.StringPtr("v1.17.3")
+	oldTemplateHash := machinePoolTemplateHash(oldTemplate)
+	g.Expect(oldTemplateHash).NotTo(Equal(templateHash))
+
+	newNode := func(name, templateHash string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Annotations: map[string]string{expv1.TemplateHashAnnotation: templateHash},
+			},
+			Spec: corev1.NodeSpec{ProviderID: "aws://us-east-1/" + name},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			},
+		}
+	}
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme),
+		Log:      log.Log,
+		recorder: record.NewFakeRecorder(32),
+	}
+	reconcileRollout := func(nodes ...*corev1.Node) {
+		listed := []corev1.Node{}
+		providerIDList := []string{}
+		for _, node := range nodes {
+			listed = append(listed, *node)
+			providerIDList = append(providerIDList, node.Spec.ProviderID)
+		}
+		result, err := r.getNodeReferences(listed, corev1.NodeReady, providerIDList)
+		g.Expect(err).NotTo(HaveOccurred())
+		reconcileRolloutStatus(mp, result.templateHashes, len(result.references))
+	}
+
+	// Partial rollout, with one Node out of three created from the current template.
+	reconcileRollout(newNode("node-1", templateHash), newNode("node-2", oldTemplateHash), newNode("node-3", oldTemplateHash))
+	g.Expect(mp.Status.RolloutStatus).To(Equal(&expv1.MachinePoolRolloutStatus{
+		TemplateHash:    templateHash,
+		InProgress:      true,
+		UpdatedReplicas: 1,
+		Replicas:        3,
+		Strategy:        clusterv1.RollingUpdateMachineDeploymentStrategyType,
+	}))
+
+	// A surge Node from the current template doesn't complete the rollout while old Nodes are left.
+	reconcileRollout(newNode("node-1", templateHash), newNode("node-2", oldTemplateHash), newNode("node-3", templateHash),
+		newNode("node-4", templateHash))
+	g.Expect(mp.Status.RolloutStatus.InProgress).To(BeTrue())
+	g.Expect(mp.Status.RolloutStatus.UpdatedReplicas).To(Equal(int32(3)))
+	g.Expect(mp.Status.RolloutStatus.Replicas).To(Equal(int32(4)))
+
+	// The rollout is complete once all the Nodes are created from the current template.
+	reconcileRollout(newNode("node-1", templateHash), newNode("node-3", templateHash), newNode("node-4", templateHash))
+	g.Expect(mp.Status.RolloutStatus.InProgress).To(BeFalse())
+	g.Expect(mp.Status.RolloutStatus.UpdatedReplicas).To(Equal(int32(3)))
+	g.Expect(mp.Status.RolloutStatus.Replicas).To(Equal(int32(3)))
+}
+
+func TestMachinePoolReconcileNodeRefsCountOnly(t *testing.T) {
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	newNode := func(name string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.NodeSpec{
+				ProviderID: "aws://us-east-1/" + name,
+			},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name              string
+		nodeRefsCountOnly bool
+		expectedNodeRefs  int
+	}{
+		{
+			name:              "node references are stored by default",
+			nodeRefsCountOnly: false,
+			expectedNodeRefs:  2,
+		},
+		{
+			name:              "node references are only counted in count only mode",
+			nodeRefsCountOnly: true,
+			expectedNodeRefs:  0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "count-only"},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName:    testCluster.Name,
+					ProviderIDList: []string{"aws://us-east-1/node-1", "aws://us-east-1/node-2"},
+				},
+				Status: expv1.MachinePoolStatus{
+					Replicas: 2,
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client:             fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, newNode("node-1"), newNode("node-2")),
+				Log:                log.Log,
+				scheme:             scheme.Scheme,
+				recorder:           record.NewFakeRecorder(32),
+				remoteClientGetter: fakeremote.NewClusterClient,
+				NodeRefsCountOnly:  tc.nodeRefsCountOnly,
+			}
+
+			g.Expect(r.reconcileNodeRefs(context.TODO(), testCluster, mp)).To(Succeed())
+			g.Expect(mp.Status.NodeRefs).To(HaveLen(tc.expectedNodeRefs))
+			g.Expect(mp.Status.NodeRefsCount).To(BeEquivalentTo(2))
+			g.Expect(mp.Status.ReadyReplicas).To(BeEquivalentTo(2))
+			g.Expect(nodeRefsCount(mp)).To(Equal(2))
+		})
+	}
+}
+
+func TestMachinePoolReconcileNodeRefsReadyConditionType(t *testing.T) {
+	const kubeletReady corev1.NodeConditionType = "example.com/KubeletReady"
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	newNode := func(name string, conditions ...corev1.NodeCondition) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.NodeSpec{
+				ProviderID: "aws://us-east-1/" + name,
+			},
+			Status: corev1.NodeStatus{
+				Conditions: conditions,
+			},
+		}
+	}
+
+	testCases := []struct {
+		name                  string
+		annotations           map[string]string
+		expectedReadyReplicas int32
+	}{
+		{
+			name:                  "nodes are ready with the Ready condition by default",
+			expectedReadyReplicas: 2,
+		},
+		{
+			name:                  "nodes are ready with the condition type from the annotation",
+			annotations:           map[string]string{expv1.NodeReadyConditionTypeAnnotation: string(kubeletReady)},
+			expectedReadyReplicas: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "custom-ready", Annotations: tc.annotations},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName:    testCluster.Name,
+					ProviderIDList: []string{"aws://us-east-1/node-1", "aws://us-east-1/node-2", "aws://us-east-1/node-3"},
+				},
+				Status: expv1.MachinePoolStatus{
+					Replicas: 3,
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, testCluster,
+					newNode("node-1",
+						corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+						corev1.NodeCondition{Type: kubeletReady, Status: corev1.ConditionTrue},
+					),
+					newNode("node-2",
+						corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+						corev1.NodeCondition{Type: kubeletReady, Status: corev1.ConditionFalse},
+					),
+					newNode("node-3",
+						corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionFalse},
+					),
+				),
+				Log:                log.Log,
+				scheme:             scheme.Scheme,
+				recorder:           record.NewFakeRecorder(32),
+				remoteClientGetter: fakeremote.NewClusterClient,
+			}
+
+			// Not all the Nodes are ready, so the reconciliation is requeued once the NodeRefs are set.
+			err := r.reconcileNodeRefs(context.TODO(), testCluster, mp)
+			var requeueErr *capierrors.RequeueAfterError
+			g.Expect(errors.As(err, &requeueErr)).To(BeTrue())
+			g.Expect(mp.Status.NodeRefs).To(HaveLen(3))
+			g.Expect(mp.Status.ReadyReplicas).To(Equal(tc.expectedReadyReplicas))
+		})
+	}
+}
+
+func TestMachinePoolReconcileNodeRefsPendingReplicas(t *testing.T) {
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	newNode := func(name string, ready corev1.ConditionStatus) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.NodeSpec{
+				ProviderID: "aws://us-east-1/" + name,
+			},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: ready},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name                    string
+		nodes                   []runtime.Object
+		expectRequeue           bool
+		expectedReadyReplicas   int32
+		expectedPendingReplicas int32
+	}{
+		{
+			name: "all instances joined",
+			nodes: []runtime.Object{
+				newNode("node-1", corev1.ConditionTrue),
+				newNode("node-2", corev1.ConditionTrue),
+				newNode("node-3", corev1.ConditionTrue),
+			},
+			expectedReadyReplicas:   3,
+			expectedPendingReplicas: 0,
+		},
+		{
+			name: "instances without a Node are pending",
+			nodes: []runtime.Object{
+				newNode("node-1", corev1.ConditionTrue),
+				newNode("node-2", corev1.ConditionFalse),
+			},
+			expectRequeue:           true,
+			expectedReadyReplicas:   1,
+			expectedPendingReplicas: 1,
+		},
+		{
+			name:                    "all instances are pending until a Node joins",
+			expectRequeue:           true,
+			expectedPendingReplicas: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "partially-joined"},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName:    testCluster.Name,
+					ProviderIDList: []string{"aws://us-east-1/node-1", "aws://us-east-1/node-2", "aws://us-east-1/node-3"},
+				},
+				Status: expv1.MachinePoolStatus{
+					Replicas: 3,
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client:             fake.NewFakeClientWithScheme(scheme.Scheme, append(tc.nodes, testCluster)...),
+				Log:                log.Log,
+				scheme:             scheme.Scheme,
+				recorder:           record.NewFakeRecorder(32),
+				remoteClientGetter: fakeremote.NewClusterClient,
+			}
+
+			err := r.reconcileNodeRefs(context.TODO(), testCluster, mp)
+			if tc.expectRequeue {
+				var requeueErr *capierrors.RequeueAfterError
+				g.Expect(errors.As(err, &requeueErr)).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(mp.Status.ReadyReplicas).To(Equal(tc.expectedReadyReplicas))
+			g.Expect(mp.Status.PendingReplicas).To(Equal(tc.expectedPendingReplicas))
+		})
+	}
+}
+
+func TestMachinePoolReconcileNodeRefsNodeStatuses(t *testing.T) {
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	transitionTime := metav1.NewTime(time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC))
+	newNode := func(name string, conditions ...corev1.NodeCondition) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.NodeSpec{
+				ProviderID: "aws://us-east-1/" + name,
+			},
+			Status: corev1.NodeStatus{
+				Conditions: conditions,
+			},
+		}
+	}
+
+	testCases := []struct {
+		name              string
+		nodeStatusesLimit int
+		expectedNames     []string
+	}{
+		{
+			name: "node statuses aren't reported by default",
+		},
+		{
+			name:              "node statuses are reported up to the limit",
+			nodeStatusesLimit: 2,
+			expectedNames:     []string{"node-1", "node-2"},
+		},
+		{
+			name:              "node statuses of all nodes are reported within the limit",
+			nodeStatusesLimit: 10,
+			expectedNames:     []string{"node-1", "node-2", "node-3"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "node-statuses"},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName:    testCluster.Name,
+					ProviderIDList: []string{"aws://us-east-1/node-1", "aws://us-east-1/node-2", "aws://us-east-1/node-3"},
+				},
+				Status: expv1.MachinePoolStatus{
+					Replicas: 3,
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, testCluster,
+					newNode("node-1", corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionTrue, LastTransitionTime: transitionTime}),
+					newNode("node-2", corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionFalse, LastTransitionTime: transitionTime}),
+					newNode("node-3"),
+				),
+				Log:                log.Log,
+				scheme:             scheme.Scheme,
+				recorder:           record.NewFakeRecorder(32),
+				remoteClientGetter: fakeremote.NewClusterClient,
+				NodeStatusesLimit:  tc.nodeStatusesLimit,
+			}
+
+			// Only one of the Nodes is ready, so the reconciliation is requeued once their statuses are reported.
+			err := r.reconcileNodeRefs(context.TODO(), testCluster, mp)
+			var requeueErr *capierrors.RequeueAfterError
+			g.Expect(errors.As(err, &requeueErr)).To(BeTrue())
+			g.Expect(mp.Status.NodeStatuses).To(HaveLen(len(tc.expectedNames)))
+			for i, status := range mp.Status.NodeStatuses {
+				g.Expect(status.Name).To(Equal(tc.expectedNames[i]))
+				g.Expect(status.ProviderID).To(Equal("aws://us-east-1/" + tc.expectedNames[i]))
+				switch status.Name {
+				case "node-1":
+					g.Expect(status.Ready).To(Equal(corev1.ConditionTrue))
+					g.Expect(status.ReadyTransitionTime.Time.Equal(transitionTime.Time)).To(BeTrue())
+				case "node-2":
+					g.Expect(status.Ready).To(Equal(corev1.ConditionFalse))
+					g.Expect(status.ReadyTransitionTime.Time.Equal(transitionTime.Time)).To(BeTrue())
+				case "node-3":
+					g.Expect(status.Ready).To(Equal(corev1.ConditionUnknown))
+					g.Expect(status.ReadyTransitionTime).To(BeNil())
+				}
+			}
+		})
+	}
+}
+
+func TestMachinePoolReconcileNodeRefsWorkloadCluster(t *testing.T) {
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec: corev1.NodeSpec{
+			ProviderID: "aws://us-east-1/node-1",
+		},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name               string
+		annotations        map[string]string
+		expectedClusterKey client.ObjectKey
+	}{
+		{
+			name:               "nodes are resolved from the cluster of the machinePool by default",
+			expectedClusterKey: client.ObjectKey{Namespace: "default", Name: "test-cluster"},
+		},
+		{
+			name:               "nodes are resolved from the workload cluster in the namespace of the machinePool",
+			annotations:        map[string]string{expv1.WorkloadClusterAnnotation: "hosted-cluster"},
+			expectedClusterKey: client.ObjectKey{Namespace: "default", Name: "hosted-cluster"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "hosted", Annotations: tc.annotations},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName:    testCluster.Name,
+					ProviderIDList: []string{"aws://us-east-1/node-1"},
+				},
+				Status: expv1.MachinePoolStatus{
+					Replicas: 1,
+				},
+			}
+
+			// Only the expected workload cluster has the Node of the MachinePool.
+			workloadClusterClient := fake.NewFakeClientWithScheme(scheme.Scheme, node)
+			otherClusterClient := fake.NewFakeClientWithScheme(scheme.Scheme)
+
+			r := &MachinePoolReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme, testCluster),
+				Log:      log.Log,
+				scheme:   scheme.Scheme,
+				recorder: record.NewFakeRecorder(32),
+				remoteClientGetter: func(_ context.Context, _ client.Client, key client.ObjectKey, _ *runtime.Scheme) (client.Client, error) {
+					if key == tc.expectedClusterKey {
+						return workloadClusterClient, nil
+					}
+					return otherClusterClient, nil
+				},
+			}
+
+			g.Expect(r.reconcileNodeRefs(context.TODO(), testCluster, mp)).To(Succeed())
+			g.Expect(mp.Status.NodeRefs).To(HaveLen(1))
+			g.Expect(mp.Status.NodeRefs[0].Name).To(Equal("node-1"))
+		})
+	}
+}
+
+func TestMachinePoolWorkloadClusterKeyInvalid(t *testing.T) {
+	g := NewWithT(t)
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "hosted",
+			Annotations: map[string]string{expv1.WorkloadClusterAnnotation: "a/b/c"},
+		},
+	}
+
+	_, err := workloadClusterKey(testCluster, mp)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestMachinePoolReconcileNodeRefsWorkloadClusterInOtherNamespace(t *testing.T) {
+	g := NewWithT(t)
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "hosted",
+			Annotations: map[string]string{expv1.WorkloadClusterAnnotation: "hosting/hosted-cluster"},
+		},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName:    testCluster.Name,
+			ProviderIDList: []string{"aws://us-east-1/node-1"},
+		},
+		Status: expv1.MachinePoolStatus{
+			Replicas: 1,
+		},
+	}
+
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, testCluster),
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(32),
+		remoteClientGetter: func(_ context.Context, _ client.Client, key client.ObjectKey, _ *runtime.Scheme) (client.Client, error) {
+			t.Errorf("unexpected connection to the workload cluster of Cluster %s", key)
+			return nil, errors.New("unexpected connection")
+		},
+	}
+
+	// The workload cluster of a Cluster in another namespace is never connected to.
+	g.Expect(r.reconcileNodeRefs(context.TODO(), testCluster, mp)).NotTo(Succeed())
+	g.Expect(conditions.IsFalse(mp, expv1.MachinePoolRemoteConnectedCondition)).To(BeTrue())
+}
+
+func TestMachinePoolUncordonNodes(t *testing.T) {
+	g := NewWithT(t)
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	newNode := func(name string, ready corev1.ConditionStatus, unschedulable bool) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.NodeSpec{
+				ProviderID:    "aws://us-east-1/" + name,
+				Unschedulable: unschedulable,
+			},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: ready},
+				},
+			},
+		}
+	}
+
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "uncordon"},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName:    testCluster.Name,
+			ProviderIDList: []string{"aws://us-east-1/ready-node", "aws://us-east-1/recovering-node", "aws://us-east-1/user-cordoned-node"},
+		},
+		Status: expv1.MachinePoolStatus{
+			Replicas: 3,
+			NodeRefs: []corev1.ObjectReference{
+				{Name: "ready-node"},
+				{Name: "recovering-node"},
+				{Name: "user-cordoned-node"},
+			},
+		},
+	}
+
+	r := &MachinePoolReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, testCluster,
+			newNode("ready-node", corev1.ConditionTrue, false),
+			newNode("recovering-node", corev1.ConditionFalse, false),
+			newNode("user-cordoned-node", corev1.ConditionTrue, true),
+		),
+		Log:                log.Log,
+		scheme:             scheme.Scheme,
+		recorder:           record.NewFakeRecorder(32),
+		remoteClientGetter: fakeremote.NewClusterClient,
+	}
+
+	getNode := func(name string) *corev1.Node {
+		node := &corev1.Node{}
+		g.Expect(r.Client.Get(ctx, client.ObjectKey{Name: name}, node)).To(Succeed())
+		return node
+	}
+
+	// The controller records the Nodes it cordons.
+	g.Expect(r.cordonNodes(ctx, testCluster, mp)).To(Succeed())
+	for _, name := range []string{"ready-node", "recovering-node"} {
+		node := getNode(name)
+		g.Expect(node.Spec.Unschedulable).To(BeTrue())
+		g.Expect(node.Annotations).To(HaveKey(expv1.CordonedAnnotation))
+	}
+	g.Expect(getNode("user-cordoned-node").Annotations).NotTo(HaveKey(expv1.CordonedAnnotation))
+
+	// Ready Nodes cordoned by the controller are uncordoned, the others stay cordoned.
+	err := r.reconcileNodeRefs(ctx, testCluster, mp)
+	g.Expect(err).To(HaveOccurred())
+	_, ok := errors.Cause(err).(capierrors.HasRequeueAfterError)
+	g.Expect(ok).To(BeTrue())
+
+	readyNode := getNode("ready-node")
+	g.Expect(readyNode.Spec.Unschedulable).To(BeFalse())
+	g.Expect(readyNode.Annotations).NotTo(HaveKey(expv1.CordonedAnnotation))
+	g.Expect(getNode("recovering-node").Spec.Unschedulable).To(BeTrue())
+	g.Expect(getNode("user-cordoned-node").Spec.Unschedulable).To(BeTrue())
+
+	// Cordoned Nodes are uncordoned once they recover.
+	recoveringNode := getNode("recovering-node")
+	recoveringNode.Status.Conditions[0].Status = corev1.ConditionTrue
+	g.Expect(r.Client.Update(ctx, recoveringNode)).To(Succeed())
+
+	g.Expect(r.reconcileNodeRefs(ctx, testCluster, mp)).To(Succeed())
+	recoveringNode = getNode("recovering-node")
+	g.Expect(recoveringNode.Spec.Unschedulable).To(BeFalse())
+	g.Expect(recoveringNode.Annotations).NotTo(HaveKey(expv1.CordonedAnnotation))
+	g.Expect(getNode("user-cordoned-node").Spec.Unschedulable).To(BeTrue())
+}
+
+func TestMachinePoolReconcileNodeZoneLabels(t *testing.T) {
+	failureDomains := map[string]string{
+		"aws:///us-east-1a/id-node-1": "us-east-1a",
+	}
+
+	testCases := []struct {
+		name            string
+		nodeLabels      map[string]string
+		nodeAnnotations map[string]string
+		providerID      string
+		expectedZone    string
+		expectZoneLabel bool
+		expectManaged   bool
+	}{
+		{
+			name:            "unlabeled node gets the zone of its instance",
+			providerID:      "aws:///us-east-1a/id-node-1",
+			expectedZone:    "us-east-1a",
+			expectZoneLabel: true,
+			expectManaged:   true,
+		},
+		{
+			name:            "zone label set by the controller is updated",
+			nodeLabels:      map[string]string{corev1.LabelZoneFailureDomainStable: "us-east-1b"},
+			nodeAnnotations: map[string]string{expv1.ManagedZoneLabelAnnotation: ""},
+			providerID:      "aws:///us-east-1a/id-node-1",
+			expectedZone:    "us-east-1a",
+			expectZoneLabel: true,
+			expectManaged:   true,
+		},
+		{
+			name:            "zone label set by someone else is left untouched",
+			nodeLabels:      map[string]string{corev1.LabelZoneFailureDomainStable: "us-east-1b"},
+			providerID:      "aws:///us-east-1a/id-node-1",
+			expectedZone:    "us-east-1b",
+			expectZoneLabel: true,
+		},
+		{
+			name:       "node without a reported failure domain isn't labeled",
+			providerID: "aws:///us-east-1a/id-node-2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "node-1",
+					Labels:      tc.nodeLabels,
+					Annotations: tc.nodeAnnotations,
+				},
+				Spec: corev1.NodeSpec{ProviderID: tc.providerID},
+			}
+			client := fake.NewFakeClientWithScheme(scheme.Scheme, node)
+
+			r := &MachinePoolReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme),
+				Log:      log.Log,
+				recorder: record.NewFakeRecorder(32),
+			}
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test"},
+				Status:     expv1.MachinePoolStatus{InstanceFailureDomains: failureDomains},
+			}
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"}}
+
+			g.Expect(r.reconcileNodes(context.TODO(), cluster, client, mp, getWorkloadNodes(g, client, "node-1"))).To(Succeed())
+
+			actual := &corev1.Node{}
+			g.Expect(client.Get(context.TODO(), types.NamespacedName{Name: "node-1"}, actual)).To(Succeed())
+			if tc.expectZoneLabel {
+				g.Expect(actual.Labels).To(HaveKeyWithValue(corev1.LabelZoneFailureDomainStable, tc.expectedZone))
+			} else {
+				g.Expect(actual.Labels).NotTo(HaveKey(corev1.LabelZoneFailureDomainStable))
+			}
+			if tc.expectManaged {
+				g.Expect(actual.Annotations).To(HaveKey(expv1.ManagedZoneLabelAnnotation))
+			} else {
+				g.Expect(actual.Annotations).NotTo(HaveKey(expv1.ManagedZoneLabelAnnotation))
+			}
+		})
+	}
+}
+
+func TestMachinePoolReconcileNodeImageLabels(t *testing.T) {
+	images := map[string]string{
+		"aws:///us-east-1a/id-node-1": "ami-0123456789abcdef0",
+		"aws:///us-east-1a/id-node-2": "projects/ubuntu/images/ubuntu-2004",
+	}
+
+	testCases := []struct {
+		name             string
+		nodeLabels       map[string]string
+		providerID       string
+		expectedImage    string
+		expectImageLabel bool
+	}{
+		{
+			name:             "unlabeled node gets the image of its instance",
+			providerID:       "aws:///us-east-1a/id-node-1",
+			expectedImage:    "ami-0123456789abcdef0",
+			expectImageLabel: true,
+		},
+		{
+			name:             "image label is updated to the image of its instance",
+			nodeLabels:       map[string]string{expv1.NodeImageLabel: "ami-00000000000000000"},
+			providerID:       "aws:///us-east-1a/id-node-1",
+			expectedImage:    "ami-0123456789abcdef0",
+			expectImageLabel: true,
+		},
+		{
+			name:       "image which isn't a valid label value isn't applied",
+			providerID: "aws:///us-east-1a/id-node-2",
+		},
+		{
+			name:             "node without a reported image keeps its label",
+			nodeLabels:       map[string]string{expv1.NodeImageLabel: "ami-00000000000000000"},
+			providerID:       "aws:///us-east-1a/id-node-3",
+			expectedImage:    "ami-00000000000000000",
+			expectImageLabel: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "node-1",
+					Labels: tc.nodeLabels,
+				},
+				Spec: corev1.NodeSpec{ProviderID: tc.providerID},
+			}
+			client := fake.NewFakeClientWithScheme(scheme.Scheme, node)
+
+			r := &MachinePoolReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme),
+				Log:      log.Log,
+				recorder: record.NewFakeRecorder(32),
+			}
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test"},
+				Status:     expv1.MachinePoolStatus{InstanceImages: images},
+			}
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"}}
+
+			g.Expect(r.reconcileNodes(context.TODO(), cluster, client, mp, getWorkloadNodes(g, client, "node-1"))).To(Succeed())
+
+			actual := &corev1.Node{}
+			g.Expect(client.Get(context.TODO(), types.NamespacedName{Name: "node-1"}, actual)).To(Succeed())
+			if tc.expectImageLabel {
+				g.Expect(actual.Labels).To(HaveKeyWithValue(expv1.NodeImageLabel, tc.expectedImage))
+			} else {
+				g.Expect(actual.Labels).NotTo(HaveKey(expv1.NodeImageLabel))
+			}
+		})
+	}
+}
+
+func TestMachinePoolReconcileNodeMachinePoolAnnotations(t *testing.T) {
+	testCases := []struct {
+		name            string
+		nodeAnnotations map[string]string
+	}{
+		{
+			name: "node without annotations is annotated",
+		},
+		{
+			name: "annotations of another pool are updated, other annotations are preserved",
+			nodeAnnotations: map[string]string{
+				expv1.NodeMachinePoolAnnotation:          "other-machinepool",
+				expv1.NodeMachinePoolNamespaceAnnotation: "other",
+				"manual":                                 "true",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "node-1",
+					Annotations: tc.nodeAnnotations,
+				},
+				Spec: corev1.NodeSpec{ProviderID: "aws://us-east-1/id-node-1"},
+			}
+			client := fake.NewFakeClientWithScheme(scheme.Scheme, node)
+
+			r := &MachinePoolReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme),
+				Log:      log.Log,
+				recorder: record.NewFakeRecorder(32),
+			}
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test"},
+			}
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"}}
+
+			g.Expect(r.reconcileNodes(context.TODO(), cluster, client, mp, getWorkloadNodes(g, client, "node-1"))).To(Succeed())
+
+			actual := &corev1.Node{}
+			g.Expect(client.Get(context.TODO(), types.NamespacedName{Name: "node-1"}, actual)).To(Succeed())
+			g.Expect(actual.Annotations).To(HaveKeyWithValue(expv1.NodeMachinePoolAnnotation, "machinepool-test"))
+			g.Expect(actual.Annotations).To(HaveKeyWithValue(expv1.NodeMachinePoolNamespaceAnnotation, "default"))
+			for key, value := range tc.nodeAnnotations {
+				if key != expv1.NodeMachinePoolAnnotation && key != expv1.NodeMachinePoolNamespaceAnnotation {
+					g.Expect(actual.Annotations).To(HaveKeyWithValue(key, value))
+				}
+			}
+		})
+	}
+}
+
+func TestMachinePoolReconcileNodePropagatedAnnotations(t *testing.T) {
+	g := NewWithT(t)
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node-1",
+			Annotations: map[string]string{"cost.example.com/owner": "manual"},
+		},
+		Spec: corev1.NodeSpec{ProviderID: "aws://us-east-1/id-node-1"},
+	}
+	client := fake.NewFakeClientWithScheme(scheme.Scheme, node)
+
+	r := &MachinePoolReconciler{
+		Client:                       fake.NewFakeClientWithScheme(scheme.Scheme),
+		Log:                          log.Log,
+		recorder:                     record.NewFakeRecorder(32),
+		PropagatedAnnotationPrefixes: []string{"cost.example.com/"},
+	}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"}}
+
+	reconcileNode := func(poolAnnotations map[string]string) *corev1.Node {
+		mp := &expv1.MachinePool{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test", Annotations: poolAnnotations},
+		}
+		g.Expect(r.reconcileNodes(context.TODO(), cluster, client, mp, getWorkloadNodes(g, client, "node-1"))).To(Succeed())
+
+		actual := &corev1.Node{}
+		g.Expect(client.Get(context.TODO(), types.NamespacedName{Name: "node-1"}, actual)).To(Succeed())
+		return actual
+	}
+
+	// Annotations matching the prefixes are added, others aren't propagated.
+	actual := reconcileNode(map[string]string{
+		"cost.example.com/team":   "platform",
+		"cost.example.com/center": "1234",
+		"other.example.com/note":  "not propagated",
+	})
+	g.Expect(actual.Annotations).To(HaveKeyWithValue("cost.example.com/team", "platform"))
+	g.Expect(actual.Annotations).To(HaveKeyWithValue("cost.example.com/center", "1234"))
+	g.Expect(actual.Annotations).NotTo(HaveKey("other.example.com/note"))
+	g.Expect(actual.Annotations).To(HaveKeyWithValue(expv1.ManagedAnnotationsAnnotation, "cost.example.com/center,cost.example.com/team"))
+	g.Expect(actual.Annotations).To(HaveKeyWithValue("cost.example.com/owner", "manual"))
+
+	// Changed values are updated.
+	actual = reconcileNode(map[string]string{
+		"cost.example.com/team":   "data",
+		"cost.example.com/center": "1234",
+	})
+	g.Expect(actual.Annotations).To(HaveKeyWithValue("cost.example.com/team", "data"))
+	g.Expect(actual.Annotations).To(HaveKeyWithValue("cost.example.com/center", "1234"))
+
+	// Annotations dropped from the MachinePool are removed, annotations not propagated by the controller are kept.
+	actual = reconcileNode(map[string]string{
+		"cost.example.com/team": "data",
+	})
+	g.Expect(actual.Annotations).To(HaveKeyWithValue("cost.example.com/team", "data"))
+	g.Expect(actual.Annotations).NotTo(HaveKey("cost.example.com/center"))
+	g.Expect(actual.Annotations).To(HaveKeyWithValue(expv1.ManagedAnnotationsAnnotation, "cost.example.com/team"))
+	g.Expect(actual.Annotations).To(HaveKeyWithValue("cost.example.com/owner", "manual"))
+
+	actual = reconcileNode(nil)
+	g.Expect(actual.Annotations).NotTo(HaveKey("cost.example.com/team"))
+	g.Expect(actual.Annotations).NotTo(HaveKey(expv1.ManagedAnnotationsAnnotation))
+	g.Expect(actual.Annotations).To(HaveKeyWithValue("cost.example.com/owner", "manual"))
+}
+
+func TestMachinePoolReconcileNodeRefsPropagatedAnnotationsUpToDate(t *testing.T) {
+	g := NewWithT(t)
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "machinepool-test",
+			Annotations: map[string]string{"cost.example.com/team": "platform"},
+		},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName:    testCluster.Name,
+			Replicas:       pointer.Int32Ptr(1),
+			ProviderIDList: []string{"aws://us-east-1/node-1"},
+		},
+		Status: expv1.MachinePoolStatus{
+			Replicas: 1,
+		},
+	}
+
+	workloadClient := &getCountingClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme, &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node-1",
+			Annotations: map[string]string{expv1.TemplateHashAnnotation: machinePoolTemplateHash(mp)},
+		},
+		Spec: corev1.NodeSpec{ProviderID: "aws://us-east-1/node-1"},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			},
+		},
+	})}
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, testCluster),
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(32),
+		remoteClientGetter: func(_ context.Context, _ client.Client, _ client.ObjectKey, _ *runtime.Scheme) (client.Client, error) {
+			return workloadClient, nil
+		},
+		PropagatedAnnotationPrefixes: []string{"cost.example.com/"},
+	}
+
+	nodeAnnotations := func() map[string]string {
+		node := &corev1.Node{}
+		g.Expect(workloadClient.Client.Get(ctx, client.ObjectKey{Name: "node-1"}, node)).To(Succeed())
+		return node.Annotations
+	}
+
+	// The annotations are propagated and recorded as applied.
+	g.Expect(r.reconcileNodeRefs(ctx, testCluster, mp)).To(Succeed())
+	g.Expect(nodeAnnotations()).To(HaveKeyWithValue("cost.example.com/team", "platform"))
+	g.Expect(mp.Annotations).To(HaveKey(expv1.AppliedAnnotationsHashAnnotation))
+
+	// The Nodes of the up to date MachinePool aren't listed again.
+	workloadClient.lists = 0
+	g.Expect(r.reconcileNodeRefs(ctx, testCluster, mp)).To(Succeed())
+	g.Expect(workloadClient.lists).To(BeZero())
+
+	// Changed annotations are propagated to the Nodes of the up to date MachinePool.
+	mp.Annotations["cost.example.com/team"] = "data"
+	g.Expect(r.reconcileNodeRefs(ctx, testCluster, mp)).To(Succeed())
+	g.Expect(nodeAnnotations()).To(HaveKeyWithValue("cost.example.com/team", "data"))
+
+	// Removed annotations are removed from the Nodes, along with the recorded hash.
+	delete(mp.Annotations, "cost.example.com/team")
+	g.Expect(r.reconcileNodeRefs(ctx, testCluster, mp)).To(Succeed())
+	g.Expect(nodeAnnotations()).NotTo(HaveKey("cost.example.com/team"))
+	g.Expect(mp.Annotations).NotTo(HaveKey(expv1.AppliedAnnotationsHashAnnotation))
+}
+
+// listWorkloadNodes lists the Nodes of a workload cluster, as done once per reconciliation.
+func listWorkloadNodes(g *WithT, c client.Client) []corev1.Node {
+	nodes, err := listNodes(context.TODO(), c)
+	g.Expect(err).NotTo(HaveOccurred())
+	return nodes
+}
+
+// getWorkloadNodes gets the named Nodes of a workload cluster.
+func getWorkloadNodes(g *WithT, c client.Client, names ...string) []*corev1.Node {
+	nodes := make([]*corev1.Node, 0, len(names))
+	for _, name := range names {
+		node := &corev1.Node{}
+		g.Expect(c.Get(context.TODO(), client.ObjectKey{Name: name}, node)).To(Succeed())
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// getCountingClient counts the objects read and patched through it.
+type getCountingClient struct {
+	client.Client
+	gets    int
+	lists   int
+	patches int
+}
+
+func (c *getCountingClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	c.gets++
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *getCountingClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	c.lists++
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c *getCountingClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.patches++
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestMachinePoolReconcileNodeRefsReadBudget(t *testing.T) {
+	g := NewWithT(t)
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	const nodeCount = 25
+	objs := []runtime.Object{testCluster}
+	providerIDList := make([]string, 0, nodeCount)
+	for i := 0; i < nodeCount; i++ {
+		name := fmt.Sprintf("node-%d", i)
+		objs = append(objs, &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.NodeSpec{
+				ProviderID: "aws://us-east-1/" + name,
+			},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+				},
+			},
+		})
+		providerIDList = append(providerIDList, "aws://us-east-1/"+name)
+	}
+
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "large"},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName:    testCluster.Name,
+			ProviderIDList: providerIDList,
+			Taints: []corev1.Taint{
+				{Key: "dedicated", Value: "large", Effect: corev1.TaintEffectNoSchedule},
+			},
+		},
+		Status: expv1.MachinePoolStatus{
+			Replicas: nodeCount,
+		},
+	}
+
+	workloadClient := &getCountingClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme, objs...)}
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, testCluster),
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(32),
+		remoteClientGetter: func(_ context.Context, _ client.Client, _ client.ObjectKey, _ *runtime.Scheme) (client.Client, error) {
+			return workloadClient, nil
+		},
+		NodeReadBudget: 10,
+	}
+
+	taintedNodes := func() int {
+		count := 0
+		for i := 0; i < nodeCount; i++ {
+			node := &corev1.Node{}
+			g.Expect(workloadClient.Client.Get(ctx, client.ObjectKey{Name: fmt.Sprintf("node-%d", i)}, node)).To(Succeed())
+			if len(node.Spec.Taints) == 1 {
+				count++
+			}
+		}
+		return count
+	}
+
+	resetCounts := func() {
+		workloadClient.gets, workloadClient.lists, workloadClient.patches = 0, 0, 0
+	}
+	// The Nodes are listed once per reconciliation, and never read one by one.
+	expectReads := func() {
+		g.Expect(workloadClient.lists).To(Equal(1))
+		g.Expect(workloadClient.gets).To(BeZero())
+		g.Expect(workloadClient.patches).To(BeNumerically("<=", r.NodeReadBudget))
+	}
+
+	// The Nodes are reconciled in chunks within the budget, requeuing in between.
+	for _, expectedReconciled := range []int{10, 20} {
+		resetCounts()
+		err := r.reconcileNodeRefs(ctx, testCluster, mp)
+		g.Expect(err).To(HaveOccurred())
+		_, ok := errors.Cause(err).(capierrors.HasRequeueAfterError)
+		g.Expect(ok).To(BeTrue())
+		expectReads()
+		g.Expect(mp.Status.ReconciledNodes).To(BeEquivalentTo(expectedReconciled))
+		g.Expect(taintedNodes()).To(Equal(expectedReconciled))
+	}
+
+	// The last chunk completes the reconciliation of the Nodes.
+	resetCounts()
+	g.Expect(r.reconcileNodeRefs(ctx, testCluster, mp)).To(Succeed())
+	expectReads()
+	g.Expect(mp.Status.ReconciledNodes).To(BeZero())
+	g.Expect(taintedNodes()).To(Equal(nodeCount))
+	g.Expect(mp.Status.NodeRefs).To(HaveLen(nodeCount))
+}
+
+func TestMachinePoolReconcileNodeRefsProviderIDReuse(t *testing.T) {
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	now := metav1.Now()
+	earlier := metav1.NewTime(now.Add(-time.Hour))
+	newNode := func(name string, uid types.UID, creationTimestamp metav1.Time) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, UID: uid, CreationTimestamp: creationTimestamp},
+			Spec: corev1.NodeSpec{
+				ProviderID: "aws://us-east-1/id-1",
+			},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name            string
+		nodes           []runtime.Object
+		expectedNodeRef corev1.ObjectReference
+		expectRefreshed bool
+	}{
+		{
+			name:            "node references are left untouched while the provider ID maps to the same Node",
+			nodes:           []runtime.Object{newNode("node-1", "uid-1", earlier)},
+			expectedNodeRef: corev1.ObjectReference{Name: "node-1", UID: "uid-1"},
+		},
+		{
+			name:            "node references are refreshed when the provider ID maps to a new Node",
+			nodes:           []runtime.Object{newNode("node-2", "uid-2", now)},
+			expectedNodeRef: corev1.ObjectReference{Name: "node-2", UID: "uid-2"},
+			expectRefreshed: true,
+		},
+		{
+			name:            "node references are refreshed when the Node is recreated with the same name",
+			nodes:           []runtime.Object{newNode("node-1", "uid-2", now)},
+			expectedNodeRef: corev1.ObjectReference{Name: "node-1", UID: "uid-2"},
+			expectRefreshed: true,
+		},
+		{
+			name:            "the newest Node is referenced while the replaced Node still exists",
+			nodes:           []runtime.Object{newNode("node-2", "uid-2", now), newNode("node-1", "uid-1", earlier)},
+			expectedNodeRef: corev1.ObjectReference{Name: "node-2", UID: "uid-2"},
+			expectRefreshed: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "reuse"},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName:    testCluster.Name,
+					ProviderIDList: []string{"aws://us-east-1/id-1"},
+				},
+				Status: expv1.MachinePoolStatus{
+					Replicas:      1,
+					ReadyReplicas: 1,
+					NodeRefs:      []corev1.ObjectReference{{Name: "node-1", UID: "uid-1"}},
+				},
+			}
+
+			recorder := record.NewFakeRecorder(32)
+			fakeClock := clock.NewFakeClock(now.Time)
+			remoteClients := 0
+			r := &MachinePoolReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme, append(tc.nodes, testCluster)...),
+				Log:      log.Log,
+				scheme:   scheme.Scheme,
+				recorder: recorder,
+				clock:    fakeClock,
+				remoteClientGetter: func(ctx context.Context, c client.Client, cluster client.ObjectKey, scheme *runtime.Scheme) (client.Client, error) {
+					remoteClients++
+					return fakeremote.NewClusterClient(ctx, c, cluster, scheme)
+				},
+			}
+
+			// The workload cluster isn't checked for stale references until the check interval elapsed.
+			g.Expect(r.reconcileNodeRefs(ctx, testCluster, mp)).To(Succeed())
+			g.Expect(remoteClients).To(BeZero())
+			g.Expect(mp.Status.NodeRefs[0].UID).To(Equal(types.UID("uid-1")))
+
+			fakeClock.Step(staleNodeRefsCheckInterval)
+			g.Expect(r.reconcileNodeRefs(ctx, testCluster, mp)).To(Succeed())
+			g.Expect(remoteClients).To(Equal(1))
+			g.Expect(mp.Status.NodeRefs).To(HaveLen(1))
+			g.Expect(mp.Status.NodeRefs[0].Name).To(Equal(tc.expectedNodeRef.Name))
+			g.Expect(mp.Status.NodeRefs[0].UID).To(Equal(tc.expectedNodeRef.UID))
+
+			if !tc.expectRefreshed {
+				g.Expect(recorder.Events).To(BeEmpty())
+				return
+			}
+			g.Expect(recorder.Events).To(Receive(ContainSubstring("StaleNodeRef")))
+		})
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// pauseConfigMapNamespace and pauseConfigMapName identify the ConfigMap used to pause the
+	// reconciliation of all MachinePools, e.g. during an upgrade of the management cluster.
+	pauseConfigMapNamespace = "capi-system"
+	pauseConfigMapName      = "machinepool-pause"
+
+	// pauseConfigMapKey is the key of the pause ConfigMap which pauses reconciliation when set to "true".
+	pauseConfigMapKey = "paused"
+)
+
+// isReconcilePaused returns true if the reconciliation of all MachinePools is paused by the pause ConfigMap.
+// The pause and the resume of the reconciliation are logged once.
+func (r *MachinePoolReconciler) isReconcilePaused(ctx context.Context) (bool, error) {
+	// Read the ConfigMap from the API server, so that the ConfigMaps of the management cluster aren't all cached.
+	reader := r.apiReader
+	if reader == nil {
+		reader = r.Client
+	}
+
+	cm := &corev1.ConfigMap{}
+	key := types.NamespacedName{Namespace: pauseConfigMapNamespace, Name: pauseConfigMapName}
+	paused := false
+	if err := reader.Get(ctx, key, cm); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, errors.Wrapf(err, "failed to retrieve ConfigMap %s", key)
+		}
+	} else {
+		paused = cm.Data[pauseConfigMapKey] == "true"
+	}
+
+	if paused {
+		if atomic.CompareAndSwapInt32(&r.paused, 0, 1) {
+			r.Log.Info("Reconciliation of MachinePools is paused", "configmap", key)
+		}
+	} else if atomic.CompareAndSwapInt32(&r.paused, 1, 0) {
+		r.Log.Info("Reconciliation of MachinePools is resumed", "configmap", key)
+	}
+	return paused, nil
+}
+
+// pauseConfigMapToMachinePools maps events from the pause ConfigMap to all MachinePools,
+// so that they are reconciled as soon as the reconciliation is resumed.
+func (r *MachinePoolReconciler) pauseConfigMapToMachinePools(o handler.MapObject) []reconcile.Request {
+	if o.Meta.GetNamespace() != pauseConfigMapNamespace || o.Meta.GetName() != pauseConfigMapName {
+		return nil
+	}
+
+	mpList := &expv1.MachinePoolList{}
+	if err := r.Client.List(context.TODO(), mpList); err != nil {
+		r.Log.Error(err, "Unable to list MachinePools")
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, mp := range mpList.Items {
+		key := types.NamespacedName{Namespace: mp.Namespace, Name: mp.Name}
+		requests = append(requests, reconcile.Request{NamespacedName: key})
+	}
+	return requests
+}
+
+// newPauseConfigMapInformer returns an informer watching only the pause ConfigMap, rather than all the ConfigMaps of
+// the management cluster.
+func newPauseConfigMapInformer(config *rest.Config) (toolscache.SharedIndexInformer, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create a clientset for the management cluster")
+	}
+
+	listWatch := toolscache.NewFilteredListWatchFromClient(clientset.CoreV1().RESTClient(), "configmaps", pauseConfigMapNamespace,
+		func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", pauseConfigMapName).String()
+		})
+	return toolscache.NewSharedIndexInformer(listWatch, &corev1.ConfigMap{}, 0, toolscache.Indexers{}), nil
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestMachinePoolReconcilePaused(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "paused"},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName: testCluster.Name,
+			Replicas:    pointer.Int32Ptr(1),
+		},
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: pauseConfigMapNamespace, Name: pauseConfigMapName},
+		Data:       map[string]string{pauseConfigMapKey: "true"},
+	}
+
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, mp, cm),
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(32),
+	}
+	request := reconcile.Request{NamespacedName: util.ObjectKey(mp)}
+
+	// The MachinePool is left untouched while reconciliation is paused.
+	res, err := r.Reconcile(request)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(res.RequeueAfter).To(Equal(pausedRequeueWait))
+	g.Expect(r.paused).To(BeEquivalentTo(1))
+
+	actual := &expv1.MachinePool{}
+	g.Expect(r.Client.Get(ctx, util.ObjectKey(mp), actual)).To(Succeed())
+	g.Expect(actual.Finalizers).To(BeEmpty())
+
+	// Any other value of the pause key resumes reconciliation.
+	cm.Data[pauseConfigMapKey] = "false"
+	g.Expect(r.Client.Update(ctx, cm)).To(Succeed())
+
+	_, _ = r.Reconcile(request)
+	g.Expect(r.paused).To(BeEquivalentTo(0))
+	g.Expect(r.Client.Get(ctx, util.ObjectKey(mp), actual)).To(Succeed())
+	g.Expect(actual.Finalizers).To(ContainElement(expv1.MachinePoolFinalizer))
+
+	// Pausing again stops reconciliation, and removing the ConfigMap resumes it.
+	cm.Data[pauseConfigMapKey] = "true"
+	g.Expect(r.Client.Update(ctx, cm)).To(Succeed())
+
+	paused, err := r.isReconcilePaused(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(paused).To(BeTrue())
+
+	g.Expect(r.Client.Delete(ctx, cm)).To(Succeed())
+
+	paused, err = r.isReconcilePaused(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(paused).To(BeFalse())
+	g.Expect(r.paused).To(BeEquivalentTo(0))
+}
+
+func TestMachinePoolReconcilePausedReadsAPIServer(t *testing.T) {
+	g := NewWithT(t)
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: pauseConfigMapNamespace, Name: pauseConfigMapName},
+		Data:       map[string]string{pauseConfigMapKey: "true"},
+	}
+
+	// The pause ConfigMap is read from the API server rather than from the cache of the client.
+	r := &MachinePoolReconciler{
+		Client:    fake.NewFakeClientWithScheme(scheme.Scheme),
+		Log:       log.Log,
+		apiReader: fake.NewFakeClientWithScheme(scheme.Scheme, cm),
+	}
+
+	paused, err := r.isReconcilePaused(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(paused).To(BeTrue())
+}
+
+func TestPauseConfigMapToMachinePools(t *testing.T) {
+	mp1 := &expv1.MachinePool{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mp1"}}
+	mp2 := &expv1.MachinePool{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "mp2"}}
+
+	r := &MachinePoolReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, mp1, mp2),
+		Log:    log.Log,
+	}
+
+	testCases := []struct {
+		name     string
+		cm       *corev1.ConfigMap
+		expected []reconcile.Request
+	}{
+		{
+			name: "should enqueue all machinePools for the pause configMap",
+			cm: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: pauseConfigMapNamespace, Name: pauseConfigMapName},
+			},
+			expected: []reconcile.Request{
+				{NamespacedName: util.ObjectKey(mp1)},
+				{NamespacedName: util.ObjectKey(mp2)},
+			},
+		},
+		{
+			name: "should ignore other configMaps",
+			cm: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: pauseConfigMapNamespace, Name: "other"},
+			},
+		},
+		{
+			name: "should ignore configMaps named after the pause configMap in other namespaces",
+			cm: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: pauseConfigMapName},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			requests := r.pauseConfigMapToMachinePools(handler.MapObject{Meta: tc.cm, Object: tc.cm})
+			if tc.expected == nil {
+				g.Expect(requests).To(BeEmpty())
+				return
+			}
+			g.Expect(requests).To(ConsistOf(tc.expected))
+		})
+	}
+}
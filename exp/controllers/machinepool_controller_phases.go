@@ -17,16 +17,25 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/external"
@@ -34,17 +43,97 @@ import (
 	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+// bootstrapDataSecretValueKey is the key of the bootstrap data in bootstrap data secrets.
+const bootstrapDataSecretValueKey = "value"
+
+// maxLastErrorLength is the maximum length of the error message reported in Status.LastError.
+const maxLastErrorLength = 1024
+
+// templateNetworkField is a network setting propagated from an annotation of the template of a MachinePool
+// to a field of its infrastructure object.
+type templateNetworkField struct {
+	annotation string
+	path       []string
+	// list is true if the field is a list, set from the comma separated values of the annotation.
+	list bool
+}
+
 var (
 	externalReadyWait = 30 * time.Second
+
+	defaultInfrastructureMissingGracePeriod = 1 * time.Minute
+
+	defaultReconcileTimeout = 5 * time.Minute
+
+	lastReconcileTimeResolution = 1 * time.Minute
+
+	reconcileErrorEventInterval = 5 * time.Minute
+
+	nodeReadBudgetWait = 5 * time.Second
+
+	staleNodeRefsCheckInterval = 5 * time.Minute
+
+	pausedRequeueWait = 30 * time.Second
+
+	crdTerminatingCheckInterval = 30 * time.Second
+
+	infrastructureRecreateInitialBackoff = 1 * time.Minute
+	infrastructureRecreateMaxBackoff     = 1 * time.Hour
+
+	apiThrottleInitialBackoff = 5 * time.Second
+	apiThrottleMaxBackoff     = 5 * time.Minute
+
+	// defaultInfrastructureConditionTypes are the types of the infrastructure object conditions copied to a
+	// MachinePool if the reconciler isn't configured with any.
+	defaultInfrastructureConditionTypes = []clusterv1.ConditionType{clusterv1.ReadyCondition}
+
+	// deletingInstanceStates are the instance states reported by infrastructure providers for instances being deleted.
+	deletingInstanceStates = sets.NewString("deleting", "shutting-down", "stopping", "terminating")
+
+	failureDomainDrainWait = 30 * time.Second
+
+	// terminalFailureReasons are the failure reasons reported by infrastructure providers for failures which
+	// retrying can't fix, e.g. a misconfiguration. Other failure reasons are considered transient.
+	terminalFailureReasons = sets.NewString(
+		string(capierrors.InvalidConfigurationMachinePoolError),
+		string(capierrors.UnsupportedChangeMachineError),
+	)
+
+	nodeDrainWait = 20 * time.Second
+
+	// templateNetworkFields maps the network annotations of the template of a MachinePool to the fields of the
+	// spec of its infrastructure object they are propagated to.
+	templateNetworkFields = []templateNetworkField{
+		{annotation: expv1.SubnetIDsAnnotation, path: []string{"spec", "subnetIDs"}, list: true},
+		{annotation: expv1.PodCIDRAnnotation, path: []string{"spec", "podCIDR"}},
+	}
+
+	reconcileMetricsInterval = 30 * time.Second
+
+	preProvisionApprovalWait = 1 * time.Minute
+
+	preProvisionWebhookTimeout = 10 * time.Second
+
+	dependencyWait = 30 * time.Second
+
+	deleteExternalInitialBackoff = 10 * time.Second
+	deleteExternalMaxBackoff     = 5 * time.Minute
+	deleteExternalMaxAttempts    = 10
+
+	surgeStepWait = 30 * time.Second
 )
 
-func (r *MachinePoolReconciler) reconcilePhase(mp *expv1.MachinePool) {
+func (r *MachinePoolReconciler) reconcilePhase(ctx context.Context, mp *expv1.MachinePool) error {
+	oldPhase := expv1.MachinePoolPhase(mp.Status.Phase)
+
 	// Set the phase to "pending" if nil.
 	if mp.Status.Phase == "" {
 		mp.Status.SetTypedPhase(expv1.MachinePoolPhasePending)
@@ -56,22 +145,23 @@ func (r *MachinePoolReconciler) reconcilePhase(mp *expv1.MachinePool) {
 	}
 
 	// Set the phase to "provisioned" if the infrastructure is ready.
-	if len(mp.Status.NodeRefs) != 0 {
+	if nodeRefsCount(mp) != 0 {
 		mp.Status.SetTypedPhase(expv1.MachinePoolPhaseProvisioned)
 	}
 
 	// Set the phase to "running" if the number of ready replicas is equal to desired replicas.
-	if mp.Status.InfrastructureReady && *mp.Spec.Replicas == mp.Status.ReadyReplicas {
+	desired := desiredReplicas(mp)
+	if mp.Status.InfrastructureReady && desired == mp.Status.ReadyReplicas {
 		mp.Status.SetTypedPhase(expv1.MachinePoolPhaseRunning)
 	}
 
 	// Set the phase to "scalingUp" if the infrastructure is scaling up.
-	if mp.Status.InfrastructureReady && *mp.Spec.Replicas > mp.Status.ReadyReplicas {
+	if mp.Status.InfrastructureReady && desired > mp.Status.ReadyReplicas {
 		mp.Status.SetTypedPhase(expv1.MachinePoolPhaseScalingUp)
 	}
 
 	// Set the phase to "scalingDown" if the infrastructure is scaling down.
-	if mp.Status.InfrastructureReady && *mp.Spec.Replicas < mp.Status.ReadyReplicas {
+	if mp.Status.InfrastructureReady && desired < mp.Status.ReadyReplicas {
 		mp.Status.SetTypedPhase(expv1.MachinePoolPhaseScalingDown)
 	}
 
@@ -84,13 +174,146 @@ func (r *MachinePoolReconciler) reconcilePhase(mp *expv1.MachinePool) {
 	if !mp.DeletionTimestamp.IsZero() {
 		mp.Status.SetTypedPhase(expv1.MachinePoolPhaseDeleting)
 	}
+
+	// Let the phase evaluator override the computed phase, if configured.
+	if r.PhaseEvaluator != nil {
+		mp.Status.SetTypedPhase(r.PhaseEvaluator(mp, expv1.MachinePoolPhase(mp.Status.Phase)))
+	}
+
+	// Set the display phase, using the configured display name for the phase if any.
+	mp.Status.DisplayPhase = mp.Status.Phase
+	if displayName, ok := r.PhaseDisplayNames[expv1.MachinePoolPhase(mp.Status.Phase)]; ok {
+		mp.Status.DisplayPhase = displayName
+	}
+	mp.Status.Summary = machinePoolSummary(mp)
+	mp.Status.ProgressPercent = machinePoolProgressPercent(mp)
+	mp.Status.NotRunningReason = machinePoolNotRunningReason(mp)
+
+	if newPhase := expv1.MachinePoolPhase(mp.Status.Phase); newPhase != oldPhase {
+		return r.runPhaseHooks(ctx, mp, oldPhase, newPhase)
+	}
+	return nil
+}
+
+// reconcilePendingCondition sets the PendingCondition of a Pending MachinePool to the first reason found why its
+// bootstrap data isn't generated yet, and removes it from MachinePools in other phases.
+func reconcilePendingCondition(cluster *clusterv1.Cluster, mp *expv1.MachinePool) {
+	if mp.Status.GetTypedPhase() != expv1.MachinePoolPhasePending {
+		conditions.Delete(mp, expv1.PendingCondition)
+		return
+	}
+
+	var reason, message string
+	switch {
+	case annotations.IsPaused(cluster, mp):
+		reason, message = expv1.ReconciliationPausedReason, "Reconciliation of the MachinePool or its Cluster is paused"
+	case !cluster.Status.InfrastructureReady:
+		reason, message = expv1.WaitingForClusterReason, fmt.Sprintf("Waiting for the infrastructure of Cluster %q to be ready", cluster.Name)
+	case !cluster.Status.ControlPlaneInitialized:
+		reason, message = expv1.WaitingForClusterReason, fmt.Sprintf("Waiting for the control plane of Cluster %q to be initialized", cluster.Name)
+	case mp.Spec.Template.Spec.Bootstrap.ConfigRef == nil && mp.Spec.Template.Spec.Bootstrap.DataSecretName == nil:
+		reason, message = expv1.BootstrapNotStartedReason, "Neither a bootstrap configuration nor a bootstrap data secret is set"
+	default:
+		reason, message = expv1.BootstrapNotStartedReason, "Waiting for the bootstrap provider to generate the bootstrap data"
+	}
+	conditions.Set(mp, &clusterv1.Condition{
+		Type:    expv1.PendingCondition,
+		Status:  corev1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// reconcilePausedPending reports why a paused MachinePool which was never reconciled, or is still Pending, is
+// Pending. Paused MachinePools in other phases are left untouched.
+func (r *MachinePoolReconciler) reconcilePausedPending(ctx context.Context, cluster *clusterv1.Cluster, mp *expv1.MachinePool) error {
+	if mp.Status.Phase != "" && mp.Status.GetTypedPhase() != expv1.MachinePoolPhasePending {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(mp, r.Client)
+	if err != nil {
+		return err
+	}
+	mp.Status.SetTypedPhase(expv1.MachinePoolPhasePending)
+	reconcilePendingCondition(cluster, mp)
+	return patchHelper.Patch(ctx, mp)
+}
+
+// replicasExternallyManaged returns true if the replicas of a MachinePool are managed by an external system,
+// as set by the ReplicasManagedByAnnotation.
+func replicasExternallyManaged(mp *expv1.MachinePool) bool {
+	_, ok := mp.Annotations[expv1.ReplicasManagedByAnnotation]
+	return ok
+}
+
+// desiredReplicas returns the number of replicas a MachinePool is expected to converge to: Spec.Replicas, or the
+// replicas observed by the infrastructure provider if they are externally managed.
+func desiredReplicas(mp *expv1.MachinePool) int32 {
+	if replicasExternallyManaged(mp) {
+		return mp.Status.Replicas
+	}
+	if mp.Spec.Replicas != nil {
+		return *mp.Spec.Replicas
+	}
+	return 0
+}
+
+// machinePoolSummary returns a one-line human readable summary of the status of a MachinePool, e.g. "3/3 ready, Running".
+func machinePoolSummary(mp *expv1.MachinePool) string {
+	return fmt.Sprintf("%d/%d ready, %s", mp.Status.ReadyReplicas, desiredReplicas(mp), mp.Status.DisplayPhase)
+}
+
+// machinePoolNotRunningReason returns a human readable reason why a MachinePool isn't Running, naming the first
+// blocking factor found, or an empty string if it is Running.
+func machinePoolNotRunningReason(mp *expv1.MachinePool) string {
+	replicas := desiredReplicas(mp)
+
+	switch {
+	case mp.Status.GetTypedPhase() == expv1.MachinePoolPhaseRunning:
+		return ""
+	case !mp.DeletionTimestamp.IsZero():
+		return "MachinePool is being deleted"
+	case mp.Status.FailureMessage != nil:
+		return fmt.Sprintf("Failure reported: %s", *mp.Status.FailureMessage)
+	case mp.Status.FailureReason != nil:
+		return fmt.Sprintf("Failure reported: %s", *mp.Status.FailureReason)
+	case !mp.Status.BootstrapReady:
+		return "Bootstrap is not ready"
+	case !mp.Status.InfrastructureReady:
+		return "Infrastructure is not ready"
+	case mp.Status.ReadyReplicas != replicas:
+		return fmt.Sprintf("%d of %d replicas are ready", mp.Status.ReadyReplicas, replicas)
+	}
+	return fmt.Sprintf("Phase is %s", mp.Status.Phase)
+}
+
+// machinePoolProgressPercent returns the percentage of the desired replicas of a MachinePool which are ready, clamped
+// between 0 and 100. MachinePools scaled to zero are complete once their infrastructure is ready.
+func machinePoolProgressPercent(mp *expv1.MachinePool) int32 {
+	replicas := desiredReplicas(mp)
+	if replicas <= 0 {
+		if mp.Status.InfrastructureReady {
+			return 100
+		}
+		return 0
+	}
+
+	percent := int64(mp.Status.ReadyReplicas) * 100 / int64(replicas)
+	switch {
+	case percent < 0:
+		return 0
+	case percent > 100:
+		return 100
+	}
+	return int32(percent)
 }
 
 // reconcileExternal handles generic unstructured objects referenced by a MachinePool.
 func (r *MachinePoolReconciler) reconcileExternal(ctx context.Context, cluster *clusterv1.Cluster, m *expv1.MachinePool, ref *corev1.ObjectReference) (external.ReconcileOutput, error) {
 	logger := r.Log.WithValues("machinepool", m.Name, "namespace", m.Namespace)
 
-	obj, err := external.Get(ctx, r.Client, ref, m.Namespace)
+	obj, err := r.getExternal(ctx, ref, m.Namespace)
 	if err != nil {
 		if apierrors.IsNotFound(errors.Cause(err)) {
 			return external.ReconcileOutput{}, errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: externalReadyWait},
@@ -106,15 +329,106 @@ func (r *MachinePoolReconciler) reconcileExternal(ctx context.Context, cluster *
 		return external.ReconcileOutput{Paused: true}, nil
 	}
 
+	// At steady state the external object has already been adopted and labeled, and the network settings of the
+	// template and the bootstrap data format propagated to the infrastructure object, skip patching it.
+	if !r.isSteadyState(m) {
+		if err := r.adoptExternal(ctx, cluster, m, obj); err != nil {
+			return external.ReconcileOutput{}, err
+		}
+		if ref == &m.Spec.Template.Spec.InfrastructureRef {
+			if err := r.propagateTemplateNetworkFields(ctx, m, obj); err != nil {
+				return external.ReconcileOutput{}, err
+			}
+			if err := r.reconcileBootstrapFormat(ctx, m, obj); err != nil {
+				return external.ReconcileOutput{}, err
+			}
+		}
+	}
+
+	// Add watcher for external object, if there isn't one already.
+	if err := r.watchExternal(obj); err != nil {
+		return external.ReconcileOutput{}, err
+	}
+
+	// Set failure reason and message, if any.
+	failureReason, failureMessage, err := external.FailuresFrom(obj)
+	if err != nil {
+		return external.ReconcileOutput{}, err
+	}
+	if failureReason != "" {
+		machineStatusFailure := capierrors.MachinePoolStatusFailure(failureReason)
+		m.Status.FailureReason = &machineStatusFailure
+	}
+	if failureMessage != "" {
+		m.Status.FailureMessage = pointer.StringPtr(
+			fmt.Sprintf("Failure detected from referenced resource %v with name %q: %s",
+				obj.GroupVersionKind(), obj.GetName(), failureMessage),
+		)
+	}
+
+	return external.ReconcileOutput{Result: obj}, nil
+}
+
+// watchExternal adds a watcher for the kind of an external object, if there isn't one already.
+func (r *MachinePoolReconciler) watchExternal(obj *unstructured.Unstructured) error {
+	_, loaded := r.externalWatchers.LoadOrStore(obj.GroupVersionKind().String(), struct{}{})
+	if loaded || r.controller == nil {
+		return nil
+	}
+
+	r.Log.Info("Adding watcher on external object", "gvk", obj.GroupVersionKind())
+	err := r.controller.Watch(
+		&source.Kind{Type: obj},
+		&handler.EnqueueRequestForOwner{OwnerType: &expv1.MachinePool{}},
+	)
+	if err != nil {
+		r.externalWatchers.Delete(obj.GroupVersionKind().String())
+		return errors.Wrapf(err, "failed to add watcher on external object %q", obj.GroupVersionKind())
+	}
+	return nil
+}
+
+// adoptExternal sets the MachinePool as the controller of an external object, and sets its Cluster label, along with
+// an owner reference to the Cluster if ClusterOwnerReferences is set. External objects controlled by another object
+// are only adopted if AdoptControlledExternalObjects is set, external objects labeled for another Cluster are never
+// adopted.
+func (r *MachinePoolReconciler) adoptExternal(ctx context.Context, cluster *clusterv1.Cluster, m *expv1.MachinePool, obj *unstructured.Unstructured) error {
+	// Check that the external object, e.g. created from a template, isn't labeled for another Cluster,
+	// rather than moving it to the Cluster of the MachinePool.
+	if clusterName, ok := obj.GetLabels()[clusterv1.ClusterLabelName]; ok && clusterName != m.Spec.ClusterName {
+		return errors.Errorf("%v %q referenced by MachinePool %q in namespace %q belongs to Cluster %q, expected Cluster %q",
+			obj.GroupVersionKind(), obj.GetName(), m.Name, m.Namespace, clusterName, m.Spec.ClusterName)
+	}
+
 	// Initialize the patch helper.
 	patchHelper, err := patch.NewHelper(obj, r.Client)
 	if err != nil {
-		return external.ReconcileOutput{}, err
+		return err
+	}
+
+	// Check whether the external object is controlled by another object, e.g. when importing MachinePools
+	// created by an older controller.
+	machinePoolRef := metav1.OwnerReference{APIVersion: expv1.GroupVersion.String(), Kind: "MachinePool", Name: m.Name}
+	adopted := false
+	if controllerRef := metav1.GetControllerOf(obj); controllerRef != nil && !util.HasOwnerRef([]metav1.OwnerReference{*controllerRef}, machinePoolRef) {
+		if !r.AdoptControlledExternalObjects {
+			return errors.Errorf("%v %q referenced by MachinePool %q in namespace %q is already controlled by %s %q",
+				obj.GroupVersionKind(), obj.GetName(), m.Name, m.Namespace, controllerRef.Kind, controllerRef.Name)
+		}
+		r.Log.Info("Adopting external object controlled by another object", "machinepool", m.Name, "namespace", m.Namespace,
+			"gvk", obj.GroupVersionKind(), "name", obj.GetName(), "controller", controllerRef.Kind+"/"+controllerRef.Name)
+		obj.SetOwnerReferences(util.RemoveOwnerRef(obj.GetOwnerReferences(), *controllerRef))
+		adopted = true
 	}
 
 	// Set external object ControllerReference to the MachinePool.
 	if err := controllerutil.SetControllerReference(m, obj, r.scheme); err != nil {
-		return external.ReconcileOutput{}, err
+		return err
+	}
+
+	// Reference the Cluster as well, if configured, so that external objects are owned by their Cluster.
+	if r.ClusterOwnerReferences && cluster != nil {
+		obj.SetOwnerReferences(util.EnsureOwnerRef(obj.GetOwnerReferences(), clusterOwnerRef(cluster)))
 	}
 
 	// Set the Cluster label.
@@ -125,46 +439,145 @@ func (r *MachinePoolReconciler) reconcileExternal(ctx context.Context, cluster *
 	labels[clusterv1.ClusterLabelName] = m.Spec.ClusterName
 	obj.SetLabels(labels)
 
+	// Propagate the trace of reconciliations acting on a new spec, so that provider controllers can continue it.
+	if traceParent := traceParentFrom(ctx); traceParent != "" && m.Generation != m.Status.ObservedGeneration {
+		objAnnotations := obj.GetAnnotations()
+		if objAnnotations == nil {
+			objAnnotations = make(map[string]string)
+		}
+		objAnnotations[expv1.TraceParentAnnotation] = traceParent
+		obj.SetAnnotations(objAnnotations)
+	}
+
+	// Apply the fields set above if configured, unless the controller reference of another object was removed,
+	// which only a patch of the whole list of owner references can do.
+	if r.ExternalPatchFieldManager != "" && !adopted {
+		return r.applyExternal(ctx, cluster, m, obj)
+	}
+
 	// Always attempt to Patch the external object.
-	if err := patchHelper.Patch(ctx, obj); err != nil {
-		return external.ReconcileOutput{}, err
+	return patchHelper.Patch(ctx, obj)
+}
+
+// applyExternal sets the Cluster label, the owner references and the TraceParentAnnotation of an external object
+// with server-side apply under the ExternalPatchFieldManager, forcing the ownership of these fields only.
+func (r *MachinePoolReconciler) applyExternal(ctx context.Context, cluster *clusterv1.Cluster, m *expv1.MachinePool, obj *unstructured.Unstructured) error {
+	applied := &unstructured.Unstructured{}
+	applied.SetGroupVersionKind(obj.GroupVersionKind())
+	applied.SetNamespace(obj.GetNamespace())
+	applied.SetName(obj.GetName())
+	applied.SetLabels(map[string]string{clusterv1.ClusterLabelName: m.Spec.ClusterName})
+	var ownerRefs []metav1.OwnerReference
+	if controllerRef := metav1.GetControllerOf(obj); controllerRef != nil {
+		ownerRefs = append(ownerRefs, *controllerRef)
+	}
+	if r.ClusterOwnerReferences && cluster != nil {
+		ownerRefs = append(ownerRefs, clusterOwnerRef(cluster))
+	}
+	applied.SetOwnerReferences(ownerRefs)
+	if traceParent, ok := obj.GetAnnotations()[expv1.TraceParentAnnotation]; ok {
+		applied.SetAnnotations(map[string]string{expv1.TraceParentAnnotation: traceParent})
 	}
 
-	// Add watcher for external object, if there isn't one already.
-	_, loaded := r.externalWatchers.LoadOrStore(obj.GroupVersionKind().String(), struct{}{})
-	if !loaded && r.controller != nil {
-		logger.Info("Adding watcher on external object", "gvk", obj.GroupVersionKind())
-		err := r.controller.Watch(
-			&source.Kind{Type: obj},
-			&handler.EnqueueRequestForOwner{OwnerType: &expv1.MachinePool{}},
-		)
-		if err != nil {
-			r.externalWatchers.Delete(obj.GroupVersionKind().String())
-			return external.ReconcileOutput{}, errors.Wrapf(err, "failed to add watcher on external object %q", obj.GroupVersionKind())
-		}
+	if err := r.Client.Patch(ctx, applied, client.Apply, client.FieldOwner(r.ExternalPatchFieldManager), client.ForceOwnership); err != nil {
+		return errors.Wrapf(err, "failed to apply %v %q for MachinePool %q in namespace %q",
+			obj.GroupVersionKind(), obj.GetName(), m.Name, m.Namespace)
 	}
+	return nil
+}
 
-	// Set failure reason and message, if any.
-	failureReason, failureMessage, err := external.FailuresFrom(obj)
+// clusterOwnerRef returns the non-controller owner reference to a Cluster set on the external objects of its
+// MachinePools.
+func clusterOwnerRef(cluster *clusterv1.Cluster) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: clusterv1.GroupVersion.String(),
+		Kind:       "Cluster",
+		Name:       cluster.Name,
+		UID:        cluster.UID,
+	}
+}
+
+// propagateTemplateNetworkFields writes the network settings set on the template of a MachinePool to the fields of
+// its infrastructure object they are mapped to in templateNetworkFields. Fields whose annotation isn't set on the
+// template are left to the infrastructure provider.
+func (r *MachinePoolReconciler) propagateTemplateNetworkFields(ctx context.Context, m *expv1.MachinePool, infraConfig *unstructured.Unstructured) error {
+	patchHelper, err := patch.NewHelper(infraConfig, r.Client)
 	if err != nil {
-		return external.ReconcileOutput{}, err
+		return err
 	}
-	if failureReason != "" {
-		machineStatusFailure := capierrors.MachinePoolStatusFailure(failureReason)
-		m.Status.FailureReason = &machineStatusFailure
+
+	changed := false
+	for _, field := range templateNetworkFields {
+		value, ok := m.Spec.Template.Annotations[field.annotation]
+		if !ok {
+			continue
+		}
+
+		var desired interface{} = value
+		if field.list {
+			values := []interface{}{}
+			for _, v := range strings.Split(value, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					values = append(values, v)
+				}
+			}
+			desired = values
+		}
+
+		current, found, err := unstructured.NestedFieldNoCopy(infraConfig.Object, field.path...)
+		if err != nil {
+			return errors.Wrapf(err, "failed to retrieve %s from infrastructure provider for MachinePool %q in namespace %q",
+				strings.Join(field.path, "."), m.Name, m.Namespace)
+		}
+		if found && reflect.DeepEqual(current, desired) {
+			continue
+		}
+		if err := unstructured.SetNestedField(infraConfig.Object, desired, field.path...); err != nil {
+			return errors.Wrapf(err, "failed to set %s on infrastructure provider for MachinePool %q in namespace %q",
+				strings.Join(field.path, "."), m.Name, m.Namespace)
+		}
+		changed = true
 	}
-	if failureMessage != "" {
-		m.Status.FailureMessage = pointer.StringPtr(
-			fmt.Sprintf("Failure detected from referenced resource %v with name %q: %s",
-				obj.GroupVersionKind(), obj.GetName(), failureMessage),
-		)
+	if !changed {
+		return nil
 	}
 
-	return external.ReconcileOutput{Result: obj}, nil
+	if err := patchHelper.Patch(ctx, infraConfig); err != nil {
+		return errors.Wrapf(err, "failed to propagate network settings to infrastructure provider for MachinePool %q in namespace %q", m.Name, m.Namespace)
+	}
+	return nil
+}
+
+// getExternal retrieves the object referenced by a MachinePool. Once a watch has been established for the
+// object's kind it is read from the informer cache, falling back to a live read if the cache doesn't have it.
+func (r *MachinePoolReconciler) getExternal(ctx context.Context, ref *corev1.ObjectReference, namespace string) (*unstructured.Unstructured, error) {
+	if _, watched := r.externalWatchers.Load(ref.GroupVersionKind().String()); watched && r.cachedReader != nil {
+		obj, err := external.Get(ctx, r.cachedReader, ref, namespace)
+		if err == nil {
+			return obj, nil
+		}
+		if !apierrors.IsNotFound(errors.Cause(err)) {
+			return nil, err
+		}
+	}
+	return external.Get(ctx, r.Client, ref, namespace)
 }
 
 // reconcileBootstrap reconciles the Spec.Bootstrap.ConfigRef object on a MachinePool.
 func (r *MachinePoolReconciler) reconcileBootstrap(ctx context.Context, cluster *clusterv1.Cluster, m *expv1.MachinePool) error {
+	ctx, span := r.startSpan(ctx, "reconcileBootstrap")
+	defer span.End()
+
+	// Hold bootstrapping until the control plane is initialized, unless the MachinePool intentionally provisions early.
+	if _, skip := m.Annotations[expv1.SkipWaitForControlPlaneInitializedAnnotation]; !skip {
+		if !cluster.Status.ControlPlaneInitialized {
+			conditions.MarkFalse(m, expv1.ControlPlaneInitializedCondition, expv1.WaitingForControlPlaneInitializedReason, clusterv1.ConditionSeverityInfo, "")
+			return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: externalReadyWait},
+				"Control plane of Cluster %q for MachinePool %q in namespace %q is not initialized, requeuing", cluster.Name, m.Name, m.Namespace)
+		}
+		conditions.MarkTrue(m, expv1.ControlPlaneInitializedCondition)
+	}
+
 	// Call generic external reconciler if we have an external reference.
 	var bootstrapConfig *unstructured.Unstructured
 	if m.Spec.Template.Spec.Bootstrap.ConfigRef != nil {
@@ -179,10 +592,15 @@ func (r *MachinePoolReconciler) reconcileBootstrap(ctx context.Context, cluster
 		bootstrapConfig = bootstrapReconcileResult.Result
 	}
 
-	// If the bootstrap data secret is populated, set ready and return.
-	if m.Spec.Template.Spec.Bootstrap.Data != nil || m.Spec.Template.Spec.Bootstrap.DataSecretName != nil {
-		m.Status.BootstrapReady = true
-		return nil
+	// If the bootstrap data is populated, store it in a secret and set ready.
+	if m.Spec.Template.Spec.Bootstrap.Data != nil {
+		return r.reconcileBootstrapData(ctx, m)
+	}
+	if m.Spec.Template.Spec.Bootstrap.DataSecretName != nil {
+		if err := r.rotateBootstrapDataSecret(m, bootstrapConfig); err != nil {
+			return err
+		}
+		return r.reconcileBootstrapDataSecret(ctx, m)
 	}
 
 	// If the bootstrap config is being deleted, return early.
@@ -195,7 +613,14 @@ func (r *MachinePoolReconciler) reconcileBootstrap(ctx context.Context, cluster
 	if err != nil {
 		return err
 	} else if !ready {
-		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: externalReadyWait},
+		if err := reconcileBootstrapNotReadyReason(m, bootstrapConfig); err != nil {
+			return err
+		}
+		if message := conditions.GetMessage(m, clusterv1.BootstrapReadyCondition); message != "" {
+			return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: r.bootstrapReadyWait()},
+				"Bootstrap provider for MachinePool %q in namespace %q is not ready: %s, requeuing", m.Name, m.Namespace, message)
+		}
+		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: r.bootstrapReadyWait()},
 			"Bootstrap provider for MachinePool %q in namespace %q is not ready, requeuing", m.Name, m.Namespace)
 	}
 
@@ -208,74 +633,1222 @@ func (r *MachinePoolReconciler) reconcileBootstrap(ctx context.Context, cluster
 	}
 
 	m.Spec.Template.Spec.Bootstrap.DataSecretName = pointer.StringPtr(secretName)
-	m.Status.BootstrapReady = true
-	return nil
+	return r.reconcileBootstrapDataSecret(ctx, m)
 }
 
-// reconcileInfrastructure reconciles the Spec.InfrastructureRef object on a MachinePool.
-func (r *MachinePoolReconciler) reconcileInfrastructure(ctx context.Context, cluster *clusterv1.Cluster, mp *expv1.MachinePool) error {
-	// Call generic external reconciler.
-	infraReconcileResult, err := r.reconcileExternal(ctx, cluster, mp, &mp.Spec.Template.Spec.InfrastructureRef)
+// reconcileBootstrapNotReadyReason sets the BootstrapReady condition of a MachinePool whose bootstrap provider isn't
+// ready to the reason reported by the provider: its failure reason and message if it failed, otherwise its own Ready
+// condition, if any.
+func reconcileBootstrapNotReadyReason(m *expv1.MachinePool, bootstrapConfig *unstructured.Unstructured) error {
+	failureReason, failureMessage, err := external.FailuresFrom(bootstrapConfig)
 	if err != nil {
-		if mp.Status.InfrastructureReady && strings.Contains(err.Error(), "could not find") {
-			// Infra object went missing after the machine pool was up and running
-			r.Log.Error(err, "MachinePool infrastructure reference has been deleted after being ready, setting failure state")
-			mp.Status.FailureReason = capierrors.MachinePoolStatusErrorPtr(capierrors.InvalidConfigurationMachinePoolError)
-			mp.Status.FailureMessage = pointer.StringPtr(fmt.Sprintf("MachinePool infrastructure resource %v with name %q has been deleted after being ready",
-				mp.Spec.Template.Spec.InfrastructureRef.GroupVersionKind(), mp.Spec.Template.Spec.InfrastructureRef.Name))
-		}
 		return err
 	}
-	// if the external object is paused, return without any further processing
-	if infraReconcileResult.Paused {
+	if failureReason != "" || failureMessage != "" {
+		reason := failureReason
+		if reason == "" {
+			reason = clusterv1.WaitingForDataSecretFallbackReason
+		}
+		conditions.MarkFalse(m, clusterv1.BootstrapReadyCondition, reason, clusterv1.ConditionSeverityError, "%s", failureMessage)
 		return nil
 	}
-	infraConfig := infraReconcileResult.Result
 
-	if !infraConfig.GetDeletionTimestamp().IsZero() {
+	conditions.SetMirror(m, clusterv1.BootstrapReadyCondition, conditions.UnstructuredGetter(bootstrapConfig),
+		conditions.WithFallbackValue(false, clusterv1.WaitingForDataSecretFallbackReason, clusterv1.ConditionSeverityInfo, ""))
+	return nil
+}
+
+// reconcileBootstrapRotation checks whether the bootstrap provider of a MachinePool at steady state, whose bootstrap
+// isn't otherwise reconciled, rotated the bootstrap data secret.
+func (r *MachinePoolReconciler) reconcileBootstrapRotation(ctx context.Context, cluster *clusterv1.Cluster, m *expv1.MachinePool) error {
+	ref := m.Spec.Template.Spec.Bootstrap.ConfigRef
+	if ref == nil {
 		return nil
 	}
 
-	ready, err := external.IsReady(infraConfig)
+	bootstrapConfig, err := r.getExternal(ctx, ref, m.Namespace)
+	if err != nil {
+		return errors.Wrapf(err, "failed to retrieve %v %q for MachinePool %q in namespace %q", ref.GroupVersionKind(), ref.Name, m.Name, m.Namespace)
+	}
+	if annotations.IsPaused(cluster, bootstrapConfig) {
+		return nil
+	}
+	return r.rotateBootstrapDataSecret(m, bootstrapConfig)
+}
+
+// rotateBootstrapDataSecret sets the bootstrap data secret name of a MachinePool to the one published by its bootstrap
+// provider, when the provider rotates the data to a new secret, e.g. versioned with a "-v2" suffix. The template of
+// the MachinePool changing, the infrastructure provider rolls its instances with the configured strategy.
+func (r *MachinePoolReconciler) rotateBootstrapDataSecret(m *expv1.MachinePool, bootstrapConfig *unstructured.Unstructured) error {
+	if bootstrapConfig == nil || m.Spec.Template.Spec.Bootstrap.DataSecretName == nil {
+		return nil
+	}
+
+	secretName, _, err := unstructured.NestedString(bootstrapConfig.Object, "status", "dataSecretName")
 	if err != nil {
+		return errors.Wrapf(err, "failed to retrieve dataSecretName from bootstrap provider for MachinePool %q in namespace %q", m.Name, m.Namespace)
+	}
+	currentSecretName := *m.Spec.Template.Spec.Bootstrap.DataSecretName
+	if secretName == "" || secretName == currentSecretName {
+		return nil
+	}
+
+	r.recorder.Eventf(m, corev1.EventTypeNormal, "BootstrapDataSecretRotated",
+		"Bootstrap data secret rotated from %q to %q", currentSecretName, secretName)
+	m.Spec.Template.Spec.Bootstrap.DataSecretName = pointer.StringPtr(secretName)
+	return nil
+}
+
+// reconcileBootstrapDataSecret marks the bootstrap of a MachinePool ready once its data secret is known. If
+// ValidateBootstrapDataSecrets is set, the secret must also hold a non-empty value, which is reported in the
+// BootstrapDataSecretValid condition. Bootstrap data secrets of the MachinePool it no longer references are deleted.
+func (r *MachinePoolReconciler) reconcileBootstrapDataSecret(ctx context.Context, m *expv1.MachinePool) error {
+	if err := r.deleteOrphanedBootstrapDataSecrets(ctx, m); err != nil {
 		return err
 	}
 
-	mp.Status.InfrastructureReady = ready
-	if !mp.Status.InfrastructureReady {
-		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: externalReadyWait},
-			"Infrastructure provider for MachinePool %q in namespace %q is not ready, requeuing", mp.Name, mp.Namespace,
-		)
+	if !r.ValidateBootstrapDataSecrets {
+		m.Status.BootstrapReady = true
+		return nil
 	}
 
-	var providerIDList []string
-	// Get Spec.ProviderIDList from the infrastructure provider.
-	if err := util.UnstructuredUnmarshalField(infraConfig, &providerIDList, "spec", "providerIDList"); err != nil {
-		return errors.Wrapf(err, "failed to retrieve data from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
-	} else if len(providerIDList) == 0 {
-		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: externalReadyWait},
-			"retrieved empty Spec.ProviderIDList from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace,
-		)
+	secretName := *m.Spec.Template.Spec.Bootstrap.DataSecretName
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: m.Namespace, Name: secretName}, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to get bootstrap data secret %q for MachinePool %q in namespace %q", secretName, m.Name, m.Namespace)
+		}
+		conditions.MarkFalse(m, expv1.BootstrapDataSecretValidCondition, expv1.BootstrapDataSecretNotFoundReason, clusterv1.ConditionSeverityWarning,
+			"Bootstrap data secret %q not found", secretName)
+	} else if value, ok := secret.Data[bootstrapDataSecretValueKey]; !ok {
+		conditions.MarkFalse(m, expv1.BootstrapDataSecretValidCondition, expv1.InvalidBootstrapDataSecretReason, clusterv1.ConditionSeverityWarning,
+			"Bootstrap data secret %q has no %q key", secretName, bootstrapDataSecretValueKey)
+	} else if len(value) == 0 {
+		conditions.MarkFalse(m, expv1.BootstrapDataSecretValidCondition, expv1.InvalidBootstrapDataSecretReason, clusterv1.ConditionSeverityWarning,
+			"Bootstrap data secret %q has an empty %q key", secretName, bootstrapDataSecretValueKey)
+	} else {
+		conditions.MarkTrue(m, expv1.BootstrapDataSecretValidCondition)
+		m.Status.BootstrapReady = true
+		return nil
 	}
 
-	// Get and set Status.Replicas from the infrastructure provider.
-	err = util.UnstructuredUnmarshalField(infraConfig, &mp.Status.Replicas, "status", "replicas")
-	if err != nil {
-		if err != util.ErrUnstructuredFieldNotFound {
-			return errors.Wrapf(err, "failed to retrieve replicas from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	m.Status.BootstrapReady = false
+	return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: r.bootstrapReadyWait()},
+		"Bootstrap data secret %q for MachinePool %q in namespace %q is not valid, requeuing", secretName, m.Name, m.Namespace)
+}
+
+// reconcileBootstrapFormat sets the BootstrapFormatAnnotation of the infrastructure object of a MachinePool to the
+// format of its bootstrap data secret. The format is left unset until the secret is known and its format detected.
+func (r *MachinePoolReconciler) reconcileBootstrapFormat(ctx context.Context, m *expv1.MachinePool, infraConfig *unstructured.Unstructured) error {
+	if m.Spec.Template.Spec.Bootstrap.DataSecretName == nil {
+		return nil
+	}
+
+	secretName := *m.Spec.Template.Spec.Bootstrap.DataSecretName
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: m.Namespace, Name: secretName}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
 		}
-	} else if mp.Status.Replicas == 0 {
-		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: externalReadyWait},
-			"retrieved unset Status.Replicas from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace,
-		)
+		return errors.Wrapf(err, "failed to get bootstrap data secret %q for MachinePool %q in namespace %q", secretName, m.Name, m.Namespace)
 	}
 
-	if !reflect.DeepEqual(mp.Spec.ProviderIDList, providerIDList) {
-		mp.Spec.ProviderIDList = providerIDList
-		mp.Status.ReadyReplicas = 0
-		mp.Status.AvailableReplicas = 0
-		mp.Status.UnavailableReplicas = mp.Status.Replicas
+	format := bootstrapFormat(secret)
+	if format == "" || infraConfig.GetAnnotations()[expv1.BootstrapFormatAnnotation] == format {
+		return nil
 	}
 
+	patchHelper, err := patch.NewHelper(infraConfig, r.Client)
+	if err != nil {
+		return err
+	}
+	infraAnnotations := infraConfig.GetAnnotations()
+	if infraAnnotations == nil {
+		infraAnnotations = make(map[string]string)
+	}
+	infraAnnotations[expv1.BootstrapFormatAnnotation] = format
+	infraConfig.SetAnnotations(infraAnnotations)
+	if err := patchHelper.Patch(ctx, infraConfig); err != nil {
+		return errors.Wrapf(err, "failed to set bootstrap format on infrastructure provider for MachinePool %q in namespace %q", m.Name, m.Namespace)
+	}
 	return nil
 }
+
+// bootstrapFormat returns the format of a bootstrap data secret, from the BootstrapFormatAnnotation label set by the
+// bootstrap provider if any, or detected from its data. Ignition data is a JSON object with an "ignition" key, cloud-init
+// data starts with a "#cloud-config" header. An empty string is returned if the format can't be detected.
+func bootstrapFormat(secret *corev1.Secret) string {
+	if format := secret.Labels[expv1.BootstrapFormatAnnotation]; format != "" {
+		return format
+	}
+
+	value := bytes.TrimSpace(secret.Data[bootstrapDataSecretValueKey])
+	if bytes.HasPrefix(value, []byte("#cloud-config")) {
+		return expv1.BootstrapFormatCloudConfig
+	}
+	var ignition map[string]json.RawMessage
+	if err := json.Unmarshal(value, &ignition); err == nil {
+		if _, ok := ignition["ignition"]; ok {
+			return expv1.BootstrapFormatIgnition
+		}
+	}
+	return ""
+}
+
+// deleteOrphanedBootstrapDataSecrets deletes the bootstrap data secrets controlled by a MachinePool, e.g. created
+// from inline bootstrap data, other than the one it references, e.g. after switching to a bootstrap provider.
+// Secrets which aren't controlled by the MachinePool, e.g. the ones of bootstrap providers, are left untouched.
+func (r *MachinePoolReconciler) deleteOrphanedBootstrapDataSecrets(ctx context.Context, m *expv1.MachinePool) error {
+	secrets := &corev1.SecretList{}
+	if err := r.Client.List(ctx, secrets, client.InNamespace(m.Namespace), client.MatchingLabels{clusterv1.ClusterLabelName: m.Spec.ClusterName}); err != nil {
+		return errors.Wrapf(err, "failed to list bootstrap data secrets for MachinePool %q in namespace %q", m.Name, m.Namespace)
+	}
+
+	machinePoolRef := metav1.OwnerReference{APIVersion: expv1.GroupVersion.String(), Kind: "MachinePool", Name: m.Name}
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if secret.Type != clusterv1.ClusterSecretType || secret.Name == *m.Spec.Template.Spec.Bootstrap.DataSecretName {
+			continue
+		}
+		if controllerRef := metav1.GetControllerOf(secret); controllerRef == nil || !util.HasOwnerRef([]metav1.OwnerReference{*controllerRef}, machinePoolRef) {
+			continue
+		}
+
+		if err := r.Client.Delete(ctx, secret); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete orphaned bootstrap data secret %q for MachinePool %q in namespace %q", secret.Name, m.Name, m.Namespace)
+		}
+		r.recorder.Eventf(m, corev1.EventTypeNormal, "DeletedOrphanedBootstrapDataSecret", "Deleted orphaned bootstrap data secret %q", secret.Name)
+	}
+	return nil
+}
+
+// reconcileBootstrapData stores the base64 encoded inline bootstrap data of a MachinePool in a Secret controlled by
+// the MachinePool, and replaces the inline data with the name of the Secret, so that it is consumed the same way as
+// the data of bootstrap providers.
+func (r *MachinePoolReconciler) reconcileBootstrapData(ctx context.Context, m *expv1.MachinePool) error {
+	value, err := base64.StdEncoding.DecodeString(*m.Spec.Template.Spec.Bootstrap.Data)
+	if err != nil {
+		return errors.Wrapf(err, "failed to decode bootstrap data for MachinePool %q in namespace %q", m.Name, m.Namespace)
+	}
+
+	machinePoolRef := metav1.OwnerReference{
+		APIVersion: expv1.GroupVersion.String(),
+		Kind:       "MachinePool",
+		Name:       m.Name,
+		UID:        m.UID,
+		Controller: pointer.BoolPtr(true),
+	}
+
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: m.Namespace, Name: fmt.Sprintf("%s-bootstrap-data", m.Name)}
+	if err := r.Client.Get(ctx, key, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to get bootstrap data secret %q for MachinePool %q in namespace %q", key.Name, m.Name, m.Namespace)
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels: map[string]string{
+					clusterv1.ClusterLabelName: m.Spec.ClusterName,
+				},
+				OwnerReferences: []metav1.OwnerReference{machinePoolRef},
+			},
+			Data: map[string][]byte{
+				bootstrapDataSecretValueKey: value,
+			},
+			Type: clusterv1.ClusterSecretType,
+		}
+		if err := r.Client.Create(ctx, secret); err != nil {
+			return errors.Wrapf(err, "failed to create bootstrap data secret %q for MachinePool %q in namespace %q", key.Name, m.Name, m.Namespace)
+		}
+	} else {
+		if controllerRef := metav1.GetControllerOf(secret); controllerRef == nil || !util.HasOwnerRef([]metav1.OwnerReference{*controllerRef}, machinePoolRef) {
+			return errors.Errorf("bootstrap data secret %q for MachinePool %q in namespace %q already exists and isn't controlled by the MachinePool",
+				key.Name, m.Name, m.Namespace)
+		}
+		if string(secret.Data[bootstrapDataSecretValueKey]) != string(value) {
+			patchBase := client.MergeFrom(secret.DeepCopy())
+			secret.Data = map[string][]byte{bootstrapDataSecretValueKey: value}
+			if err := r.Client.Patch(ctx, secret, patchBase); err != nil {
+				return errors.Wrapf(err, "failed to patch bootstrap data secret %q for MachinePool %q in namespace %q", key.Name, m.Name, m.Namespace)
+			}
+		}
+	}
+
+	m.Spec.Template.Spec.Bootstrap.Data = nil
+	m.Spec.Template.Spec.Bootstrap.DataSecretName = pointer.StringPtr(secret.Name)
+	return r.reconcileBootstrapDataSecret(ctx, m)
+}
+
+// reconcileInfrastructureTemplate clones the infrastructure template referenced by Spec.InfrastructureTemplateRef, if
+// any, into an infrastructure object for the MachinePool, and points Template.Spec.InfrastructureRef to it. The name
+// of the clone is recorded with the GeneratedInfrastructureNameAnnotation, so that the template is only cloned once.
+func (r *MachinePoolReconciler) reconcileInfrastructureTemplate(ctx context.Context, mp *expv1.MachinePool) error {
+	if mp.Spec.InfrastructureTemplateRef == nil {
+		return nil
+	}
+	if _, ok := mp.Annotations[expv1.GeneratedInfrastructureNameAnnotation]; ok {
+		return nil
+	}
+
+	ref, err := external.CloneTemplate(ctx, &external.CloneTemplateInput{
+		Client:      r.Client,
+		TemplateRef: mp.Spec.InfrastructureTemplateRef,
+		Namespace:   mp.Namespace,
+		ClusterName: mp.Spec.ClusterName,
+		OwnerRef: &metav1.OwnerReference{
+			APIVersion: expv1.GroupVersion.String(),
+			Kind:       "MachinePool",
+			Name:       mp.Name,
+			UID:        mp.UID,
+			Controller: pointer.BoolPtr(true),
+		},
+		Labels: map[string]string{expv1.MachinePoolNameLabel: mp.Name},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to clone infrastructure template %q for MachinePool %q in namespace %q",
+			mp.Spec.InfrastructureTemplateRef.Name, mp.Name, mp.Namespace)
+	}
+
+	mp.Spec.Template.Spec.InfrastructureRef = *ref
+	if mp.Annotations == nil {
+		mp.Annotations = make(map[string]string)
+	}
+	mp.Annotations[expv1.GeneratedInfrastructureNameAnnotation] = ref.Name
+	r.recorder.Eventf(mp, corev1.EventTypeNormal, "InfrastructureCloned", "Cloned %s %q from template %q",
+		ref.Kind, ref.Name, mp.Spec.InfrastructureTemplateRef.Name)
+	return nil
+}
+
+// reconcileInfrastructureGeneration requeues a MachinePool pinned to a generation of its infrastructure object until
+// that generation is observed. The infrastructure object is only read, a missing object is left to reconcileExternal.
+func (r *MachinePoolReconciler) reconcileInfrastructureGeneration(ctx context.Context, mp *expv1.MachinePool) error {
+	if mp.Spec.InfrastructureGeneration == nil {
+		return nil
+	}
+
+	infraConfig, err := r.getExternal(ctx, &mp.Spec.Template.Spec.InfrastructureRef, mp.Namespace)
+	if err != nil {
+		if apierrors.IsNotFound(errors.Cause(err)) {
+			return nil
+		}
+		return err
+	}
+	if infraConfig.GetGeneration() != *mp.Spec.InfrastructureGeneration {
+		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: r.infrastructureReadyWait()},
+			"Infrastructure provider for MachinePool %q in namespace %q is at generation %d, waiting for pinned generation %d, requeuing",
+			mp.Name, mp.Namespace, infraConfig.GetGeneration(), *mp.Spec.InfrastructureGeneration,
+		)
+	}
+	return nil
+}
+
+// reconcileInfrastructure reconciles the Spec.InfrastructureRef object on a MachinePool.
+func (r *MachinePoolReconciler) reconcileInfrastructure(ctx context.Context, cluster *clusterv1.Cluster, mp *expv1.MachinePool) error {
+	ctx, span := r.startSpan(ctx, "reconcileInfrastructure")
+	defer span.End()
+
+	// Wait for the MachinePools this one depends on, and for the approval to provision the infrastructure, if required.
+	if err := r.reconcileDependencies(ctx, mp); err != nil {
+		return err
+	}
+	if err := r.reconcilePreProvisionApproval(ctx, mp); err != nil {
+		return err
+	}
+
+	if err := r.reconcileInfrastructureTemplate(ctx, mp); err != nil {
+		return err
+	}
+
+	// If the MachinePool is pinned to a generation of the infrastructure object, wait until it is observed before
+	// adopting, patching or reading the infrastructure object, so that the controller doesn't move it past the pin.
+	if err := r.reconcileInfrastructureGeneration(ctx, mp); err != nil {
+		return err
+	}
+
+	// Call generic external reconciler.
+	infraReconcileResult, err := r.reconcileExternal(ctx, cluster, mp, &mp.Spec.Template.Spec.InfrastructureRef)
+	if err != nil {
+		if mp.Status.InfrastructureReady && strings.Contains(err.Error(), "could not find") && r.infrastructureMissingGracePeriodElapsed(mp) {
+			// Infra object went missing after the machine pool was up and running
+			r.Log.Error(err, "MachinePool infrastructure reference has been deleted after being ready, setting failure state")
+			mp.Status.FailureReason = capierrors.MachinePoolStatusErrorPtr(capierrors.InvalidConfigurationMachinePoolError)
+			mp.Status.FailureMessage = pointer.StringPtr(fmt.Sprintf("MachinePool infrastructure resource %v with name %q has been deleted after being ready",
+				mp.Spec.Template.Spec.InfrastructureRef.GroupVersionKind(), mp.Spec.Template.Spec.InfrastructureRef.Name))
+		}
+		return err
+	}
+	delete(mp.Annotations, expv1.InfrastructureMissingSinceAnnotation)
+
+	// if the external object is paused, return without any further processing
+	if infraReconcileResult.Paused {
+		return nil
+	}
+	infraConfig := infraReconcileResult.Result
+
+	if !infraConfig.GetDeletionTimestamp().IsZero() {
+		return nil
+	}
+
+	// Stop requeuing a MachinePool whose infrastructure failed for a reason retrying can't fix, unless its
+	// infrastructure object can be recreated.
+	terminal, err := terminalInfrastructureFailure(mp, infraConfig)
+	if err != nil {
+		return err
+	}
+	if terminal {
+		if err := r.recreateFailedInfrastructure(ctx, mp, infraConfig); err != nil {
+			return err
+		}
+		r.Log.Info("Infrastructure provider failed with a terminal reason, not requeuing", "machinepool", mp.Name, "namespace", mp.Namespace,
+			"reason", *mp.Status.FailureReason)
+		return nil
+	}
+
+	if err := reconcileProviderStatus(mp, infraConfig); err != nil {
+		return err
+	}
+
+	if err := r.reconcileInfrastructureConditions(mp, infraConfig); err != nil {
+		return err
+	}
+
+	if err := r.reconcileInfrastructureCredentials(ctx, mp, infraConfig); err != nil {
+		return err
+	}
+
+	// Get Status.InstanceStates from the infrastructure provider, if it reports them.
+	mp.Status.InstanceStates = nil
+	err = util.UnstructuredUnmarshalField(infraConfig, &mp.Status.InstanceStates, "status", "instanceStates")
+	if err != nil && err != util.ErrUnstructuredFieldNotFound {
+		return errors.Wrapf(err, "failed to retrieve instance states from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+
+	// Get Status.InstanceFailureDomains from the infrastructure provider, if it reports them.
+	mp.Status.InstanceFailureDomains = nil
+	err = util.UnstructuredUnmarshalField(infraConfig, &mp.Status.InstanceFailureDomains, "status", "instanceFailureDomains")
+	if err != nil && err != util.ErrUnstructuredFieldNotFound {
+		return errors.Wrapf(err, "failed to retrieve instance failure domains from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+	reconcileFailureDomainCoverage(cluster, mp)
+	if err := r.reconcileFailureDomainDistribution(ctx, mp, infraConfig); err != nil {
+		return err
+	}
+
+	// Get Status.InstanceImages from the infrastructure provider, if it reports them.
+	mp.Status.InstanceImages = nil
+	err = util.UnstructuredUnmarshalField(infraConfig, &mp.Status.InstanceImages, "status", "instanceImages")
+	if err != nil && err != util.ErrUnstructuredFieldNotFound {
+		return errors.Wrapf(err, "failed to retrieve instance images from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+
+	if err := reconcileCapacity(mp, infraConfig); err != nil {
+		return err
+	}
+
+	// Get the utilization of the instances from the infrastructure provider, if it reports it.
+	var utilization map[string]float64
+	err = util.UnstructuredUnmarshalField(infraConfig, &utilization, "status", "instanceUtilization")
+	if err != nil && err != util.ErrUnstructuredFieldNotFound {
+		return errors.Wrapf(err, "failed to retrieve instance utilization from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+
+	surgeLimited, err := r.syncReplicasToInfrastructure(ctx, mp, infraConfig)
+	if err != nil {
+		return err
+	}
+	if err := r.reconcileReplicasDrift(ctx, mp, infraConfig); err != nil {
+		return err
+	}
+
+	provisioned, err := r.requestProvisioning(ctx, mp, infraConfig)
+	if err != nil {
+		return err
+	}
+	if !provisioned {
+		mp.Status.InfrastructureReady = false
+		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: r.infrastructureReadyWait()},
+			"Infrastructure provider for MachinePool %q in namespace %q hasn't acknowledged the provisioning request, requeuing", mp.Name, mp.Namespace,
+		)
+	}
+
+	ready, err := external.IsReady(infraConfig)
+	if err != nil {
+		return err
+	}
+
+	mp.Status.InfrastructureReady = ready
+	if mp.Status.InfrastructureReady {
+		delete(mp.Annotations, expv1.InfrastructureRecreatedAtAnnotation)
+		delete(mp.Annotations, expv1.InfrastructureRecreateAttemptsAnnotation)
+	} else {
+		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: r.infrastructureReadyWait()},
+			"Infrastructure provider for MachinePool %q in namespace %q is not ready, requeuing", mp.Name, mp.Namespace,
+		)
+	}
+
+	// Infrastructure providers scaled to zero may never report any provider ID or replicas.
+	zeroReplicas := r.TreatMissingReplicasAsZero && mp.Spec.Replicas != nil && *mp.Spec.Replicas == 0
+
+	var providerIDList []string
+	// Get Spec.ProviderIDList from the infrastructure provider, unless the MachinePool is the source of truth for it.
+	if mp.Spec.ProviderIDListSource != expv1.ProviderIDListSourceMachinePool {
+		providerIDList, err = r.getProviderIDList(infraConfig)
+		switch {
+		case err == util.ErrUnstructuredFieldNotFound:
+			// A missing provider ID list is empty, which is only expected of MachinePools scaled to zero.
+			if !zeroReplicas {
+				return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: r.infrastructureReadyWait()},
+					"retrieved unset Spec.ProviderIDList from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace,
+				)
+			}
+		case err != nil:
+			return errors.Wrapf(err, "failed to retrieve data from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+		case len(providerIDList) == 0 && !zeroReplicas:
+			return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: r.infrastructureReadyWait()},
+				"retrieved empty Spec.ProviderIDList from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace,
+			)
+		}
+	}
+
+	// Get and set Status.Replicas from the infrastructure provider.
+	statusReplicas, coerced, err := infrastructureStatusReplicas(infraConfig)
+	switch {
+	case err == util.ErrUnstructuredFieldNotFound:
+		if zeroReplicas {
+			mp.Status.Replicas = 0
+		}
+	case err != nil:
+		return errors.Wrapf(err, "failed to retrieve replicas from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	default:
+		// Only warn about the coercion when the replicas change, rather than on every reconciliation.
+		if coerced && statusReplicas != mp.Status.Replicas {
+			r.Log.Info("Infrastructure provider reports status.replicas as a string, coerced it to a number", "machinepool", mp.Name,
+				"namespace", mp.Namespace, "kind", infraConfig.GetKind(), "name", infraConfig.GetName(), "replicas", statusReplicas)
+		}
+		mp.Status.Replicas = statusReplicas
+		if mp.Status.Replicas == 0 && !zeroReplicas {
+			return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: r.infrastructureReadyWait()},
+				"retrieved unset Status.Replicas from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace,
+			)
+		}
+	}
+	mp.Status.ReplicaBreakdown = replicaBreakdown(mp)
+
+	if mp.Spec.ProviderIDListSource == expv1.ProviderIDListSourceMachinePool {
+		mp.Status.ScaleDownCandidates = scaleDownCandidates(mp.Spec.ProviderIDList, utilization)
+		if err := r.syncProviderIDListToInfrastructure(ctx, mp, infraConfig); err != nil {
+			return err
+		}
+		return surgeLimitedError(mp, surgeLimited)
+	}
+
+	// Store the provider IDs in a canonical order, so that the infrastructure provider reordering them
+	// doesn't reset the replica counters or patch the MachinePool.
+	providerIDList = sortedProviderIDs(providerIDList)
+	if !reflect.DeepEqual(sortedProviderIDs(mp.Spec.ProviderIDList), providerIDList) {
+		mp.Status.ReadyReplicas = 0
+		mp.Status.AvailableReplicas = 0
+		mp.Status.UnavailableReplicas = mp.Status.Replicas
+	}
+	mp.Spec.ProviderIDList = providerIDList
+	mp.Status.ScaleDownCandidates = scaleDownCandidates(providerIDList, utilization)
+	r.reconcileOverProvisioned(mp)
+
+	return surgeLimitedError(mp, surgeLimited)
+}
+
+// reconcileOverProvisioned sets the OverProvisionedCondition of a MachinePool while its infrastructure provider
+// reports more than OverProvisionedMargin instances beyond Spec.Replicas, recording a warning event when it is first
+// set. Externally managed replicas are never over-provisioned.
+func (r *MachinePoolReconciler) reconcileOverProvisioned(mp *expv1.MachinePool) {
+	if r.OverProvisionedMargin <= 0 || mp.Spec.Replicas == nil || replicasExternallyManaged(mp) {
+		conditions.Delete(mp, expv1.OverProvisionedCondition)
+		return
+	}
+
+	instances, desired := len(mp.Spec.ProviderIDList), int(*mp.Spec.Replicas)
+	if instances-desired <= r.OverProvisionedMargin {
+		conditions.Delete(mp, expv1.OverProvisionedCondition)
+		return
+	}
+
+	message := fmt.Sprintf("Infrastructure provider reports %d instances, %d more than the %d desired replicas",
+		instances, instances-desired, desired)
+	if !conditions.IsTrue(mp, expv1.OverProvisionedCondition) {
+		r.recorder.Event(mp, corev1.EventTypeWarning, "OverProvisioned", message)
+	}
+	conditions.Set(mp, &clusterv1.Condition{
+		Type:    expv1.OverProvisionedCondition,
+		Status:  corev1.ConditionTrue,
+		Reason:  expv1.TooManyInstancesReason,
+		Message: message,
+	})
+}
+
+// surgeLimitedError returns a RequeueAfterError if the replicas requested from the infrastructure provider of a
+// MachinePool were limited by its max surge, so that the next step of the scale-up is requested.
+func surgeLimitedError(mp *expv1.MachinePool, surgeLimited bool) error {
+	if !surgeLimited {
+		return nil
+	}
+	return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: surgeStepWait},
+		"MachinePool %q in namespace %q is scaling up to %d replicas in steps limited by its max surge, requeuing", mp.Name, mp.Namespace, *mp.Spec.Replicas)
+}
+
+// scaleDownCandidates returns the provider IDs of a MachinePool whose utilization is known, from the least to the
+// most utilized. Instances with the same utilization are ordered by provider ID, so that the list is stable.
+func scaleDownCandidates(providerIDList []string, utilization map[string]float64) []string {
+	var candidates []string
+	for _, providerID := range providerIDList {
+		if _, ok := utilization[providerID]; ok {
+			candidates = append(candidates, providerID)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		ui, uj := utilization[candidates[i]], utilization[candidates[j]]
+		if ui != uj {
+			return ui < uj
+		}
+		return candidates[i] < candidates[j]
+	})
+	return candidates
+}
+
+// sortedProviderIDs returns a sorted copy of a list of provider IDs.
+func sortedProviderIDs(providerIDList []string) []string {
+	if providerIDList == nil {
+		return nil
+	}
+	sorted := append([]string{}, providerIDList...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// recreateFailedInfrastructure deletes the infrastructure object of a MachinePool using the RecreateOnFailure strategy,
+// so that it gets recreated. It must only be called once terminalInfrastructureFailure classified the failure of the
+// infrastructure object as terminal, since other failures are left for the infrastructure provider to recover from.
+// Deletions are backed off exponentially until the infrastructure object becomes ready, so that an object failing
+// right after being recreated isn't deleted in a loop.
+func (r *MachinePoolReconciler) recreateFailedInfrastructure(ctx context.Context, mp *expv1.MachinePool, infraConfig *unstructured.Unstructured) error {
+	if mp.Spec.Strategy == nil || !mp.Spec.Strategy.RecreateOnFailure {
+		return nil
+	}
+
+	failureReason, _, err := external.FailuresFrom(infraConfig)
+	if err != nil {
+		return err
+	}
+	if failureReason == "" {
+		return nil
+	}
+
+	now := r.now()
+	attempts, _ := strconv.Atoi(mp.Annotations[expv1.InfrastructureRecreateAttemptsAnnotation])
+	if recreatedAt, err := time.Parse(time.RFC3339, mp.Annotations[expv1.InfrastructureRecreatedAtAnnotation]); err == nil {
+		if wait := infrastructureRecreateBackoff(attempts) - now.Sub(recreatedAt); wait > 0 {
+			return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: wait},
+				"Infrastructure provider for MachinePool %q in namespace %q failed again after being recreated, backing off", mp.Name, mp.Namespace,
+			)
+		}
+	}
+
+	if err := r.Client.Delete(ctx, infraConfig); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete failed infrastructure object %v %q for MachinePool %q in namespace %q",
+			infraConfig.GroupVersionKind(), infraConfig.GetName(), mp.Name, mp.Namespace)
+	}
+	r.recorder.Eventf(mp, corev1.EventTypeWarning, "RecreatingInfrastructure",
+		"Deleted infrastructure object %v %q which failed with reason %q", infraConfig.GroupVersionKind(), infraConfig.GetName(), failureReason)
+
+	if mp.Annotations == nil {
+		mp.Annotations = make(map[string]string)
+	}
+	mp.Annotations[expv1.InfrastructureRecreatedAtAnnotation] = now.Format(time.RFC3339)
+	mp.Annotations[expv1.InfrastructureRecreateAttemptsAnnotation] = strconv.Itoa(attempts + 1)
+	mp.Status.FailureReason = nil
+	mp.Status.FailureMessage = nil
+	mp.Status.InfrastructureReady = false
+
+	return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: r.infrastructureReadyWait()},
+		"Deleted failed infrastructure object for MachinePool %q in namespace %q, waiting for it to be recreated", mp.Name, mp.Namespace,
+	)
+}
+
+// terminalInfrastructureFailure returns true if the infrastructure object of a MachinePool reports one of the
+// terminalFailureReasons, in which case the failure message of the MachinePool explains that it isn't retried.
+func terminalInfrastructureFailure(mp *expv1.MachinePool, infraConfig *unstructured.Unstructured) (bool, error) {
+	failureReason, failureMessage, err := external.FailuresFrom(infraConfig)
+	if err != nil {
+		return false, err
+	}
+	if !terminalFailureReasons.Has(failureReason) {
+		return false, nil
+	}
+
+	mp.Status.FailureReason = capierrors.MachinePoolStatusErrorPtr(capierrors.MachinePoolStatusFailure(failureReason))
+	mp.Status.FailureMessage = pointer.StringPtr(
+		fmt.Sprintf("Referenced resource %v with name %q failed with terminal reason %q, not retrying until it is fixed: %s",
+			infraConfig.GroupVersionKind(), infraConfig.GetName(), failureReason, failureMessage),
+	)
+	return true, nil
+}
+
+// infrastructureRecreateBackoff returns how long to wait after the given number of deletions of a failed
+// infrastructure object before deleting it again.
+func infrastructureRecreateBackoff(attempts int) time.Duration {
+	backoff := infrastructureRecreateInitialBackoff
+	for i := 1; i < attempts && backoff < infrastructureRecreateMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > infrastructureRecreateMaxBackoff {
+		backoff = infrastructureRecreateMaxBackoff
+	}
+	return backoff
+}
+
+// replicaBreakdown counts the replicas of a MachinePool by readiness, from the instance states reported by the
+// infrastructure provider and the readiness of its Nodes. Instances being deleted are counted first, then the ones
+// with a Ready Node and the ones with a Node which isn't Ready, any remaining replicas are provisioning.
+func replicaBreakdown(mp *expv1.MachinePool) *expv1.MachinePoolReplicaBreakdown {
+	remaining := mp.Status.Replicas
+	count := func(n int32) int32 {
+		if n < 0 {
+			n = 0
+		}
+		if n > remaining {
+			n = remaining
+		}
+		remaining -= n
+		return n
+	}
+
+	var deleting int32
+	for _, state := range mp.Status.InstanceStates {
+		if deletingInstanceStates.Has(strings.ToLower(state)) {
+			deleting++
+		}
+	}
+
+	breakdown := &expv1.MachinePoolReplicaBreakdown{}
+	breakdown.Deleting = count(deleting)
+	breakdown.Ready = count(mp.Status.ReadyReplicas)
+	breakdown.NotReady = count(int32(nodeRefsCount(mp)) - mp.Status.ReadyReplicas)
+	breakdown.Provisioning = remaining
+	return breakdown
+}
+
+// getProviderIDList returns the spec.providerIDList of an infrastructure object. Kinds of infrastructure objects
+// configured to publish their provider IDs under status fall back to status.providerIDList if the spec has none.
+func (r *MachinePoolReconciler) getProviderIDList(infraConfig *unstructured.Unstructured) ([]string, error) {
+	var providerIDList []string
+	err := util.UnstructuredUnmarshalField(infraConfig, &providerIDList, "spec", "providerIDList")
+	if err != util.ErrUnstructuredFieldNotFound {
+		return providerIDList, err
+	}
+
+	for _, gvk := range r.StatusProviderIDListKinds {
+		if gvk == infraConfig.GroupVersionKind() {
+			err = util.UnstructuredUnmarshalField(infraConfig, &providerIDList, "status", "providerIDList")
+			break
+		}
+	}
+	return providerIDList, err
+}
+
+// reconcileProviderStatus copies the fields selected by Spec.StatusFieldMappings from the status of the
+// infrastructure object into Status.ProviderStatus. Fields which aren't set on the infrastructure object are left out.
+func reconcileProviderStatus(mp *expv1.MachinePool, infraConfig *unstructured.Unstructured) error {
+	if len(mp.Spec.StatusFieldMappings) == 0 {
+		mp.Status.ProviderStatus = nil
+		return nil
+	}
+
+	providerStatus := make(map[string]string, len(mp.Spec.StatusFieldMappings))
+	for _, mapping := range mp.Spec.StatusFieldMappings {
+		fields := append([]string{"status"}, strings.Split(mapping.Path, ".")...)
+		value, found, err := unstructured.NestedFieldNoCopy(infraConfig.Object, fields...)
+		if err != nil {
+			return errors.Wrapf(err, "failed to retrieve status field %q from infrastructure provider for MachinePool %q in namespace %q",
+				mapping.Path, mp.Name, mp.Namespace)
+		}
+		if !found {
+			continue
+		}
+
+		// Strings are copied as is, any other value is copied in its JSON representation.
+		if str, ok := value.(string); ok {
+			providerStatus[mapping.Name] = str
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal status field %q from infrastructure provider for MachinePool %q in namespace %q",
+				mapping.Path, mp.Name, mp.Namespace)
+		}
+		providerStatus[mapping.Name] = string(data)
+	}
+	mp.Status.ProviderStatus = providerStatus
+	return nil
+}
+
+// reconcileInfrastructureConditions copies the conditions of the infrastructure object whose types are listed in
+// InfrastructureConditionTypes into Status.InfrastructureConditions, in the order of the list.
+func (r *MachinePoolReconciler) reconcileInfrastructureConditions(mp *expv1.MachinePool, infraConfig *unstructured.Unstructured) error {
+	var infraConditions clusterv1.Conditions
+	err := util.UnstructuredUnmarshalField(infraConfig, &infraConditions, "status", "conditions")
+	if err != nil && err != util.ErrUnstructuredFieldNotFound {
+		return errors.Wrapf(err, "failed to retrieve conditions from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+
+	conditionTypes := r.InfrastructureConditionTypes
+	if len(conditionTypes) == 0 {
+		conditionTypes = defaultInfrastructureConditionTypes
+	}
+
+	var conditions clusterv1.Conditions
+	for _, conditionType := range conditionTypes {
+		for i := range infraConditions {
+			if infraConditions[i].Type == conditionType {
+				conditions = append(conditions, infraConditions[i])
+				break
+			}
+		}
+	}
+	mp.Status.InfrastructureConditions = conditions
+	return nil
+}
+
+// reconcileFailureDomainCoverage sets Status.FailureDomainCoverage to the number of instances of a MachinePool in each
+// failure domain of its Cluster, restricted to Spec.FailureDomains if set, from Status.InstanceFailureDomains. The
+// coverage is unknown, and left unset, if the Cluster has no failure domains or the provider doesn't report them.
+func reconcileFailureDomainCoverage(cluster *clusterv1.Cluster, mp *expv1.MachinePool) {
+	mp.Status.FailureDomainCoverage = nil
+	if cluster == nil || len(cluster.Status.FailureDomains) == 0 || mp.Status.InstanceFailureDomains == nil {
+		return
+	}
+
+	attached := sets.NewString(mp.Spec.FailureDomains...)
+	instances := map[string]int32{}
+	for failureDomain := range cluster.Status.FailureDomains {
+		if attached.Len() == 0 || attached.Has(failureDomain) {
+			instances[failureDomain] = 0
+		}
+	}
+	for _, failureDomain := range mp.Status.InstanceFailureDomains {
+		if _, ok := instances[failureDomain]; ok {
+			instances[failureDomain]++
+		}
+	}
+
+	failureDomains := make([]string, 0, len(instances))
+	for failureDomain := range instances {
+		failureDomains = append(failureDomains, failureDomain)
+	}
+	sort.Strings(failureDomains)
+	for _, failureDomain := range failureDomains {
+		mp.Status.FailureDomainCoverage = append(mp.Status.FailureDomainCoverage, expv1.MachinePoolFailureDomainCoverage{
+			FailureDomain: failureDomain,
+			Instances:     instances[failureDomain],
+		})
+	}
+}
+
+// reconcileFailureDomainDistribution sets the FailureDomainDistributionAnnotation of the infrastructure object of a
+// MachinePool being scaled up, if the reconciler is configured to rebalance failure domains, to the distribution of
+// its instances across failure domains once the new instances are placed in the least populated ones. The
+// annotation is removed when the MachinePool isn't scaling up, or when its failure domain coverage is unknown.
+func (r *MachinePoolReconciler) reconcileFailureDomainDistribution(ctx context.Context, mp *expv1.MachinePool, infraConfig *unstructured.Unstructured) error {
+	if !r.RebalanceFailureDomains {
+		return nil
+	}
+
+	value := formatFailureDomainDistribution(mp.Status.FailureDomainCoverage,
+		desiredFailureDomainDistribution(mp.Status.FailureDomainCoverage, desiredReplicas(mp)-int32(len(mp.Status.InstanceFailureDomains))))
+	current, ok := infraConfig.GetAnnotations()[expv1.FailureDomainDistributionAnnotation]
+	if current == value && ok == (value != "") {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(infraConfig, r.Client)
+	if err != nil {
+		return err
+	}
+	infraAnnotations := infraConfig.GetAnnotations()
+	if infraAnnotations == nil {
+		infraAnnotations = make(map[string]string)
+	}
+	if value == "" {
+		delete(infraAnnotations, expv1.FailureDomainDistributionAnnotation)
+	} else {
+		infraAnnotations[expv1.FailureDomainDistributionAnnotation] = value
+	}
+	infraConfig.SetAnnotations(infraAnnotations)
+	if err := patchHelper.Patch(ctx, infraConfig); err != nil {
+		return errors.Wrapf(err, "failed to set the failure domain distribution on infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+	return nil
+}
+
+// desiredFailureDomainDistribution returns the number of instances desired in each failure domain of the coverage
+// once the given number of new instances are added one by one to the failure domain with the fewest instances,
+// ties going to the first one in the coverage. It returns nil if there are no new instances to place.
+func desiredFailureDomainDistribution(coverage []expv1.MachinePoolFailureDomainCoverage, newInstances int32) map[string]int32 {
+	if len(coverage) == 0 || newInstances <= 0 {
+		return nil
+	}
+
+	distribution := make(map[string]int32, len(coverage))
+	for _, c := range coverage {
+		distribution[c.FailureDomain] = c.Instances
+	}
+	for i := int32(0); i < newInstances; i++ {
+		least := coverage[0].FailureDomain
+		for _, c := range coverage[1:] {
+			if distribution[c.FailureDomain] < distribution[least] {
+				least = c.FailureDomain
+			}
+		}
+		distribution[least]++
+	}
+	return distribution
+}
+
+// formatFailureDomainDistribution formats the distribution as the value of the FailureDomainDistributionAnnotation,
+// in the order of the coverage.
+func formatFailureDomainDistribution(coverage []expv1.MachinePoolFailureDomainCoverage, distribution map[string]int32) string {
+	if len(distribution) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(coverage))
+	for _, c := range coverage {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", c.FailureDomain, distribution[c.FailureDomain]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// reconcileCapacity sets Status.Capacity to the sum of the capacities of the instances of a MachinePool, from the
+// status.instanceCapacity of its infrastructure object, by provider ID, if the infrastructure provider reports it.
+func reconcileCapacity(mp *expv1.MachinePool, infraConfig *unstructured.Unstructured) error {
+	mp.Status.Capacity = nil
+	var instanceCapacity map[string]corev1.ResourceList
+	err := util.UnstructuredUnmarshalField(infraConfig, &instanceCapacity, "status", "instanceCapacity")
+	if err != nil && err != util.ErrUnstructuredFieldNotFound {
+		return errors.Wrapf(err, "failed to retrieve instance capacity from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+	if len(instanceCapacity) == 0 {
+		return nil
+	}
+
+	capacity := corev1.ResourceList{}
+	for _, resources := range instanceCapacity {
+		for name, quantity := range resources {
+			total := capacity[name]
+			total.Add(quantity)
+			capacity[name] = total
+		}
+	}
+	mp.Status.Capacity = capacity
+	return nil
+}
+
+// reconcileInfrastructureCredentials sets the InfrastructureCredentialsAvailable condition of a MachinePool according
+// to whether the credentials secret referenced at the CredentialsSecretPath of its infrastructure object exists.
+// Infrastructure objects not referencing any secret at that path are assumed to use default credentials.
+func (r *MachinePoolReconciler) reconcileInfrastructureCredentials(ctx context.Context, mp *expv1.MachinePool, infraConfig *unstructured.Unstructured) error {
+	if r.CredentialsSecretPath == "" {
+		return nil
+	}
+
+	key := client.ObjectKey{Namespace: infraConfig.GetNamespace()}
+	ref, found, err := unstructured.NestedFieldNoCopy(infraConfig.Object, strings.Split(r.CredentialsSecretPath, ".")...)
+	if err != nil {
+		return errors.Wrapf(err, "failed to retrieve credentials secret reference from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+	switch ref := ref.(type) {
+	case string:
+		key.Name = ref
+	case map[string]interface{}:
+		key.Name, _, _ = unstructured.NestedString(ref, "name")
+		if namespace, _, _ := unstructured.NestedString(ref, "namespace"); namespace != "" {
+			key.Namespace = namespace
+		}
+	}
+	if !found || key.Name == "" {
+		conditions.Delete(mp, expv1.InfrastructureCredentialsAvailableCondition)
+		return nil
+	}
+
+	if err := r.Client.Get(ctx, key, &corev1.Secret{}); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to get credentials secret %q for MachinePool %q in namespace %q", key.Name, mp.Name, mp.Namespace)
+		}
+		conditions.MarkFalse(mp, expv1.InfrastructureCredentialsAvailableCondition, expv1.MissingCredentialsReason, clusterv1.ConditionSeverityError,
+			"Credentials secret %s referenced by %s %q doesn't exist", key, infraConfig.GetKind(), infraConfig.GetName())
+		return nil
+	}
+	conditions.MarkTrue(mp, expv1.InfrastructureCredentialsAvailableCondition)
+	return nil
+}
+
+// syncReplicasToInfrastructure writes Spec.Replicas to spec.replicas of the infrastructure object of a MachinePool,
+// if its kind is one of the InfrastructureReplicasKinds. The field is only written if the infrastructure object
+// already has it, as it is not part of the schema of every version of these kinds. When scaling up, the replicas
+// written are limited by Spec.Strategy.RollingUpdate.MaxSurge, if set, in which case true is returned.
+// Externally managed replicas are never written.
+func (r *MachinePoolReconciler) syncReplicasToInfrastructure(ctx context.Context, mp *expv1.MachinePool, infraConfig *unstructured.Unstructured) (bool, error) {
+	if mp.Spec.Replicas == nil || replicasExternallyManaged(mp) {
+		return false, nil
+	}
+
+	gvk := infraConfig.GroupVersionKind()
+	for _, kind := range r.InfrastructureReplicasKinds {
+		if kind != gvk {
+			continue
+		}
+
+		replicas, found, err := unstructured.NestedInt64(infraConfig.Object, "spec", "replicas")
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to retrieve replicas from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+		}
+		if !found {
+			r.Log.V(2).Info("Infrastructure provider has no spec.replicas, skipping replicas sync", "machinepool", mp.Name, "namespace", mp.Namespace, "kind", gvk.Kind)
+			return false, nil
+		}
+
+		target, err := surgeLimitedReplicas(mp, infraConfig, replicas)
+		if err != nil {
+			return false, err
+		}
+		surgeLimited := target != int64(*mp.Spec.Replicas)
+		if replicas == target {
+			return surgeLimited, nil
+		}
+
+		patchHelper, err := patch.NewHelper(infraConfig, r.Client)
+		if err != nil {
+			return false, err
+		}
+		if err := unstructured.SetNestedField(infraConfig.Object, target, "spec", "replicas"); err != nil {
+			return false, errors.Wrapf(err, "failed to set replicas on infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+		}
+		if err := patchHelper.Patch(ctx, infraConfig); err != nil {
+			return false, errors.Wrapf(err, "failed to sync replicas to infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+		}
+		return surgeLimited, nil
+	}
+	return false, nil
+}
+
+// infrastructureStatusReplicas returns status.replicas of the infrastructure object of a MachinePool, or
+// util.ErrUnstructuredFieldNotFound if it isn't set. Numeric strings, reported by some providers with non-standard
+// or buggy CRDs, are coerced into a number, in which case coerced is true.
+func infrastructureStatusReplicas(infraConfig *unstructured.Unstructured) (replicas int32, coerced bool, err error) {
+	err = util.UnstructuredUnmarshalField(infraConfig, &replicas, "status", "replicas")
+	if err == nil || err == util.ErrUnstructuredFieldNotFound {
+		return replicas, false, err
+	}
+
+	value, _, _ := unstructured.NestedFieldNoCopy(infraConfig.Object, "status", "replicas")
+	s, ok := value.(string)
+	if !ok {
+		return 0, false, err
+	}
+	parsed, parseErr := strconv.ParseInt(strings.TrimSpace(s), 10, 32)
+	if parseErr != nil {
+		return 0, false, err
+	}
+	return int32(parsed), true, nil
+}
+
+// surgeLimitedReplicas returns the replicas to request from the infrastructure object of a MachinePool, given the
+// replicas it currently requests. When scaling up, Spec.Strategy.RollingUpdate.MaxSurge, if set, limits how many
+// instances are requested above the ones the infrastructure object reports in status.replicas. Percentages are of
+// Spec.Replicas, rounded up, and at least one instance is requested at a time. Requested replicas are never lowered
+// while scaling up, so that instances being provisioned aren't cancelled.
+func surgeLimitedReplicas(mp *expv1.MachinePool, infraConfig *unstructured.Unstructured, requested int64) (int64, error) {
+	desired := int64(*mp.Spec.Replicas)
+	if mp.Spec.Strategy == nil || mp.Spec.Strategy.RollingUpdate == nil || mp.Spec.Strategy.RollingUpdate.MaxSurge == nil {
+		return desired, nil
+	}
+
+	statusReplicas, _, err := infrastructureStatusReplicas(infraConfig)
+	if err != nil && err != util.ErrUnstructuredFieldNotFound {
+		return 0, errors.Wrapf(err, "failed to retrieve replicas from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+	current := int64(statusReplicas)
+	if current >= desired || requested > desired {
+		return desired, nil
+	}
+
+	surge, err := intstr.GetValueFromIntOrPercent(mp.Spec.Strategy.RollingUpdate.MaxSurge, int(desired), true)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid max surge for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+	if surge < 1 {
+		surge = 1
+	}
+
+	target := current + int64(surge)
+	if target < requested {
+		target = requested
+	}
+	if target > desired {
+		target = desired
+	}
+	return target, nil
+}
+
+// reconcileReplicasDrift sets the SpecDriftCondition of a MachinePool when spec.replicas of its infrastructure object
+// diverges from Spec.Replicas, or writes Spec.Replicas back if CorrectReplicasDrift is set. Infrastructure objects
+// of the InfrastructureReplicasKinds are left to syncReplicasToInfrastructure, and externally managed replicas
+// never drift.
+func (r *MachinePoolReconciler) reconcileReplicasDrift(ctx context.Context, mp *expv1.MachinePool, infraConfig *unstructured.Unstructured) error {
+	gvk := infraConfig.GroupVersionKind()
+	for _, kind := range r.InfrastructureReplicasKinds {
+		if kind == gvk {
+			conditions.Delete(mp, expv1.SpecDriftCondition)
+			return nil
+		}
+	}
+
+	replicas, found, err := unstructured.NestedInt64(infraConfig.Object, "spec", "replicas")
+	if err != nil {
+		return errors.Wrapf(err, "failed to retrieve replicas from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+	if !found || mp.Spec.Replicas == nil || replicasExternallyManaged(mp) || replicas == int64(*mp.Spec.Replicas) {
+		conditions.Delete(mp, expv1.SpecDriftCondition)
+		return nil
+	}
+
+	if !r.CorrectReplicasDrift {
+		conditions.Set(mp, &clusterv1.Condition{
+			Type:    expv1.SpecDriftCondition,
+			Status:  corev1.ConditionTrue,
+			Reason:  expv1.ReplicasDriftedReason,
+			Message: fmt.Sprintf("%s %q has %d replicas, expected %d", gvk.Kind, infraConfig.GetName(), replicas, *mp.Spec.Replicas),
+		})
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(infraConfig, r.Client)
+	if err != nil {
+		return err
+	}
+	if err := unstructured.SetNestedField(infraConfig.Object, int64(*mp.Spec.Replicas), "spec", "replicas"); err != nil {
+		return errors.Wrapf(err, "failed to set replicas on infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+	if err := patchHelper.Patch(ctx, infraConfig); err != nil {
+		return errors.Wrapf(err, "failed to correct replicas drift of infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+	r.recorder.Eventf(mp, corev1.EventTypeNormal, "ReplicasDriftCorrected",
+		"Corrected replicas of %s %q from %d to %d", gvk.Kind, infraConfig.GetName(), replicas, *mp.Spec.Replicas)
+	conditions.Delete(mp, expv1.SpecDriftCondition)
+	return nil
+}
+
+// requestProvisioning sets the ProvisionAnnotation of the infrastructure object of a MachinePool to ProvisionRequested,
+// if the reconciler is configured to request provisioning, and returns whether the provider acknowledged the request.
+func (r *MachinePoolReconciler) requestProvisioning(ctx context.Context, mp *expv1.MachinePool, infraConfig *unstructured.Unstructured) (bool, error) {
+	if !r.RequestProvisioning {
+		return true, nil
+	}
+
+	value, ok := infraConfig.GetAnnotations()[expv1.ProvisionAnnotation]
+	if ok {
+		return value == expv1.ProvisionProvisioned, nil
+	}
+
+	patchHelper, err := patch.NewHelper(infraConfig, r.Client)
+	if err != nil {
+		return false, err
+	}
+	annotations := infraConfig.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[expv1.ProvisionAnnotation] = expv1.ProvisionRequested
+	infraConfig.SetAnnotations(annotations)
+	if err := patchHelper.Patch(ctx, infraConfig); err != nil {
+		return false, errors.Wrapf(err, "failed to request provisioning from infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+	return false, nil
+}
+
+// syncProviderIDListToInfrastructure writes Spec.ProviderIDList to the infrastructure object of a MachinePool
+// which is the source of truth for it. The list is only written when it changed since the last sync, so that
+// a provider updating its own copy of the list doesn't cause both controllers to keep overwriting each other.
+func (r *MachinePoolReconciler) syncProviderIDListToInfrastructure(ctx context.Context, mp *expv1.MachinePool, infraConfig *unstructured.Unstructured) error {
+	hash := providerIDListHash(mp.Spec.ProviderIDList)
+	if infraConfig.GetAnnotations()[expv1.ProviderIDListSyncedHashAnnotation] == hash {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(infraConfig, r.Client)
+	if err != nil {
+		return err
+	}
+
+	if err := unstructured.SetNestedStringSlice(infraConfig.Object, mp.Spec.ProviderIDList, "spec", "providerIDList"); err != nil {
+		return errors.Wrapf(err, "failed to set providerIDList on infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+	infraAnnotations := infraConfig.GetAnnotations()
+	if infraAnnotations == nil {
+		infraAnnotations = make(map[string]string)
+	}
+	infraAnnotations[expv1.ProviderIDListSyncedHashAnnotation] = hash
+	infraConfig.SetAnnotations(infraAnnotations)
+
+	if err := patchHelper.Patch(ctx, infraConfig); err != nil {
+		return errors.Wrapf(err, "failed to sync providerIDList to infrastructure provider for MachinePool %q in namespace %q", mp.Name, mp.Namespace)
+	}
+	return nil
+}
+
+// providerIDListHash returns a hash of the given provider ID list.
+func providerIDListHash(providerIDList []string) string {
+	hasher := fnv.New32a()
+	for _, providerID := range providerIDList {
+		// Separate the entries with a character which can't appear in a provider ID.
+		_, _ = hasher.Write([]byte(providerID + "\n"))
+	}
+	return fmt.Sprintf("%x", hasher.Sum32())
+}
+
+// bootstrapReadyWait returns how long to wait before checking again on the bootstrap of a MachinePool which isn't ready.
+func (r *MachinePoolReconciler) bootstrapReadyWait() time.Duration {
+	if r.BootstrapReadyWait == 0 {
+		return externalReadyWait
+	}
+	return r.BootstrapReadyWait
+}
+
+// infrastructureReadyWait returns how long to wait before checking again on the infrastructure of a MachinePool
+// which isn't ready.
+func (r *MachinePoolReconciler) infrastructureReadyWait() time.Duration {
+	if r.InfrastructureReadyWait == 0 {
+		return externalReadyWait
+	}
+	return r.InfrastructureReadyWait
+}
+
+// infrastructureMissingGracePeriodElapsed records when the infrastructure object of a MachinePool was first
+// found missing, and returns true once it has been missing for longer than the configured grace period.
+func (r *MachinePoolReconciler) infrastructureMissingGracePeriodElapsed(mp *expv1.MachinePool) bool {
+	gracePeriod := r.InfrastructureMissingGracePeriod
+	if gracePeriod == 0 {
+		gracePeriod = defaultInfrastructureMissingGracePeriod
+	}
+
+	now := r.now()
+	missingSince, err := time.Parse(time.RFC3339, mp.Annotations[expv1.InfrastructureMissingSinceAnnotation])
+	if err != nil {
+		if mp.Annotations == nil {
+			mp.Annotations = make(map[string]string)
+		}
+		mp.Annotations[expv1.InfrastructureMissingSinceAnnotation] = now.Format(time.RFC3339)
+		return false
+	}
+	return now.Sub(missingSince) >= gracePeriod
+}
@@ -18,23 +18,37 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/kubeconfig"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 func init() {
@@ -50,6 +64,9 @@ var _ = Describe("Reconcile MachinePool Phases", func() {
 			Name:      "test-cluster",
 			Namespace: metav1.NamespaceDefault,
 		},
+		Status: clusterv1.ClusterStatus{
+			ControlPlaneInitialized: true,
+		},
 	}
 
 	defaultMachinePool := expv1.MachinePool{
@@ -128,7 +145,7 @@ var _ = Describe("Reconcile MachinePool Phases", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(res.Requeue).To(BeTrue())
 
-		r.reconcilePhase(machinepool)
+		Expect(r.reconcilePhase(ctx, machinepool)).To(Succeed())
 
 		Expect(r.Client.Get(ctx, types.NamespacedName{Name: bootstrapConfig.GetName(), Namespace: bootstrapConfig.GetNamespace()}, bootstrapConfig)).To(Succeed())
 
@@ -156,7 +173,7 @@ var _ = Describe("Reconcile MachinePool Phases", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(res.Requeue).To(BeTrue())
 
-		r.reconcilePhase(machinepool)
+		Expect(r.reconcilePhase(ctx, machinepool)).To(Succeed())
 		Expect(machinepool.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhasePending))
 	})
 
@@ -182,7 +199,7 @@ var _ = Describe("Reconcile MachinePool Phases", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(res.Requeue).To(BeTrue())
 
-		r.reconcilePhase(machinepool)
+		Expect(r.reconcilePhase(ctx, machinepool)).To(Succeed())
 		Expect(machinepool.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhaseProvisioning))
 	})
 
@@ -227,7 +244,7 @@ var _ = Describe("Reconcile MachinePool Phases", func() {
 		// Set ReadyReplicas
 		machinepool.Status.ReadyReplicas = 1
 
-		r.reconcilePhase(machinepool)
+		Expect(r.reconcilePhase(ctx, machinepool)).To(Succeed())
 		Expect(machinepool.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhaseRunning))
 	})
 
@@ -281,7 +298,7 @@ var _ = Describe("Reconcile MachinePool Phases", func() {
 		// Set ReadyReplicas
 		machinepool.Status.ReadyReplicas = 1
 
-		r.reconcilePhase(machinepool)
+		Expect(r.reconcilePhase(ctx, machinepool)).To(Succeed())
 		Expect(machinepool.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhaseRunning))
 	})
 
@@ -310,7 +327,7 @@ var _ = Describe("Reconcile MachinePool Phases", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(res.Requeue).To(BeTrue())
 
-		r.reconcilePhase(machinepool)
+		Expect(r.reconcilePhase(ctx, machinepool)).To(Succeed())
 		Expect(machinepool.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhaseProvisioned))
 	})
 
@@ -355,7 +372,7 @@ var _ = Describe("Reconcile MachinePool Phases", func() {
 		// Scale up
 		machinepool.Spec.Replicas = pointer.Int32Ptr(5)
 
-		r.reconcilePhase(machinepool)
+		Expect(r.reconcilePhase(ctx, machinepool)).To(Succeed())
 		Expect(machinepool.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhaseScalingUp))
 	})
 
@@ -407,7 +424,7 @@ var _ = Describe("Reconcile MachinePool Phases", func() {
 		// Scale down
 		machinepool.Spec.Replicas = pointer.Int32Ptr(1)
 
-		r.reconcilePhase(machinepool)
+		Expect(r.reconcilePhase(ctx, machinepool)).To(Succeed())
 		Expect(machinepool.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhaseScalingDown))
 	})
 
@@ -458,7 +475,7 @@ var _ = Describe("Reconcile MachinePool Phases", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(res.Requeue).To(BeFalse())
 
-		r.reconcilePhase(machinepool)
+		Expect(r.reconcilePhase(ctx, machinepool)).To(Succeed())
 		Expect(machinepool.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhaseDeleting))
 	})
 })
@@ -492,6 +509,9 @@ func TestReconcileMachinePoolBootstrap(t *testing.T) {
 			Name:      "test-cluster",
 			Namespace: "default",
 		},
+		Status: clusterv1.ClusterStatus{
+			ControlPlaneInitialized: true,
+		},
 	}
 
 	testCases := []struct {
@@ -620,7 +640,7 @@ func TestReconcileMachinePoolBootstrap(t *testing.T) {
 									Kind:       "BootstrapConfig",
 									Name:       "bootstrap-config1",
 								},
-								Data: pointer.StringPtr("#!/bin/bash ... data"),
+								Data: pointer.StringPtr("IyEvYmluL2Jhc2ggLi4uIGRhdGE="),
 							},
 						},
 					},
@@ -632,7 +652,8 @@ func TestReconcileMachinePoolBootstrap(t *testing.T) {
 			expectError: false,
 			expected: func(g *WithT, m *expv1.MachinePool) {
 				g.Expect(m.Status.BootstrapReady).To(BeTrue())
-				g.Expect(*m.Spec.Template.Spec.Bootstrap.Data).To(Equal("#!/bin/bash ... data"))
+				g.Expect(m.Spec.Template.Spec.Bootstrap.Data).To(BeNil())
+				g.Expect(m.Spec.Template.Spec.Bootstrap.DataSecretName).To(Equal(pointer.StringPtr("bootstrap-test-existing-bootstrap-data")))
 			},
 		},
 		{
@@ -664,7 +685,7 @@ func TestReconcileMachinePoolBootstrap(t *testing.T) {
 									Kind:       "BootstrapConfig",
 									Name:       "bootstrap-config1",
 								},
-								Data: pointer.StringPtr("#!/bin/bash ... data"),
+								Data: pointer.StringPtr("IyEvYmluL2Jhc2ggLi4uIGRhdGE="),
 							},
 						},
 					},
@@ -711,6 +732,111 @@ func TestReconcileMachinePoolBootstrap(t *testing.T) {
 	}
 }
 
+func TestReconcileMachinePoolBootstrapNotReadyReason(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+		Status:     clusterv1.ClusterStatus{ControlPlaneInitialized: true},
+	}
+
+	testCases := []struct {
+		name              string
+		status            map[string]interface{}
+		expectedCondition *clusterv1.Condition
+	}{
+		{
+			name: "reason of the Ready condition of the provider",
+			status: map[string]interface{}{
+				"ready": false,
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"type":     "Ready",
+						"status":   "False",
+						"severity": "Info",
+						"reason":   "WaitingForControlPlaneAvailable",
+						"message":  "Waiting for the control plane to be available",
+					},
+				},
+			},
+			expectedCondition: conditions.FalseCondition(clusterv1.BootstrapReadyCondition, "WaitingForControlPlaneAvailable",
+				clusterv1.ConditionSeverityInfo, "Waiting for the control plane to be available"),
+		},
+		{
+			name: "failure of the provider",
+			status: map[string]interface{}{
+				"ready":          false,
+				"failureReason":  "InvalidConfiguration",
+				"failureMessage": "Invalid join configuration",
+			},
+			expectedCondition: conditions.FalseCondition(clusterv1.BootstrapReadyCondition, "InvalidConfiguration",
+				clusterv1.ConditionSeverityError, "Invalid join configuration"),
+		},
+		{
+			name: "provider without conditions",
+			status: map[string]interface{}{
+				"ready": false,
+			},
+			expectedCondition: conditions.FalseCondition(clusterv1.BootstrapReadyCondition, clusterv1.WaitingForDataSecretFallbackReason,
+				clusterv1.ConditionSeverityInfo, ""),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			bootstrapConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "BootstrapConfig",
+					"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "bootstrap-config1",
+						"namespace": "default",
+					},
+					"spec":   map[string]interface{}{},
+					"status": tc.status,
+				},
+			}
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test"},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							Bootstrap: clusterv1.Bootstrap{
+								ConfigRef: &corev1.ObjectReference{
+									APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+									Kind:       "BootstrapConfig",
+									Name:       "bootstrap-config1",
+								},
+							},
+						},
+					},
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, mp, bootstrapConfig),
+				Log:    log.Log,
+				scheme: scheme.Scheme,
+			}
+
+			err := r.reconcileBootstrap(context.Background(), defaultCluster, mp)
+			var requeueErr *capierrors.RequeueAfterError
+			g.Expect(errors.As(err, &requeueErr)).To(BeTrue())
+			g.Expect(err.Error()).To(ContainSubstring(tc.expectedCondition.Message))
+
+			// The reason of the provider is kept when the ready conditions are reconciled.
+			reconcileReadyConditions(mp)
+			c := conditions.Get(mp, clusterv1.BootstrapReadyCondition)
+			g.Expect(c).NotTo(BeNil())
+			g.Expect(c.Status).To(Equal(corev1.ConditionFalse))
+			g.Expect(c.Reason).To(Equal(tc.expectedCondition.Reason))
+			g.Expect(c.Severity).To(Equal(tc.expectedCondition.Severity))
+			g.Expect(c.Message).To(Equal(tc.expectedCondition.Message))
+		})
+	}
+}
+
 func TestReconcileMachinePoolInfrastructure(t *testing.T) {
 	defaultMachinePool := expv1.MachinePool{
 		ObjectMeta: metav1.ObjectMeta{
@@ -793,11 +919,14 @@ func TestReconcileMachinePoolInfrastructure(t *testing.T) {
 			},
 		},
 		{
-			name: "ready bootstrap, infra, and nodeRef, machinepool is running, infra object is deleted, expect failed",
+			name: "ready bootstrap, infra, and nodeRef, machinepool is running, infra object is deleted past the grace period, expect failed",
 			machinepool: &expv1.MachinePool{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "machinepool-test",
 					Namespace: "default",
+					Annotations: map[string]string{
+						expv1.InfrastructureMissingSinceAnnotation: time.Now().Add(-2 * time.Minute).Format(time.RFC3339),
+					},
 				},
 				Spec: expv1.MachinePoolSpec{
 					Replicas: pointer.Int32Ptr(1),
@@ -851,6 +980,162 @@ func TestReconcileMachinePoolInfrastructure(t *testing.T) {
 				g.Expect(m.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhaseFailed))
 			},
 		},
+		{
+			name: "ready bootstrap, infra, and nodeRef, machinepool is running, infra object is missing within the grace period, expect requeue",
+			machinepool: &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					Replicas: pointer.Int32Ptr(1),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+								Kind:       "InfrastructureConfig",
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+				Status: expv1.MachinePoolStatus{
+					BootstrapReady:      true,
+					InfrastructureReady: true,
+					NodeRefs:            []corev1.ObjectReference{{Kind: "Node", Name: "machinepool-test-node"}},
+				},
+			},
+			infraConfig: map[string]interface{}{
+				"kind":       "InfrastructureConfig",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata":   map[string]interface{}{},
+			},
+			expectError:        true,
+			expectRequeueAfter: true,
+			expected: func(g *WithT, m *expv1.MachinePool) {
+				g.Expect(m.Status.InfrastructureReady).To(BeTrue())
+				g.Expect(m.Status.FailureMessage).To(BeNil())
+				g.Expect(m.Status.FailureReason).To(BeNil())
+				g.Expect(m.Annotations).To(HaveKey(expv1.InfrastructureMissingSinceAnnotation))
+				g.Expect(m.Status.GetTypedPhase()).NotTo(Equal(expv1.MachinePoolPhaseFailed))
+			},
+		},
+		{
+			name: "ready machinepool, infra object found again within the grace period, expect recovered",
+			machinepool: &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+					Annotations: map[string]string{
+						expv1.InfrastructureMissingSinceAnnotation: time.Now().Add(-10 * time.Second).Format(time.RFC3339),
+					},
+				},
+				Spec: expv1.MachinePoolSpec{
+					Replicas: pointer.Int32Ptr(1),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+								Kind:       "InfrastructureConfig",
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+				Status: expv1.MachinePoolStatus{
+					BootstrapReady:      true,
+					InfrastructureReady: true,
+				},
+			},
+			infraConfig: map[string]interface{}{
+				"kind":       "InfrastructureConfig",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "infra-config1",
+					"namespace": "default",
+				},
+				"spec": map[string]interface{}{
+					"providerIDList": []interface{}{
+						"test://id-1",
+					},
+				},
+				"status": map[string]interface{}{
+					"ready": true,
+				},
+			},
+			expectError: false,
+			expected: func(g *WithT, m *expv1.MachinePool) {
+				g.Expect(m.Status.InfrastructureReady).To(BeTrue())
+				g.Expect(m.Status.FailureMessage).To(BeNil())
+				g.Expect(m.Annotations).NotTo(HaveKey(expv1.InfrastructureMissingSinceAnnotation))
+			},
+		},
+		{
+			name: "infrastructure config ready, machinepool pinned to a newer generation, expect requeue",
+			machinepool: func() *expv1.MachinePool {
+				mp := defaultMachinePool.DeepCopy()
+				mp.Spec.InfrastructureGeneration = pointer.Int64Ptr(2)
+				return mp
+			}(),
+			infraConfig: map[string]interface{}{
+				"kind":       "InfrastructureConfig",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":       "infra-config1",
+					"namespace":  "default",
+					"generation": int64(1),
+				},
+				"spec": map[string]interface{}{
+					"providerIDList": []interface{}{
+						"test://id-1",
+					},
+				},
+				"status": map[string]interface{}{
+					"ready": true,
+					"instanceStates": map[string]interface{}{
+						"test://id-1": "running",
+					},
+				},
+			},
+			expectError:        true,
+			expectRequeueAfter: true,
+			expected: func(g *WithT, m *expv1.MachinePool) {
+				g.Expect(m.Status.InfrastructureReady).To(BeFalse())
+				g.Expect(m.Spec.ProviderIDList).To(BeEmpty())
+				// Nothing is read from the unpinned generation.
+				g.Expect(m.Status.InstanceStates).To(BeEmpty())
+			},
+		},
+		{
+			name: "infrastructure config ready, machinepool pinned to the current generation",
+			machinepool: func() *expv1.MachinePool {
+				mp := defaultMachinePool.DeepCopy()
+				mp.Spec.InfrastructureGeneration = pointer.Int64Ptr(1)
+				return mp
+			}(),
+			infraConfig: map[string]interface{}{
+				"kind":       "InfrastructureConfig",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":       "infra-config1",
+					"namespace":  "default",
+					"generation": int64(1),
+				},
+				"spec": map[string]interface{}{
+					"providerIDList": []interface{}{
+						"test://id-1",
+					},
+				},
+				"status": map[string]interface{}{
+					"ready": true,
+				},
+			},
+			expectError: false,
+			expected: func(g *WithT, m *expv1.MachinePool) {
+				g.Expect(m.Status.InfrastructureReady).To(BeTrue())
+				g.Expect(m.Spec.ProviderIDList).To(ConsistOf("test://id-1"))
+			},
+		},
 		{
 			name: "infrastructure ref is paused",
 			infraConfig: map[string]interface{}{
@@ -908,7 +1193,7 @@ func TestReconcileMachinePoolInfrastructure(t *testing.T) {
 			}
 
 			err := r.reconcileInfrastructure(context.Background(), defaultCluster, tc.machinepool)
-			r.reconcilePhase(tc.machinepool)
+			g.Expect(r.reconcilePhase(context.Background(), tc.machinepool)).To(Succeed())
 			if tc.expectError {
 				g.Expect(err).ToNot(BeNil())
 			} else {
@@ -921,3 +1206,4268 @@ func TestReconcileMachinePoolInfrastructure(t *testing.T) {
 		})
 	}
 }
+
+func TestMachinePoolGetExternal(t *testing.T) {
+	ref := &corev1.ObjectReference{
+		APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+		Kind:       "InfrastructureConfig",
+		Name:       "infra-config1",
+	}
+
+	newInfraConfig := func(source string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"kind":       "InfrastructureConfig",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "infra-config1",
+					"namespace": "default",
+					"labels": map[string]interface{}{
+						"source": source,
+					},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name           string
+		watched        bool
+		cached         bool
+		expectedSource string
+	}{
+		{
+			name:           "watch not established, reads from the live client",
+			watched:        false,
+			cached:         true,
+			expectedSource: "live",
+		},
+		{
+			name:           "watch established, reads from the cache",
+			watched:        true,
+			cached:         true,
+			expectedSource: "cache",
+		},
+		{
+			name:           "watch established, cache miss falls back to the live client",
+			watched:        true,
+			cached:         false,
+			expectedSource: "live",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cachedObjs := []runtime.Object{}
+			if tc.cached {
+				cachedObjs = append(cachedObjs, newInfraConfig("cache"))
+			}
+
+			r := &MachinePoolReconciler{
+				Client:       fake.NewFakeClientWithScheme(scheme.Scheme, newInfraConfig("live")),
+				Log:          log.Log,
+				scheme:       scheme.Scheme,
+				cachedReader: fake.NewFakeClientWithScheme(scheme.Scheme, cachedObjs...),
+			}
+			if tc.watched {
+				r.externalWatchers.Store(ref.GroupVersionKind().String(), struct{}{})
+			}
+
+			obj, err := r.getExternal(context.Background(), ref, "default")
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(obj.GetLabels()).To(HaveKeyWithValue("source", tc.expectedSource))
+		})
+	}
+}
+
+func TestReconcileMachinePoolProviderIDListToInfrastructure(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+		},
+	}
+
+	defaultMachinePool := expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machinepool-test",
+			Namespace: "default",
+		},
+		Spec: expv1.MachinePoolSpec{
+			Replicas:             pointer.Int32Ptr(2),
+			ProviderIDListSource: expv1.ProviderIDListSourceMachinePool,
+			ProviderIDList:       []string{"test://id-1", "test://id-2"},
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureConfig",
+						Name:       "infra-config1",
+					},
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name                   string
+		infraAnnotations       map[string]interface{}
+		infraProviderIDList    []interface{}
+		expectedProviderIDList []string
+	}{
+		{
+			name:                   "provider id list is written to the infrastructure object",
+			infraProviderIDList:    []interface{}{},
+			expectedProviderIDList: []string{"test://id-1", "test://id-2"},
+		},
+		{
+			name: "provider id list already synced is not written again, even if the provider changed it",
+			infraAnnotations: map[string]interface{}{
+				expv1.ProviderIDListSyncedHashAnnotation: providerIDListHash([]string{"test://id-1", "test://id-2"}),
+			},
+			infraProviderIDList:    []interface{}{"test://id-1", "test://id-3"},
+			expectedProviderIDList: []string{"test://id-1", "test://id-3"},
+		},
+		{
+			name: "provider id list changed since the last sync is written again",
+			infraAnnotations: map[string]interface{}{
+				expv1.ProviderIDListSyncedHashAnnotation: providerIDListHash([]string{"test://id-1"}),
+			},
+			infraProviderIDList:    []interface{}{"test://id-1"},
+			expectedProviderIDList: []string{"test://id-1", "test://id-2"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			metadata := map[string]interface{}{
+				"name":      "infra-config1",
+				"namespace": "default",
+			}
+			if tc.infraAnnotations != nil {
+				metadata["annotations"] = tc.infraAnnotations
+			}
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata":   metadata,
+					"spec": map[string]interface{}{
+						"providerIDList": tc.infraProviderIDList,
+					},
+					"status": map[string]interface{}{
+						"ready": true,
+					},
+				},
+			}
+
+			machinepool := defaultMachinePool.DeepCopy()
+			r := &MachinePoolReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, machinepool, infraConfig),
+				Log:    log.Log,
+				scheme: scheme.Scheme,
+			}
+
+			g.Expect(r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)).To(Succeed())
+			g.Expect(machinepool.Status.InfrastructureReady).To(BeTrue())
+			g.Expect(machinepool.Spec.ProviderIDList).To(Equal(defaultMachinePool.Spec.ProviderIDList))
+
+			actual := &unstructured.Unstructured{}
+			actual.SetGroupVersionKind(infraConfig.GroupVersionKind())
+			g.Expect(r.Client.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "infra-config1"}, actual)).To(Succeed())
+
+			providerIDList, _, err := unstructured.NestedStringSlice(actual.Object, "spec", "providerIDList")
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(providerIDList).To(Equal(tc.expectedProviderIDList))
+			g.Expect(actual.GetAnnotations()).To(HaveKey(expv1.ProviderIDListSyncedHashAnnotation))
+		})
+	}
+}
+
+func TestReconcileMachinePoolDisplayPhase(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		phaseDisplayNames    map[expv1.MachinePoolPhase]string
+		infrastructureReady  bool
+		expectedPhase        expv1.MachinePoolPhase
+		expectedDisplayPhase string
+	}{
+		{
+			name:                 "phase is displayed as is without display names",
+			infrastructureReady:  true,
+			expectedPhase:        expv1.MachinePoolPhaseRunning,
+			expectedDisplayPhase: "Running",
+		},
+		{
+			name: "phase is displayed with its display name",
+			phaseDisplayNames: map[expv1.MachinePoolPhase]string{
+				expv1.MachinePoolPhaseRunning: "Ready",
+			},
+			infrastructureReady:  true,
+			expectedPhase:        expv1.MachinePoolPhaseRunning,
+			expectedDisplayPhase: "Ready",
+		},
+		{
+			name: "phase without a display name is displayed as is",
+			phaseDisplayNames: map[expv1.MachinePoolPhase]string{
+				expv1.MachinePoolPhaseRunning: "Ready",
+			},
+			expectedPhase:        expv1.MachinePoolPhasePending,
+			expectedDisplayPhase: "Pending",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				Spec: expv1.MachinePoolSpec{
+					Replicas: pointer.Int32Ptr(0),
+				},
+				Status: expv1.MachinePoolStatus{
+					InfrastructureReady: tc.infrastructureReady,
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Log:               log.Log,
+				PhaseDisplayNames: tc.phaseDisplayNames,
+			}
+			g.Expect(r.reconcilePhase(context.Background(), mp)).To(Succeed())
+
+			g.Expect(mp.Status.GetTypedPhase()).To(Equal(tc.expectedPhase))
+			g.Expect(mp.Status.DisplayPhase).To(Equal(tc.expectedDisplayPhase))
+		})
+	}
+}
+
+func TestReconcileMachinePoolSummary(t *testing.T) {
+	testCases := []struct {
+		name                string
+		replicas            int32
+		readyReplicas       int32
+		infrastructureReady bool
+		failed              bool
+		phaseDisplayNames   map[expv1.MachinePoolPhase]string
+		expectedSummary     string
+	}{
+		{
+			name:            "pending pool",
+			replicas:        3,
+			expectedSummary: "0/3 ready, Pending",
+		},
+		{
+			name:                "running pool",
+			replicas:            3,
+			readyReplicas:       3,
+			infrastructureReady: true,
+			expectedSummary:     "3/3 ready, Running",
+		},
+		{
+			name:                "pool scaling up",
+			replicas:            5,
+			readyReplicas:       3,
+			infrastructureReady: true,
+			expectedSummary:     "3/5 ready, ScalingUp",
+		},
+		{
+			name:                "pool scaling down",
+			replicas:            1,
+			readyReplicas:       3,
+			infrastructureReady: true,
+			expectedSummary:     "3/1 ready, ScalingDown",
+		},
+		{
+			name:                "failed pool",
+			replicas:            3,
+			readyReplicas:       2,
+			infrastructureReady: true,
+			failed:              true,
+			expectedSummary:     "2/3 ready, Failed",
+		},
+		{
+			name:                "phase is summarized with its display name",
+			replicas:            2,
+			readyReplicas:       2,
+			infrastructureReady: true,
+			phaseDisplayNames: map[expv1.MachinePoolPhase]string{
+				expv1.MachinePoolPhaseRunning: "Ready",
+			},
+			expectedSummary: "2/2 ready, Ready",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				Spec: expv1.MachinePoolSpec{
+					Replicas: pointer.Int32Ptr(tc.replicas),
+				},
+				Status: expv1.MachinePoolStatus{
+					ReadyReplicas:       tc.readyReplicas,
+					InfrastructureReady: tc.infrastructureReady,
+				},
+			}
+			if tc.failed {
+				mp.Status.FailureReason = capierrors.MachinePoolStatusErrorPtr(capierrors.InvalidConfigurationMachinePoolError)
+			}
+
+			r := &MachinePoolReconciler{
+				Log:               log.Log,
+				PhaseDisplayNames: tc.phaseDisplayNames,
+			}
+			g.Expect(r.reconcilePhase(context.Background(), mp)).To(Succeed())
+			g.Expect(mp.Status.Summary).To(Equal(tc.expectedSummary))
+		})
+	}
+}
+
+func TestReconcileMachinePoolProgressPercent(t *testing.T) {
+	testCases := []struct {
+		name                string
+		replicas            int32
+		readyReplicas       int32
+		infrastructureReady bool
+		expectedPercent     int32
+	}{
+		{
+			name:            "no ready replicas",
+			replicas:        3,
+			expectedPercent: 0,
+		},
+		{
+			name:                "some ready replicas",
+			replicas:            3,
+			readyReplicas:       1,
+			infrastructureReady: true,
+			expectedPercent:     33,
+		},
+		{
+			name:                "half of the replicas ready",
+			replicas:            4,
+			readyReplicas:       2,
+			infrastructureReady: true,
+			expectedPercent:     50,
+		},
+		{
+			name:                "all replicas ready",
+			replicas:            3,
+			readyReplicas:       3,
+			infrastructureReady: true,
+			expectedPercent:     100,
+		},
+		{
+			name:                "more ready replicas than desired while scaling down",
+			replicas:            1,
+			readyReplicas:       3,
+			infrastructureReady: true,
+			expectedPercent:     100,
+		},
+		{
+			name:            "pool scaled to zero with infrastructure not ready",
+			replicas:        0,
+			expectedPercent: 0,
+		},
+		{
+			name:                "pool scaled to zero with infrastructure ready",
+			replicas:            0,
+			infrastructureReady: true,
+			expectedPercent:     100,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				Spec: expv1.MachinePoolSpec{
+					Replicas: pointer.Int32Ptr(tc.replicas),
+				},
+				Status: expv1.MachinePoolStatus{
+					ReadyReplicas:       tc.readyReplicas,
+					InfrastructureReady: tc.infrastructureReady,
+				},
+			}
+
+			r := &MachinePoolReconciler{Log: log.Log}
+			g.Expect(r.reconcilePhase(context.Background(), mp)).To(Succeed())
+			g.Expect(mp.Status.ProgressPercent).To(Equal(tc.expectedPercent))
+		})
+	}
+}
+
+func TestReconcileMachinePoolNotRunningReason(t *testing.T) {
+	testCases := []struct {
+		name                string
+		replicas            int32
+		readyReplicas       int32
+		bootstrapReady      bool
+		infrastructureReady bool
+		failureMessage      *string
+		deleting            bool
+		expectedReason      string
+	}{
+		{
+			name:           "bootstrap not ready",
+			replicas:       3,
+			expectedReason: "Bootstrap is not ready",
+		},
+		{
+			name:           "infrastructure not ready",
+			replicas:       3,
+			bootstrapReady: true,
+			expectedReason: "Infrastructure is not ready",
+		},
+		{
+			name:                "replicas mismatch",
+			replicas:            3,
+			readyReplicas:       1,
+			bootstrapReady:      true,
+			infrastructureReady: true,
+			expectedReason:      "1 of 3 replicas are ready",
+		},
+		{
+			name:                "failure present",
+			replicas:            3,
+			readyReplicas:       3,
+			bootstrapReady:      true,
+			infrastructureReady: true,
+			failureMessage:      pointer.StringPtr("instance quota exceeded"),
+			expectedReason:      "Failure reported: instance quota exceeded",
+		},
+		{
+			name:                "deleting",
+			replicas:            3,
+			readyReplicas:       3,
+			bootstrapReady:      true,
+			infrastructureReady: true,
+			deleting:            true,
+			expectedReason:      "MachinePool is being deleted",
+		},
+		{
+			name:                "running",
+			replicas:            3,
+			readyReplicas:       3,
+			bootstrapReady:      true,
+			infrastructureReady: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				Spec: expv1.MachinePoolSpec{
+					Replicas: pointer.Int32Ptr(tc.replicas),
+				},
+				Status: expv1.MachinePoolStatus{
+					ReadyReplicas:       tc.readyReplicas,
+					BootstrapReady:      tc.bootstrapReady,
+					InfrastructureReady: tc.infrastructureReady,
+					FailureMessage:      tc.failureMessage,
+					// A previous reason is cleared once the MachinePool is Running.
+					NotRunningReason: "Infrastructure is not ready",
+				},
+			}
+			if tc.deleting {
+				mp.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			}
+
+			r := &MachinePoolReconciler{Log: log.Log}
+			g.Expect(r.reconcilePhase(context.Background(), mp)).To(Succeed())
+			g.Expect(mp.Status.NotRunningReason).To(Equal(tc.expectedReason))
+		})
+	}
+}
+
+func TestReconcileMachinePoolProviderStatus(t *testing.T) {
+	infraConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureConfig",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "infra-config1",
+				"namespace": "default",
+			},
+			"status": map[string]interface{}{
+				"ready": true,
+				"autoScalingGroup": map[string]interface{}{
+					"arn":           "arn:aws:autoscaling:us-east-1:123456789012:autoScalingGroup",
+					"instanceCount": int64(3),
+				},
+				"zones": []interface{}{"us-east-1a", "us-east-1b"},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name                   string
+		statusFieldMappings    []expv1.StatusFieldMapping
+		expectError            bool
+		expectedProviderStatus map[string]string
+	}{
+		{
+			name:                   "no status field mappings",
+			expectedProviderStatus: nil,
+		},
+		{
+			name: "nested status fields are copied",
+			statusFieldMappings: []expv1.StatusFieldMapping{
+				{Name: "asgARN", Path: "autoScalingGroup.arn"},
+				{Name: "instanceCount", Path: "autoScalingGroup.instanceCount"},
+				{Name: "zones", Path: "zones"},
+			},
+			expectedProviderStatus: map[string]string{
+				"asgARN":        "arn:aws:autoscaling:us-east-1:123456789012:autoScalingGroup",
+				"instanceCount": "3",
+				"zones":         `["us-east-1a","us-east-1b"]`,
+			},
+		},
+		{
+			name: "missing status fields are left out",
+			statusFieldMappings: []expv1.StatusFieldMapping{
+				{Name: "asgARN", Path: "autoScalingGroup.arn"},
+				{Name: "launchTemplate", Path: "launchTemplate.id"},
+			},
+			expectedProviderStatus: map[string]string{
+				"asgARN": "arn:aws:autoscaling:us-east-1:123456789012:autoScalingGroup",
+			},
+		},
+		{
+			name: "path through a non-object field is an error",
+			statusFieldMappings: []expv1.StatusFieldMapping{
+				{Name: "ready", Path: "ready.value"},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					StatusFieldMappings: tc.statusFieldMappings,
+				},
+			}
+
+			err := reconcileProviderStatus(mp, infraConfig)
+			if tc.expectError {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(mp.Status.ProviderStatus).To(Equal(tc.expectedProviderStatus))
+		})
+	}
+}
+
+func TestReconcileMachinePoolBootstrapWaitsForControlPlane(t *testing.T) {
+	bootstrapConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "BootstrapConfig",
+			"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "bootstrap-config1",
+				"namespace": "default",
+			},
+			"spec": map[string]interface{}{},
+			"status": map[string]interface{}{
+				"ready":          true,
+				"dataSecretName": "secret-data",
+			},
+		},
+	}
+
+	testCases := []struct {
+		name                    string
+		controlPlaneInitialized bool
+		annotations             map[string]string
+		expectRequeue           bool
+		expectedBootstrapReady  bool
+		expectedCondition       *clusterv1.Condition
+	}{
+		{
+			name:                    "control plane not initialized holds bootstrapping",
+			controlPlaneInitialized: false,
+			expectRequeue:           true,
+			expectedBootstrapReady:  false,
+			expectedCondition: conditions.FalseCondition(expv1.ControlPlaneInitializedCondition,
+				expv1.WaitingForControlPlaneInitializedReason, clusterv1.ConditionSeverityInfo, ""),
+		},
+		{
+			name:                    "control plane initialized allows bootstrapping",
+			controlPlaneInitialized: true,
+			expectedBootstrapReady:  true,
+			expectedCondition:       conditions.TrueCondition(expv1.ControlPlaneInitializedCondition),
+		},
+		{
+			name:                    "pools provisioning early don't wait for the control plane",
+			controlPlaneInitialized: false,
+			annotations: map[string]string{
+				expv1.SkipWaitForControlPlaneInitializedAnnotation: "",
+			},
+			expectedBootstrapReady: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cluster := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+				Status:     clusterv1.ClusterStatus{ControlPlaneInitialized: tc.controlPlaneInitialized},
+			}
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "machinepool-test",
+					Namespace:   "default",
+					Annotations: tc.annotations,
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: cluster.Name,
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							Bootstrap: clusterv1.Bootstrap{
+								ConfigRef: &corev1.ObjectReference{
+									APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+									Kind:       "BootstrapConfig",
+									Name:       "bootstrap-config1",
+								},
+							},
+						},
+					},
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, cluster, mp, bootstrapConfig.DeepCopy()),
+				Log:    log.Log,
+				scheme: scheme.Scheme,
+			}
+
+			err := r.reconcileBootstrap(context.Background(), cluster, mp)
+			if tc.expectRequeue {
+				g.Expect(err).To(HaveOccurred())
+				_, ok := errors.Cause(err).(capierrors.HasRequeueAfterError)
+				g.Expect(ok).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(mp.Status.BootstrapReady).To(Equal(tc.expectedBootstrapReady))
+
+			if tc.expectedCondition == nil {
+				g.Expect(conditions.Has(mp, expv1.ControlPlaneInitializedCondition)).To(BeFalse())
+				return
+			}
+			c := conditions.Get(mp, expv1.ControlPlaneInitializedCondition)
+			g.Expect(c).NotTo(BeNil())
+			g.Expect(c.Status).To(Equal(tc.expectedCondition.Status))
+			g.Expect(c.Reason).To(Equal(tc.expectedCondition.Reason))
+		})
+	}
+}
+
+func TestReconcileMachinePoolBootstrapDataSecretValidation(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		validate               bool
+		secretData             map[string][]byte
+		expectRequeue          bool
+		expectedBootstrapReady bool
+		expectedCondition      *clusterv1.Condition
+	}{
+		{
+			name:                   "secret with a value is valid",
+			validate:               true,
+			secretData:             map[string][]byte{"value": []byte("#cloud-config")},
+			expectedBootstrapReady: true,
+			expectedCondition:      conditions.TrueCondition(expv1.BootstrapDataSecretValidCondition),
+		},
+		{
+			name:                   "secret with an empty value is invalid",
+			validate:               true,
+			secretData:             map[string][]byte{"value": {}},
+			expectRequeue:          true,
+			expectedBootstrapReady: false,
+			expectedCondition: conditions.FalseCondition(expv1.BootstrapDataSecretValidCondition,
+				expv1.InvalidBootstrapDataSecretReason, clusterv1.ConditionSeverityWarning, ""),
+		},
+		{
+			name:                   "secret without a value key is invalid",
+			validate:               true,
+			secretData:             map[string][]byte{"format": []byte("cloud-config")},
+			expectRequeue:          true,
+			expectedBootstrapReady: false,
+			expectedCondition: conditions.FalseCondition(expv1.BootstrapDataSecretValidCondition,
+				expv1.InvalidBootstrapDataSecretReason, clusterv1.ConditionSeverityWarning, ""),
+		},
+		{
+			name:                   "missing secret is invalid",
+			validate:               true,
+			expectRequeue:          true,
+			expectedBootstrapReady: false,
+			expectedCondition: conditions.FalseCondition(expv1.BootstrapDataSecretValidCondition,
+				expv1.BootstrapDataSecretNotFoundReason, clusterv1.ConditionSeverityWarning, ""),
+		},
+		{
+			name:                   "secret isn't read without validation",
+			validate:               false,
+			expectedBootstrapReady: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cluster := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+				Status:     clusterv1.ClusterStatus{ControlPlaneInitialized: true},
+			}
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: cluster.Name,
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							Bootstrap: clusterv1.Bootstrap{
+								DataSecretName: pointer.StringPtr("secret-data"),
+							},
+						},
+					},
+				},
+			}
+
+			objs := []runtime.Object{cluster, mp}
+			if tc.secretData != nil {
+				objs = append(objs, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "secret-data"},
+					Data:       tc.secretData,
+				})
+			}
+
+			r := &MachinePoolReconciler{
+				Client:                       fake.NewFakeClientWithScheme(scheme.Scheme, objs...),
+				Log:                          log.Log,
+				scheme:                       scheme.Scheme,
+				ValidateBootstrapDataSecrets: tc.validate,
+			}
+
+			err := r.reconcileBootstrap(context.Background(), cluster, mp)
+			if tc.expectRequeue {
+				g.Expect(err).To(HaveOccurred())
+				_, ok := errors.Cause(err).(capierrors.HasRequeueAfterError)
+				g.Expect(ok).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(mp.Status.BootstrapReady).To(Equal(tc.expectedBootstrapReady))
+
+			if tc.expectedCondition == nil {
+				g.Expect(conditions.Has(mp, expv1.BootstrapDataSecretValidCondition)).To(BeFalse())
+				return
+			}
+			c := conditions.Get(mp, expv1.BootstrapDataSecretValidCondition)
+			g.Expect(c).NotTo(BeNil())
+			g.Expect(c.Status).To(Equal(tc.expectedCondition.Status))
+			g.Expect(c.Reason).To(Equal(tc.expectedCondition.Reason))
+		})
+	}
+}
+
+func TestReconcileMachinePoolBootstrapData(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+		Status:     clusterv1.ClusterStatus{ControlPlaneInitialized: true},
+	}
+
+	machinePoolRef := metav1.OwnerReference{
+		APIVersion: expv1.GroupVersion.String(),
+		Kind:       "MachinePool",
+		Name:       "machinepool-test",
+		Controller: pointer.BoolPtr(true),
+	}
+
+	testCases := []struct {
+		name          string
+		data          string
+		secret        *corev1.Secret
+		expectError   bool
+		expectedValue string
+	}{
+		{
+			name:          "inline data is stored in a new secret",
+			data:          "IyEvYmluL2Jhc2ggLi4uIGRhdGE=",
+			expectedValue: "#!/bin/bash ... data",
+		},
+		{
+			name: "secret controlled by the machinepool is updated",
+			data: "IyEvYmluL2Jhc2ggLi4uIGRhdGE=",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:       "default",
+					Name:            "machinepool-test-bootstrap-data",
+					Labels:          map[string]string{clusterv1.ClusterLabelName: "test-cluster"},
+					OwnerReferences: []metav1.OwnerReference{machinePoolRef},
+				},
+				Data: map[string][]byte{"value": []byte("stale")},
+				Type: clusterv1.ClusterSecretType,
+			},
+			expectedValue: "#!/bin/bash ... data",
+		},
+		{
+			name: "secret not controlled by the machinepool is left untouched",
+			data: "IyEvYmluL2Jhc2ggLi4uIGRhdGE=",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "default",
+					Name:      "machinepool-test-bootstrap-data",
+				},
+				Data: map[string][]byte{"value": []byte("other")},
+			},
+			expectError:   true,
+			expectedValue: "other",
+		},
+		{
+			name:        "inline data which isn't base64 encoded is rejected",
+			data:        "#!/bin/bash ... data",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							Bootstrap: clusterv1.Bootstrap{Data: pointer.StringPtr(tc.data)},
+						},
+					},
+				},
+			}
+
+			objs := []runtime.Object{machinepool}
+			if tc.secret != nil {
+				objs = append(objs, tc.secret)
+			}
+			r := &MachinePoolReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, objs...),
+				Log:    log.Log,
+				scheme: scheme.Scheme,
+			}
+
+			err := r.reconcileBootstrap(context.Background(), defaultCluster, machinepool)
+
+			secret := &corev1.Secret{}
+			secretErr := r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "machinepool-test-bootstrap-data"}, secret)
+			if tc.expectedValue == "" {
+				g.Expect(apierrors.IsNotFound(secretErr)).To(BeTrue())
+			} else {
+				g.Expect(secretErr).NotTo(HaveOccurred())
+				g.Expect(string(secret.Data["value"])).To(Equal(tc.expectedValue))
+			}
+
+			if tc.expectError {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(machinepool.Status.BootstrapReady).To(BeFalse())
+				g.Expect(machinepool.Spec.Template.Spec.Bootstrap.Data).To(Equal(pointer.StringPtr(tc.data)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(machinepool.Status.BootstrapReady).To(BeTrue())
+			g.Expect(machinepool.Spec.Template.Spec.Bootstrap.Data).To(BeNil())
+			g.Expect(machinepool.Spec.Template.Spec.Bootstrap.DataSecretName).To(Equal(pointer.StringPtr("machinepool-test-bootstrap-data")))
+			g.Expect(secret.Labels).To(HaveKeyWithValue(clusterv1.ClusterLabelName, defaultCluster.Name))
+			g.Expect(secret.Type).To(Equal(clusterv1.ClusterSecretType))
+			g.Expect(metav1.GetControllerOf(secret)).NotTo(BeNil())
+			g.Expect(metav1.GetControllerOf(secret).Name).To(Equal(machinepool.Name))
+		})
+	}
+}
+
+func TestReconcileMachinePoolOrphanedBootstrapDataSecrets(t *testing.T) {
+	g := NewWithT(t)
+
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+		Status:     clusterv1.ClusterStatus{ControlPlaneInitialized: true},
+	}
+
+	newSecret := func(name string, ownerRefs ...metav1.OwnerReference) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "default",
+				Name:            name,
+				Labels:          map[string]string{clusterv1.ClusterLabelName: "test-cluster"},
+				OwnerReferences: ownerRefs,
+			},
+			Data: map[string][]byte{"value": []byte("data")},
+			Type: clusterv1.ClusterSecretType,
+		}
+	}
+	machinePoolRef := metav1.OwnerReference{
+		APIVersion: expv1.GroupVersion.String(),
+		Kind:       "MachinePool",
+		Name:       "machinepool-test",
+		Controller: pointer.BoolPtr(true),
+	}
+	otherMachinePoolRef := metav1.OwnerReference{
+		APIVersion: expv1.GroupVersion.String(),
+		Kind:       "MachinePool",
+		Name:       "other-machinepool",
+		Controller: pointer.BoolPtr(true),
+	}
+	bootstrapConfigRef := metav1.OwnerReference{
+		APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+		Kind:       "BootstrapConfig",
+		Name:       "bootstrap-config1",
+		Controller: pointer.BoolPtr(true),
+	}
+
+	machinepool := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machinepool-test",
+			Namespace: "default",
+		},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName: defaultCluster.Name,
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					Bootstrap: clusterv1.Bootstrap{DataSecretName: pointer.StringPtr("bootstrap-config1")},
+				},
+			},
+		},
+	}
+
+	r := &MachinePoolReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme,
+			machinepool,
+			// The secret in use, e.g. after switching from inline data to a bootstrap provider.
+			newSecret("bootstrap-config1", bootstrapConfigRef),
+			// The secret the inline data of the MachinePool was stored in.
+			newSecret("machinepool-test-bootstrap-data", machinePoolRef),
+			// The secret of another MachinePool.
+			newSecret("other-machinepool-bootstrap-data", otherMachinePoolRef),
+		),
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	g.Expect(r.reconcileBootstrap(context.Background(), defaultCluster, machinepool)).To(Succeed())
+	g.Expect(machinepool.Status.BootstrapReady).To(BeTrue())
+
+	secrets := &corev1.SecretList{}
+	g.Expect(r.Client.List(ctx, secrets)).To(Succeed())
+	names := []string{}
+	for _, secret := range secrets.Items {
+		names = append(names, secret.Name)
+	}
+	g.Expect(names).To(ConsistOf("bootstrap-config1", "other-machinepool-bootstrap-data"))
+
+	// The secret in use is never deleted, even when controlled by the MachinePool.
+	machinepool.Spec.Template.Spec.Bootstrap.DataSecretName = pointer.StringPtr("other-machinepool-bootstrap-data")
+	machinepool.Name = "other-machinepool"
+	g.Expect(r.reconcileBootstrap(context.Background(), defaultCluster, machinepool)).To(Succeed())
+	g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "other-machinepool-bootstrap-data"}, &corev1.Secret{})).To(Succeed())
+}
+
+func TestReconcileMachinePoolInfrastructurePinnedGeneration(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"}}
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test"},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName:              cluster.Name,
+			InfrastructureGeneration: pointer.Int64Ptr(2),
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureConfig",
+						Name:       "infra-config1",
+					},
+				},
+			},
+		},
+	}
+	infraConfig := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":       "InfrastructureConfig",
+		"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+		"metadata": map[string]interface{}{
+			"name":       "infra-config1",
+			"namespace":  "default",
+			"generation": int64(1),
+		},
+		"status": map[string]interface{}{
+			"ready": true,
+		},
+	}}
+
+	r := &MachinePoolReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, mp, infraConfig),
+		Log:    log.Log,
+		scheme: scheme.Scheme,
+	}
+
+	err := r.reconcileInfrastructure(context.Background(), cluster, mp)
+	g.Expect(err).To(HaveOccurred())
+	_, ok := errors.Cause(err).(capierrors.HasRequeueAfterError)
+	g.Expect(ok).To(BeTrue())
+
+	// The infrastructure object isn't adopted nor patched until the pinned generation is observed.
+	actual := &unstructured.Unstructured{}
+	actual.SetGroupVersionKind(infraConfig.GroupVersionKind())
+	g.Expect(r.Client.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "infra-config1"}, actual)).To(Succeed())
+	g.Expect(actual.GetOwnerReferences()).To(BeEmpty())
+	g.Expect(actual.GetLabels()).NotTo(HaveKey(clusterv1.ClusterLabelName))
+	g.Expect(actual.GetGeneration()).To(BeEquivalentTo(1))
+}
+
+func TestReconcileMachinePoolInfrastructureProviderIDListLocation(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	infraGVK := schema.GroupVersionKind{
+		Group:   "infrastructure.cluster.x-k8s.io",
+		Version: "v1alpha3",
+		Kind:    "InfrastructureConfig",
+	}
+
+	testCases := []struct {
+		name                      string
+		spec                      map[string]interface{}
+		status                    map[string]interface{}
+		statusProviderIDListKinds []schema.GroupVersionKind
+		expectError               bool
+		expectedProviderIDList    []string
+	}{
+		{
+			name: "provider IDs are read from spec by default",
+			spec: map[string]interface{}{
+				"providerIDList": []interface{}{"aws://us-east-1/id-spec"},
+			},
+			status:                 map[string]interface{}{},
+			expectedProviderIDList: []string{"aws://us-east-1/id-spec"},
+		},
+		{
+			name: "provider IDs are read from status for configured kinds",
+			spec: map[string]interface{}{},
+			status: map[string]interface{}{
+				"providerIDList": []interface{}{"aws://us-east-1/id-status"},
+			},
+			statusProviderIDListKinds: []schema.GroupVersionKind{infraGVK},
+			expectedProviderIDList:    []string{"aws://us-east-1/id-status"},
+		},
+		{
+			name: "provider IDs in spec take precedence over status for configured kinds",
+			spec: map[string]interface{}{
+				"providerIDList": []interface{}{"aws://us-east-1/id-spec"},
+			},
+			status: map[string]interface{}{
+				"providerIDList": []interface{}{"aws://us-east-1/id-status"},
+			},
+			statusProviderIDListKinds: []schema.GroupVersionKind{infraGVK},
+			expectedProviderIDList:    []string{"aws://us-east-1/id-spec"},
+		},
+		{
+			name: "provider IDs are not read from status for other kinds",
+			spec: map[string]interface{}{},
+			status: map[string]interface{}{
+				"providerIDList": []interface{}{"aws://us-east-1/id-status"},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			status := tc.status
+			status["ready"] = true
+			status["replicas"] = int64(1)
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       infraGVK.Kind,
+					"apiVersion": infraGVK.GroupVersion().String(),
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"spec":   tc.spec,
+					"status": status,
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Replicas:    pointer.Int32Ptr(1),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: infraGVK.GroupVersion().String(),
+								Kind:       infraGVK.Kind,
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client:                    fake.NewFakeClientWithScheme(scheme.Scheme, machinepool, infraConfig),
+				Log:                       log.Log,
+				scheme:                    scheme.Scheme,
+				StatusProviderIDListKinds: tc.statusProviderIDListKinds,
+			}
+
+			err := r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)
+			if tc.expectError {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(machinepool.Spec.ProviderIDList).To(Equal(tc.expectedProviderIDList))
+		})
+	}
+}
+
+func TestReconcileMachinePoolInfrastructureProviderIDListOrder(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	testCases := []struct {
+		name                   string
+		providerIDList         []string
+		infraProviderIDList    []interface{}
+		expectedProviderIDList []string
+		expectedReadyReplicas  int32
+	}{
+		{
+			name:                   "reordered provider IDs are a no-op",
+			providerIDList:         []string{"test://id-1", "test://id-2", "test://id-3"},
+			infraProviderIDList:    []interface{}{"test://id-3", "test://id-1", "test://id-2"},
+			expectedProviderIDList: []string{"test://id-1", "test://id-2", "test://id-3"},
+			expectedReadyReplicas:  3,
+		},
+		{
+			name:                   "provider IDs stored out of order are sorted without resetting the replicas",
+			providerIDList:         []string{"test://id-2", "test://id-3", "test://id-1"},
+			infraProviderIDList:    []interface{}{"test://id-3", "test://id-2", "test://id-1"},
+			expectedProviderIDList: []string{"test://id-1", "test://id-2", "test://id-3"},
+			expectedReadyReplicas:  3,
+		},
+		{
+			name:                   "changed provider IDs are stored sorted and reset the replicas",
+			providerIDList:         []string{"test://id-1", "test://id-2", "test://id-3"},
+			infraProviderIDList:    []interface{}{"test://id-4", "test://id-1", "test://id-2"},
+			expectedProviderIDList: []string{"test://id-1", "test://id-2", "test://id-4"},
+			expectedReadyReplicas:  0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{
+						"providerIDList": tc.infraProviderIDList,
+					},
+					"status": map[string]interface{}{
+						"ready":    true,
+						"replicas": int64(3),
+					},
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName:    defaultCluster.Name,
+					Replicas:       pointer.Int32Ptr(3),
+					ProviderIDList: tc.providerIDList,
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+								Kind:       "InfrastructureConfig",
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+				Status: expv1.MachinePoolStatus{
+					Replicas:          3,
+					ReadyReplicas:     3,
+					AvailableReplicas: 3,
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, machinepool, infraConfig),
+				Log:    log.Log,
+				scheme: scheme.Scheme,
+			}
+
+			g.Expect(r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)).To(Succeed())
+			g.Expect(machinepool.Spec.ProviderIDList).To(Equal(tc.expectedProviderIDList))
+			g.Expect(machinepool.Status.ReadyReplicas).To(Equal(tc.expectedReadyReplicas))
+			g.Expect(machinepool.Status.AvailableReplicas).To(Equal(tc.expectedReadyReplicas))
+		})
+	}
+}
+
+func TestReconcileMachinePoolInfrastructureReplicas(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	infraGVK := schema.GroupVersionKind{
+		Group:   "infrastructure.cluster.x-k8s.io",
+		Version: "v1alpha3",
+		Kind:    "InfrastructureConfig",
+	}
+
+	testCases := []struct {
+		name                        string
+		spec                        map[string]interface{}
+		infrastructureReplicasKinds []schema.GroupVersionKind
+		expectedReplicas            []interface{}
+	}{
+		{
+			name: "replicas are written to configured kinds",
+			spec: map[string]interface{}{
+				"replicas": int64(1),
+			},
+			infrastructureReplicasKinds: []schema.GroupVersionKind{infraGVK},
+			expectedReplicas:            []interface{}{int64(3), int64(5)},
+		},
+		{
+			name: "replicas are not written to other kinds",
+			spec: map[string]interface{}{
+				"replicas": int64(1),
+			},
+			expectedReplicas: []interface{}{int64(1), int64(1)},
+		},
+		{
+			name:                        "replicas are not written to infrastructure objects without the field",
+			spec:                        map[string]interface{}{},
+			infrastructureReplicasKinds: []schema.GroupVersionKind{infraGVK},
+			expectedReplicas:            []interface{}{nil, nil},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			spec := tc.spec
+			spec["providerIDList"] = []interface{}{"aws://us-east-1/id-1"}
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       infraGVK.Kind,
+					"apiVersion": infraGVK.GroupVersion().String(),
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"spec": spec,
+					"status": map[string]interface{}{
+						"ready":    true,
+						"replicas": int64(1),
+					},
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Replicas:    pointer.Int32Ptr(3),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: infraGVK.GroupVersion().String(),
+								Kind:       infraGVK.Kind,
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client:                      fake.NewFakeClientWithScheme(scheme.Scheme, machinepool, infraConfig),
+				Log:                         log.Log,
+				scheme:                      scheme.Scheme,
+				InfrastructureReplicasKinds: tc.infrastructureReplicasKinds,
+			}
+
+			// Scaling the MachinePool scales the infrastructure.
+			for i, replicas := range []int32{3, 5} {
+				machinepool.Spec.Replicas = pointer.Int32Ptr(replicas)
+				g.Expect(r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)).To(Succeed())
+
+				actual := &unstructured.Unstructured{}
+				actual.SetGroupVersionKind(infraGVK)
+				g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "infra-config1"}, actual)).To(Succeed())
+				value, found, err := unstructured.NestedFieldNoCopy(actual.Object, "spec", "replicas")
+				g.Expect(err).NotTo(HaveOccurred())
+				if tc.expectedReplicas[i] == nil {
+					g.Expect(found).To(BeFalse())
+					continue
+				}
+				g.Expect(value).To(BeEquivalentTo(tc.expectedReplicas[i]))
+			}
+		})
+	}
+}
+
+func TestReconcileMachinePoolInfrastructureCredentials(t *testing.T) {
+	credentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "credentials"},
+	}
+
+	testCases := []struct {
+		name              string
+		spec              map[string]interface{}
+		expectedCondition *clusterv1.Condition
+	}{
+		{
+			name:              "credentials referenced by name are present",
+			spec:              map[string]interface{}{"identityRef": "credentials"},
+			expectedCondition: conditions.TrueCondition(expv1.InfrastructureCredentialsAvailableCondition),
+		},
+		{
+			name:              "credentials referenced by name and namespace are present",
+			spec:              map[string]interface{}{"identityRef": map[string]interface{}{"name": "credentials", "namespace": "default"}},
+			expectedCondition: conditions.TrueCondition(expv1.InfrastructureCredentialsAvailableCondition),
+		},
+		{
+			name: "credentials are missing",
+			spec: map[string]interface{}{"identityRef": map[string]interface{}{"name": "other-credentials"}},
+			expectedCondition: conditions.FalseCondition(expv1.InfrastructureCredentialsAvailableCondition, expv1.MissingCredentialsReason, clusterv1.ConditionSeverityError,
+				"Credentials secret default/other-credentials referenced by InfrastructureConfig \"infra-config1\" doesn't exist"),
+		},
+		{
+			name: "infrastructure without credentials reference",
+			spec: map[string]interface{}{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"spec": tc.spec,
+				},
+			}
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test"},
+			}
+
+			r := &MachinePoolReconciler{
+				Client:                fake.NewFakeClientWithScheme(scheme.Scheme, credentials),
+				Log:                   log.Log,
+				CredentialsSecretPath: "spec.identityRef",
+			}
+			g.Expect(r.reconcileInfrastructureCredentials(context.Background(), mp, infraConfig)).To(Succeed())
+
+			if tc.expectedCondition == nil {
+				g.Expect(conditions.Has(mp, expv1.InfrastructureCredentialsAvailableCondition)).To(BeFalse())
+				return
+			}
+			c := conditions.Get(mp, expv1.InfrastructureCredentialsAvailableCondition)
+			g.Expect(c).NotTo(BeNil())
+			g.Expect(c.Status).To(Equal(tc.expectedCondition.Status))
+			g.Expect(c.Reason).To(Equal(tc.expectedCondition.Reason))
+			g.Expect(c.Severity).To(Equal(tc.expectedCondition.Severity))
+			g.Expect(c.Message).To(Equal(tc.expectedCondition.Message))
+		})
+	}
+}
+
+func TestReconcileMachinePoolInfrastructureMaxSurge(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	infraGVK := schema.GroupVersionKind{
+		Group:   "infrastructure.cluster.x-k8s.io",
+		Version: "v1alpha3",
+		Kind:    "InfrastructureConfig",
+	}
+
+	intSurge := intstr.FromInt(4)
+	percentSurge := intstr.FromString("25%")
+	zeroSurge := intstr.FromInt(0)
+
+	testCases := []struct {
+		name             string
+		maxSurge         *intstr.IntOrString
+		expectedReplicas []int64
+	}{
+		{
+			name:             "without max surge the infrastructure is scaled at once",
+			expectedReplicas: []int64{10},
+		},
+		{
+			name:             "integer max surge",
+			maxSurge:         &intSurge,
+			expectedReplicas: []int64{6, 10},
+		},
+		{
+			name:             "percentage max surge is rounded up",
+			maxSurge:         &percentSurge,
+			expectedReplicas: []int64{5, 8, 10},
+		},
+		{
+			name:             "zero max surge still scales one instance at a time",
+			maxSurge:         &zeroSurge,
+			expectedReplicas: []int64{3, 4, 5, 6, 7, 8, 9, 10},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       infraGVK.Kind,
+					"apiVersion": infraGVK.GroupVersion().String(),
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{
+						"replicas":       int64(2),
+						"providerIDList": []interface{}{"aws://us-east-1/id-1"},
+					},
+					"status": map[string]interface{}{
+						"ready":    true,
+						"replicas": int64(2),
+					},
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Replicas:    pointer.Int32Ptr(10),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: infraGVK.GroupVersion().String(),
+								Kind:       infraGVK.Kind,
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+			}
+			if tc.maxSurge != nil {
+				machinepool.Spec.Strategy = &expv1.MachinePoolStrategy{
+					MachineDeploymentStrategy: clusterv1.MachineDeploymentStrategy{
+						RollingUpdate: &clusterv1.MachineRollingUpdateDeployment{MaxSurge: tc.maxSurge},
+					},
+				}
+			}
+
+			r := &MachinePoolReconciler{
+				Client:                      fake.NewFakeClientWithScheme(scheme.Scheme, machinepool, infraConfig),
+				Log:                         log.Log,
+				scheme:                      scheme.Scheme,
+				InfrastructureReplicasKinds: []schema.GroupVersionKind{infraGVK},
+			}
+
+			// Each step requests more instances and requeues until all the replicas are requested, the
+			// infrastructure provider reporting the requested instances in between.
+			for i, expected := range tc.expectedReplicas {
+				err := r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)
+				if i < len(tc.expectedReplicas)-1 {
+					var requeueErr *capierrors.RequeueAfterError
+					g.Expect(errors.As(err, &requeueErr)).To(BeTrue())
+				} else {
+					g.Expect(err).NotTo(HaveOccurred())
+				}
+
+				actual := &unstructured.Unstructured{}
+				actual.SetGroupVersionKind(infraGVK)
+				g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "infra-config1"}, actual)).To(Succeed())
+				replicas, _, err := unstructured.NestedInt64(actual.Object, "spec", "replicas")
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(replicas).To(Equal(expected))
+
+				g.Expect(unstructured.SetNestedField(actual.Object, replicas, "status", "replicas")).To(Succeed())
+				g.Expect(r.Client.Update(ctx, actual)).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestReconcileMachinePoolInfrastructureReplicasDrift(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	infraGVK := schema.GroupVersionKind{
+		Group:   "infrastructure.cluster.x-k8s.io",
+		Version: "v1alpha3",
+		Kind:    "InfrastructureConfig",
+	}
+
+	testCases := []struct {
+		name                        string
+		infraReplicas               int64
+		correctDrift                bool
+		infrastructureReplicasKinds []schema.GroupVersionKind
+		expectDrift                 bool
+		expectedInfraReplicas       int64
+	}{
+		{
+			name:                  "no drift is reported when the replicas agree",
+			infraReplicas:         3,
+			expectedInfraReplicas: 3,
+		},
+		{
+			name:                  "drift is reported when the replicas of the infrastructure object diverge",
+			infraReplicas:         5,
+			expectDrift:           true,
+			expectedInfraReplicas: 5,
+		},
+		{
+			name:                  "drift is corrected if configured",
+			infraReplicas:         5,
+			correctDrift:          true,
+			expectedInfraReplicas: 3,
+		},
+		{
+			name:                        "drift isn't reported for kinds the replicas are written to",
+			infraReplicas:               5,
+			infrastructureReplicasKinds: []schema.GroupVersionKind{infraGVK},
+			expectedInfraReplicas:       3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       infraGVK.Kind,
+					"apiVersion": infraGVK.GroupVersion().String(),
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{
+						"providerIDList": []interface{}{"aws://us-east-1/id-1"},
+						"replicas":       tc.infraReplicas,
+					},
+					"status": map[string]interface{}{
+						"ready":    true,
+						"replicas": int64(1),
+					},
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Replicas:    pointer.Int32Ptr(3),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: infraGVK.GroupVersion().String(),
+								Kind:       infraGVK.Kind,
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client:                      fake.NewFakeClientWithScheme(scheme.Scheme, machinepool, infraConfig),
+				Log:                         log.Log,
+				scheme:                      scheme.Scheme,
+				recorder:                    record.NewFakeRecorder(32),
+				InfrastructureReplicasKinds: tc.infrastructureReplicasKinds,
+				CorrectReplicasDrift:        tc.correctDrift,
+			}
+
+			g.Expect(r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)).To(Succeed())
+
+			if tc.expectDrift {
+				g.Expect(conditions.IsTrue(machinepool, expv1.SpecDriftCondition)).To(BeTrue())
+				g.Expect(conditions.GetReason(machinepool, expv1.SpecDriftCondition)).To(Equal(expv1.ReplicasDriftedReason))
+				g.Expect(conditions.GetMessage(machinepool, expv1.SpecDriftCondition)).To(Equal(`InfrastructureConfig "infra-config1" has 5 replicas, expected 3`))
+			} else {
+				g.Expect(conditions.Has(machinepool, expv1.SpecDriftCondition)).To(BeFalse())
+			}
+
+			actual := &unstructured.Unstructured{}
+			actual.SetGroupVersionKind(infraGVK)
+			g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "infra-config1"}, actual)).To(Succeed())
+			replicas, _, err := unstructured.NestedInt64(actual.Object, "spec", "replicas")
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(replicas).To(Equal(tc.expectedInfraReplicas))
+
+			// Drift is no longer reported once the replicas agree again.
+			if tc.expectDrift {
+				g.Expect(unstructured.SetNestedField(actual.Object, int64(3), "spec", "replicas")).To(Succeed())
+				g.Expect(r.Client.Update(ctx, actual)).To(Succeed())
+				g.Expect(r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)).To(Succeed())
+				g.Expect(conditions.Has(machinepool, expv1.SpecDriftCondition)).To(BeFalse())
+			}
+		})
+	}
+}
+
+func TestReconcileMachinePoolInfrastructureRecreateOnFailure(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	now := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name                string
+		recreateOnFailure   bool
+		failureReason       string
+		annotations         map[string]string
+		expectErr           bool
+		expectDeleted       bool
+		expectedRequeue     time.Duration
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:              "failed infrastructure is deleted to be recreated",
+			recreateOnFailure: true,
+			failureReason:     string(capierrors.InvalidConfigurationMachinePoolError),
+			expectDeleted:     true,
+			expectedRequeue:   externalReadyWait,
+			expectedAnnotations: map[string]string{
+				expv1.InfrastructureRecreatedAtAnnotation:      now.Format(time.RFC3339),
+				expv1.InfrastructureRecreateAttemptsAnnotation: "1",
+			},
+		},
+		{
+			name:              "failed infrastructure isn't deleted again before the backoff elapsed",
+			recreateOnFailure: true,
+			failureReason:     string(capierrors.InvalidConfigurationMachinePoolError),
+			annotations: map[string]string{
+				expv1.InfrastructureRecreatedAtAnnotation:      now.Add(-30 * time.Second).Format(time.RFC3339),
+				expv1.InfrastructureRecreateAttemptsAnnotation: "1",
+			},
+			expectedRequeue: 30 * time.Second,
+			expectedAnnotations: map[string]string{
+				expv1.InfrastructureRecreatedAtAnnotation:      now.Add(-30 * time.Second).Format(time.RFC3339),
+				expv1.InfrastructureRecreateAttemptsAnnotation: "1",
+			},
+		},
+		{
+			name:              "the backoff doubles with every deletion",
+			recreateOnFailure: true,
+			failureReason:     string(capierrors.InvalidConfigurationMachinePoolError),
+			annotations: map[string]string{
+				expv1.InfrastructureRecreatedAtAnnotation:      now.Add(-3 * time.Minute).Format(time.RFC3339),
+				expv1.InfrastructureRecreateAttemptsAnnotation: "3",
+			},
+			expectedRequeue: 1 * time.Minute,
+			expectedAnnotations: map[string]string{
+				expv1.InfrastructureRecreatedAtAnnotation:      now.Add(-3 * time.Minute).Format(time.RFC3339),
+				expv1.InfrastructureRecreateAttemptsAnnotation: "3",
+			},
+		},
+		{
+			name:              "failed infrastructure is deleted again once the backoff elapsed",
+			recreateOnFailure: true,
+			failureReason:     string(capierrors.InvalidConfigurationMachinePoolError),
+			annotations: map[string]string{
+				expv1.InfrastructureRecreatedAtAnnotation:      now.Add(-2 * time.Minute).Format(time.RFC3339),
+				expv1.InfrastructureRecreateAttemptsAnnotation: "2",
+			},
+			expectDeleted:   true,
+			expectedRequeue: externalReadyWait,
+			expectedAnnotations: map[string]string{
+				expv1.InfrastructureRecreatedAtAnnotation:      now.Format(time.RFC3339),
+				expv1.InfrastructureRecreateAttemptsAnnotation: "3",
+			},
+		},
+		{
+			name:          "failed infrastructure is left failed without the recreate on failure strategy",
+			failureReason: string(capierrors.InvalidConfigurationMachinePoolError),
+		},
+		{
+			name:              "infrastructure failing with a retryable reason isn't recreated",
+			recreateOnFailure: true,
+			failureReason:     "InstanceTerminated",
+			expectErr:         true,
+		},
+		{
+			name:              "the backoff is reset once the infrastructure is ready",
+			recreateOnFailure: true,
+			annotations: map[string]string{
+				expv1.InfrastructureRecreatedAtAnnotation:      now.Add(-30 * time.Second).Format(time.RFC3339),
+				expv1.InfrastructureRecreateAttemptsAnnotation: "1",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			failed := tc.failureReason != ""
+			status := map[string]interface{}{
+				"ready":    !failed,
+				"replicas": int64(1),
+			}
+			if failed {
+				status["failureReason"] = tc.failureReason
+				status["failureMessage"] = "instance was terminated"
+			}
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{
+						"providerIDList": []interface{}{"test://id-1"},
+					},
+					"status": status,
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "machinepool-test",
+					Namespace:   "default",
+					Annotations: tc.annotations,
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Replicas:    pointer.Int32Ptr(1),
+					Strategy:    &expv1.MachinePoolStrategy{RecreateOnFailure: tc.recreateOnFailure},
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+								Kind:       "InfrastructureConfig",
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+			}
+
+			recorder := record.NewFakeRecorder(32)
+			r := &MachinePoolReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme, machinepool, infraConfig),
+				Log:      log.Log,
+				scheme:   scheme.Scheme,
+				recorder: recorder,
+				clock:    clock.NewFakeClock(now),
+			}
+
+			err := r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)
+			if tc.expectedRequeue > 0 {
+				var requeueErr *capierrors.RequeueAfterError
+				g.Expect(errors.As(err, &requeueErr)).To(BeTrue())
+				g.Expect(requeueErr.GetRequeueAfter()).To(Equal(tc.expectedRequeue))
+			} else if tc.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			actual := &unstructured.Unstructured{}
+			actual.SetGroupVersionKind(infraConfig.GroupVersionKind())
+			err = r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "infra-config1"}, actual)
+			if tc.expectDeleted {
+				g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+				g.Expect(machinepool.Status.FailureReason).To(BeNil())
+				g.Expect(machinepool.Status.FailureMessage).To(BeNil())
+				g.Expect(recorder.Events).To(Receive(ContainSubstring("RecreatingInfrastructure")))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(machinepool.Status.FailureReason != nil).To(Equal(failed))
+				g.Expect(recorder.Events).NotTo(Receive())
+			}
+
+			for _, key := range []string{expv1.InfrastructureRecreatedAtAnnotation, expv1.InfrastructureRecreateAttemptsAnnotation} {
+				if value, ok := tc.expectedAnnotations[key]; ok {
+					g.Expect(machinepool.Annotations).To(HaveKeyWithValue(key, value))
+				} else {
+					g.Expect(machinepool.Annotations).NotTo(HaveKey(key))
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileMachinePoolReadyWait(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+		Status:     clusterv1.ClusterStatus{ControlPlaneInitialized: true},
+	}
+
+	testCases := []struct {
+		name                          string
+		bootstrapReadyWait            time.Duration
+		infrastructureReadyWait       time.Duration
+		expectedBootstrapRequeue      time.Duration
+		expectedInfrastructureRequeue time.Duration
+	}{
+		{
+			name:                          "both readiness types default to the external ready wait",
+			expectedBootstrapRequeue:      externalReadyWait,
+			expectedInfrastructureRequeue: externalReadyWait,
+		},
+		{
+			name:                          "each readiness type uses its own wait",
+			bootstrapReadyWait:            2 * time.Minute,
+			infrastructureReadyWait:       10 * time.Second,
+			expectedBootstrapRequeue:      2 * time.Minute,
+			expectedInfrastructureRequeue: 10 * time.Second,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			bootstrapConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "BootstrapConfig",
+					"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "bootstrap-config1",
+						"namespace": "default",
+					},
+					"spec":   map[string]interface{}{},
+					"status": map[string]interface{}{"ready": false},
+				},
+			}
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"spec":   map[string]interface{}{},
+					"status": map[string]interface{}{"ready": false},
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Replicas:    pointer.Int32Ptr(1),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							Bootstrap: clusterv1.Bootstrap{
+								ConfigRef: &corev1.ObjectReference{
+									APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+									Kind:       "BootstrapConfig",
+									Name:       "bootstrap-config1",
+								},
+							},
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+								Kind:       "InfrastructureConfig",
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client:                  fake.NewFakeClientWithScheme(scheme.Scheme, defaultCluster, machinepool, bootstrapConfig, infraConfig),
+				Log:                     log.Log,
+				scheme:                  scheme.Scheme,
+				BootstrapReadyWait:      tc.bootstrapReadyWait,
+				InfrastructureReadyWait: tc.infrastructureReadyWait,
+			}
+
+			var requeueErr *capierrors.RequeueAfterError
+			err := r.reconcileBootstrap(context.Background(), defaultCluster, machinepool)
+			g.Expect(errors.As(err, &requeueErr)).To(BeTrue())
+			g.Expect(requeueErr.GetRequeueAfter()).To(Equal(tc.expectedBootstrapRequeue))
+
+			err = r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)
+			g.Expect(errors.As(err, &requeueErr)).To(BeTrue())
+			g.Expect(requeueErr.GetRequeueAfter()).To(Equal(tc.expectedInfrastructureRequeue))
+		})
+	}
+}
+
+func TestReconcileMachinePoolInfrastructureMissingReplicas(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	testCases := []struct {
+		name                       string
+		treatMissingReplicasAsZero bool
+		replicas                   int32
+		infraStatus                map[string]interface{}
+		expectRequeue              bool
+	}{
+		{
+			name:          "missing replicas of a pool scaled to zero are waited for by default",
+			infraStatus:   map[string]interface{}{"ready": true},
+			expectRequeue: true,
+		},
+		{
+			name:                       "missing replicas of a pool scaled to zero are treated as zero",
+			treatMissingReplicasAsZero: true,
+			infraStatus:                map[string]interface{}{"ready": true},
+		},
+		{
+			name:                       "zero replicas of a pool scaled to zero are accepted",
+			treatMissingReplicasAsZero: true,
+			infraStatus:                map[string]interface{}{"ready": true, "replicas": int64(0)},
+		},
+		{
+			name:                       "missing replicas of a pool which isn't scaled to zero are waited for",
+			treatMissingReplicasAsZero: true,
+			replicas:                   1,
+			infraStatus:                map[string]interface{}{"ready": true},
+			expectRequeue:              true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"spec":   map[string]interface{}{},
+					"status": tc.infraStatus,
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Replicas:    pointer.Int32Ptr(tc.replicas),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+								Kind:       "InfrastructureConfig",
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+				Status: expv1.MachinePoolStatus{
+					// Replicas reported before the MachinePool was scaled to zero.
+					Replicas: 2,
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client:                     fake.NewFakeClientWithScheme(scheme.Scheme, defaultCluster, machinepool, infraConfig),
+				Log:                        log.Log,
+				scheme:                     scheme.Scheme,
+				TreatMissingReplicasAsZero: tc.treatMissingReplicasAsZero,
+			}
+
+			err := r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)
+			if tc.expectRequeue {
+				var requeueErr *capierrors.RequeueAfterError
+				g.Expect(errors.As(err, &requeueErr)).To(BeTrue())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(machinepool.Status.Replicas).To(BeZero())
+			g.Expect(machinepool.Status.InfrastructureReady).To(BeTrue())
+			g.Expect(machinepool.Spec.ProviderIDList).To(BeEmpty())
+		})
+	}
+}
+
+func TestReconcileMachinePoolInfrastructureStringReplicas(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	testCases := []struct {
+		name             string
+		statusReplicas   interface{}
+		expectedReplicas int32
+		expectError      bool
+	}{
+		{
+			name:             "numeric replicas are read as is",
+			statusReplicas:   int64(2),
+			expectedReplicas: 2,
+		},
+		{
+			name:             "numeric string replicas are coerced",
+			statusReplicas:   "2",
+			expectedReplicas: 2,
+		},
+		{
+			name:             "numeric string replicas surrounded by spaces are coerced",
+			statusReplicas:   " 2 ",
+			expectedReplicas: 2,
+		},
+		{
+			name:           "non numeric string replicas fail the reconciliation",
+			statusReplicas: "two",
+			expectError:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{
+						"providerIDList": []interface{}{"aws://us-east-1/id-1", "aws://us-east-1/id-2"},
+					},
+					"status": map[string]interface{}{
+						"ready":    true,
+						"replicas": tc.statusReplicas,
+					},
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Replicas:    pointer.Int32Ptr(2),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+								Kind:       "InfrastructureConfig",
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, defaultCluster, machinepool, infraConfig),
+				Log:    log.Log,
+				scheme: scheme.Scheme,
+			}
+
+			err := r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)
+			if tc.expectError {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring("failed to retrieve replicas"))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(machinepool.Status.Replicas).To(Equal(tc.expectedReplicas))
+		})
+	}
+}
+
+func TestReconcileMachinePoolInfrastructureProvisioningRequest(t *testing.T) {
+	g := NewWithT(t)
+
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	infraConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureConfig",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "infra-config1",
+				"namespace": "default",
+			},
+			"spec": map[string]interface{}{
+				"providerIDList": []interface{}{"aws://us-east-1/id-1"},
+			},
+			"status": map[string]interface{}{
+				"ready":    true,
+				"replicas": int64(1),
+			},
+		},
+	}
+
+	machinepool := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machinepool-test",
+			Namespace: "default",
+		},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName: defaultCluster.Name,
+			Replicas:    pointer.Int32Ptr(1),
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureConfig",
+						Name:       "infra-config1",
+					},
+				},
+			},
+		},
+	}
+
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, defaultCluster, machinepool, infraConfig),
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	getInfraConfig := func() *unstructured.Unstructured {
+		actual := &unstructured.Unstructured{}
+		actual.SetGroupVersionKind(infraConfig.GroupVersionKind())
+		g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "infra-config1"}, actual)).To(Succeed())
+		return actual
+	}
+
+	// Provisioning isn't requested by default.
+	g.Expect(r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)).To(Succeed())
+	g.Expect(machinepool.Status.InfrastructureReady).To(BeTrue())
+	g.Expect(getInfraConfig().GetAnnotations()).NotTo(HaveKey(expv1.ProvisionAnnotation))
+
+	// Provisioning is requested, and the infrastructure isn't ready until the provider acknowledges the request,
+	// even if it reports being ready.
+	r.RequestProvisioning = true
+	for i := 0; i < 2; i++ {
+		var requeueErr *capierrors.RequeueAfterError
+		err := r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)
+		g.Expect(errors.As(err, &requeueErr)).To(BeTrue())
+		g.Expect(machinepool.Status.InfrastructureReady).To(BeFalse())
+		g.Expect(getInfraConfig().GetAnnotations()).To(HaveKeyWithValue(expv1.ProvisionAnnotation, expv1.ProvisionRequested))
+	}
+
+	// The provider acknowledges the request.
+	acknowledged := getInfraConfig()
+	acknowledged.SetAnnotations(map[string]string{expv1.ProvisionAnnotation: expv1.ProvisionProvisioned})
+	g.Expect(r.Client.Update(ctx, acknowledged)).To(Succeed())
+
+	g.Expect(r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)).To(Succeed())
+	g.Expect(machinepool.Status.InfrastructureReady).To(BeTrue())
+	g.Expect(machinepool.Spec.ProviderIDList).To(Equal([]string{"aws://us-east-1/id-1"}))
+	g.Expect(getInfraConfig().GetAnnotations()).To(HaveKeyWithValue(expv1.ProvisionAnnotation, expv1.ProvisionProvisioned))
+}
+
+func TestReconcileMachinePoolInfrastructureConditions(t *testing.T) {
+	infraConditions := []interface{}{
+		map[string]interface{}{
+			"type":               "AutoScalingGroupReady",
+			"status":             "False",
+			"severity":           "Warning",
+			"reason":             "ASGProvisionFailed",
+			"message":            "failed to provision instances",
+			"lastTransitionTime": "2020-06-01T12:00:00Z",
+		},
+		map[string]interface{}{
+			"type":               "Ready",
+			"status":             "False",
+			"severity":           "Warning",
+			"reason":             "ASGProvisionFailed",
+			"lastTransitionTime": "2020-06-01T12:00:00Z",
+		},
+		map[string]interface{}{
+			"type":               "LaunchTemplateReady",
+			"status":             "True",
+			"lastTransitionTime": "2020-06-01T11:00:00Z",
+		},
+	}
+
+	testCases := []struct {
+		name               string
+		conditionTypes     []clusterv1.ConditionType
+		status             map[string]interface{}
+		expectedConditions clusterv1.Conditions
+	}{
+		{
+			name:   "only the Ready condition is copied by default",
+			status: map[string]interface{}{"conditions": infraConditions},
+			expectedConditions: clusterv1.Conditions{
+				{
+					Type:               clusterv1.ReadyCondition,
+					Status:             corev1.ConditionFalse,
+					Severity:           clusterv1.ConditionSeverityWarning,
+					Reason:             "ASGProvisionFailed",
+					LastTransitionTime: metav1.NewTime(time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)),
+				},
+			},
+		},
+		{
+			name:           "configured condition types are copied in order",
+			conditionTypes: []clusterv1.ConditionType{"LaunchTemplateReady", "AutoScalingGroupReady", "InstancesReady"},
+			status:         map[string]interface{}{"conditions": infraConditions},
+			expectedConditions: clusterv1.Conditions{
+				{
+					Type:               "LaunchTemplateReady",
+					Status:             corev1.ConditionTrue,
+					LastTransitionTime: metav1.NewTime(time.Date(2020, time.June, 1, 11, 0, 0, 0, time.UTC)),
+				},
+				{
+					Type:               "AutoScalingGroupReady",
+					Status:             corev1.ConditionFalse,
+					Severity:           clusterv1.ConditionSeverityWarning,
+					Reason:             "ASGProvisionFailed",
+					Message:            "failed to provision instances",
+					LastTransitionTime: metav1.NewTime(time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)),
+				},
+			},
+		},
+		{
+			name:   "infrastructure without conditions",
+			status: map[string]interface{}{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"status": tc.status,
+				},
+			}
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Name: "machinepool-test", Namespace: "default"},
+				Status: expv1.MachinePoolStatus{
+					InfrastructureConditions: clusterv1.Conditions{{Type: "Stale", Status: corev1.ConditionTrue}},
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Log:                          log.Log,
+				InfrastructureConditionTypes: tc.conditionTypes,
+			}
+
+			g.Expect(r.reconcileInfrastructureConditions(machinepool, infraConfig)).To(Succeed())
+			if tc.expectedConditions == nil {
+				g.Expect(machinepool.Status.InfrastructureConditions).To(BeEmpty())
+				return
+			}
+			g.Expect(machinepool.Status.InfrastructureConditions).To(HaveLen(len(tc.expectedConditions)))
+			for i := range tc.expectedConditions {
+				actual := machinepool.Status.InfrastructureConditions[i]
+				g.Expect(actual.Type).To(Equal(tc.expectedConditions[i].Type))
+				g.Expect(actual.Status).To(Equal(tc.expectedConditions[i].Status))
+				g.Expect(actual.Severity).To(Equal(tc.expectedConditions[i].Severity))
+				g.Expect(actual.Reason).To(Equal(tc.expectedConditions[i].Reason))
+				g.Expect(actual.Message).To(Equal(tc.expectedConditions[i].Message))
+				g.Expect(actual.LastTransitionTime.Equal(&tc.expectedConditions[i].LastTransitionTime)).To(BeTrue())
+			}
+		})
+	}
+}
+
+func TestReconcileMachinePoolInstanceStates(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	testCases := []struct {
+		name                   string
+		instanceStates         map[string]interface{}
+		expectedInstanceStates map[string]string
+	}{
+		{
+			name: "instance states reported by the provider are surfaced",
+			instanceStates: map[string]interface{}{
+				"aws://us-east-1/id-1": "running",
+				"aws://us-east-1/id-2": "stopping",
+			},
+			expectedInstanceStates: map[string]string{
+				"aws://us-east-1/id-1": "running",
+				"aws://us-east-1/id-2": "stopping",
+			},
+		},
+		{
+			name:                   "instance states are optional",
+			expectedInstanceStates: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			status := map[string]interface{}{
+				"ready":    true,
+				"replicas": int64(2),
+			}
+			if tc.instanceStates != nil {
+				status["instanceStates"] = tc.instanceStates
+			}
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{
+						"providerIDList": []interface{}{"aws://us-east-1/id-1", "aws://us-east-1/id-2"},
+					},
+					"status": status,
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Replicas:    pointer.Int32Ptr(2),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+								Kind:       "InfrastructureConfig",
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+				Status: expv1.MachinePoolStatus{
+					InstanceStates: map[string]string{"aws://us-east-1/id-0": "terminated"},
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, machinepool, infraConfig),
+				Log:    log.Log,
+				scheme: scheme.Scheme,
+			}
+
+			g.Expect(r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)).To(Succeed())
+			g.Expect(machinepool.Status.InstanceStates).To(Equal(tc.expectedInstanceStates))
+		})
+	}
+}
+
+func TestReconcileMachinePoolExternalAdoption(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	bootstrapRef := &corev1.ObjectReference{
+		APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+		Kind:       "BootstrapConfig",
+		Name:       "bootstrap-config1",
+	}
+
+	otherControllerRef := metav1.OwnerReference{
+		APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+		Kind:       "BootstrapConfigOwner",
+		Name:       "other-owner",
+		UID:        "other-uid",
+		Controller: pointer.BoolPtr(true),
+	}
+
+	testCases := []struct {
+		name            string
+		ownerReferences []interface{}
+		adopt           bool
+		expectError     bool
+	}{
+		{
+			name: "unowned external objects are adopted",
+		},
+		{
+			name: "external objects controlled by the MachinePool are kept",
+			ownerReferences: []interface{}{
+				map[string]interface{}{
+					"apiVersion": expv1.GroupVersion.String(),
+					"kind":       "MachinePool",
+					"name":       "machinepool-test",
+					"uid":        "previous-uid",
+					"controller": true,
+				},
+			},
+		},
+		{
+			name: "external objects controlled by another object are not adopted by default",
+			ownerReferences: []interface{}{
+				map[string]interface{}{
+					"apiVersion": otherControllerRef.APIVersion,
+					"kind":       otherControllerRef.Kind,
+					"name":       otherControllerRef.Name,
+					"uid":        string(otherControllerRef.UID),
+					"controller": true,
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "external objects controlled by another object are adopted if configured",
+			ownerReferences: []interface{}{
+				map[string]interface{}{
+					"apiVersion": otherControllerRef.APIVersion,
+					"kind":       otherControllerRef.Kind,
+					"name":       otherControllerRef.Name,
+					"uid":        string(otherControllerRef.UID),
+					"controller": true,
+				},
+			},
+			adopt: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			metadata := map[string]interface{}{
+				"name":      "bootstrap-config1",
+				"namespace": "default",
+			}
+			if tc.ownerReferences != nil {
+				metadata["ownerReferences"] = tc.ownerReferences
+			}
+			bootstrapConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "BootstrapConfig",
+					"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+					"metadata":   metadata,
+					"spec":       map[string]interface{}{},
+					"status":     map[string]interface{}{},
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+					UID:       "machinepool-uid",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client:                         fake.NewFakeClientWithScheme(scheme.Scheme, machinepool, bootstrapConfig),
+				Log:                            log.Log,
+				scheme:                         scheme.Scheme,
+				AdoptControlledExternalObjects: tc.adopt,
+			}
+
+			_, err := r.reconcileExternal(context.Background(), defaultCluster, machinepool, bootstrapRef)
+
+			actual := &unstructured.Unstructured{}
+			actual.SetGroupVersionKind(bootstrapConfig.GroupVersionKind())
+			g.Expect(r.Client.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "bootstrap-config1"}, actual)).To(Succeed())
+			controllerRef := metav1.GetControllerOf(actual)
+			g.Expect(controllerRef).NotTo(BeNil())
+
+			if tc.expectError {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(controllerRef.Name).To(Equal(otherControllerRef.Name))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(actual.GetOwnerReferences()).To(HaveLen(1))
+			g.Expect(controllerRef.Kind).To(Equal("MachinePool"))
+			g.Expect(controllerRef.Name).To(Equal(machinepool.Name))
+			g.Expect(controllerRef.UID).To(Equal(machinepool.UID))
+		})
+	}
+}
+
+func TestReconcileMachinePoolReplicaBreakdown(t *testing.T) {
+	nodeRefs := func(n int) []corev1.ObjectReference {
+		refs := make([]corev1.ObjectReference, n)
+		for i := range refs {
+			refs[i] = corev1.ObjectReference{Name: fmt.Sprintf("node-%d", i)}
+		}
+		return refs
+	}
+
+	testCases := []struct {
+		name     string
+		status   expv1.MachinePoolStatus
+		expected expv1.MachinePoolReplicaBreakdown
+	}{
+		{
+			name:     "replicas without nodes are provisioning",
+			status:   expv1.MachinePoolStatus{Replicas: 3},
+			expected: expv1.MachinePoolReplicaBreakdown{Provisioning: 3},
+		},
+		{
+			name: "replicas are counted by node readiness",
+			status: expv1.MachinePoolStatus{
+				Replicas:      5,
+				ReadyReplicas: 2,
+				NodeRefs:      nodeRefs(3),
+			},
+			expected: expv1.MachinePoolReplicaBreakdown{Provisioning: 2, Ready: 2, NotReady: 1},
+		},
+		{
+			name: "node refs are counted in count only mode",
+			status: expv1.MachinePoolStatus{
+				Replicas:      3,
+				ReadyReplicas: 1,
+				NodeRefsCount: 3,
+			},
+			expected: expv1.MachinePoolReplicaBreakdown{Ready: 1, NotReady: 2},
+		},
+		{
+			name: "instances reported as being deleted are deleting",
+			status: expv1.MachinePoolStatus{
+				Replicas:      4,
+				ReadyReplicas: 3,
+				NodeRefs:      nodeRefs(3),
+				InstanceStates: map[string]string{
+					"aws://us-east-1/id-1": "running",
+					"aws://us-east-1/id-2": "shutting-down",
+					"aws://us-east-1/id-3": "Terminating",
+					"aws://us-east-1/id-4": "pending",
+				},
+			},
+			expected: expv1.MachinePoolReplicaBreakdown{Deleting: 2, Ready: 2},
+		},
+		{
+			name: "counts never exceed the replicas",
+			status: expv1.MachinePoolStatus{
+				Replicas:      2,
+				ReadyReplicas: 3,
+				NodeRefs:      nodeRefs(4),
+			},
+			expected: expv1.MachinePoolReplicaBreakdown{Ready: 2},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{Status: tc.status}
+			breakdown := replicaBreakdown(mp)
+			g.Expect(*breakdown).To(Equal(tc.expected))
+			g.Expect(breakdown.Provisioning + breakdown.Ready + breakdown.NotReady + breakdown.Deleting).To(Equal(mp.Status.Replicas))
+		})
+	}
+}
+
+func TestMachinePoolScaleDownCandidates(t *testing.T) {
+	providerIDList := []string{
+		"aws://us-east-1/id-1",
+		"aws://us-east-1/id-2",
+		"aws://us-east-1/id-3",
+		"aws://us-east-1/id-4",
+	}
+
+	testCases := []struct {
+		name        string
+		utilization map[string]float64
+		expected    []string
+	}{
+		{
+			name: "no utilization reported",
+		},
+		{
+			name: "instances are ranked from the least to the most utilized",
+			utilization: map[string]float64{
+				"aws://us-east-1/id-1": 0.75,
+				"aws://us-east-1/id-2": 0.05,
+				"aws://us-east-1/id-3": 1,
+				"aws://us-east-1/id-4": 0.3,
+			},
+			expected: []string{"aws://us-east-1/id-2", "aws://us-east-1/id-4", "aws://us-east-1/id-1", "aws://us-east-1/id-3"},
+		},
+		{
+			name: "instances with the same utilization are ranked by provider ID",
+			utilization: map[string]float64{
+				"aws://us-east-1/id-3": 0,
+				"aws://us-east-1/id-1": 0.5,
+				"aws://us-east-1/id-2": 0,
+			},
+			expected: []string{"aws://us-east-1/id-2", "aws://us-east-1/id-3", "aws://us-east-1/id-1"},
+		},
+		{
+			name: "instances which aren't part of the pool are left out",
+			utilization: map[string]float64{
+				"aws://us-east-1/id-4": 0.2,
+				"aws://us-east-1/id-5": 0.1,
+			},
+			expected: []string{"aws://us-east-1/id-4"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(scaleDownCandidates(providerIDList, tc.utilization)).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestReconcileMachinePoolInfrastructureScaleDownCandidates(t *testing.T) {
+	g := NewWithT(t)
+
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	infraConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureConfig",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "infra-config1",
+				"namespace": "default",
+			},
+			"spec": map[string]interface{}{
+				"providerIDList": []interface{}{"aws://us-east-1/id-1", "aws://us-east-1/id-2"},
+			},
+			"status": map[string]interface{}{
+				"ready":    true,
+				"replicas": int64(2),
+				"instanceUtilization": map[string]interface{}{
+					"aws://us-east-1/id-1": int64(1),
+					"aws://us-east-1/id-2": 0.25,
+				},
+			},
+		},
+	}
+
+	machinepool := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machinepool-test",
+			Namespace: "default",
+		},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName: defaultCluster.Name,
+			Replicas:    pointer.Int32Ptr(2),
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureConfig",
+						Name:       "infra-config1",
+					},
+				},
+			},
+		},
+	}
+
+	r := &MachinePoolReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, defaultCluster, machinepool, infraConfig),
+		Log:    log.Log,
+		scheme: scheme.Scheme,
+	}
+
+	g.Expect(r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)).To(Succeed())
+	g.Expect(machinepool.Status.ScaleDownCandidates).To(Equal([]string{"aws://us-east-1/id-2", "aws://us-east-1/id-1"}))
+}
+
+func TestReconcileMachinePoolInfrastructureTerminalFailure(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	testCases := []struct {
+		name          string
+		failureReason string
+		expectRequeue bool
+	}{
+		{
+			name:          "terminal failure reasons aren't requeued",
+			failureReason: "InvalidConfiguration",
+		},
+		{
+			name:          "transient failure reasons are requeued",
+			failureReason: "CreateError",
+			expectRequeue: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{},
+					"status": map[string]interface{}{
+						"ready":          false,
+						"failureReason":  tc.failureReason,
+						"failureMessage": "instance type m5.huge doesn't exist",
+					},
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Replicas:    pointer.Int32Ptr(1),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+								Kind:       "InfrastructureConfig",
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme, defaultCluster, machinepool, infraConfig),
+				Log:      log.Log,
+				scheme:   scheme.Scheme,
+				recorder: record.NewFakeRecorder(32),
+			}
+
+			err := r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)
+			g.Expect(machinepool.Status.FailureReason).NotTo(BeNil())
+			g.Expect(string(*machinepool.Status.FailureReason)).To(Equal(tc.failureReason))
+			g.Expect(machinepool.Status.FailureMessage).NotTo(BeNil())
+			g.Expect(*machinepool.Status.FailureMessage).To(ContainSubstring("instance type m5.huge doesn't exist"))
+			g.Expect(machinepool.Status.InfrastructureReady).To(BeFalse())
+
+			if tc.expectRequeue {
+				var requeueErr *capierrors.RequeueAfterError
+				g.Expect(errors.As(err, &requeueErr)).To(BeTrue())
+				g.Expect(*machinepool.Status.FailureMessage).NotTo(ContainSubstring("terminal"))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(*machinepool.Status.FailureMessage).To(ContainSubstring(`terminal reason "InvalidConfiguration"`))
+			}
+
+			g.Expect(r.reconcilePhase(context.Background(), machinepool)).To(Succeed())
+			g.Expect(machinepool.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhaseFailed))
+		})
+	}
+}
+
+func TestReconcileMachinePoolInfrastructureClusterLabel(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	testCases := []struct {
+		name        string
+		labels      map[string]interface{}
+		expectError bool
+	}{
+		{
+			name: "infrastructure objects without a cluster label are labeled",
+		},
+		{
+			name:   "infrastructure objects labeled for the cluster of the pool are adopted",
+			labels: map[string]interface{}{clusterv1.ClusterLabelName: "test-cluster"},
+		},
+		{
+			name:        "infrastructure objects labeled for another cluster aren't adopted",
+			labels:      map[string]interface{}{clusterv1.ClusterLabelName: "other-cluster"},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			metadata := map[string]interface{}{
+				"name":      "infra-config1",
+				"namespace": "default",
+			}
+			if tc.labels != nil {
+				metadata["labels"] = tc.labels
+			}
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata":   metadata,
+					"spec": map[string]interface{}{
+						"providerIDList": []interface{}{"aws://us-east-1/id-1"},
+					},
+					"status": map[string]interface{}{
+						"ready":    true,
+						"replicas": int64(1),
+					},
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Replicas:    pointer.Int32Ptr(1),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+								Kind:       "InfrastructureConfig",
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, defaultCluster, machinepool, infraConfig),
+				Log:    log.Log,
+				scheme: scheme.Scheme,
+			}
+
+			err := r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)
+
+			actual := &unstructured.Unstructured{}
+			actual.SetGroupVersionKind(infraConfig.GroupVersionKind())
+			g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "infra-config1"}, actual)).To(Succeed())
+
+			if tc.expectError {
+				g.Expect(err).To(MatchError(ContainSubstring(`belongs to Cluster "other-cluster", expected Cluster "test-cluster"`)))
+				g.Expect(actual.GetLabels()).To(HaveKeyWithValue(clusterv1.ClusterLabelName, "other-cluster"))
+				g.Expect(metav1.GetControllerOf(actual)).To(BeNil())
+				g.Expect(machinepool.Status.InfrastructureReady).To(BeFalse())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(actual.GetLabels()).To(HaveKeyWithValue(clusterv1.ClusterLabelName, "test-cluster"))
+			g.Expect(metav1.GetControllerOf(actual)).NotTo(BeNil())
+			g.Expect(machinepool.Status.InfrastructureReady).To(BeTrue())
+		})
+	}
+}
+
+func TestReconcileMachinePoolInfrastructureNetworkFields(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	testCases := []struct {
+		name                string
+		templateAnnotations map[string]string
+		spec                map[string]interface{}
+		expectedSpec        map[string]interface{}
+	}{
+		{
+			name: "infrastructure objects are left untouched without network annotations on the template",
+			spec: map[string]interface{}{
+				"subnetIDs": []interface{}{"subnet-provider"},
+			},
+			expectedSpec: map[string]interface{}{
+				"subnetIDs": []interface{}{"subnet-provider"},
+			},
+		},
+		{
+			name: "network annotations on the template are written to the infrastructure object",
+			templateAnnotations: map[string]string{
+				expv1.SubnetIDsAnnotation: "subnet-a, subnet-b",
+				expv1.PodCIDRAnnotation:   "192.168.0.0/16",
+			},
+			spec: map[string]interface{}{},
+			expectedSpec: map[string]interface{}{
+				"subnetIDs": []interface{}{"subnet-a", "subnet-b"},
+				"podCIDR":   "192.168.0.0/16",
+			},
+		},
+		{
+			name: "network fields of the infrastructure object are overwritten from the template",
+			templateAnnotations: map[string]string{
+				expv1.SubnetIDsAnnotation: "subnet-a",
+			},
+			spec: map[string]interface{}{
+				"subnetIDs": []interface{}{"subnet-provider"},
+				"podCIDR":   "10.0.0.0/16",
+			},
+			expectedSpec: map[string]interface{}{
+				"subnetIDs": []interface{}{"subnet-a"},
+				"podCIDR":   "10.0.0.0/16",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			tc.spec["providerIDList"] = []interface{}{"aws://us-east-1/id-1"}
+			tc.expectedSpec["providerIDList"] = []interface{}{"aws://us-east-1/id-1"}
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"spec": tc.spec,
+					"status": map[string]interface{}{
+						"ready":    true,
+						"replicas": int64(1),
+					},
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Replicas:    pointer.Int32Ptr(1),
+					Template: clusterv1.MachineTemplateSpec{
+						ObjectMeta: clusterv1.ObjectMeta{
+							Annotations: tc.templateAnnotations,
+						},
+						Spec: clusterv1.MachineSpec{
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+								Kind:       "InfrastructureConfig",
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, defaultCluster, machinepool, infraConfig),
+				Log:    log.Log,
+				scheme: scheme.Scheme,
+			}
+
+			g.Expect(r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)).To(Succeed())
+
+			actual := &unstructured.Unstructured{}
+			actual.SetGroupVersionKind(infraConfig.GroupVersionKind())
+			g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "infra-config1"}, actual)).To(Succeed())
+			spec, _, err := unstructured.NestedMap(actual.Object, "spec")
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(spec).To(Equal(tc.expectedSpec))
+		})
+	}
+}
+
+func TestReconcileMachinePoolInfrastructureBootstrapFormat(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	testCases := []struct {
+		name           string
+		labels         map[string]string
+		value          string
+		expectedFormat string
+	}{
+		{
+			name:           "cloud-init bootstrap data is detected",
+			value:          "#cloud-config\nruncmd:\n- kubeadm join\n",
+			expectedFormat: expv1.BootstrapFormatCloudConfig,
+		},
+		{
+			name:           "ignition bootstrap data is detected",
+			value:          `{"ignition": {"version": "2.3.0"}, "storage": {}}`,
+			expectedFormat: expv1.BootstrapFormatIgnition,
+		},
+		{
+			name:           "the format label set by the bootstrap provider takes precedence",
+			labels:         map[string]string{expv1.BootstrapFormatAnnotation: expv1.BootstrapFormatIgnition},
+			value:          "#cloud-config\n",
+			expectedFormat: expv1.BootstrapFormatIgnition,
+		},
+		{
+			name:  "unknown bootstrap data formats aren't set",
+			value: "#!/bin/bash\nkubeadm join\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "default",
+					Name:      "bootstrap-data",
+					Labels:    tc.labels,
+				},
+				Data: map[string][]byte{"value": []byte(tc.value)},
+			}
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{
+						"providerIDList": []interface{}{"aws://us-east-1/id-1"},
+					},
+					"status": map[string]interface{}{
+						"ready":    true,
+						"replicas": int64(1),
+					},
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Replicas:    pointer.Int32Ptr(1),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							Bootstrap: clusterv1.Bootstrap{
+								DataSecretName: pointer.StringPtr("bootstrap-data"),
+							},
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+								Kind:       "InfrastructureConfig",
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, defaultCluster, machinepool, infraConfig, secret),
+				Log:    log.Log,
+				scheme: scheme.Scheme,
+			}
+
+			g.Expect(r.reconcileInfrastructure(context.Background(), defaultCluster, machinepool)).To(Succeed())
+
+			actual := &unstructured.Unstructured{}
+			actual.SetGroupVersionKind(infraConfig.GroupVersionKind())
+			g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "infra-config1"}, actual)).To(Succeed())
+			if tc.expectedFormat == "" {
+				g.Expect(actual.GetAnnotations()).NotTo(HaveKey(expv1.BootstrapFormatAnnotation))
+				return
+			}
+			g.Expect(actual.GetAnnotations()).To(HaveKeyWithValue(expv1.BootstrapFormatAnnotation, tc.expectedFormat))
+		})
+	}
+}
+
+func TestReconcileMachinePoolBootstrapDataSecretRotation(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+		Status:     clusterv1.ClusterStatus{ControlPlaneInitialized: true},
+	}
+
+	testCases := []struct {
+		name               string
+		providerSecretName string
+		steadyState        bool
+		expectedSecretName string
+		expectRotated      bool
+	}{
+		{
+			name:               "bound secret published by the provider is kept",
+			providerSecretName: "secret-data-v1",
+			expectedSecretName: "secret-data-v1",
+		},
+		{
+			name:               "secret bumped by the provider is bound",
+			providerSecretName: "secret-data-v2",
+			expectedSecretName: "secret-data-v2",
+			expectRotated:      true,
+		},
+		{
+			name:               "secret bumped by the provider is bound at steady state",
+			providerSecretName: "secret-data-v2",
+			steadyState:        true,
+			expectedSecretName: "secret-data-v2",
+			expectRotated:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			bootstrapConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "BootstrapConfig",
+					"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "bootstrap-config1",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{},
+					"status": map[string]interface{}{
+						"ready":          true,
+						"dataSecretName": tc.providerSecretName,
+					},
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+					Replicas:    pointer.Int32Ptr(1),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							Bootstrap: clusterv1.Bootstrap{
+								ConfigRef: &corev1.ObjectReference{
+									APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+									Kind:       "BootstrapConfig",
+									Name:       "bootstrap-config1",
+								},
+								DataSecretName: pointer.StringPtr("secret-data-v1"),
+							},
+						},
+					},
+				},
+				Status: expv1.MachinePoolStatus{
+					BootstrapReady: true,
+				},
+			}
+
+			recorder := record.NewFakeRecorder(32)
+			r := &MachinePoolReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme, defaultCluster, machinepool, bootstrapConfig),
+				Log:      log.Log,
+				scheme:   scheme.Scheme,
+				recorder: recorder,
+			}
+
+			if tc.steadyState {
+				g.Expect(r.reconcileBootstrapRotation(context.Background(), defaultCluster, machinepool)).To(Succeed())
+			} else {
+				g.Expect(r.reconcileBootstrap(context.Background(), defaultCluster, machinepool)).To(Succeed())
+			}
+			g.Expect(*machinepool.Spec.Template.Spec.Bootstrap.DataSecretName).To(Equal(tc.expectedSecretName))
+			g.Expect(machinepool.Status.BootstrapReady).To(BeTrue())
+
+			if tc.expectRotated {
+				g.Expect(recorder.Events).To(Receive(ContainSubstring("BootstrapDataSecretRotated")))
+			} else {
+				g.Expect(recorder.Events).NotTo(Receive())
+			}
+		})
+	}
+}
+
+func TestReconcileMachinePoolInfrastructureTemplate(t *testing.T) {
+	g := NewWithT(t)
+
+	infraGVK := schema.GroupVersionKind{
+		Group:   "infrastructure.cluster.x-k8s.io",
+		Version: "v1alpha3",
+		Kind:    "InfrastructureConfig",
+	}
+
+	infraTemplate := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       infraGVK.Kind + "Template",
+			"apiVersion": infraGVK.GroupVersion().String(),
+			"metadata": map[string]interface{}{
+				"name":      "infra-config-template",
+				"namespace": "default",
+			},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"instanceType": "m5.large",
+					},
+				},
+			},
+		},
+	}
+
+	machinepool := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machinepool-test",
+			Namespace: "default",
+			UID:       "machinepool-uid",
+		},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName: "test-cluster",
+			InfrastructureTemplateRef: &corev1.ObjectReference{
+				APIVersion: infraGVK.GroupVersion().String(),
+				Kind:       infraGVK.Kind + "Template",
+				Name:       "infra-config-template",
+				Namespace:  "default",
+			},
+		},
+	}
+
+	c := &createCountingClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme, machinepool, infraTemplate)}
+	recorder := record.NewFakeRecorder(32)
+	r := &MachinePoolReconciler{
+		Client:   c,
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: recorder,
+	}
+
+	// The first reconciliation clones the template and points the MachinePool to the clone.
+	g.Expect(r.reconcileInfrastructureTemplate(ctx, machinepool)).To(Succeed())
+
+	infraRef := machinepool.Spec.Template.Spec.InfrastructureRef
+	g.Expect(infraRef.Kind).To(Equal(infraGVK.Kind))
+	g.Expect(infraRef.Name).To(HavePrefix("infra-config-template-"))
+	g.Expect(machinepool.Annotations).To(HaveKeyWithValue(expv1.GeneratedInfrastructureNameAnnotation, infraRef.Name))
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("InfrastructureCloned")))
+
+	clone := &unstructured.Unstructured{}
+	clone.SetGroupVersionKind(infraGVK)
+	g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: infraRef.Name}, clone)).To(Succeed())
+	g.Expect(clone.GetLabels()).To(HaveKeyWithValue(expv1.MachinePoolNameLabel, machinepool.Name))
+	g.Expect(clone.GetLabels()).To(HaveKeyWithValue(clusterv1.ClusterLabelName, "test-cluster"))
+	g.Expect(clone.GetOwnerReferences()).To(HaveLen(1))
+	g.Expect(clone.GetOwnerReferences()[0].UID).To(Equal(machinepool.UID))
+	instanceType, _, err := unstructured.NestedString(clone.Object, "spec", "instanceType")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instanceType).To(Equal("m5.large"))
+
+	// Later reconciliations reconcile the clone instead of cloning the template again.
+	g.Expect(r.reconcileInfrastructureTemplate(ctx, machinepool)).To(Succeed())
+	g.Expect(machinepool.Spec.Template.Spec.InfrastructureRef).To(Equal(infraRef))
+	g.Expect(recorder.Events).NotTo(Receive())
+
+	// The clone reconciled is the one recorded in the annotation, and no other was created.
+	g.Expect(machinepool.Annotations).To(HaveKeyWithValue(expv1.GeneratedInfrastructureNameAnnotation, infraRef.Name))
+	g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: infraRef.Name}, clone)).To(Succeed())
+	g.Expect(c.created).To(Equal(1))
+}
+
+// createCountingClient counts the objects created through it.
+type createCountingClient struct {
+	client.Client
+	created int
+}
+
+func (c *createCountingClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	if err := c.Client.Create(ctx, obj, opts...); err != nil {
+		return err
+	}
+	c.created++
+	return nil
+}
+
+func TestReconcileMachinePoolCapacity(t *testing.T) {
+	testCases := []struct {
+		name             string
+		instanceCapacity interface{}
+		expected         corev1.ResourceList
+	}{
+		{
+			name: "capacity isn't reported",
+		},
+		{
+			name: "capacity of the instances is aggregated",
+			instanceCapacity: map[string]interface{}{
+				"aws://us-east-1/id-1": map[string]interface{}{"cpu": "2", "memory": "8Gi"},
+				"aws://us-east-1/id-2": map[string]interface{}{"cpu": "4", "memory": "16Gi", "nvidia.com/gpu": "1"},
+				"aws://us-east-1/id-3": map[string]interface{}{"cpu": "500m", "memory": "512Mi"},
+			},
+			expected: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("6500m"),
+				corev1.ResourceMemory: resource.MustParse("25088Mi"),
+				"nvidia.com/gpu":      resource.MustParse("1"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"status": map[string]interface{}{},
+				},
+			}
+			if tc.instanceCapacity != nil {
+				g.Expect(unstructured.SetNestedField(infraConfig.Object, tc.instanceCapacity, "status", "instanceCapacity")).To(Succeed())
+			}
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test"},
+				Status: expv1.MachinePoolStatus{
+					Capacity: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+				},
+			}
+
+			g.Expect(reconcileCapacity(mp, infraConfig)).To(Succeed())
+			if tc.expected == nil {
+				g.Expect(mp.Status.Capacity).To(BeNil())
+				return
+			}
+			g.Expect(mp.Status.Capacity).To(HaveLen(len(tc.expected)))
+			for name, quantity := range tc.expected {
+				actual, ok := mp.Status.Capacity[name]
+				g.Expect(ok).To(BeTrue())
+				g.Expect(actual.Cmp(quantity)).To(BeZero(), "%s: expected %s, got %s", name, quantity.String(), actual.String())
+			}
+		})
+	}
+}
+
+func TestReconcileMachinePoolExternallyManagedReplicas(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	infraGVK := schema.GroupVersionKind{
+		Group:   "infrastructure.cluster.x-k8s.io",
+		Version: "v1alpha3",
+		Kind:    "InfrastructureConfig",
+	}
+
+	t.Run("phase is computed relative to the observed replicas", func(t *testing.T) {
+		testCases := []struct {
+			name          string
+			replicas      int32
+			readyReplicas int32
+			expectedPhase expv1.MachinePoolPhase
+		}{
+			{
+				name:          "all observed replicas are ready",
+				replicas:      5,
+				readyReplicas: 5,
+				expectedPhase: expv1.MachinePoolPhaseRunning,
+			},
+			{
+				name:          "observed replicas are scaling up",
+				replicas:      5,
+				readyReplicas: 3,
+				expectedPhase: expv1.MachinePoolPhaseScalingUp,
+			},
+			{
+				name:          "observed replicas are scaling down",
+				replicas:      1,
+				readyReplicas: 3,
+				expectedPhase: expv1.MachinePoolPhaseScalingDown,
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				g := NewWithT(t)
+
+				mp := &expv1.MachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "machinepool-test",
+						Namespace:   "default",
+						Annotations: map[string]string{expv1.ReplicasManagedByAnnotation: "cluster-autoscaler"},
+					},
+					Spec: expv1.MachinePoolSpec{
+						ClusterName: defaultCluster.Name,
+						Replicas:    pointer.Int32Ptr(3),
+					},
+					Status: expv1.MachinePoolStatus{
+						BootstrapReady:      true,
+						InfrastructureReady: true,
+						Replicas:            tc.replicas,
+						ReadyReplicas:       tc.readyReplicas,
+					},
+				}
+
+				r := &MachinePoolReconciler{
+					Client: fake.NewFakeClientWithScheme(scheme.Scheme, mp),
+					Log:    log.Log,
+					scheme: scheme.Scheme,
+				}
+
+				g.Expect(r.reconcilePhase(ctx, mp)).To(Succeed())
+				g.Expect(mp.Status.GetTypedPhase()).To(Equal(tc.expectedPhase))
+				g.Expect(mp.Status.Summary).To(HavePrefix(fmt.Sprintf("%d/%d ready", tc.readyReplicas, tc.replicas)))
+			})
+		}
+	})
+
+	t.Run("infrastructure is never scaled", func(t *testing.T) {
+		g := NewWithT(t)
+
+		infraConfig := &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"kind":       infraGVK.Kind,
+				"apiVersion": infraGVK.GroupVersion().String(),
+				"metadata": map[string]interface{}{
+					"name":      "infra-config1",
+					"namespace": "default",
+				},
+				"spec": map[string]interface{}{
+					"replicas":       int64(5),
+					"providerIDList": []interface{}{"aws://us-east-1/id-1"},
+				},
+				"status": map[string]interface{}{
+					"ready":    true,
+					"replicas": int64(5),
+				},
+			},
+		}
+
+		mp := &expv1.MachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "machinepool-test",
+				Namespace:   "default",
+				Annotations: map[string]string{expv1.ReplicasManagedByAnnotation: "cluster-autoscaler"},
+			},
+			Spec: expv1.MachinePoolSpec{
+				ClusterName: defaultCluster.Name,
+				Replicas:    pointer.Int32Ptr(1),
+				MinReplicas: pointer.Int32Ptr(2),
+				Template: clusterv1.MachineTemplateSpec{
+					Spec: clusterv1.MachineSpec{
+						InfrastructureRef: corev1.ObjectReference{
+							APIVersion: infraGVK.GroupVersion().String(),
+							Kind:       infraGVK.Kind,
+							Name:       "infra-config1",
+						},
+					},
+				},
+			},
+		}
+
+		for _, kinds := range [][]schema.GroupVersionKind{nil, {infraGVK}} {
+			recorder := record.NewFakeRecorder(32)
+			r := &MachinePoolReconciler{
+				Client:                      fake.NewFakeClientWithScheme(scheme.Scheme, mp.DeepCopy(), infraConfig.DeepCopy()),
+				Log:                         log.Log,
+				scheme:                      scheme.Scheme,
+				recorder:                    recorder,
+				InfrastructureReplicasKinds: kinds,
+				CorrectReplicasDrift:        true,
+			}
+
+			actualMP := mp.DeepCopy()
+			r.reconcileMinReplicas(actualMP)
+			g.Expect(*actualMP.Spec.Replicas).To(BeEquivalentTo(1))
+			g.Expect(recorder.Events).To(BeEmpty())
+
+			g.Expect(r.reconcileInfrastructure(context.Background(), defaultCluster, actualMP)).To(Succeed())
+			g.Expect(actualMP.Status.Replicas).To(BeEquivalentTo(5))
+			g.Expect(conditions.Has(actualMP, expv1.SpecDriftCondition)).To(BeFalse())
+
+			actual := &unstructured.Unstructured{}
+			actual.SetGroupVersionKind(infraGVK)
+			g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "infra-config1"}, actual)).To(Succeed())
+			replicas, _, err := unstructured.NestedInt64(actual.Object, "spec", "replicas")
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(replicas).To(BeEquivalentTo(5))
+		}
+	})
+}
+
+func TestReconcileMachinePoolFailureDomainCoverage(t *testing.T) {
+	clusterFailureDomains := clusterv1.FailureDomains{
+		"us-east-1a": clusterv1.FailureDomainSpec{},
+		"us-east-1b": clusterv1.FailureDomainSpec{},
+		"us-east-1c": clusterv1.FailureDomainSpec{ControlPlane: true},
+	}
+
+	testCases := []struct {
+		name                   string
+		clusterFailureDomains  clusterv1.FailureDomains
+		failureDomains         []string
+		instanceFailureDomains map[string]string
+		expected               []expv1.MachinePoolFailureDomainCoverage
+	}{
+		{
+			name:                  "full coverage",
+			clusterFailureDomains: clusterFailureDomains,
+			instanceFailureDomains: map[string]string{
+				"aws://us-east-1a/id-1": "us-east-1a",
+				"aws://us-east-1a/id-2": "us-east-1a",
+				"aws://us-east-1b/id-3": "us-east-1b",
+				"aws://us-east-1c/id-4": "us-east-1c",
+			},
+			expected: []expv1.MachinePoolFailureDomainCoverage{
+				{FailureDomain: "us-east-1a", Instances: 2},
+				{FailureDomain: "us-east-1b", Instances: 1},
+				{FailureDomain: "us-east-1c", Instances: 1},
+			},
+		},
+		{
+			name:                  "partial coverage",
+			clusterFailureDomains: clusterFailureDomains,
+			instanceFailureDomains: map[string]string{
+				"aws://us-east-1a/id-1": "us-east-1a",
+				"aws://us-east-1c/id-2": "us-east-1c",
+				"aws://us-west-2a/id-3": "us-west-2a",
+			},
+			expected: []expv1.MachinePoolFailureDomainCoverage{
+				{FailureDomain: "us-east-1a", Instances: 1},
+				{FailureDomain: "us-east-1b", Instances: 0},
+				{FailureDomain: "us-east-1c", Instances: 1},
+			},
+		},
+		{
+			name:                  "coverage is restricted to the failure domains the MachinePool is attached to",
+			clusterFailureDomains: clusterFailureDomains,
+			failureDomains:        []string{"us-east-1a", "us-east-1b"},
+			instanceFailureDomains: map[string]string{
+				"aws://us-east-1a/id-1": "us-east-1a",
+			},
+			expected: []expv1.MachinePoolFailureDomainCoverage{
+				{FailureDomain: "us-east-1a", Instances: 1},
+				{FailureDomain: "us-east-1b", Instances: 0},
+			},
+		},
+		{
+			name:                  "coverage is unknown if the provider doesn't report failure domains",
+			clusterFailureDomains: clusterFailureDomains,
+		},
+		{
+			name: "coverage is unknown if the Cluster has no failure domains",
+			instanceFailureDomains: map[string]string{
+				"aws://us-east-1a/id-1": "us-east-1a",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cluster := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+				Status:     clusterv1.ClusterStatus{FailureDomains: tc.clusterFailureDomains},
+			}
+			mp := &expv1.MachinePool{
+				Spec: expv1.MachinePoolSpec{
+					ClusterName:    cluster.Name,
+					FailureDomains: tc.failureDomains,
+				},
+				Status: expv1.MachinePoolStatus{
+					InstanceFailureDomains: tc.instanceFailureDomains,
+					FailureDomainCoverage:  []expv1.MachinePoolFailureDomainCoverage{{FailureDomain: "stale"}},
+				},
+			}
+
+			reconcileFailureDomainCoverage(cluster, mp)
+			g.Expect(mp.Status.FailureDomainCoverage).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestDesiredFailureDomainDistribution(t *testing.T) {
+	testCases := []struct {
+		name         string
+		coverage     []expv1.MachinePoolFailureDomainCoverage
+		newInstances int32
+		expected     map[string]int32
+	}{
+		{
+			name: "new instances go to the empty failure domains first",
+			coverage: []expv1.MachinePoolFailureDomainCoverage{
+				{FailureDomain: "us-east-1a", Instances: 2},
+				{FailureDomain: "us-east-1b", Instances: 0},
+				{FailureDomain: "us-east-1c", Instances: 0},
+			},
+			newInstances: 2,
+			expected:     map[string]int32{"us-east-1a": 2, "us-east-1b": 1, "us-east-1c": 1},
+		},
+		{
+			name: "new instances are spread once the failure domains are balanced",
+			coverage: []expv1.MachinePoolFailureDomainCoverage{
+				{FailureDomain: "us-east-1a", Instances: 3},
+				{FailureDomain: "us-east-1b", Instances: 1},
+				{FailureDomain: "us-east-1c", Instances: 0},
+			},
+			newInstances: 6,
+			expected:     map[string]int32{"us-east-1a": 4, "us-east-1b": 3, "us-east-1c": 3},
+		},
+		{
+			name: "ties go to the first failure domain",
+			coverage: []expv1.MachinePoolFailureDomainCoverage{
+				{FailureDomain: "us-east-1a", Instances: 1},
+				{FailureDomain: "us-east-1b", Instances: 1},
+			},
+			newInstances: 1,
+			expected:     map[string]int32{"us-east-1a": 2, "us-east-1b": 1},
+		},
+		{
+			name: "nothing to place when not scaling up",
+			coverage: []expv1.MachinePoolFailureDomainCoverage{
+				{FailureDomain: "us-east-1a", Instances: 1},
+			},
+			newInstances: -1,
+		},
+		{
+			name:         "nothing to place without a failure domain coverage",
+			newInstances: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(desiredFailureDomainDistribution(tc.coverage, tc.newInstances)).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestReconcileMachinePoolFailureDomainDistribution(t *testing.T) {
+	coverage := []expv1.MachinePoolFailureDomainCoverage{
+		{FailureDomain: "us-east-1a", Instances: 1},
+		{FailureDomain: "us-east-1b", Instances: 0},
+		{FailureDomain: "us-east-1c", Instances: 0},
+	}
+
+	testCases := []struct {
+		name                string
+		rebalance           bool
+		replicas            int32
+		existingAnnotations map[string]interface{}
+		expectedValue       string
+	}{
+		{
+			name:          "scaling up requests the new instances in the empty failure domains",
+			rebalance:     true,
+			replicas:      3,
+			expectedValue: "us-east-1a=1,us-east-1b=1,us-east-1c=1",
+		},
+		{
+			name:                "the distribution is removed once the MachinePool isn't scaling up",
+			rebalance:           true,
+			replicas:            1,
+			existingAnnotations: map[string]interface{}{expv1.FailureDomainDistributionAnnotation: "us-east-1a=1,us-east-1b=1"},
+		},
+		{
+			name:     "the distribution isn't requested unless rebalancing is enabled",
+			replicas: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			metadata := map[string]interface{}{
+				"name":      "infra-config1",
+				"namespace": "default",
+			}
+			if tc.existingAnnotations != nil {
+				metadata["annotations"] = tc.existingAnnotations
+			}
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata":   metadata,
+					"spec":       map[string]interface{}{"replicas": int64(tc.replicas)},
+				},
+			}
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test"},
+				Spec:       expv1.MachinePoolSpec{Replicas: pointer.Int32Ptr(tc.replicas)},
+				Status: expv1.MachinePoolStatus{
+					InstanceFailureDomains: map[string]string{"aws://us-east-1a/id-1": "us-east-1a"},
+					FailureDomainCoverage:  coverage,
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client:                  fake.NewFakeClientWithScheme(scheme.Scheme, infraConfig.DeepCopy()),
+				Log:                     log.Log,
+				scheme:                  scheme.Scheme,
+				RebalanceFailureDomains: tc.rebalance,
+			}
+			g.Expect(r.reconcileFailureDomainDistribution(ctx, mp, infraConfig)).To(Succeed())
+
+			actual := &unstructured.Unstructured{}
+			actual.SetGroupVersionKind(infraConfig.GroupVersionKind())
+			g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "infra-config1"}, actual)).To(Succeed())
+			value, ok := actual.GetAnnotations()[expv1.FailureDomainDistributionAnnotation]
+			g.Expect(ok).To(Equal(tc.expectedValue != ""))
+			g.Expect(value).To(Equal(tc.expectedValue))
+		})
+	}
+}
+
+// applyRecordingClient records the server-side apply patches sent through it, which the fake client doesn't
+// support, and forwards other patches.
+type applyRecordingClient struct {
+	client.Client
+	applied []*unstructured.Unstructured
+	options []*client.PatchOptions
+}
+
+func (c *applyRecordingClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	c.applied = append(c.applied, obj.(*unstructured.Unstructured).DeepCopy())
+	c.options = append(c.options, (&client.PatchOptions{}).ApplyOptions(opts))
+	return nil
+}
+
+func TestReconcileMachinePoolExternalServerSideApply(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	bootstrapRef := &corev1.ObjectReference{
+		APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+		Kind:       "BootstrapConfig",
+		Name:       "bootstrap-config1",
+	}
+
+	testCases := []struct {
+		name            string
+		fieldManager    string
+		ownerReferences []interface{}
+		expectApply     bool
+	}{
+		{
+			name:         "external objects are applied with the configured field manager",
+			fieldManager: "capi-machinepool",
+			expectApply:  true,
+		},
+		{
+			name: "external objects are patched by default",
+		},
+		{
+			name:         "external objects controlled by another object are patched when adopted",
+			fieldManager: "capi-machinepool",
+			ownerReferences: []interface{}{
+				map[string]interface{}{
+					"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+					"kind":       "BootstrapConfigOwner",
+					"name":       "other-owner",
+					"uid":        "other-uid",
+					"controller": true,
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			metadata := map[string]interface{}{
+				"name":      "bootstrap-config1",
+				"namespace": "default",
+			}
+			if tc.ownerReferences != nil {
+				metadata["ownerReferences"] = tc.ownerReferences
+			}
+			bootstrapConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "BootstrapConfig",
+					"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+					"metadata":   metadata,
+					"spec":       map[string]interface{}{},
+					"status":     map[string]interface{}{},
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+					UID:       "machinepool-uid",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+				},
+			}
+
+			c := &applyRecordingClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme, machinepool, bootstrapConfig)}
+			r := &MachinePoolReconciler{
+				Client:                         c,
+				Log:                            log.Log,
+				scheme:                         scheme.Scheme,
+				AdoptControlledExternalObjects: true,
+				ExternalPatchFieldManager:      tc.fieldManager,
+			}
+
+			_, err := r.reconcileExternal(context.Background(), defaultCluster, machinepool, bootstrapRef)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			if !tc.expectApply {
+				g.Expect(c.applied).To(BeEmpty())
+
+				actual := &unstructured.Unstructured{}
+				actual.SetGroupVersionKind(bootstrapConfig.GroupVersionKind())
+				g.Expect(c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "bootstrap-config1"}, actual)).To(Succeed())
+				g.Expect(actual.GetLabels()).To(HaveKeyWithValue(clusterv1.ClusterLabelName, defaultCluster.Name))
+				g.Expect(metav1.GetControllerOf(actual).Name).To(Equal(machinepool.Name))
+				return
+			}
+
+			// Only the fields set by the MachinePool are applied, forcing their ownership.
+			g.Expect(c.applied).To(HaveLen(1))
+			g.Expect(c.options[0].FieldManager).To(Equal(tc.fieldManager))
+			g.Expect(c.options[0].Force).To(Equal(pointer.BoolPtr(true)))
+
+			applied := c.applied[0]
+			g.Expect(applied.GroupVersionKind()).To(Equal(bootstrapConfig.GroupVersionKind()))
+			g.Expect(applied.GetName()).To(Equal("bootstrap-config1"))
+			g.Expect(applied.GetLabels()).To(Equal(map[string]string{clusterv1.ClusterLabelName: defaultCluster.Name}))
+			g.Expect(applied.GetOwnerReferences()).To(HaveLen(1))
+			g.Expect(applied.GetOwnerReferences()[0].Kind).To(Equal("MachinePool"))
+			g.Expect(applied.GetOwnerReferences()[0].UID).To(Equal(machinepool.UID))
+			g.Expect(applied.Object).NotTo(HaveKey("spec"))
+		})
+	}
+}
+
+func TestReconcileMachinePoolOverProvisioned(t *testing.T) {
+	providerIDs := func(n int) []string {
+		providerIDList := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			providerIDList = append(providerIDList, fmt.Sprintf("aws://us-east-1/id-%d", i))
+		}
+		return providerIDList
+	}
+
+	testCases := []struct {
+		name                  string
+		margin                int
+		instances             int
+		annotations           map[string]string
+		expectOverProvisioned bool
+	}{
+		{
+			name:      "instances matching the replicas are normal",
+			margin:    2,
+			instances: 3,
+		},
+		{
+			name:      "instances within the margin are normal",
+			margin:    2,
+			instances: 5,
+		},
+		{
+			name:                  "instances beyond the margin are over-provisioned",
+			margin:                2,
+			instances:             6,
+			expectOverProvisioned: true,
+		},
+		{
+			name:      "instances are not checked without a margin",
+			instances: 30,
+		},
+		{
+			name:        "externally managed replicas are never over-provisioned",
+			margin:      2,
+			instances:   30,
+			annotations: map[string]string{expv1.ReplicasManagedByAnnotation: "cluster-autoscaler"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Name: "machinepool-test", Namespace: "default", Annotations: tc.annotations},
+				Spec: expv1.MachinePoolSpec{
+					Replicas:       pointer.Int32Ptr(3),
+					ProviderIDList: providerIDs(tc.instances),
+				},
+			}
+
+			recorder := record.NewFakeRecorder(32)
+			r := &MachinePoolReconciler{
+				Log:                   log.Log,
+				recorder:              recorder,
+				OverProvisionedMargin: tc.margin,
+			}
+
+			// The instances are only reported, and the event only recorded once.
+			for i := 0; i < 2; i++ {
+				r.reconcileOverProvisioned(mp)
+				g.Expect(mp.Spec.ProviderIDList).To(HaveLen(tc.instances))
+			}
+
+			if !tc.expectOverProvisioned {
+				g.Expect(conditions.Has(mp, expv1.OverProvisionedCondition)).To(BeFalse())
+				g.Expect(recorder.Events).To(BeEmpty())
+				return
+			}
+			g.Expect(conditions.IsTrue(mp, expv1.OverProvisionedCondition)).To(BeTrue())
+			g.Expect(conditions.GetReason(mp, expv1.OverProvisionedCondition)).To(Equal(expv1.TooManyInstancesReason))
+			g.Expect(recorder.Events).To(HaveLen(1))
+			g.Expect(<-recorder.Events).To(ContainSubstring("6 instances, 3 more than the 3 desired replicas"))
+
+			// The condition is removed once the excess is gone.
+			mp.Spec.ProviderIDList = providerIDs(3)
+			r.reconcileOverProvisioned(mp)
+			g.Expect(conditions.Has(mp, expv1.OverProvisionedCondition)).To(BeFalse())
+		})
+	}
+}
+
+func TestReconcileMachinePoolExternalClusterOwnerReference(t *testing.T) {
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster", UID: "cluster-uid"},
+	}
+
+	infraRef := &corev1.ObjectReference{
+		APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+		Kind:       "InfrastructureConfig",
+		Name:       "infra-config1",
+	}
+
+	testCases := []struct {
+		name                   string
+		clusterOwnerReferences bool
+		expectedOwners         []string
+	}{
+		{
+			name:           "external objects are only owned by the MachinePool by default",
+			expectedOwners: []string{"MachinePool"},
+		},
+		{
+			name:                   "external objects are owned by the MachinePool and the Cluster if configured",
+			clusterOwnerReferences: true,
+			expectedOwners:         []string{"MachinePool", "Cluster"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       infraRef.Kind,
+					"apiVersion": infraRef.APIVersion,
+					"metadata": map[string]interface{}{
+						"name":      infraRef.Name,
+						"namespace": "default",
+					},
+					"spec":   map[string]interface{}{},
+					"status": map[string]interface{}{},
+				},
+			}
+
+			machinepool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machinepool-test",
+					Namespace: "default",
+					UID:       "machinepool-uid",
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: defaultCluster.Name,
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client:                 fake.NewFakeClientWithScheme(scheme.Scheme, defaultCluster, machinepool, infraConfig),
+				Log:                    log.Log,
+				scheme:                 scheme.Scheme,
+				ClusterOwnerReferences: tc.clusterOwnerReferences,
+			}
+
+			// Reconciling again doesn't duplicate the owner references.
+			for i := 0; i < 2; i++ {
+				_, err := r.reconcileExternal(context.Background(), defaultCluster, machinepool, infraRef)
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			actual := &unstructured.Unstructured{}
+			actual.SetGroupVersionKind(infraConfig.GroupVersionKind())
+			g.Expect(r.Client.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: infraRef.Name}, actual)).To(Succeed())
+			ownerRefs := actual.GetOwnerReferences()
+			g.Expect(ownerRefs).To(HaveLen(len(tc.expectedOwners)))
+			for i, kind := range tc.expectedOwners {
+				g.Expect(ownerRefs[i].Kind).To(Equal(kind))
+			}
+
+			controllerRef := metav1.GetControllerOf(actual)
+			g.Expect(controllerRef).NotTo(BeNil())
+			g.Expect(controllerRef.UID).To(Equal(machinepool.UID))
+
+			if tc.clusterOwnerReferences {
+				g.Expect(ownerRefs[1].APIVersion).To(Equal(clusterv1.GroupVersion.String()))
+				g.Expect(ownerRefs[1].Name).To(Equal(defaultCluster.Name))
+				g.Expect(ownerRefs[1].UID).To(Equal(defaultCluster.UID))
+				g.Expect(ownerRefs[1].Controller).To(BeNil())
+			}
+		})
+	}
+}
+
+func TestReconcileMachinePoolPendingCondition(t *testing.T) {
+	readyCluster := clusterv1.ClusterStatus{InfrastructureReady: true, ControlPlaneInitialized: true}
+	bootstrapConfig := clusterv1.Bootstrap{
+		ConfigRef: &corev1.ObjectReference{
+			APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+			Kind:       "BootstrapConfig",
+			Name:       "bootstrap-config1",
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		phase          expv1.MachinePoolPhase
+		paused         bool
+		clusterStatus  clusterv1.ClusterStatus
+		bootstrap      clusterv1.Bootstrap
+		expectedReason string
+	}{
+		{
+			name:           "paused",
+			phase:          expv1.MachinePoolPhasePending,
+			paused:         true,
+			clusterStatus:  readyCluster,
+			bootstrap:      bootstrapConfig,
+			expectedReason: expv1.ReconciliationPausedReason,
+		},
+		{
+			name:           "waiting for the Cluster infrastructure",
+			phase:          expv1.MachinePoolPhasePending,
+			bootstrap:      bootstrapConfig,
+			expectedReason: expv1.WaitingForClusterReason,
+		},
+		{
+			name:           "waiting for the Cluster control plane",
+			phase:          expv1.MachinePoolPhasePending,
+			clusterStatus:  clusterv1.ClusterStatus{InfrastructureReady: true},
+			bootstrap:      bootstrapConfig,
+			expectedReason: expv1.WaitingForClusterReason,
+		},
+		{
+			name:           "no bootstrap configuration",
+			phase:          expv1.MachinePoolPhasePending,
+			clusterStatus:  readyCluster,
+			expectedReason: expv1.BootstrapNotStartedReason,
+		},
+		{
+			name:           "waiting for the bootstrap data",
+			phase:          expv1.MachinePoolPhasePending,
+			clusterStatus:  readyCluster,
+			bootstrap:      bootstrapConfig,
+			expectedReason: expv1.BootstrapNotStartedReason,
+		},
+		{
+			name:          "not pending",
+			phase:         expv1.MachinePoolPhaseProvisioning,
+			clusterStatus: readyCluster,
+			bootstrap:     bootstrapConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cluster := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+				Spec:       clusterv1.ClusterSpec{Paused: tc.paused},
+				Status:     tc.clusterStatus,
+			}
+			mp := &expv1.MachinePool{
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: cluster.Name,
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{Bootstrap: tc.bootstrap},
+					},
+				},
+			}
+			mp.Status.SetTypedPhase(tc.phase)
+			conditions.MarkTrue(mp, expv1.PendingCondition)
+
+			reconcilePendingCondition(cluster, mp)
+
+			if tc.expectedReason == "" {
+				g.Expect(conditions.Has(mp, expv1.PendingCondition)).To(BeFalse())
+				return
+			}
+			g.Expect(conditions.IsTrue(mp, expv1.PendingCondition)).To(BeTrue())
+			g.Expect(conditions.GetReason(mp, expv1.PendingCondition)).To(Equal(tc.expectedReason))
+			g.Expect(conditions.GetMessage(mp, expv1.PendingCondition)).NotTo(BeEmpty())
+		})
+	}
+}
+
+func TestReconcileMachinePoolPausedPending(t *testing.T) {
+	g := NewWithT(t)
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+	newMachinePool := func(name string, phase expv1.MachinePoolPhase) *expv1.MachinePool {
+		mp := &expv1.MachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        name,
+				Annotations: map[string]string{clusterv1.PausedAnnotation: ""},
+			},
+			Spec: expv1.MachinePoolSpec{
+				ClusterName: testCluster.Name,
+				Replicas:    pointer.Int32Ptr(1),
+			},
+		}
+		mp.Status.SetTypedPhase(phase)
+		return mp
+	}
+	created := newMachinePool("created", "")
+	running := newMachinePool("running", expv1.MachinePoolPhaseRunning)
+
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, created, running),
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	// Paused MachinePools which were never reconciled report why they are Pending.
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(created)})
+	g.Expect(err).NotTo(HaveOccurred())
+	actual := &expv1.MachinePool{}
+	g.Expect(r.Client.Get(ctx, util.ObjectKey(created), actual)).To(Succeed())
+	g.Expect(actual.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhasePending))
+	g.Expect(conditions.GetReason(actual, expv1.PendingCondition)).To(Equal(expv1.ReconciliationPausedReason))
+	g.Expect(actual.Finalizers).To(BeEmpty())
+
+	// Paused MachinePools in other phases are left untouched.
+	_, err = r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(running)})
+	g.Expect(err).NotTo(HaveOccurred())
+	actual = &expv1.MachinePool{}
+	g.Expect(r.Client.Get(ctx, util.ObjectKey(running), actual)).To(Succeed())
+	g.Expect(actual.Status.GetTypedPhase()).To(Equal(expv1.MachinePoolPhaseRunning))
+	g.Expect(conditions.Has(actual, expv1.PendingCondition)).To(BeFalse())
+}
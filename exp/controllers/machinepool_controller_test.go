@@ -17,19 +17,39 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 
 	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	fakeremote "sigs.k8s.io/cluster-api/controllers/remote/fake"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -120,8 +140,9 @@ func TestMachinePoolFinalizer(t *testing.T) {
 					machinePoolValidCluster,
 					machinePoolWithFinalizer,
 				),
-				Log:    log.Log,
-				scheme: scheme.Scheme,
+				Log:      log.Log,
+				scheme:   scheme.Scheme,
+				recorder: record.NewFakeRecorder(32),
 			}
 
 			_, _ = mr.Reconcile(tc.request)
@@ -144,6 +165,7 @@ func TestMachinePoolOwnerReference(t *testing.T) {
 	testCluster := &clusterv1.Cluster{
 		TypeMeta:   metav1.TypeMeta{Kind: "Cluster", APIVersion: clusterv1.GroupVersion.String()},
 		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+		Status:     clusterv1.ClusterStatus{ControlPlaneInitialized: true},
 	}
 
 	machinePoolInvalidCluster := &expv1.MachinePool{
@@ -233,8 +255,9 @@ func TestMachinePoolOwnerReference(t *testing.T) {
 					machinePoolValidCluster,
 					machinePoolValidMachinePool,
 				),
-				Log:    log.Log,
-				scheme: scheme.Scheme,
+				Log:      log.Log,
+				scheme:   scheme.Scheme,
+				recorder: record.NewFakeRecorder(32),
 			}
 
 			key := client.ObjectKey{Namespace: tc.m.Namespace, Name: tc.m.Name}
@@ -290,6 +313,7 @@ func TestReconcileMachinePoolRequest(t *testing.T) {
 	testCluster := clusterv1.Cluster{
 		TypeMeta:   metav1.TypeMeta{Kind: "Cluster", APIVersion: clusterv1.GroupVersion.String()},
 		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+		Status:     clusterv1.ClusterStatus{ControlPlaneInitialized: true},
 	}
 
 	bootstrapConfig := &unstructured.Unstructured{
@@ -432,9 +456,10 @@ func TestReconcileMachinePoolRequest(t *testing.T) {
 			)
 
 			r := &MachinePoolReconciler{
-				Client: clientFake,
-				Log:    log.Log,
-				scheme: scheme.Scheme,
+				Client:   clientFake,
+				Log:      log.Log,
+				scheme:   scheme.Scheme,
+				recorder: record.NewFakeRecorder(32),
 			}
 
 			result, err := r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(&tc.machinePool)})
@@ -562,17 +587,178 @@ func TestReconcileMachinePoolDeleteExternal(t *testing.T) {
 				scheme: scheme.Scheme,
 			}
 
-			ok, err := r.reconcileDeleteExternal(ctx, machinePool)
+			mp := machinePool.DeepCopy()
+			mp.Status.DeletionProgress = &expv1.MachinePoolDeletionProgress{}
+
+			ok, err := r.reconcileDeleteExternal(ctx, mp)
 			g.Expect(ok).To(Equal(tc.expected))
 			if tc.expectError {
 				g.Expect(err).To(HaveOccurred())
 			} else {
 				g.Expect(err).NotTo(HaveOccurred())
 			}
+			g.Expect(mp.Status.DeletionProgress.BootstrapRemaining).To(Equal(tc.bootstrapExists))
+			g.Expect(mp.Status.DeletionProgress.InfrastructureRemaining).To(Equal(tc.infraExists))
 		})
 	}
 }
 
+// flakyDeleteClient fails the first deletes issued through it with a transient error.
+type flakyDeleteClient struct {
+	client.Client
+	failures int
+	deletes  int
+}
+
+func (c *flakyDeleteClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	c.deletes++
+	if c.deletes <= c.failures {
+		return apierrors.NewServiceUnavailable("etcd leader changed")
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func TestReconcileDeleteExternalRetry(t *testing.T) {
+	g := NewWithT(t)
+
+	infraConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureConfig",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "delete-infra",
+				"namespace": "default",
+			},
+		},
+	}
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Name: "delete", Namespace: "default"},
+		Spec: expv1.MachinePoolSpec{
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureConfig",
+						Name:       "delete-infra",
+					},
+				},
+			},
+		},
+	}
+
+	c := &flakyDeleteClient{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, infraConfig),
+		failures: 2,
+	}
+	r := &MachinePoolReconciler{
+		Client:   c,
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	// Failed deletes block the deletion, and are retried by requeueing with backoff rather than within the reconciliation.
+	for i := 1; i <= c.failures; i++ {
+		ok, err := r.reconcileDeleteExternal(ctx, mp)
+		g.Expect(ok).To(BeFalse())
+		g.Expect(err).To(HaveOccurred())
+		requeueErr, isRequeue := errors.Cause(err).(capierrors.HasRequeueAfterError)
+		g.Expect(isRequeue).To(BeTrue())
+		g.Expect(requeueErr.GetRequeueAfter()).To(Equal(deleteExternalBackoff(i)))
+		g.Expect(c.deletes).To(Equal(i))
+		g.Expect(mp.Annotations).To(HaveKeyWithValue(expv1.DeleteExternalAttemptsAnnotation, strconv.Itoa(i)))
+		g.Expect(conditions.IsTrue(mp, expv1.DeletionBlockedCondition)).To(BeTrue())
+		g.Expect(conditions.GetMessage(mp, expv1.DeletionBlockedCondition)).To(ContainSubstring("delete-infra"))
+	}
+	g.Expect(deleteExternalBackoff(2)).To(Equal(2 * deleteExternalBackoff(1)))
+
+	// The flaky delete eventually succeeds.
+	ok, err := r.reconcileDeleteExternal(ctx, mp)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+	g.Expect(mp.Annotations).NotTo(HaveKey(expv1.DeleteExternalAttemptsAnnotation))
+	g.Expect(conditions.Has(mp, expv1.DeletionBlockedCondition)).To(BeFalse())
+
+	// The next reconciliation finds the infrastructure object gone.
+	ok, err = r.reconcileDeleteExternal(ctx, mp)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+}
+
+func TestReconcileDeleteExternalRetriesExhausted(t *testing.T) {
+	g := NewWithT(t)
+
+	infraConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureConfig",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "stuck-infra",
+				"namespace": "default",
+			},
+		},
+	}
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Name: "stuck", Namespace: "default"},
+		Spec: expv1.MachinePoolSpec{
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureConfig",
+						Name:       "stuck-infra",
+					},
+				},
+			},
+		},
+	}
+
+	c := &flakyDeleteClient{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, infraConfig),
+		failures: deleteExternalMaxAttempts + 1,
+	}
+	recorder := record.NewFakeRecorder(32)
+	r := &MachinePoolReconciler{
+		Client:   c,
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: recorder,
+	}
+
+	// The backoff is capped.
+	g.Expect(deleteExternalBackoff(deleteExternalMaxAttempts)).To(Equal(deleteExternalMaxBackoff))
+
+	for i := 1; i < deleteExternalMaxAttempts; i++ {
+		_, err := r.reconcileDeleteExternal(ctx, mp)
+		g.Expect(err).To(HaveOccurred())
+	}
+
+	// The last attempt stops the retries, reporting it in the DeletionBlocked condition and an event.
+	ok, err := r.reconcileDeleteExternal(ctx, mp)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+	g.Expect(c.deletes).To(Equal(deleteExternalMaxAttempts))
+	g.Expect(conditions.GetReason(mp, expv1.DeletionBlockedCondition)).To(Equal(expv1.DeleteRetriesExhaustedReason))
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("DeleteRetriesExhausted")))
+
+	// The deletion isn't retried anymore.
+	ok, err = r.reconcileDeleteExternal(ctx, mp)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+	g.Expect(c.deletes).To(Equal(deleteExternalMaxAttempts))
+
+	// Removing the attempts annotation resumes the retries.
+	delete(mp.Annotations, expv1.DeleteExternalAttemptsAnnotation)
+	_, err = r.reconcileDeleteExternal(ctx, mp)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(c.deletes).To(Equal(deleteExternalMaxAttempts + 1))
+
+	ok, err = r.reconcileDeleteExternal(ctx, mp)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+	g.Expect(conditions.Has(mp, expv1.DeletionBlockedCondition)).To(BeFalse())
+}
+
 func TestRemoveMachinePoolFinalizerAfterDeleteReconcile(t *testing.T) {
 	g := NewWithT(t)
 
@@ -608,9 +794,10 @@ func TestRemoveMachinePoolFinalizerAfterDeleteReconcile(t *testing.T) {
 	}
 	key := client.ObjectKey{Namespace: m.Namespace, Name: m.Name}
 	mr := &MachinePoolReconciler{
-		Client: fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, m),
-		Log:    log.Log,
-		scheme: scheme.Scheme,
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, m),
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(32),
 	}
 	_, err := mr.Reconcile(reconcile.Request{NamespacedName: key})
 	g.Expect(err).ToNot(HaveOccurred())
@@ -618,4 +805,1388 @@ func TestRemoveMachinePoolFinalizerAfterDeleteReconcile(t *testing.T) {
 	var actual expv1.MachinePool
 	g.Expect(mr.Client.Get(ctx, key, &actual)).To(Succeed())
 	g.Expect(actual.ObjectMeta.Finalizers).To(BeEmpty())
+	g.Expect(actual.Status.DeletionProgress).To(Equal(&expv1.MachinePoolDeletionProgress{}))
+}
+
+func TestMachinePoolGetRemoteClient(t *testing.T) {
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	testCases := []struct {
+		name               string
+		remoteClientGetter remote.ClusterClientGetter
+		expectErr          bool
+		expectedStatus     corev1.ConditionStatus
+		expectedReason     string
+	}{
+		{
+			name:           "kubeconfig secret is missing",
+			expectErr:      true,
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: expv1.KubeconfigMissingReason,
+		},
+		{
+			name: "connection to the workload cluster is refused",
+			remoteClientGetter: func(_ context.Context, _ client.Client, _ client.ObjectKey, _ *runtime.Scheme) (client.Client, error) {
+				return nil, errors.Wrap(&url.Error{
+					Op:  "Get",
+					URL: "https://test-cluster:6443/api",
+					Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+				}, "failed to create client for Cluster default/test-cluster")
+			},
+			expectErr:      true,
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: expv1.ConnectionRefusedReason,
+		},
+		{
+			name: "credentials are rejected by the workload cluster",
+			remoteClientGetter: func(_ context.Context, _ client.Client, _ client.ObjectKey, _ *runtime.Scheme) (client.Client, error) {
+				return nil, errors.Wrap(apierrors.NewUnauthorized("Unauthorized"), "failed to create client for Cluster default/test-cluster")
+			},
+			expectErr:      true,
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: expv1.UnauthorizedReason,
+		},
+		{
+			name:               "workload cluster is reachable",
+			remoteClientGetter: fakeremote.NewClusterClient,
+			expectErr:          false,
+			expectedStatus:     corev1.ConditionTrue,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "remote"},
+			}
+			r := &MachinePoolReconciler{
+				Client:             fake.NewFakeClientWithScheme(scheme.Scheme, testCluster),
+				Log:                log.Log,
+				scheme:             scheme.Scheme,
+				remoteClientGetter: tc.remoteClientGetter,
+			}
+
+			_, err := r.getRemoteClient(ctx, testCluster, mp)
+			if tc.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			c := conditions.Get(mp, expv1.MachinePoolRemoteConnectedCondition)
+			g.Expect(c).NotTo(BeNil())
+			g.Expect(c.Status).To(Equal(tc.expectedStatus))
+			g.Expect(c.Reason).To(Equal(tc.expectedReason))
+		})
+	}
+}
+
+func TestMachinePoolReconcileSteadyState(t *testing.T) {
+	testCluster := &clusterv1.Cluster{
+		TypeMeta:   metav1.TypeMeta{Kind: "Cluster", APIVersion: clusterv1.GroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+		Status:     clusterv1.ClusterStatus{ControlPlaneInitialized: true},
+	}
+
+	testCases := []struct {
+		name                   string
+		generation             int64
+		expectExternalsPatched bool
+	}{
+		{
+			name:                   "steady state skips patching the external objects",
+			generation:             1,
+			expectExternalsPatched: false,
+		},
+		{
+			name:                   "spec change bypasses the steady state",
+			generation:             2,
+			expectExternalsPatched: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			bootstrapConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "BootstrapConfig",
+					"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "bootstrap-config1",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{},
+					"status": map[string]interface{}{
+						"ready":          true,
+						"dataSecretName": "secret-data",
+					},
+				},
+			}
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{
+						"providerIDList": []interface{}{
+							"aws://us-east-1/id-node-1",
+						},
+					},
+					"status": map[string]interface{}{
+						"ready":    true,
+						"replicas": int64(1),
+					},
+				},
+			}
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "steady-state",
+					Namespace:  "default",
+					Generation: tc.generation,
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName:    testCluster.Name,
+					Replicas:       pointer.Int32Ptr(1),
+					ProviderIDList: []string{"aws://us-east-1/id-node-1"},
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							Bootstrap: clusterv1.Bootstrap{
+								ConfigRef: &corev1.ObjectReference{
+									APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+									Kind:       "BootstrapConfig",
+									Name:       "bootstrap-config1",
+								},
+								DataSecretName: pointer.StringPtr("secret-data"),
+							},
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+								Kind:       "InfrastructureConfig",
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+				Status: expv1.MachinePoolStatus{
+					BootstrapReady:      true,
+					InfrastructureReady: true,
+					Replicas:            1,
+					ReadyReplicas:       1,
+					NodeRefs:            []corev1.ObjectReference{{Name: "node-1"}},
+					ObservedGeneration:  1,
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client:             fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, mp, bootstrapConfig, infraConfig),
+				Log:                log.Log,
+				scheme:             scheme.Scheme,
+				recorder:           record.NewFakeRecorder(32),
+				remoteClientGetter: fakeremote.NewClusterClient,
+			}
+			r.externalWatchers.Store(infraConfig.GroupVersionKind().String(), struct{}{})
+
+			_, err := r.reconcile(ctx, testCluster, mp)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(mp.Status.Replicas).To(BeEquivalentTo(1))
+
+			for _, obj := range []*unstructured.Unstructured{bootstrapConfig, infraConfig} {
+				g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: obj.GetNamespace(), Name: obj.GetName()}, obj)).To(Succeed())
+				if tc.expectExternalsPatched {
+					g.Expect(obj.GetOwnerReferences()).To(HaveLen(1))
+					g.Expect(obj.GetLabels()).To(HaveKeyWithValue(clusterv1.ClusterLabelName, testCluster.Name))
+				} else {
+					g.Expect(obj.GetOwnerReferences()).To(BeEmpty())
+					g.Expect(obj.GetLabels()).NotTo(HaveKey(clusterv1.ClusterLabelName))
+				}
+			}
+		})
+	}
+}
+
+func TestMachinePoolReconcileDeletingOwner(t *testing.T) {
+	testCluster := &clusterv1.Cluster{
+		TypeMeta:   metav1.TypeMeta{Kind: "Cluster", APIVersion: clusterv1.GroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+		Status:     clusterv1.ClusterStatus{ControlPlaneInitialized: true},
+	}
+
+	newOwner := func(deleting bool) *unstructured.Unstructured {
+		owner := &unstructured.Unstructured{}
+		owner.SetAPIVersion("cluster.x-k8s.io/v1alpha3")
+		owner.SetKind("MachineDeployment")
+		owner.SetNamespace("default")
+		owner.SetName("parent")
+		if deleting {
+			owner.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
+		}
+		return owner
+	}
+
+	testCases := []struct {
+		name                  string
+		owner                 *unstructured.Unstructured
+		expectExternalPatched bool
+	}{
+		{
+			name:                  "pools whose owner is gone are reconciled",
+			expectExternalPatched: true,
+		},
+		{
+			name:                  "pools whose owner isn't being deleted are reconciled",
+			owner:                 newOwner(false),
+			expectExternalPatched: true,
+		},
+		{
+			name:                  "pools whose owner is being deleted aren't mutated",
+			owner:                 newOwner(true),
+			expectExternalPatched: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureConfig",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{
+						"providerIDList": []interface{}{
+							"aws://us-east-1/id-node-1",
+						},
+					},
+					"status": map[string]interface{}{
+						"ready":    true,
+						"replicas": int64(1),
+					},
+				},
+			}
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "owned",
+					Namespace: "default",
+					OwnerReferences: []metav1.OwnerReference{
+						{APIVersion: "cluster.x-k8s.io/v1alpha3", Kind: "MachineDeployment", Name: "parent"},
+					},
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: testCluster.Name,
+					Replicas:    pointer.Int32Ptr(1),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							Bootstrap: clusterv1.Bootstrap{
+								DataSecretName: pointer.StringPtr("secret-data"),
+							},
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+								Kind:       "InfrastructureConfig",
+								Name:       "infra-config1",
+							},
+						},
+					},
+				},
+			}
+
+			objs := []runtime.Object{testCluster, mp, infraConfig}
+			if tc.owner != nil {
+				objs = append(objs, tc.owner)
+			}
+			r := &MachinePoolReconciler{
+				Client:             fake.NewFakeClientWithScheme(scheme.Scheme, objs...),
+				Log:                log.Log,
+				scheme:             scheme.Scheme,
+				recorder:           record.NewFakeRecorder(32),
+				remoteClientGetter: fakeremote.NewClusterClient,
+			}
+
+			_, err := r.reconcile(ctx, testCluster, mp)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "infra-config1"}, infraConfig)).To(Succeed())
+			if tc.expectExternalPatched {
+				g.Expect(infraConfig.GetOwnerReferences()).To(HaveLen(1))
+				g.Expect(mp.OwnerReferences).To(HaveLen(2))
+				g.Expect(mp.Status.InfrastructureReady).To(BeTrue())
+			} else {
+				g.Expect(infraConfig.GetOwnerReferences()).To(BeEmpty())
+				g.Expect(mp.OwnerReferences).To(HaveLen(1))
+				g.Expect(mp.Status.InfrastructureReady).To(BeFalse())
+			}
+		})
+	}
+}
+
+// ownerErrorClient fails the reads of unstructured objects, e.g. the owners of MachinePools, with the given error.
+type ownerErrorClient struct {
+	client.Client
+	err error
+}
+
+func (c *ownerErrorClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if _, ok := obj.(*unstructured.Unstructured); ok {
+		return c.err
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestMachinePoolDeletingOwnerUnreadable(t *testing.T) {
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "owned",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "example.com/v1", Kind: "Parent", Name: "parent"},
+			},
+		},
+	}
+	groupResource := schema.GroupResource{Group: "example.com", Resource: "parents"}
+
+	testCases := []struct {
+		name        string
+		err         error
+		expectError bool
+	}{
+		{
+			name: "owners the controller has no RBAC for aren't considered being deleted",
+			err:  apierrors.NewForbidden(groupResource, "parent", errors.New("no RBAC")),
+		},
+		{
+			name: "owners of unknown kinds aren't considered being deleted",
+			err:  &meta.NoKindMatchError{GroupKind: schema.GroupKind{Group: "example.com", Kind: "Parent"}, SearchedVersions: []string{"v1"}},
+		},
+		{
+			name:        "other errors are returned",
+			err:         apierrors.NewServiceUnavailable("etcd leader changed"),
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r := &MachinePoolReconciler{
+				Client: &ownerErrorClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme), err: tc.err},
+				Log:    log.Log,
+			}
+
+			owner, err := r.deletingOwner(ctx, testCluster, mp)
+			if tc.expectError {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(owner).To(BeNil())
+		})
+	}
+}
+
+func TestReconcileMachinePoolDeleteCordonsNodes(t *testing.T) {
+	g := NewWithT(t)
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	infraConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureConfig",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "delete-infra",
+				"namespace": "default",
+			},
+		},
+	}
+
+	schedulableNode := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "schedulable-node"},
+	}
+	cordonedNode := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cordoned-node"},
+		Spec:       corev1.NodeSpec{Unschedulable: true},
+	}
+
+	dt := metav1.Now()
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "delete",
+			Namespace:         "default",
+			Finalizers:        []string{expv1.MachinePoolFinalizer},
+			DeletionTimestamp: &dt,
+		},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName: testCluster.Name,
+			Replicas:    pointer.Int32Ptr(3),
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureConfig",
+						Name:       "delete-infra",
+					},
+				},
+			},
+		},
+		Status: expv1.MachinePoolStatus{
+			NodeRefs: []corev1.ObjectReference{
+				{Name: schedulableNode.Name},
+				{Name: cordonedNode.Name},
+				{Name: "missing-node"},
+			},
+		},
+	}
+
+	r := &MachinePoolReconciler{
+		Client:             fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, mp, infraConfig, schedulableNode, cordonedNode),
+		Log:                log.Log,
+		scheme:             scheme.Scheme,
+		remoteClientGetter: fakeremote.NewClusterClient,
+	}
+
+	// The infrastructure is still being deleted, but the Nodes are cordoned already.
+	_, err := r.reconcileDelete(ctx, testCluster, mp)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mp.Finalizers).To(ContainElement(expv1.MachinePoolFinalizer))
+
+	for _, name := range []string{schedulableNode.Name, cordonedNode.Name} {
+		node := &corev1.Node{}
+		g.Expect(r.Client.Get(ctx, client.ObjectKey{Name: name}, node)).To(Succeed())
+		g.Expect(node.Spec.Unschedulable).To(BeTrue())
+	}
+}
+
+func TestReconcileMachinePoolDeleteDrainsFailureDomainsSequentially(t *testing.T) {
+	g := NewWithT(t)
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	zoneANodes := []*corev1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "zone-a-1", Labels: map[string]string{corev1.LabelZoneFailureDomainStable: "zone-a"}},
+			Spec:       corev1.NodeSpec{ProviderID: "aws://zone-a/id-zone-a-1"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "zone-a-2", Labels: map[string]string{corev1.LabelZoneFailureDomainStable: "zone-a"}},
+			Spec:       corev1.NodeSpec{ProviderID: "aws://zone-a/id-zone-a-2"},
+		},
+	}
+	zoneBNode := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "zone-b-1", Labels: map[string]string{corev1.LabelZoneFailureDomain: "zone-b"}},
+		Spec:       corev1.NodeSpec{ProviderID: "aws://zone-b/id-zone-b-1"},
+	}
+
+	dt := metav1.Now()
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "delete",
+			Namespace:         "default",
+			Finalizers:        []string{expv1.MachinePoolFinalizer},
+			DeletionTimestamp: &dt,
+		},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName: testCluster.Name,
+			Replicas:    pointer.Int32Ptr(3),
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureConfig",
+						Name:       "delete-infra",
+					},
+				},
+			},
+		},
+		Status: expv1.MachinePoolStatus{
+			NodeRefs: []corev1.ObjectReference{
+				{Name: zoneBNode.Name},
+				{Name: zoneANodes[0].Name},
+				{Name: zoneANodes[1].Name},
+			},
+		},
+	}
+
+	r := &MachinePoolReconciler{
+		Client:                          fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, mp, zoneANodes[0], zoneANodes[1], zoneBNode),
+		Log:                             log.Log,
+		scheme:                          scheme.Scheme,
+		remoteClientGetter:              fakeremote.NewClusterClient,
+		DrainFailureDomainsSequentially: true,
+	}
+
+	// The Nodes of the first failure domain are drained, the others wait for the next reconciliation, cordoned.
+	res, err := r.reconcileDelete(ctx, testCluster, mp)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(res.RequeueAfter).To(Equal(failureDomainDrainWait))
+	g.Expect(mp.Finalizers).To(ContainElement(expv1.MachinePoolFinalizer))
+	for _, node := range zoneANodes {
+		g.Expect(apierrors.IsNotFound(r.Client.Get(ctx, client.ObjectKey{Name: node.Name}, &corev1.Node{}))).To(BeTrue())
+	}
+	node := &corev1.Node{}
+	g.Expect(r.Client.Get(ctx, client.ObjectKey{Name: zoneBNode.Name}, node)).To(Succeed())
+	g.Expect(node.Spec.Unschedulable).To(BeTrue())
+
+	// The next failure domain is drained once the first one is gone.
+	res, err = r.reconcileDelete(ctx, testCluster, mp)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(res.RequeueAfter).To(BeZero())
+	g.Expect(apierrors.IsNotFound(r.Client.Get(ctx, client.ObjectKey{Name: zoneBNode.Name}, &corev1.Node{}))).To(BeTrue())
+	g.Expect(mp.Finalizers).NotTo(ContainElement(expv1.MachinePoolFinalizer))
+}
+
+func TestMachinePoolReconcileMinReplicas(t *testing.T) {
+	testCases := []struct {
+		name             string
+		replicas         int32
+		minReplicas      *int32
+		topologyOwned    bool
+		expectedReplicas int32
+		expectEvent      bool
+	}{
+		{
+			name:             "no minimum replicas",
+			replicas:         0,
+			expectedReplicas: 0,
+		},
+		{
+			name:             "replicas below the minimum are clamped",
+			replicas:         0,
+			minReplicas:      pointer.Int32Ptr(2),
+			expectedReplicas: 2,
+			expectEvent:      true,
+		},
+		{
+			name:             "replicas equal to the minimum are kept",
+			replicas:         2,
+			minReplicas:      pointer.Int32Ptr(2),
+			expectedReplicas: 2,
+		},
+		{
+			name:             "replicas above the minimum are kept",
+			replicas:         5,
+			minReplicas:      pointer.Int32Ptr(2),
+			expectedReplicas: 5,
+		},
+		{
+			name:             "replicas below the minimum of topology managed pools are kept",
+			replicas:         0,
+			minReplicas:      pointer.Int32Ptr(2),
+			topologyOwned:    true,
+			expectedReplicas: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "min-replicas"},
+				Spec: expv1.MachinePoolSpec{
+					Replicas:    pointer.Int32Ptr(tc.replicas),
+					MinReplicas: tc.minReplicas,
+				},
+			}
+			if tc.topologyOwned {
+				mp.Labels = map[string]string{expv1.TopologyOwnedLabel: ""}
+			}
+			recorder := record.NewFakeRecorder(32)
+			r := &MachinePoolReconciler{
+				Log:      log.Log,
+				recorder: recorder,
+			}
+
+			r.reconcileTopologyManaged(mp)
+			r.reconcileMinReplicas(mp)
+			g.Expect(*mp.Spec.Replicas).To(Equal(tc.expectedReplicas))
+			if tc.expectEvent {
+				g.Expect(recorder.Events).To(Receive(ContainSubstring("ReplicasBelowMinimum")))
+			} else {
+				g.Expect(recorder.Events).NotTo(Receive())
+			}
+		})
+	}
+}
+
+func TestMachinePoolReconcileReplicasDefaulted(t *testing.T) {
+	testCases := []struct {
+		name              string
+		replicas          int32
+		annotations       map[string]string
+		conditions        clusterv1.Conditions
+		expectedDefaulted bool
+	}{
+		{
+			name:              "defaulted replicas are reported",
+			replicas:          1,
+			annotations:       map[string]string{expv1.ReplicasDefaultedAnnotation: "1"},
+			expectedDefaulted: true,
+		},
+		{
+			name:     "explicit replicas aren't reported",
+			replicas: 1,
+		},
+		{
+			name:        "changed replicas clear the defaulted condition",
+			replicas:    3,
+			annotations: map[string]string{expv1.ReplicasDefaultedAnnotation: "1"},
+			conditions:  clusterv1.Conditions{*conditions.TrueCondition(expv1.ReplicasDefaultedCondition)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "defaulted", Annotations: tc.annotations},
+				Spec:       expv1.MachinePoolSpec{Replicas: pointer.Int32Ptr(tc.replicas)},
+				Status:     expv1.MachinePoolStatus{Conditions: tc.conditions},
+			}
+
+			r := &MachinePoolReconciler{Log: log.Log}
+			r.reconcileReplicasDefaulted(mp)
+			if tc.expectedDefaulted {
+				g.Expect(conditions.IsTrue(mp, expv1.ReplicasDefaultedCondition)).To(BeTrue())
+				g.Expect(mp.Annotations).To(HaveKey(expv1.ReplicasDefaultedAnnotation))
+			} else {
+				g.Expect(conditions.Has(mp, expv1.ReplicasDefaultedCondition)).To(BeFalse())
+				g.Expect(mp.Annotations).NotTo(HaveKey(expv1.ReplicasDefaultedAnnotation))
+			}
+		})
+	}
+}
+
+func TestMachinePoolReconcileTopologyManaged(t *testing.T) {
+	testCases := []struct {
+		name                    string
+		labels                  map[string]string
+		expectedTopologyManaged bool
+	}{
+		{
+			name:                    "standalone machinePools aren't topology managed",
+			labels:                  map[string]string{clusterv1.ClusterLabelName: "test-cluster"},
+			expectedTopologyManaged: false,
+		},
+		{
+			name:                    "topology owned machinePools are topology managed",
+			labels:                  map[string]string{expv1.TopologyOwnedLabel: ""},
+			expectedTopologyManaged: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "topology", Labels: tc.labels},
+				Status:     expv1.MachinePoolStatus{TopologyManaged: !tc.expectedTopologyManaged},
+			}
+
+			r := &MachinePoolReconciler{Log: log.Log}
+			r.reconcileTopologyManaged(mp)
+			g.Expect(mp.Status.TopologyManaged).To(Equal(tc.expectedTopologyManaged))
+		})
+	}
+}
+
+func TestMachinePoolClusterLabel(t *testing.T) {
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	testCases := []struct {
+		name           string
+		labels         map[string]string
+		expectedLabels map[string]string
+	}{
+		{
+			name:   "should add the cluster name label to a machinePool without labels",
+			labels: nil,
+			expectedLabels: map[string]string{
+				clusterv1.ClusterLabelName: testCluster.Name,
+			},
+		},
+		{
+			name:   "should add the cluster name label to a machinePool with other labels",
+			labels: map[string]string{"foo": "bar"},
+			expectedLabels: map[string]string{
+				"foo":                      "bar",
+				clusterv1.ClusterLabelName: testCluster.Name,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "unlabeled",
+					Namespace: "default",
+					Labels:    tc.labels,
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: testCluster.Name,
+					Replicas:    pointer.Int32Ptr(1),
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, mp),
+				Log:      log.Log,
+				scheme:   scheme.Scheme,
+				recorder: record.NewFakeRecorder(32),
+			}
+
+			_, err := r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(mp)})
+			g.Expect(err).NotTo(HaveOccurred())
+
+			actual := &expv1.MachinePool{}
+			g.Expect(r.Client.Get(ctx, util.ObjectKey(mp), actual)).To(Succeed())
+			g.Expect(actual.Labels).To(Equal(tc.expectedLabels))
+		})
+	}
+}
+
+// blockingClient blocks reads of external objects until the context of the call is done.
+type blockingClient struct {
+	client.Client
+}
+
+func (c *blockingClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if _, ok := obj.(*unstructured.Unstructured); ok {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestMachinePoolReconcileSelector(t *testing.T) {
+	testCases := []struct {
+		name             string
+		clusterName      string
+		machinePoolName  string
+		expectedSelector string
+	}{
+		{
+			name:             "should select the objects of the machinePool",
+			clusterName:      "test-cluster",
+			machinePoolName:  "pool",
+			expectedSelector: "cluster.x-k8s.io/cluster-name=test-cluster,exp.cluster.x-k8s.io/machine-pool-name=pool",
+		},
+		{
+			name:             "should select the objects of a machinePool named after its cluster",
+			clusterName:      "test-cluster",
+			machinePoolName:  "test-cluster",
+			expectedSelector: "cluster.x-k8s.io/cluster-name=test-cluster,exp.cluster.x-k8s.io/machine-pool-name=test-cluster",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: tc.machinePoolName},
+				Spec:       expv1.MachinePoolSpec{ClusterName: tc.clusterName},
+				Status:     expv1.MachinePoolStatus{Selector: "stale=true"},
+			}
+
+			r := &MachinePoolReconciler{Log: log.Log}
+			r.reconcileSelector(mp)
+			g.Expect(mp.Status.Selector).To(Equal(tc.expectedSelector))
+
+			selector, err := labels.Parse(mp.Status.Selector)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(selector.Matches(labels.Set{
+				clusterv1.ClusterLabelName: tc.clusterName,
+				expv1.MachinePoolNameLabel: tc.machinePoolName,
+			})).To(BeTrue())
+			g.Expect(selector.Matches(labels.Set{clusterv1.ClusterLabelName: tc.clusterName})).To(BeFalse())
+		})
+	}
+}
+
+func TestMachinePoolReconcileTimeout(t *testing.T) {
+	g := NewWithT(t)
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "timeout",
+			Namespace:  "default",
+			Finalizers: []string{expv1.MachinePoolFinalizer},
+		},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName: testCluster.Name,
+			Replicas:    pointer.Int32Ptr(1),
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					Bootstrap: clusterv1.Bootstrap{
+						ConfigRef: &corev1.ObjectReference{
+							APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+							Kind:       "BootstrapConfig",
+							Name:       "bootstrap-config1",
+						},
+					},
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureConfig",
+						Name:       "infra-config1",
+					},
+				},
+			},
+		},
+	}
+
+	r := &MachinePoolReconciler{
+		Client:           &blockingClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, mp)},
+		Log:              log.Log,
+		scheme:           scheme.Scheme,
+		ReconcileTimeout: 100 * time.Millisecond,
+		recorder:         record.NewFakeRecorder(32),
+	}
+
+	start := time.Now()
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(mp)})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring(context.DeadlineExceeded.Error()))
+	g.Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+}
+
+func TestMachinePoolLastError(t *testing.T) {
+	g := NewWithT(t)
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	// The bootstrap config doesn't exist, so that reconciling without timing out is requeued without error.
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "last-error",
+			Namespace:  "default",
+			Finalizers: []string{expv1.MachinePoolFinalizer},
+		},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName: testCluster.Name,
+			Replicas:    pointer.Int32Ptr(1),
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					Bootstrap: clusterv1.Bootstrap{
+						ConfigRef: &corev1.ObjectReference{
+							APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+							Kind:       "BootstrapConfig",
+							Name:       "missing-bootstrap",
+						},
+					},
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureConfig",
+						Name:       "missing-infra",
+					},
+				},
+			},
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, mp)
+	r := &MachinePoolReconciler{
+		Client:           &blockingClient{Client: c},
+		Log:              log.Log,
+		scheme:           scheme.Scheme,
+		ReconcileTimeout: 100 * time.Millisecond,
+		recorder:         record.NewFakeRecorder(32),
+	}
+
+	// The error of a failed reconciliation is reported in the status.
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(mp)})
+	g.Expect(err).To(HaveOccurred())
+	actual := &expv1.MachinePool{}
+	g.Expect(c.Get(ctx, util.ObjectKey(mp), actual)).To(Succeed())
+	g.Expect(actual.Status.LastError).To(ContainSubstring(context.DeadlineExceeded.Error()))
+	g.Expect(actual.Status.FailureReason).To(BeNil())
+	g.Expect(actual.Status.FailureMessage).To(BeNil())
+
+	// It is cleared by the next successful reconciliation.
+	r.Client = c
+	_, err = r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(mp)})
+	g.Expect(err).NotTo(HaveOccurred())
+	actual = &expv1.MachinePool{}
+	g.Expect(c.Get(ctx, util.ObjectKey(mp), actual)).To(Succeed())
+	g.Expect(actual.Status.LastError).To(BeEmpty())
+}
+
+func TestMachinePoolSkipReconcile(t *testing.T) {
+	g := NewWithT(t)
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "skip-reconcile",
+			Namespace:   "default",
+			Annotations: map[string]string{expv1.SkipReconcileAnnotation: "investigating stuck scale up"},
+		},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName: testCluster.Name,
+			Replicas:    pointer.Int32Ptr(1),
+		},
+	}
+
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, mp),
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	// With the annotation, only the reason is reported.
+	res, err := r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(mp)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(res).To(Equal(ctrl.Result{}))
+	actual := &expv1.MachinePool{}
+	g.Expect(r.Client.Get(ctx, util.ObjectKey(mp), actual)).To(Succeed())
+	g.Expect(conditions.IsTrue(actual, expv1.ReconcileSkippedCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(actual, expv1.ReconcileSkippedCondition)).To(Equal(expv1.SkipReconcileRequestedReason))
+	g.Expect(conditions.GetMessage(actual, expv1.ReconcileSkippedCondition)).To(Equal("investigating stuck scale up"))
+	g.Expect(actual.Finalizers).To(BeEmpty())
+	g.Expect(actual.Labels).NotTo(HaveKey(clusterv1.ClusterLabelName))
+	g.Expect(actual.Status.Phase).To(BeEmpty())
+
+	// Without the annotation, the MachinePool is reconciled again and the condition removed.
+	delete(actual.Annotations, expv1.SkipReconcileAnnotation)
+	g.Expect(r.Client.Update(ctx, actual)).To(Succeed())
+	_, err = r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(mp)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r.Client.Get(ctx, util.ObjectKey(mp), actual)).To(Succeed())
+	g.Expect(conditions.Has(actual, expv1.ReconcileSkippedCondition)).To(BeFalse())
+	g.Expect(actual.Finalizers).To(ContainElement(expv1.MachinePoolFinalizer))
+	g.Expect(actual.Labels).To(HaveKeyWithValue(clusterv1.ClusterLabelName, testCluster.Name))
+}
+
+func TestReconcileLastErrorTruncated(t *testing.T) {
+	g := NewWithT(t)
+
+	mp := &expv1.MachinePool{}
+	reconcileLastError(mp, errors.New(strings.Repeat("a", 2*maxLastErrorLength)))
+	g.Expect(mp.Status.LastError).To(HaveLen(maxLastErrorLength))
+	g.Expect(mp.Status.LastError).To(HaveSuffix("..."))
+
+	reconcileLastError(mp, nil)
+	g.Expect(mp.Status.LastError).To(BeEmpty())
+}
+
+func TestMachinePoolLastReconcileTime(t *testing.T) {
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+		Status:     clusterv1.ClusterStatus{ControlPlaneInitialized: true},
+	}
+
+	twoMinutesAgo := metav1.NewTime(time.Now().Add(-2 * time.Minute).Truncate(time.Second))
+	tenSecondsAgo := metav1.NewTime(time.Now().Add(-10 * time.Second).Truncate(time.Second))
+
+	testCases := []struct {
+		name              string
+		finalizers        []string
+		ownerReferences   []metav1.OwnerReference
+		lastReconcileTime *metav1.Time
+		expectAdvanced    bool
+	}{
+		{
+			name:           "is set on a successful reconciliation",
+			expectAdvanced: true,
+		},
+		{
+			name:              "advances on a successful reconciliation",
+			lastReconcileTime: &twoMinutesAgo,
+			expectAdvanced:    true,
+		},
+		{
+			name:              "doesn't advance within its resolution",
+			lastReconcileTime: &tenSecondsAgo,
+			expectAdvanced:    false,
+		},
+		{
+			name:              "advances when the successful reconciliation is requeued",
+			finalizers:        []string{expv1.MachinePoolFinalizer},
+			lastReconcileTime: &twoMinutesAgo,
+			expectAdvanced:    true,
+		},
+		{
+			name:       "doesn't advance when the reconciliation fails",
+			finalizers: []string{expv1.MachinePoolFinalizer},
+			ownerReferences: []metav1.OwnerReference{
+				{APIVersion: "invalid/api/version", Kind: "Owner", Name: "owner"},
+			},
+			lastReconcileTime: &twoMinutesAgo,
+			expectAdvanced:    false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			// The bootstrap config doesn't exist, so that reconciling past the finalizer is requeued.
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "last-reconcile-time",
+					Namespace:       "default",
+					Finalizers:      tc.finalizers,
+					OwnerReferences: tc.ownerReferences,
+				},
+				Spec: expv1.MachinePoolSpec{
+					ClusterName: testCluster.Name,
+					Replicas:    pointer.Int32Ptr(1),
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							Bootstrap: clusterv1.Bootstrap{
+								ConfigRef: &corev1.ObjectReference{
+									APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+									Kind:       "BootstrapConfig",
+									Name:       "missing-bootstrap",
+								},
+							},
+							InfrastructureRef: corev1.ObjectReference{
+								APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+								Kind:       "InfrastructureConfig",
+								Name:       "missing-infra",
+							},
+						},
+					},
+				},
+				Status: expv1.MachinePoolStatus{
+					LastReconcileTime: tc.lastReconcileTime,
+				},
+			}
+
+			r := &MachinePoolReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, mp),
+				Log:      log.Log,
+				scheme:   scheme.Scheme,
+				recorder: record.NewFakeRecorder(32),
+			}
+
+			_, _ = r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(mp)})
+
+			actual := &expv1.MachinePool{}
+			g.Expect(r.Client.Get(ctx, util.ObjectKey(mp), actual)).To(Succeed())
+			if !tc.expectAdvanced {
+				g.Expect(actual.Status.LastReconcileTime.Time).To(BeTemporally("==", tc.lastReconcileTime.Time))
+				return
+			}
+			g.Expect(actual.Status.LastReconcileTime).NotTo(BeNil())
+			g.Expect(actual.Status.LastReconcileTime.Time).To(BeTemporally("~", time.Now(), 5*time.Second))
+		})
+	}
+}
+
+func TestMachinePoolReconcilerClock(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeClock := clock.NewFakeClock(time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC))
+	r := &MachinePoolReconciler{
+		Log:                              log.Log,
+		InfrastructureMissingGracePeriod: time.Minute,
+		clock:                            fakeClock,
+	}
+
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "clock"},
+		Spec: expv1.MachinePoolSpec{
+			NodeStartupTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+		},
+	}
+
+	// The infrastructure missing grace period is measured with the clock.
+	g.Expect(r.infrastructureMissingGracePeriodElapsed(mp)).To(BeFalse())
+	g.Expect(mp.Annotations).To(HaveKeyWithValue(expv1.InfrastructureMissingSinceAnnotation, "2020-06-01T12:00:00Z"))
+	fakeClock.Step(59 * time.Second)
+	g.Expect(r.infrastructureMissingGracePeriodElapsed(mp)).To(BeFalse())
+	fakeClock.Step(time.Second)
+	g.Expect(r.infrastructureMissingGracePeriodElapsed(mp)).To(BeTrue())
+
+	// So is the node startup timeout.
+	notReadySince := []metav1.Time{metav1.NewTime(fakeClock.Now())}
+	fakeClock.Step(10 * time.Minute)
+	r.reconcileNodeStartupTimeout(mp, notReadySince)
+	g.Expect(mp.Status.UnhealthyReplicas).To(BeEquivalentTo(0))
+	fakeClock.Step(time.Second)
+	r.reconcileNodeStartupTimeout(mp, notReadySince)
+	g.Expect(mp.Status.UnhealthyReplicas).To(BeEquivalentTo(1))
+
+	// And the last reconcile time.
+	r.reconcileLastReconcileTime(mp)
+	g.Expect(mp.Status.LastReconcileTime.Time).To(BeTemporally("==", fakeClock.Now()))
+}
+
+func TestMachinePoolRecordReconcileError(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeClock := clock.NewFakeClock(time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC))
+	recorder := record.NewFakeRecorder(32)
+	r := &MachinePoolReconciler{
+		Log:      log.Log,
+		recorder: recorder,
+		clock:    fakeClock,
+	}
+
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "errors"},
+	}
+	otherMP := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other-errors"},
+	}
+
+	// The first occurrence of an error is recorded.
+	r.recordReconcileError(mp, errors.New("failed to reach infrastructure"))
+	g.Expect(recorder.Events).To(Receive(Equal("Warning ReconcileError failed to reach infrastructure")))
+
+	// Duplicates within the interval are not, unless they are for another MachinePool.
+	fakeClock.Step(reconcileErrorEventInterval - time.Second)
+	r.recordReconcileError(mp, errors.New("failed to reach infrastructure"))
+	g.Expect(recorder.Events).NotTo(Receive())
+	r.recordReconcileError(otherMP, errors.New("failed to reach infrastructure"))
+	g.Expect(recorder.Events).To(Receive(Equal("Warning ReconcileError failed to reach infrastructure")))
+
+	// A different error is recorded straight away.
+	r.recordReconcileError(mp, errors.New("failed to reach bootstrap"))
+	g.Expect(recorder.Events).To(Receive(Equal("Warning ReconcileError failed to reach bootstrap")))
+
+	// The same error is recorded again once the interval elapsed.
+	fakeClock.Step(reconcileErrorEventInterval)
+	r.recordReconcileError(mp, errors.New("failed to reach bootstrap"))
+	g.Expect(recorder.Events).To(Receive(Equal("Warning ReconcileError failed to reach bootstrap")))
+
+	// A successful reconciliation resets the throttling.
+	r.recordReconcileError(mp, nil)
+	g.Expect(recorder.Events).NotTo(Receive())
+	r.recordReconcileError(mp, errors.New("failed to reach bootstrap"))
+	g.Expect(recorder.Events).To(Receive(Equal("Warning ReconcileError failed to reach bootstrap")))
+}
+
+func TestMachinePoolReconcileForgetsDeletedReconcileErrors(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(apiextensionsv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	recorder := record.NewFakeRecorder(32)
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme),
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: recorder,
+	}
+
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "deleted-while-erroring"},
+	}
+	r.recordReconcileError(mp, errors.New("failed to reach infrastructure"))
+	g.Expect(recorder.Events).To(Receive())
+
+	// The throttled error of a MachinePool which is gone is forgotten.
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(mp)})
+	g.Expect(err).NotTo(HaveOccurred())
+	_, ok := r.reconcileErrorEvents.Load(util.ObjectKey(mp).String())
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestMachinePoolRecordConditionTransitions(t *testing.T) {
+	testCases := []struct {
+		name           string
+		before         []*clusterv1.Condition
+		after          []*clusterv1.Condition
+		expectedEvents []string
+	}{
+		{
+			name:   "stable conditions don't record events",
+			before: []*clusterv1.Condition{conditions.TrueCondition(clusterv1.ReadyCondition)},
+			after:  []*clusterv1.Condition{conditions.TrueCondition(clusterv1.ReadyCondition)},
+		},
+		{
+			name: "stable False conditions with a new reason don't record events",
+			before: []*clusterv1.Condition{
+				conditions.FalseCondition(clusterv1.InfrastructureReadyCondition, "Provisioning", clusterv1.ConditionSeverityInfo, ""),
+			},
+			after: []*clusterv1.Condition{
+				conditions.FalseCondition(clusterv1.InfrastructureReadyCondition, "ScalingUp", clusterv1.ConditionSeverityInfo, ""),
+			},
+		},
+		{
+			name: "conditions becoming True record a normal event",
+			before: []*clusterv1.Condition{
+				conditions.FalseCondition(clusterv1.BootstrapReadyCondition, clusterv1.WaitingForDataSecretFallbackReason, clusterv1.ConditionSeverityInfo, ""),
+			},
+			after:          []*clusterv1.Condition{conditions.TrueCondition(clusterv1.BootstrapReadyCondition)},
+			expectedEvents: []string{"Normal BootstrapReady Condition BootstrapReady changed from False to True"},
+		},
+		{
+			name:   "conditions becoming False record a warning event with the reason",
+			before: []*clusterv1.Condition{conditions.TrueCondition(clusterv1.InfrastructureReadyCondition)},
+			after: []*clusterv1.Condition{
+				conditions.FalseCondition(clusterv1.InfrastructureReadyCondition, clusterv1.WaitingForInfrastructureFallbackReason, clusterv1.ConditionSeverityInfo, ""),
+			},
+			expectedEvents: []string{"Warning InfrastructureReady Condition InfrastructureReady changed from True to False: WaitingForInfrastructure"},
+		},
+		{
+			name: "conditions set for the first time don't record events",
+			after: []*clusterv1.Condition{
+				conditions.FalseCondition(clusterv1.ReadyCondition, clusterv1.WaitingForInfrastructureFallbackReason, clusterv1.ConditionSeverityInfo, ""),
+			},
+		},
+		{
+			name:   "other conditions don't record events",
+			before: []*clusterv1.Condition{conditions.TrueCondition(expv1.NodesHealthyCondition)},
+			after: []*clusterv1.Condition{
+				conditions.FalseCondition(expv1.NodesHealthyCondition, clusterv1.UnhealthyNodeConditionReason, clusterv1.ConditionSeverityWarning, ""),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			initial := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "conditions"},
+			}
+			for _, c := range tc.before {
+				conditions.Set(initial, c)
+			}
+			mp := initial.DeepCopy()
+			for _, c := range tc.after {
+				conditions.Set(mp, c)
+			}
+
+			recorder := record.NewFakeRecorder(32)
+			r := &MachinePoolReconciler{
+				Log:      log.Log,
+				recorder: recorder,
+			}
+
+			r.recordConditionTransitions(initial, mp)
+			for _, event := range tc.expectedEvents {
+				g.Expect(recorder.Events).To(Receive(Equal(event)))
+			}
+			g.Expect(recorder.Events).NotTo(Receive())
+		})
+	}
+}
+
+func TestMachinePoolReconcileReadyConditions(t *testing.T) {
+	g := NewWithT(t)
+
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "conditions"},
+	}
+
+	reconcileReadyConditions(mp)
+	g.Expect(conditions.IsFalse(mp, clusterv1.BootstrapReadyCondition)).To(BeTrue())
+	g.Expect(conditions.IsFalse(mp, clusterv1.InfrastructureReadyCondition)).To(BeTrue())
+	g.Expect(conditions.IsFalse(mp, clusterv1.ReadyCondition)).To(BeTrue())
+
+	mp.Status.BootstrapReady = true
+	mp.Status.InfrastructureReady = true
+	reconcileReadyConditions(mp)
+	g.Expect(conditions.IsTrue(mp, clusterv1.BootstrapReadyCondition)).To(BeTrue())
+	g.Expect(conditions.IsTrue(mp, clusterv1.InfrastructureReadyCondition)).To(BeTrue())
+	g.Expect(conditions.IsTrue(mp, clusterv1.ReadyCondition)).To(BeTrue())
+}
+
+func TestMachinePoolReconcileImmutableTemplateFields(t *testing.T) {
+	testCases := []struct {
+		name                string
+		infrastructureReady bool
+		annotations         map[string]string
+		expectErr           bool
+		expectedAnnotation  string
+	}{
+		{
+			name: "fields aren't recorded before the infrastructure is ready",
+		},
+		{
+			name:                "fields are recorded once the infrastructure is ready",
+			infrastructureReady: true,
+			expectedAnnotation:  `{"spec.infrastructureRef.kind":"\"InfrastructureConfig\""}`,
+		},
+		{
+			name:                "unchanged fields are accepted",
+			infrastructureReady: true,
+			annotations:         map[string]string{expv1.ImmutableTemplateFieldsAnnotation: `{"spec.infrastructureRef.kind":"\"InfrastructureConfig\""}`},
+			expectedAnnotation:  `{"spec.infrastructureRef.kind":"\"InfrastructureConfig\""}`,
+		},
+		{
+			name:                "paths configured since the fields were recorded are recorded",
+			infrastructureReady: true,
+			annotations:         map[string]string{expv1.ImmutableTemplateFieldsAnnotation: `{}`},
+			expectedAnnotation:  `{"spec.infrastructureRef.kind":"\"InfrastructureConfig\""}`,
+		},
+		{
+			name:               "changed fields are rejected",
+			annotations:        map[string]string{expv1.ImmutableTemplateFieldsAnnotation: `{"spec.infrastructureRef.kind":"\"OtherInfrastructureConfig\""}`},
+			expectErr:          true,
+			expectedAnnotation: `{"spec.infrastructureRef.kind":"\"OtherInfrastructureConfig\""}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mp := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machinepool-test", Annotations: tc.annotations},
+				Spec: expv1.MachinePoolSpec{
+					Template: clusterv1.MachineTemplateSpec{
+						Spec: clusterv1.MachineSpec{
+							InfrastructureRef: corev1.ObjectReference{Kind: "InfrastructureConfig", Name: "infra-config1"},
+						},
+					},
+				},
+				Status: expv1.MachinePoolStatus{InfrastructureReady: tc.infrastructureReady},
+			}
+
+			r := &MachinePoolReconciler{Log: log.Log, ImmutableTemplatePaths: []string{"spec.infrastructureRef.kind"}}
+			err := r.reconcileImmutableTemplateFields(mp)
+			if tc.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring("spec.infrastructureRef.kind"))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			if tc.expectedAnnotation == "" {
+				g.Expect(mp.Annotations).NotTo(HaveKey(expv1.ImmutableTemplateFieldsAnnotation))
+			} else {
+				g.Expect(mp.Annotations).To(HaveKeyWithValue(expv1.ImmutableTemplateFieldsAnnotation, tc.expectedAnnotation))
+			}
+		})
+	}
 }
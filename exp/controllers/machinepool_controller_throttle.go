@@ -0,0 +1,98 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// apiThrottleWait returns how long reconciliations are still deferred for, after the management cluster API server
+// rejected requests of the controller with 429 responses.
+func (r *MachinePoolReconciler) apiThrottleWait() time.Duration {
+	r.throttleLock.Lock()
+	defer r.throttleLock.Unlock()
+
+	if r.throttleBackoff == 0 {
+		return 0
+	}
+	return r.throttledUntil.Sub(r.now())
+}
+
+// throttleRequeue adapts the global backoff to the result of a reconciliation. Once the previous backoff elapsed,
+// the backoff is doubled, up to apiThrottleMaxBackoff, if the reconciliation was rate limited by the management
+// cluster API server, and halved otherwise until the API server recovered. Rate limited reconciliations are requeued
+// after the backoff rather than returning their error, and other requeues are delayed to at least the backoff.
+func (r *MachinePoolReconciler) throttleRequeue(res ctrl.Result, err error) (ctrl.Result, error) {
+	r.throttleLock.Lock()
+	defer r.throttleLock.Unlock()
+
+	now := r.now()
+	elapsed := !now.Before(r.throttledUntil)
+	if !isTooManyRequests(err) {
+		if r.throttleBackoff == 0 || !elapsed {
+			return res, err
+		}
+		r.throttleBackoff /= 2
+		if r.throttleBackoff < apiThrottleInitialBackoff {
+			r.throttleBackoff = 0
+			r.Log.Info("Management cluster API server recovered, reconciliation of MachinePools is no longer throttled")
+			return res, err
+		}
+		r.throttledUntil = now.Add(r.throttleBackoff)
+		if err == nil && (res.Requeue || res.RequeueAfter > 0) && res.RequeueAfter < r.throttleBackoff {
+			res.RequeueAfter = r.throttleBackoff
+		}
+		return res, err
+	}
+
+	switch {
+	case r.throttleBackoff == 0:
+		r.throttleBackoff = apiThrottleInitialBackoff
+	case elapsed:
+		r.throttleBackoff *= 2
+		if r.throttleBackoff > apiThrottleMaxBackoff {
+			r.throttleBackoff = apiThrottleMaxBackoff
+		}
+	}
+	if elapsed {
+		r.throttledUntil = now.Add(r.throttleBackoff)
+	}
+	r.Log.Error(err, "Management cluster API server is rate limiting requests, throttling reconciliation of MachinePools",
+		"backoff", r.throttleBackoff)
+	return ctrl.Result{RequeueAfter: r.throttledUntil.Sub(now)}, nil
+}
+
+// isTooManyRequests returns true if the error, or one of the errors it aggregates, is a 429 response.
+func isTooManyRequests(err error) bool {
+	if err == nil {
+		return false
+	}
+	if agg, ok := err.(kerrors.Aggregate); ok {
+		for _, err := range agg.Errors() {
+			if isTooManyRequests(err) {
+				return true
+			}
+		}
+		return false
+	}
+	return apierrors.IsTooManyRequests(errors.Cause(err))
+}
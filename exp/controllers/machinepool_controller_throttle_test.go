@@ -0,0 +1,123 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// tooManyRequestsClient rejects reads with 429 responses while rateLimited is true, and counts the reads.
+type tooManyRequestsClient struct {
+	client.Client
+	rateLimited bool
+	gets        int
+}
+
+func (c *tooManyRequestsClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	c.gets++
+	if c.rateLimited {
+		return apierrors.NewTooManyRequests("the server has received too many requests", 1)
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestMachinePoolReconcileThrottle(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeClock := clock.NewFakeClock(time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC))
+	c := &tooManyRequestsClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme), rateLimited: true}
+	r := &MachinePoolReconciler{
+		Client:   c,
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(32),
+		clock:    fakeClock,
+	}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "machinepool-test"}}
+
+	// Rate limited reconciliations are requeued with an increasing backoff rather than failing.
+	var previous time.Duration
+	for i := 0; i < 3; i++ {
+		fakeClock.Step(previous)
+		res, err := r.Reconcile(request)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(res.RequeueAfter).To(BeNumerically(">", previous))
+		previous = res.RequeueAfter
+	}
+	g.Expect(previous).To(Equal(4 * apiThrottleInitialBackoff))
+
+	// Reconciliations during the backoff are deferred without calling the API server.
+	res, err := r.Reconcile(request)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(res.RequeueAfter).To(Equal(previous))
+	fakeClock.Step(time.Second)
+	gets := c.gets
+	res, err = r.Reconcile(request)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(res.RequeueAfter).To(Equal(previous - time.Second))
+	g.Expect(c.gets).To(Equal(gets))
+
+	// The backoff is capped.
+	for i := 0; i < 10; i++ {
+		fakeClock.Step(res.RequeueAfter)
+		res, err = r.Reconcile(request)
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+	g.Expect(res.RequeueAfter).To(Equal(apiThrottleMaxBackoff))
+
+	// The backoff decreases once the API server recovers, until reconciliations aren't throttled anymore.
+	c.rateLimited = false
+	for backoff := apiThrottleMaxBackoff; backoff >= apiThrottleInitialBackoff; backoff /= 2 {
+		g.Expect(r.apiThrottleWait()).To(BeNumerically(">", 0))
+		fakeClock.Step(r.apiThrottleWait())
+		_, err = r.Reconcile(request)
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+	g.Expect(r.throttleBackoff).To(BeZero())
+	g.Expect(r.apiThrottleWait()).To(BeNumerically("<=", 0))
+}
+
+func TestIsTooManyRequests(t *testing.T) {
+	g := NewWithT(t)
+
+	tooManyRequests := apierrors.NewTooManyRequests("the server has received too many requests", 1)
+	g.Expect(isTooManyRequests(nil)).To(BeFalse())
+	g.Expect(isTooManyRequests(errors.New("boom"))).To(BeFalse())
+	g.Expect(isTooManyRequests(tooManyRequests)).To(BeTrue())
+	g.Expect(isTooManyRequests(errors.Wrap(tooManyRequests, "failed to get MachinePool"))).To(BeTrue())
+	g.Expect(isTooManyRequests(kerrors.NewAggregate([]error{
+		errors.New("boom"),
+		kerrors.NewAggregate([]error{errors.Wrap(tooManyRequests, "failed to patch MachinePool")}),
+	}))).To(BeTrue())
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer starts spans around the phases of a MachinePool reconciliation.
+// It is intentionally small so that it can be backed by any tracing library, e.g. by
+// OpenTelemetry with NewOpenTelemetryTracer.
+type Tracer interface {
+	// Start creates a span with the given name as a child of the span in ctx, if any.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single operation traced by a Tracer.
+type Span interface {
+	// End completes the span.
+	End()
+
+	// TraceParent returns the span context in the W3C traceparent format, used to propagate
+	// the trace to the provider controllers.
+	TraceParent() string
+}
+
+type spanContextKey struct{}
+
+type noopSpan struct{}
+
+func (noopSpan) End() {}
+
+func (noopSpan) TraceParent() string { return "" }
+
+// startSpan starts a span with the given name if tracing is enabled, and returns a no-op span otherwise.
+func (r *MachinePoolReconciler) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if r.Tracer == nil {
+		return ctx, noopSpan{}
+	}
+	ctx, span := r.Tracer.Start(ctx, name)
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// traceParentFrom returns the traceparent of the current span in ctx, if any.
+func traceParentFrom(ctx context.Context) string {
+	span, ok := ctx.Value(spanContextKey{}).(Span)
+	if !ok {
+		return ""
+	}
+	return span.TraceParent()
+}
+
+// NewOpenTelemetryTracer returns a Tracer creating its spans with the given OpenTelemetry tracer.
+func NewOpenTelemetryTracer(tracer trace.Tracer) Tracer {
+	return &otelTracer{tracer: tracer}
+}
+
+type otelTracer struct {
+	tracer trace.Tracer
+}
+
+func (t *otelTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	ctx, span := t.tracer.Start(ctx, name)
+	return ctx, &otelSpan{span: span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s *otelSpan) End() {
+	s.span.End()
+}
+
+func (s *otelSpan) TraceParent() string {
+	carrier := propagation.HeaderCarrier(http.Header{})
+	propagation.TraceContext{}.Inject(trace.ContextWithSpan(context.Background(), s.span), carrier)
+	return carrier.Get("traceparent")
+}
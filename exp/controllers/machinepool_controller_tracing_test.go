@@ -0,0 +1,199 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// inMemoryTracer records the spans it creates.
+type inMemoryTracer struct {
+	lock    sync.Mutex
+	started []string
+	ended   []string
+}
+
+type inMemorySpan struct {
+	name   string
+	tracer *inMemoryTracer
+}
+
+func (t *inMemoryTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.started = append(t.started, name)
+	return ctx, &inMemorySpan{name: name, tracer: t}
+}
+
+func (s *inMemorySpan) End() {
+	s.tracer.lock.Lock()
+	defer s.tracer.lock.Unlock()
+	s.tracer.ended = append(s.tracer.ended, s.name)
+}
+
+func (s *inMemorySpan) TraceParent() string {
+	return testTraceParent
+}
+
+func TestMachinePoolReconcileTracing(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+		Status:     clusterv1.ClusterStatus{ControlPlaneInitialized: true},
+	}
+
+	bootstrapConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "BootstrapConfig",
+			"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "bootstrap-config1",
+				"namespace": "default",
+			},
+			"status": map[string]interface{}{
+				"ready":          true,
+				"dataSecretName": "secret-data",
+			},
+		},
+	}
+
+	infraConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureConfig",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "infra-config1",
+				"namespace": "default",
+			},
+			"status": map[string]interface{}{
+				"ready": false,
+			},
+		},
+	}
+
+	mp := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "tracing",
+			Namespace:  "default",
+			Generation: 1,
+			Finalizers: []string{expv1.MachinePoolFinalizer},
+		},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName: "test-cluster",
+			Replicas:    pointer.Int32Ptr(1),
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					Bootstrap: clusterv1.Bootstrap{
+						ConfigRef: &corev1.ObjectReference{
+							APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+							Kind:       "BootstrapConfig",
+							Name:       "bootstrap-config1",
+						},
+					},
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureConfig",
+						Name:       "infra-config1",
+					},
+				},
+			},
+		},
+	}
+
+	tracer := &inMemoryTracer{}
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, mp, bootstrapConfig, infraConfig),
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		Tracer:   tracer,
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: client.ObjectKey{Namespace: mp.Namespace, Name: mp.Name}})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(tracer.started).To(Equal([]string{"Reconcile", "reconcileBootstrap", "reconcileInfrastructure", "reconcilePhase"}))
+	g.Expect(tracer.ended).To(ConsistOf(tracer.started))
+
+	for _, obj := range []*unstructured.Unstructured{bootstrapConfig, infraConfig} {
+		actual := &unstructured.Unstructured{}
+		actual.SetGroupVersionKind(obj.GroupVersionKind())
+		g.Expect(r.Client.Get(context.Background(), client.ObjectKey{Namespace: obj.GetNamespace(), Name: obj.GetName()}, actual)).To(Succeed())
+		g.Expect(actual.GetAnnotations()).To(HaveKeyWithValue(expv1.TraceParentAnnotation, testTraceParent))
+	}
+}
+
+func TestMachinePoolReconcileTracingDisabled(t *testing.T) {
+	g := NewWithT(t)
+
+	r := &MachinePoolReconciler{}
+	ctx, span := r.startSpan(context.Background(), "Reconcile")
+	defer span.End()
+
+	g.Expect(span).To(Equal(noopSpan{}))
+	g.Expect(traceParentFrom(ctx)).To(BeEmpty())
+}
+
+func TestMachinePoolOpenTelemetryTracer(t *testing.T) {
+	g := NewWithT(t)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	r := &MachinePoolReconciler{
+		Tracer: NewOpenTelemetryTracer(tracerProvider.Tracer("test")),
+	}
+
+	ctx, reconcileSpan := r.startSpan(context.Background(), "Reconcile")
+	_, phaseSpan := r.startSpan(ctx, "reconcilePhase")
+	phaseSpan.End()
+	reconcileSpan.End()
+
+	spans := exporter.GetSpans()
+	g.Expect(spans).To(HaveLen(2))
+	g.Expect(spans[0].Name).To(Equal("reconcilePhase"))
+	g.Expect(spans[1].Name).To(Equal("Reconcile"))
+	g.Expect(spans[0].Parent.SpanID()).To(Equal(spans[1].SpanContext.SpanID()))
+	g.Expect(spans[0].SpanContext.TraceID()).To(Equal(spans[1].SpanContext.TraceID()))
+
+	// The traceparent of the current span identifies it to the provider controllers.
+	sc := spans[1].SpanContext
+	g.Expect(traceParentFrom(ctx)).To(Equal("00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"))
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+)
+
+// warmUpExternalWatches adds the watchers for the kinds of the bootstrap and infrastructure objects referenced by
+// all MachinePools. It runs once the manager is elected leader, so that a new leader sets up its watchers at once
+// rather than one by one as the first reconciliations of the MachinePools come in.
+func (r *MachinePoolReconciler) warmUpExternalWatches(ctx context.Context) error {
+	mpList := &expv1.MachinePoolList{}
+	if err := r.Client.List(ctx, mpList); err != nil {
+		return errors.Wrap(err, "failed to list MachinePools to warm up external watchers")
+	}
+
+	for i := range mpList.Items {
+		mp := &mpList.Items[i]
+		refs := []*corev1.ObjectReference{&mp.Spec.Template.Spec.InfrastructureRef}
+		if mp.Spec.Template.Spec.Bootstrap.ConfigRef != nil {
+			refs = append(refs, mp.Spec.Template.Spec.Bootstrap.ConfigRef)
+		}
+
+		for _, ref := range refs {
+			if ref.Kind == "" {
+				continue
+			}
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(ref.GroupVersionKind())
+			if err := r.watchExternal(obj); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// watchRecordingController is a controller recording the kinds it is asked to watch.
+type watchRecordingController struct {
+	watched []schema.GroupVersionKind
+}
+
+func (c *watchRecordingController) Reconcile(reconcile.Request) (reconcile.Result, error) {
+	return reconcile.Result{}, nil
+}
+
+func (c *watchRecordingController) Watch(src source.Source, _ handler.EventHandler, _ ...predicate.Predicate) error {
+	c.watched = append(c.watched, src.(*source.Kind).Type.GetObjectKind().GroupVersionKind())
+	return nil
+}
+
+func (c *watchRecordingController) Start(<-chan struct{}) error {
+	return nil
+}
+
+func TestMachinePoolWarmUpExternalWatches(t *testing.T) {
+	g := NewWithT(t)
+
+	newMachinePool := func(name, bootstrapKind, infraKind string) *expv1.MachinePool {
+		mp := &expv1.MachinePool{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: expv1.MachinePoolSpec{
+				Template: clusterv1.MachineTemplateSpec{
+					Spec: clusterv1.MachineSpec{
+						InfrastructureRef: corev1.ObjectReference{
+							APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+							Kind:       infraKind,
+							Name:       name,
+						},
+					},
+				},
+			},
+		}
+		if bootstrapKind != "" {
+			mp.Spec.Template.Spec.Bootstrap.ConfigRef = &corev1.ObjectReference{
+				APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+				Kind:       bootstrapKind,
+				Name:       name,
+			}
+		}
+		return mp
+	}
+
+	c := &watchRecordingController{}
+	r := &MachinePoolReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme,
+			newMachinePool("mp1", "BootstrapConfig", "InfrastructureConfig"),
+			newMachinePool("mp2", "BootstrapConfig", "OtherInfrastructureConfig"),
+			// MachinePools with bootstrap data don't reference a bootstrap config.
+			newMachinePool("mp3", "", "InfrastructureConfig"),
+		),
+		Log:        log.Log,
+		controller: c,
+	}
+
+	g.Expect(r.warmUpExternalWatches(context.Background())).To(Succeed())
+	g.Expect(c.watched).To(ConsistOf(
+		schema.GroupVersionKind{Group: "infrastructure.cluster.x-k8s.io", Version: "v1alpha3", Kind: "InfrastructureConfig"},
+		schema.GroupVersionKind{Group: "infrastructure.cluster.x-k8s.io", Version: "v1alpha3", Kind: "OtherInfrastructureConfig"},
+		schema.GroupVersionKind{Group: "bootstrap.cluster.x-k8s.io", Version: "v1alpha3", Kind: "BootstrapConfig"},
+	))
+
+	// Watchers registered during the warm-up aren't added again by the reconciliations.
+	infraConfig := &unstructured.Unstructured{}
+	infraConfig.SetAPIVersion("infrastructure.cluster.x-k8s.io/v1alpha3")
+	infraConfig.SetKind("InfrastructureConfig")
+	g.Expect(r.watchExternal(infraConfig)).To(Succeed())
+	g.Expect(c.watched).To(HaveLen(3))
+}
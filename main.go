@@ -23,9 +23,13 @@ import (
 	"os"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/klog"
 	"k8s.io/klog/klogr"
@@ -63,6 +67,40 @@ var (
 	machineSetConcurrency         int
 	machineDeploymentConcurrency  int
 	machinePoolConcurrency        int
+	machinePoolInfraGracePeriod   time.Duration
+	machinePoolPhaseDisplayNames  map[string]string
+	machinePoolNodeRefsCountOnly  bool
+	machinePoolReconcileTimeout   time.Duration
+	machinePoolBootstrapWait      time.Duration
+	machinePoolInfraReadyWait     time.Duration
+	machinePoolProviderIDKinds    []string
+	machinePoolSpecReplicasKinds  []string
+	machinePoolInfraConditions    []string
+	machinePoolAdoptExternal      bool
+	machinePoolClusterOwnerRefs   bool
+	machinePoolSequentialDrain    bool
+	machinePoolRebalanceZones     bool
+	machinePoolDrainOnTermination bool
+	machinePoolDrainRetiredNodes  bool
+	machinePoolDrainGracePeriod   time.Duration
+	machinePoolDrainPodTimeout    time.Duration
+	machinePoolMissingAsZero      bool
+	machinePoolRequestProvision   bool
+	machinePoolPreProvisionURL    string
+	machinePoolCorrectDrift       bool
+	machinePoolValidateDataSecret bool
+	machinePoolNodeReadBudget     int
+	machinePoolNodeReadinessGate  string
+	machinePoolNodeStatusesLimit  int
+	machinePoolImmutablePaths     []string
+	machinePoolAllowedProviderIDs []string
+	machinePoolCredentialsPath    string
+	machinePoolAnnotationPrefixes []string
+	machinePoolUnhealthyCondition string
+	machinePoolReplaceUnhealthy   bool
+	machinePoolFieldManager       string
+	machinePoolOverProvMargin     int
+	machinePoolTracing            bool
 	clusterResourceSetConcurrency int
 	machineHealthCheckConcurrency int
 	syncPeriod                    time.Duration
@@ -120,6 +158,108 @@ func InitFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&machinePoolConcurrency, "machinepool-concurrency", 10,
 		"Number of machine pools to process simultaneously")
 
+	fs.DurationVar(&machinePoolInfraGracePeriod, "machinepool-infrastructure-missing-grace-period", 1*time.Minute,
+		"The amount of time the infrastructure object of a ready machine pool can be missing before the machine pool is marked as failed (duration string)")
+
+	fs.StringToStringVar(&machinePoolPhaseDisplayNames, "machinepool-phase-display-names", nil,
+		"Names to display machine pool phases with in their status.displayPhase field (e.g. Running=Ready,ScalingUp=Scaling)")
+
+	fs.DurationVar(&machinePoolReconcileTimeout, "machinepool-reconcile-timeout", 5*time.Minute,
+		"The maximum amount of time a single reconciliation of a machine pool can take before it is requeued (duration string)")
+
+	fs.DurationVar(&machinePoolBootstrapWait, "machinepool-bootstrap-ready-wait", 30*time.Second,
+		"The amount of time to wait before checking again on the bootstrap of a machine pool which isn't ready (duration string)")
+
+	fs.DurationVar(&machinePoolInfraReadyWait, "machinepool-infrastructure-ready-wait", 30*time.Second,
+		"The amount of time to wait before checking again on the infrastructure of a machine pool which isn't ready (duration string)")
+
+	fs.StringSliceVar(&machinePoolProviderIDKinds, "machinepool-status-provider-id-list-kinds", nil,
+		"Kinds of machine pool infrastructure objects publishing their provider IDs under status instead of spec (e.g. AWSMachinePool.v1alpha3.exp.infrastructure.cluster.x-k8s.io)")
+
+	fs.StringSliceVar(&machinePoolSpecReplicasKinds, "machinepool-infrastructure-replicas-kinds", nil,
+		"Kinds of machine pool infrastructure objects reading their desired number of instances from spec.replicas, to which the machine pool replicas are written (e.g. AWSMachinePool.v1alpha3.exp.infrastructure.cluster.x-k8s.io)")
+
+	fs.StringSliceVar(&machinePoolInfraConditions, "machinepool-infrastructure-condition-types", []string{string(clusterv1alpha3.ReadyCondition)},
+		"Types of the conditions of machine pool infrastructure objects copied to the status.infrastructureConditions field of the machine pools")
+
+	fs.StringSliceVar(&machinePoolAllowedProviderIDs, "machinepool-allowed-provider-id-prefixes", nil,
+		"Prefixes, e.g. of cloud accounts or regions, the provider IDs reported by the infrastructure of machine pools must start with, any by default")
+
+	fs.StringVar(&machinePoolCredentialsPath, "machinepool-infrastructure-credentials-secret-path", "",
+		"Dotted path of the field of machine pool infrastructure objects referencing their credentials secret (e.g. spec.identityRef), checked to exist")
+
+	fs.StringSliceVar(&machinePoolAnnotationPrefixes, "machinepool-propagated-annotation-prefixes", nil,
+		"Prefixes of the annotations of machine pools (e.g. cost allocation annotations) propagated to their nodes")
+
+	fs.StringVar(&machinePoolUnhealthyCondition, "machinepool-unhealthy-node-condition-type", "",
+		"Type of the provider specific node condition signaling a host failure, machine pool nodes with the condition true are cordoned")
+
+	fs.BoolVar(&machinePoolReplaceUnhealthy, "machinepool-replace-unhealthy-nodes", false,
+		"Request the replacement of the instances of machine pool nodes with the unhealthy node condition from infrastructure providers")
+
+	fs.StringVar(&machinePoolFieldManager, "machinepool-external-patch-field-manager", "",
+		"Field manager under which machine pools label and own their bootstrap and infrastructure objects with server-side apply, instead of merge patches")
+
+	fs.IntVar(&machinePoolOverProvMargin, "machinepool-over-provisioned-margin", 0,
+		"Number of instances infrastructure providers can report beyond the desired replicas of a machine pool before it is flagged as over-provisioned, disabled if not positive")
+
+	fs.BoolVar(&machinePoolTracing, "machinepool-tracing", false,
+		"Trace the reconciliations of machine pools with OpenTelemetry, exporting the spans to the standard output")
+
+	fs.BoolVar(&machinePoolAdoptExternal, "machinepool-adopt-controlled-external-objects", false,
+		"Make machine pools take over the bootstrap and infrastructure objects they reference when these are controlled by another object")
+
+	fs.BoolVar(&machinePoolClusterOwnerRefs, "machinepool-cluster-owner-references", false,
+		"Add an owner reference to their cluster on the bootstrap and infrastructure objects of machine pools, in addition to the machine pool controller reference")
+
+	fs.BoolVar(&machinePoolNodeRefsCountOnly, "machinepool-node-refs-count-only", false,
+		"Only store the number of nodes of machine pools in their status, instead of the list of node references")
+
+	fs.BoolVar(&machinePoolSequentialDrain, "machinepool-sequential-failure-domain-drain", false,
+		"Drain and delete the nodes removed from machine pools one failure domain at a time, the nodes of deleted machine pools being all cordoned up front")
+
+	fs.BoolVar(&machinePoolRebalanceZones, "machinepool-rebalance-failure-domains", false,
+		"Ask the infrastructure providers of machine pools being scaled up to create the new instances in their least populated failure domains")
+
+	fs.BoolVar(&machinePoolDrainOnTermination, "machinepool-drain-nodes-on-termination-signal", false,
+		"Drain the nodes of machine pools annotated with a termination signal before acknowledging it")
+
+	fs.BoolVar(&machinePoolDrainRetiredNodes, "machinepool-drain-retired-nodes", false,
+		"Drain the nodes removed from machine pools before deleting them")
+
+	fs.DurationVar(&machinePoolDrainGracePeriod, "machinepool-node-drain-grace-period", 0,
+		"Grace period given to the pods evicted when draining the nodes of machine pools, 0 to honor the grace period of each pod")
+
+	fs.DurationVar(&machinePoolDrainPodTimeout, "machinepool-node-drain-pod-timeout", 20*time.Second,
+		"Time a single drain attempt of a node of a machine pool waits for the evicted pods to terminate before being retried")
+
+	fs.BoolVar(&machinePoolMissingAsZero, "machinepool-treat-missing-replicas-as-zero", false,
+		"Set the replicas of machine pools scaled to zero to zero when their infrastructure provider doesn't report any, instead of waiting for it")
+
+	fs.BoolVar(&machinePoolRequestProvision, "machinepool-request-infrastructure-provisioning", false,
+		"Request provisioning of the infrastructure objects of machine pools with an annotation, and wait for providers to acknowledge it before marking them ready")
+
+	fs.StringVar(&machinePoolPreProvisionURL, "machinepool-pre-provision-webhook-url", "",
+		"URL of a webhook approving, with a 200 response, the provisioning of the infrastructure of new machine pools")
+
+	fs.BoolVar(&machinePoolCorrectDrift, "machinepool-correct-replicas-drift", false,
+		"Write the replicas of machine pools back to their infrastructure objects when they diverge, instead of only reporting the drift")
+
+	fs.IntVar(&machinePoolNodeReadBudget, "machinepool-node-read-budget", 0,
+		"Maximum number of nodes reconciled by a single reconciliation of a machine pool, larger machine pools being reconciled in chunks, 0 for no limit")
+
+	fs.StringVar(&machinePoolNodeReadinessGate, "machinepool-node-readiness-gate", "",
+		"Key of a label or annotation the nodes of machine pools must have, in addition to being Ready, to be counted as ready replicas")
+
+	fs.IntVar(&machinePoolNodeStatusesLimit, "machinepool-node-statuses-limit", 0,
+		"Maximum number of nodes whose readiness is reported in the status of machine pools, 0 to not report it")
+
+	fs.StringSliceVar(&machinePoolImmutablePaths, "machinepool-immutable-template-paths", nil,
+		"Dotted paths of the template fields of machine pools, e.g. spec.infrastructureRef.name, which can't change once their infrastructure has been ready")
+
+	fs.BoolVar(&machinePoolValidateDataSecret, "machinepool-validate-bootstrap-data-secrets", false,
+		"Only mark the bootstrap of machine pools ready once their bootstrap data secret holds a non-empty value")
+
 	fs.IntVar(&clusterResourceSetConcurrency, "clusterresourceset-concurrency", 10,
 		"Number of cluster resource sets to process simultaneously")
 
@@ -253,9 +393,63 @@ func setupReconcilers(mgr ctrl.Manager) {
 	}
 
 	if feature.Gates.Enabled(feature.MachinePool) {
+		statusProviderIDListKinds := parseKindArgs(machinePoolProviderIDKinds, "invalid machine pool status provider ID list kind")
+		infrastructureReplicasKinds := parseKindArgs(machinePoolSpecReplicasKinds, "invalid machine pool infrastructure replicas kind")
+		infrastructureConditionTypes := make([]clusterv1alpha3.ConditionType, 0, len(machinePoolInfraConditions))
+		for _, conditionType := range machinePoolInfraConditions {
+			infrastructureConditionTypes = append(infrastructureConditionTypes, clusterv1alpha3.ConditionType(conditionType))
+		}
+		phaseDisplayNames := make(map[expv1alpha3.MachinePoolPhase]string, len(machinePoolPhaseDisplayNames))
+		for phase, displayName := range machinePoolPhaseDisplayNames {
+			phaseDisplayNames[expv1alpha3.MachinePoolPhase(phase)] = displayName
+		}
+		var machinePoolTracer expcontrollers.Tracer
+		if machinePoolTracing {
+			exporter, err := stdouttrace.New()
+			if err != nil {
+				setupLog.Error(err, "unable to create trace exporter", "controller", "MachinePool")
+				os.Exit(1)
+			}
+			tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+			machinePoolTracer = expcontrollers.NewOpenTelemetryTracer(tracerProvider.Tracer("machinepool-controller"))
+		}
 		if err := (&expcontrollers.MachinePoolReconciler{
-			Client: mgr.GetClient(),
-			Log:    ctrl.Log.WithName("controllers").WithName("MachinePool"),
+			Client:                           mgr.GetClient(),
+			Log:                              ctrl.Log.WithName("controllers").WithName("MachinePool"),
+			InfrastructureMissingGracePeriod: machinePoolInfraGracePeriod,
+			PhaseDisplayNames:                phaseDisplayNames,
+			NodeRefsCountOnly:                machinePoolNodeRefsCountOnly,
+			ReconcileTimeout:                 machinePoolReconcileTimeout,
+			TreatMissingReplicasAsZero:       machinePoolMissingAsZero,
+			RequestProvisioning:              machinePoolRequestProvision,
+			PreProvisionWebhookURL:           machinePoolPreProvisionURL,
+			BootstrapReadyWait:               machinePoolBootstrapWait,
+			InfrastructureReadyWait:          machinePoolInfraReadyWait,
+			StatusProviderIDListKinds:        statusProviderIDListKinds,
+			InfrastructureReplicasKinds:      infrastructureReplicasKinds,
+			CorrectReplicasDrift:             machinePoolCorrectDrift,
+			InfrastructureConditionTypes:     infrastructureConditionTypes,
+			AdoptControlledExternalObjects:   machinePoolAdoptExternal,
+			ClusterOwnerReferences:           machinePoolClusterOwnerRefs,
+			DrainFailureDomainsSequentially:  machinePoolSequentialDrain,
+			RebalanceFailureDomains:          machinePoolRebalanceZones,
+			DrainNodesOnTerminationSignal:    machinePoolDrainOnTermination,
+			DrainRetiredNodes:                machinePoolDrainRetiredNodes,
+			NodeDrainGracePeriod:             machinePoolDrainGracePeriod,
+			NodeDrainPodTimeout:              machinePoolDrainPodTimeout,
+			ValidateBootstrapDataSecrets:     machinePoolValidateDataSecret,
+			NodeReadBudget:                   machinePoolNodeReadBudget,
+			NodeReadinessGate:                machinePoolNodeReadinessGate,
+			NodeStatusesLimit:                machinePoolNodeStatusesLimit,
+			ImmutableTemplatePaths:           machinePoolImmutablePaths,
+			AllowedProviderIDPrefixes:        machinePoolAllowedProviderIDs,
+			CredentialsSecretPath:            machinePoolCredentialsPath,
+			PropagatedAnnotationPrefixes:     machinePoolAnnotationPrefixes,
+			UnhealthyNodeConditionType:       machinePoolUnhealthyCondition,
+			ReplaceUnhealthyNodes:            machinePoolReplaceUnhealthy,
+			ExternalPatchFieldManager:        machinePoolFieldManager,
+			OverProvisionedMargin:            machinePoolOverProvMargin,
+			Tracer:                           machinePoolTracer,
 		}).SetupWithManager(mgr, concurrency(machinePoolConcurrency)); err != nil {
 			setupLog.Error(err, "unable to create controller", "controller", "MachinePool")
 			os.Exit(1)
@@ -352,7 +546,9 @@ func setupWebhooks(mgr ctrl.Manager) {
 	}
 
 	if feature.Gates.Enabled(feature.MachinePool) {
-		if err := (&expv1alpha3.MachinePool{}).SetupWebhookWithManager(mgr); err != nil {
+		if err := (&expv1alpha3.MachinePoolValidator{
+			ImmutableTemplatePaths: machinePoolImmutablePaths,
+		}).SetupWebhookWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "MachinePool")
 			os.Exit(1)
 		}
@@ -374,3 +570,17 @@ func setupWebhooks(mgr ctrl.Manager) {
 func concurrency(c int) controller.Options {
 	return controller.Options{MaxConcurrentReconciles: c}
 }
+
+// parseKindArgs parses kinds in Kind.version.group format, exiting on invalid ones.
+func parseKindArgs(kinds []string, msg string) []schema.GroupVersionKind {
+	gvks := make([]schema.GroupVersionKind, 0, len(kinds))
+	for _, kind := range kinds {
+		gvk, _ := schema.ParseKindArg(kind)
+		if gvk == nil {
+			setupLog.Error(errors.Errorf("expected Kind.version.group, got %q", kind), msg)
+			os.Exit(1)
+		}
+		gvks = append(gvks, *gvk)
+	}
+	return gvks
+}